@@ -0,0 +1,181 @@
+﻿package config
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// 告警规则配置，每轮采集结束后评估
+const AlertRulesConfigKey = "alertRules"
+
+// 告警规则的类型
+const (
+	// 窗口内失败率（百分比）超过阈值
+	AlertFailureRate = "failureRate"
+	// 最近连续失败次数达到阈值
+	AlertConsecutiveFailures = "consecutiveFailures"
+	// 窗口内没有成功的构建
+	AlertNoSuccess = "noSuccess"
+	// Jenkins 实例健康探测失败，或连续采集失败次数达到阈值（阈值为 0 时只看探测结果），按实例分组
+	AlertInstanceDown = "instanceDown"
+	// 运行中的构建超时或卡住（判定见 runningBuilds 配置，threshold 大于 0 时分别覆盖超时倍数与卡住的小时数），按构建告警
+	AlertBuildOverrun = "buildOverrun"
+	AlertBuildStuck   = "buildStuck"
+	// 窗口内构建时长偏高或每日失败次数偏高的异常（判定见 anomalyDetection 配置，threshold 大于 0 时覆盖分数阈值），按 job 告警
+	AlertDurationAnomaly = "durationAnomaly"
+	AlertFailureAnomaly  = "failureAnomaly"
+)
+
+// 按参数维度（parameterDimensions 中的名称）或原始构建参数名称筛选构建
+type AlertParameterMatch struct {
+	Name   string   `mapstructure:"name"`
+	Values []string `mapstructure:"values"`
+}
+
+// 规则作用的构建范围，为空的条件不做限制。实例、分支支持 path.Match 通配符，job 的匹配规则见 MatchJob
+type AlertSelector struct {
+	Instances  []string              `mapstructure:"instances"`
+	Jobs       []string              `mapstructure:"jobs"`
+	Branches   []string              `mapstructure:"branches"`
+	Parameters []AlertParameterMatch `mapstructure:"parameters"`
+}
+
+type AlertRule struct {
+	Name string `mapstructure:"name"`
+	Type string `mapstructure:"type"`
+	// 统计窗口，consecutiveFailures 不使用；异常类规则为检查异常的时间范围，默认 24h
+	Window time.Duration `mapstructure:"window"`
+	// failureRate 为百分比，consecutiveFailures 为次数
+	Threshold float64 `mapstructure:"threshold"`
+	// failureRate 窗口内至少有这么多构建才评估，避免构建太少时误报
+	MinBuilds int           `mapstructure:"minBuilds"`
+	Selector  AlertSelector `mapstructure:"selector"`
+	// 分组维度，每组单独告警，支持内置维度（instance、job、trigger、user、branch、branchClass、team）以及参数维度名称
+	GroupBy []string `mapstructure:"groupBy"`
+	// noSuccess 规则中即使没有任何构建也要评估的分组，标签需包含 groupBy 中的全部维度，
+	// 如 groupBy 为 [job] 时配置 [{job: payment/api-deploy}]
+	ExpectedGroups []map[string]string `mapstructure:"expectedGroups"`
+	// 条件持续满足这么久才从 pending 变为 firing
+	For      time.Duration     `mapstructure:"for"`
+	Severity string            `mapstructure:"severity"`
+	Labels   map[string]string `mapstructure:"labels"`
+	// 支持 text/template，可以使用 .Value、.Threshold 与 .Labels
+	Annotations map[string]string `mapstructure:"annotations"`
+}
+
+// 读取告警规则，未配置时返回空
+func GetAlertRules() ([]AlertRule, error) {
+	var rules []AlertRule
+	if !viper.IsSet(AlertRulesConfigKey) {
+		return rules, nil
+	}
+	if err := viper.UnmarshalKey(AlertRulesConfigKey, &rules); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	// 参数维度配置只在 groupBy 用到非内置维度时读取
+	var dimensions []ParameterDimension
+	dimensionsLoaded := false
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" || names[rule.Name] {
+			return nil, fmt.Errorf("alert rule %d: missing or duplicate name", i)
+		}
+		names[rule.Name] = true
+
+		for _, name := range rule.GroupBy {
+			if IsBuiltinDimension(name) {
+				continue
+			}
+			if !dimensionsLoaded {
+				var err error
+				if dimensions, err = GetParameterDimensions(); err != nil {
+					return nil, fmt.Errorf("alert rule %s: invalid parameterDimensions config: %w", rule.Name, err)
+				}
+				dimensionsLoaded = true
+			}
+			if !hasParameterDimension(dimensions, name) {
+				return nil, fmt.Errorf("alert rule %s: invalid groupBy dimension %q", rule.Name, name)
+			}
+		}
+
+		switch rule.Type {
+		case AlertFailureRate, AlertNoSuccess:
+			if rule.Window <= 0 {
+				return nil, fmt.Errorf("alert rule %s: invalid window", rule.Name)
+			}
+			for _, labels := range rule.ExpectedGroups {
+				for _, name := range rule.GroupBy {
+					if labels[name] == "" {
+						return nil, fmt.Errorf("alert rule %s: expected group missing label %q", rule.Name, name)
+					}
+				}
+			}
+		case AlertInstanceDown, AlertBuildOverrun, AlertBuildStuck:
+		case AlertDurationAnomaly, AlertFailureAnomaly:
+			if rule.Window <= 0 {
+				rule.Window = 24 * time.Hour
+			}
+		case AlertConsecutiveFailures:
+			if rule.Threshold <= 0 {
+				return nil, fmt.Errorf("alert rule %s: invalid threshold", rule.Name)
+			}
+		default:
+			return nil, fmt.Errorf("alert rule %s: invalid type %q", rule.Name, rule.Type)
+		}
+		if rule.MinBuilds <= 0 {
+			rule.MinBuilds = 1
+		}
+		if rule.Severity == "" {
+			rule.Severity = "warning"
+		}
+	}
+	return rules, nil
+}
+
+func hasParameterDimension(dimensions []ParameterDimension, name string) bool {
+	for i := range dimensions {
+		if dimensions[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断构建的实例、job 与分支是否在规则范围内，参数条件由调用方判断
+func (s *AlertSelector) MatchBuild(jenkinsInstanceName, jobName, branch string) bool {
+	return matchAny(s.Instances, jenkinsInstanceName) && s.MatchJob(jobName) && matchAny(s.Branches, branch)
+}
+
+func (s *AlertSelector) MatchJob(jobName string) bool {
+	if len(s.Jobs) == 0 {
+		return true
+	}
+	for _, pattern := range s.Jobs {
+		if MatchJob(pattern, jobName) {
+			return true
+		}
+	}
+	return false
+}
+
+// 只判断实例是否在规则范围内，用于按实例评估的规则
+func (s *AlertSelector) MatchInstance(jenkinsInstanceName string) bool {
+	return matchAny(s.Instances, jenkinsInstanceName)
+}
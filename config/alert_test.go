@@ -0,0 +1,32 @@
+﻿package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestGetAlertRulesGroupBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		groupBy []string
+		wantErr bool
+	}{
+		{"builtin dimensions", []string{"instance", "job", "trigger", "user", "branch", "branchClass", "team"}, false},
+		{"parameter dimension", []string{"job", "env"}, false},
+		{"unknown dimension", []string{"job", "triger"}, true},
+		{"build parameter without dimension", []string{"ENV"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.Set(ParameterDimensionsConfigKey, []map[string]interface{}{{"name": "env", "parameters": []string{"ENV"}}})
+			viper.Set(AlertRulesConfigKey, []map[string]interface{}{{"name": "rule", "type": AlertConsecutiveFailures, "threshold": 3, "groupBy": tt.groupBy}})
+
+			if _, err := GetAlertRules(); (err != nil) != tt.wantErr {
+				t.Fatalf("GetAlertRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
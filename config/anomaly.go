@@ -0,0 +1,35 @@
+﻿package config
+
+import (
+	"github.com/spf13/viper"
+)
+
+// 构建时长与每日失败次数的异常检测配置
+const AnomalyDetectionConfigKey = "anomalyDetection"
+
+type AnomalyDetection struct {
+	// 基线使用的历史天数
+	HistoryDays int `mapstructure:"historyDays"`
+	// 稳健 z 分数（与中位数的偏差除以 1.4826 倍 MAD）超过该值时视为异常
+	Threshold float64 `mapstructure:"threshold"`
+	// 同一季节分组（星期+小时、小时、星期）的样本少于该值时退回到更粗的分组
+	MinSamples int `mapstructure:"minSamples"`
+	// 构建时长与中位数的相对偏差至少达到该比例才视为异常，避免时长很稳定的 job 因几秒的波动被标记
+	MinDurationChange float64 `mapstructure:"minDurationChange"`
+}
+
+func GetAnomalyDetection() (*AnomalyDetection, error) {
+	anomaly := AnomalyDetection{
+		HistoryDays:       56,
+		Threshold:         3.5,
+		MinSamples:        5,
+		MinDurationChange: 0.25,
+	}
+	if !viper.IsSet(AnomalyDetectionConfigKey) {
+		return &anomaly, nil
+	}
+	if err := viper.UnmarshalKey(AnomalyDetectionConfigKey, &anomaly); err != nil {
+		return nil, err
+	}
+	return &anomaly, nil
+}
@@ -0,0 +1,55 @@
+﻿package config
+
+import (
+	"path"
+
+	"github.com/spf13/viper"
+)
+
+// 分支分类配置，按顺序匹配，第一个匹配的分类生效
+const BranchClassesConfigKey = "branchClasses"
+
+type BranchClass struct {
+	Name string `mapstructure:"name"`
+	// 分支名称，支持 path.Match 通配符，如 release/*
+	Patterns []string `mapstructure:"patterns"`
+}
+
+// 未匹配到任何分类的分支，以及无法确定分支的构建使用的分类名
+const (
+	OtherBranchClass   = "other"
+	UnknownBranchClass = "unknown"
+)
+
+// 未配置时使用的默认分类
+var defaultBranchClasses = []BranchClass{
+	{Name: "release", Patterns: []string{"release/*", "release-*", "hotfix/*"}},
+	{Name: "main", Patterns: []string{"main", "master"}},
+	{Name: "develop", Patterns: []string{"develop", "dev"}},
+	{Name: "feature", Patterns: []string{"feature/*", "feat/*", "bugfix/*"}},
+}
+
+func GetBranchClasses() ([]BranchClass, error) {
+	if !viper.IsSet(BranchClassesConfigKey) {
+		return defaultBranchClasses, nil
+	}
+	var classes []BranchClass
+	if err := viper.UnmarshalKey(BranchClassesConfigKey, &classes); err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
+
+func ClassOfBranch(classes []BranchClass, branch string) string {
+	if branch == "" {
+		return UnknownBranchClass
+	}
+	for _, class := range classes {
+		for _, pattern := range class.Patterns {
+			if ok, _ := path.Match(pattern, branch); ok {
+				return class.Name
+			}
+		}
+	}
+	return OtherBranchClass
+}
@@ -0,0 +1,39 @@
+﻿package config
+
+import (
+	"github.com/spf13/viper"
+)
+
+// 演示数据配置，开启后使用进程内的模拟 Jenkins 代替配置中的 Jenkins 实例
+const DemoConfigKey = "demo"
+
+type Demo struct {
+	Enabled bool `mapstructure:"enabled"`
+	// 随机数种子，相同的种子生成相同的数据
+	Seed int64 `mapstructure:"seed"`
+	// 模拟的 Jenkins 实例数、每个实例的文件夹数与每个文件夹中的 job 数
+	Instances     int `mapstructure:"instances"`
+	Folders       int `mapstructure:"folders"`
+	JobsPerFolder int `mapstructure:"jobsPerFolder"`
+	// 生成最近多少天的构建历史，以及每个 job 平均每个工作日的构建次数
+	Days         int     `mapstructure:"days"`
+	BuildsPerDay float64 `mapstructure:"buildsPerDay"`
+}
+
+func GetDemo() (*Demo, error) {
+	demo := Demo{
+		Seed:          1,
+		Instances:     2,
+		Folders:       3,
+		JobsPerFolder: 4,
+		Days:          30,
+		BuildsPerDay:  4,
+	}
+	if !viper.IsSet(DemoConfigKey) {
+		return &demo, nil
+	}
+	if err := viper.UnmarshalKey(DemoConfigKey, &demo); err != nil {
+		return nil, err
+	}
+	return &demo, nil
+}
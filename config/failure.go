@@ -0,0 +1,65 @@
+﻿package config
+
+import (
+	"github.com/spf13/viper"
+)
+
+// 构建失败原因分类配置
+const FailureClassificationConfigKey = "failureClassification"
+
+type FailureRule struct {
+	Category string `mapstructure:"category"`
+	// 正则表达式，匹配控制台日志中的任意一处即归入该类别
+	Patterns []string `mapstructure:"patterns"`
+}
+
+type FailureClassification struct {
+	// 只读取控制台日志末尾的字节数
+	TailBytes int `mapstructure:"tailBytes"`
+	// 按顺序匹配，第一个匹配的规则生效。配置的规则排在默认规则之前，
+	// 只有 disableDefaultRules 为 true 时才不使用默认规则
+	Rules               []FailureRule `mapstructure:"rules"`
+	DisableDefaultRules bool          `mapstructure:"disableDefaultRules"`
+}
+
+// 没有匹配任何规则的失败构建归入该类别，留待人工分拣
+const UnclassifiedFailure = "unclassified"
+
+// 默认规则
+var defaultFailureRules = []FailureRule{
+	{Category: "manual_abort", Patterns: []string{`Aborted by \S+`, `(?i)build was aborted`}},
+	{Category: "out_of_memory", Patterns: []string{`OutOfMemoryError`, `(?i)cannot allocate memory`, `JavaScript heap out of memory`, `exit code 137`}},
+	{Category: "agent_disconnect", Patterns: []string{`ChannelClosedException`, `RequestAbortedException`, `(?i)agent went offline`, `(?i)connection was broken`, `RemotingSystemException`}},
+	{Category: "docker_registry_error", Patterns: []string{`denied: requested access`, `(?i)error pulling image`, `manifest unknown`, `toomanyrequests`, `unauthorized: authentication required`}},
+	{Category: "dependency_download_timeout", Patterns: []string{`Could not (resolve|transfer) (dependencies|artifact)`, `(?i)read timed out`, `(?i)connect(ion)? timed out`, `ETIMEDOUT`, `npm ERR! network`}},
+	{Category: "compile_error", Patterns: []string{`(?i)compilation (error|failure)`, `error: cannot find symbol`, `error TS\d+`, `undefined reference to`, `SyntaxError`}},
+	{Category: "unit_test_failure", Patterns: []string{`Tests run: \d+, Failures: [1-9]`, `There (are|were) test failures`, `--- FAIL:`, `\d+ failing`}},
+}
+
+// 默认只读取控制台日志末尾的字节数
+const defaultFailureTailBytes = 256 * 1024
+
+// 只使用默认规则的分类配置，配置格式错误时使用
+func DefaultFailureClassification() *FailureClassification {
+	return &FailureClassification{
+		TailBytes: defaultFailureTailBytes,
+		Rules:     append([]FailureRule(nil), defaultFailureRules...),
+	}
+}
+
+func GetFailureClassification() (*FailureClassification, error) {
+	classification := FailureClassification{
+		TailBytes: defaultFailureTailBytes,
+	}
+	if viper.IsSet(FailureClassificationConfigKey) {
+		if err := viper.UnmarshalKey(FailureClassificationConfigKey, &classification); err != nil {
+			return nil, err
+		}
+	}
+	if !classification.DisableDefaultRules {
+		rules := make([]FailureRule, 0, len(classification.Rules)+len(defaultFailureRules))
+		rules = append(rules, classification.Rules...)
+		classification.Rules = append(rules, defaultFailureRules...)
+	}
+	return &classification, nil
+}
@@ -0,0 +1,32 @@
+﻿package config
+
+import (
+	"github.com/spf13/viper"
+)
+
+// GitLab 实例配置统一放在顶级配置项 gitlab 下，按实例名区分
+const GitlabConfigKey = "gitlab"
+
+type GitlabInstance struct {
+	GitlabURL string `mapstructure:"gitlabURL"`
+	Token     string `mapstructure:"token"`
+	// personal 或 project，缺省为 personal
+	TokenType string `mapstructure:"tokenType"`
+	// 需要采集的 group（包含子 group）与项目，均为空时采集 token 所属成员的全部项目
+	Groups   []string `mapstructure:"groups"`
+	Projects []string `mapstructure:"projects"`
+	// webhook 校验密钥，需与 GitLab 中配置的 Secret token 一致
+	WebhookSecret string `mapstructure:"webhookSecret"`
+}
+
+// 读取 GitLab 实例配置，未配置时返回空 map
+func GetGitlabInstances() (map[string]GitlabInstance, error) {
+	instances := make(map[string]GitlabInstance)
+	if !viper.IsSet(GitlabConfigKey) {
+		return instances, nil
+	}
+	if err := viper.UnmarshalKey(GitlabConfigKey, &instances); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
@@ -0,0 +1,50 @@
+﻿package config
+
+import (
+	"github.com/spf13/viper"
+)
+
+// 提交到生产的交付周期统计配置
+const LeadTimeConfigKey = "leadTime"
+
+type JobProject struct {
+	Job     string `mapstructure:"job"`
+	Gitlab  string `mapstructure:"gitlab"`
+	Project string `mapstructure:"project"`
+}
+
+type LeadTime struct {
+	// 生产部署 job 名称，匹配规则见 MatchJob
+	DeployJobs []string `mapstructure:"deployJobs"`
+	// 显式指定 job 对应的 GitLab 实例与项目，未指定时根据构建检出的 git 仓库地址推断
+	JobProjects []JobProject `mapstructure:"jobProjects"`
+}
+
+func GetLeadTime() (*LeadTime, error) {
+	var leadTime LeadTime
+	if !viper.IsSet(LeadTimeConfigKey) {
+		return &leadTime, nil
+	}
+	if err := viper.UnmarshalKey(LeadTimeConfigKey, &leadTime); err != nil {
+		return nil, err
+	}
+	return &leadTime, nil
+}
+
+func (l *LeadTime) IsDeployJob(jobName string) bool {
+	for _, pattern := range l.DeployJobs {
+		if MatchJob(pattern, jobName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *LeadTime) ProjectOf(jobName string) *JobProject {
+	for i := range l.JobProjects {
+		if l.JobProjects[i].Job == jobName {
+			return &l.JobProjects[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+﻿package config
+
+import (
+	"path"
+	"strings"
+)
+
+// 匹配包含文件夹路径的 job 名称，如 payment/api-deploy。
+// 不含 / 的模式匹配 job 名称的最后一段，因此 *deploy* 可以匹配任意文件夹下的部署 job；
+// 含 / 的模式按段匹配完整名称，其中 ** 可以匹配任意多层文件夹，如 payment/**/*-deploy
+func MatchJob(pattern, jobName string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(jobName))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(jobName, "/"))
+}
+
+func matchSegments(patterns, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(patterns[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(patterns[0], names[0]); !ok {
+			return false
+		}
+		patterns, names = patterns[1:], names[1:]
+	}
+	return len(names) == 0
+}
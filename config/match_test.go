@@ -0,0 +1,50 @@
+﻿package config
+
+import "testing"
+
+func TestMatchJob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		jobName string
+		want    bool
+	}{
+		{"*deploy*", "api-deploy", true},
+		{"*deploy*", "payment/api-deploy", true},
+		{"*-prod-deploy", "team/x-prod-deploy", true},
+		{"*-prod-deploy", "team/x-prod-deploy/main", false},
+		{"api-*", "payment/api-build", true},
+		{"payment/*", "payment/api-build", true},
+		{"payment/*", "payment/sub/api-build", false},
+		{"payment/**", "payment/sub/api-build", true},
+		{"**/*-deploy", "a/b/c-deploy", true},
+		{"**/*-deploy", "c-deploy", true},
+		{"payment/**/*-deploy", "payment/api-deploy", true},
+		{"payment/**/*-deploy", "order/api-deploy", false},
+		{"*deploy*", "payment/api-build", false},
+	}
+	for _, tt := range tests {
+		if got := MatchJob(tt.pattern, tt.jobName); got != tt.want {
+			t.Errorf("MatchJob(%q, %q) = %v, want %v", tt.pattern, tt.jobName, got, tt.want)
+		}
+	}
+}
+
+func TestTeamAndDeployJobsMatchFolders(t *testing.T) {
+	teams := map[string]Team{"payment": {Jobs: []string{"payment/**"}}, "ops": {Jobs: []string{"*deploy*"}}}
+	if team := TeamOfJob(teams, "payment/api/build"); team != "payment" {
+		t.Errorf("TeamOfJob = %q, want payment", team)
+	}
+	if team := TeamOfJob(teams, "order/api-deploy"); team != "ops" {
+		t.Errorf("TeamOfJob = %q, want ops", team)
+	}
+
+	leadTime := LeadTime{DeployJobs: []string{"*-prod-deploy"}}
+	if !leadTime.IsDeployJob("payment/api-prod-deploy") {
+		t.Errorf("foldered deploy job should match")
+	}
+
+	selector := AlertSelector{Jobs: []string{"*deploy*"}, Branches: []string{"main"}}
+	if !selector.MatchBuild("jenkins", "payment/api-deploy", "main") || selector.MatchBuild("jenkins", "payment/api-build", "main") {
+		t.Errorf("unexpected selector match")
+	}
+}
@@ -0,0 +1,178 @@
+﻿package config
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// 通知渠道与路由配置，告警与报表按标签路由到不同的渠道
+const NotifyConfigKey = "notify"
+
+// 通知渠道的类型
+const (
+	ChannelDingTalk = "dingtalk"
+	ChannelWeCom    = "wecom"
+	ChannelFeishu   = "feishu"
+	ChannelSlack    = "slack"
+	ChannelEmail    = "email"
+	ChannelWebhook  = "webhook"
+)
+
+// 通知消息的类型，用于路由
+const (
+	MessageAlert  = "alert"
+	MessageReport = "report"
+)
+
+type SMTP struct {
+	Host     string   `mapstructure:"host"`
+	Port     int      `mapstructure:"port"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+}
+
+type NotifyChannel struct {
+	Name string `mapstructure:"name"`
+	Type string `mapstructure:"type"`
+	// 机器人或 webhook 的地址，邮件渠道不使用
+	URL string `mapstructure:"url"`
+	// 钉钉、飞书机器人的加签密钥；通用 webhook 用它计算请求体的 HMAC-SHA256 签名
+	Secret string `mapstructure:"secret"`
+	// 消息正文模板（text/template），为空时使用渠道类型的默认模板
+	Template string `mapstructure:"template"`
+	SMTP     SMTP   `mapstructure:"smtp"`
+}
+
+// 消息的标签（以及 kind 类型）都匹配时发送到 Channels，标签值支持 path.Match 通配符。
+// 所有匹配的路由都生效，同一渠道只发送一次
+type NotifyRoute struct {
+	Kinds    []string          `mapstructure:"kinds"`
+	Match    map[string]string `mapstructure:"match"`
+	Channels []string          `mapstructure:"channels"`
+}
+
+// 源告警 firing 时，抑制与它 Equal 标签取值相同的目标告警，如实例不可用时抑制该实例上的 job 告警。
+// 匹配条件的标签值支持 path.Match 通配符
+type InhibitRule struct {
+	SourceMatch map[string]string `mapstructure:"sourceMatch"`
+	TargetMatch map[string]string `mapstructure:"targetMatch"`
+	Equal       []string          `mapstructure:"equal"`
+}
+
+type Notify struct {
+	Channels []NotifyChannel `mapstructure:"channels"`
+	Routes   []NotifyRoute   `mapstructure:"routes"`
+	// 告警按 GroupBy 标签分组后合并为一条通知：新分组等待 GroupWait 收集同组告警后发送，
+	// 分组内告警变化后至少间隔 GroupInterval 再发送，没有变化时每隔 RepeatInterval 重复提醒
+	GroupBy        []string      `mapstructure:"groupBy"`
+	GroupWait      time.Duration `mapstructure:"groupWait"`
+	GroupInterval  time.Duration `mapstructure:"groupInterval"`
+	RepeatInterval time.Duration `mapstructure:"repeatInterval"`
+	InhibitRules   []InhibitRule `mapstructure:"inhibitRules"`
+	// 每个渠道的最多发送次数，以及第一次重试前的等待时间（之后每次翻倍）
+	Attempts int           `mapstructure:"attempts"`
+	Backoff  time.Duration `mapstructure:"backoff"`
+	// 重试后仍发送失败的消息追加写入这里（JSON Lines），为空时只记录日志
+	DeadLetterPath string `mapstructure:"deadLetterPath"`
+	// 每天在该时刻（HH:MM，按 workingHours.timezone）发送前一天的构建日报，为空时不发送
+	DailyReport string `mapstructure:"dailyReport"`
+}
+
+func GetNotify() (*Notify, error) {
+	notify := Notify{
+		GroupBy:        []string{"alertname"},
+		GroupWait:      30 * time.Second,
+		GroupInterval:  5 * time.Minute,
+		RepeatInterval: 4 * time.Hour,
+		Attempts:       3,
+		Backoff:        time.Second,
+		DeadLetterPath: "./data/notify-dead-letter.jsonl",
+	}
+	if !viper.IsSet(NotifyConfigKey) {
+		return &notify, nil
+	}
+	if err := viper.UnmarshalKey(NotifyConfigKey, &notify); err != nil {
+		return nil, err
+	}
+	if notify.Attempts <= 0 {
+		notify.Attempts = 1
+	}
+	if notify.DailyReport != "" {
+		if _, err := time.Parse(clockLayout, notify.DailyReport); err != nil {
+			return nil, fmt.Errorf("invalid notify dailyReport %q", notify.DailyReport)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, channel := range notify.Channels {
+		if channel.Name == "" || names[channel.Name] {
+			return nil, fmt.Errorf("notify channel %d: missing or duplicate name", i)
+		}
+		names[channel.Name] = true
+
+		switch channel.Type {
+		case ChannelDingTalk, ChannelWeCom, ChannelFeishu, ChannelSlack, ChannelWebhook:
+			if channel.URL == "" {
+				return nil, fmt.Errorf("notify channel %s: missing url", channel.Name)
+			}
+		case ChannelEmail:
+			if channel.SMTP.Host == "" || channel.SMTP.From == "" || len(channel.SMTP.To) == 0 {
+				return nil, fmt.Errorf("notify channel %s: missing smtp host, from or to", channel.Name)
+			}
+		default:
+			return nil, fmt.Errorf("notify channel %s: invalid type %q", channel.Name, channel.Type)
+		}
+	}
+	for i, route := range notify.Routes {
+		for _, name := range route.Channels {
+			if !names[name] {
+				return nil, fmt.Errorf("notify route %d: unknown channel %q", i, name)
+			}
+		}
+	}
+	return &notify, nil
+}
+
+// 判断消息是否匹配路由
+func (r *NotifyRoute) MatchMessage(kind string, labels map[string]string) bool {
+	if len(r.Kinds) > 0 {
+		matched := false
+		for _, k := range r.Kinds {
+			if k == kind {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return matchLabels(r.Match, labels)
+}
+
+func matchLabels(match map[string]string, labels map[string]string) bool {
+	for name, pattern := range match {
+		if ok, _ := path.Match(pattern, labels[name]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// 判断 source 告警是否抑制 target 告警
+func (r *InhibitRule) Inhibits(source, target map[string]string) bool {
+	if !matchLabels(r.SourceMatch, source) || !matchLabels(r.TargetMatch, target) {
+		return false
+	}
+	for _, name := range r.Equal {
+		if source[name] != target[name] {
+			return false
+		}
+	}
+	return true
+}
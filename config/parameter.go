@@ -0,0 +1,110 @@
+﻿package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// 作为统计维度的构建参数配置
+const ParameterDimensionsConfigKey = "parameterDimensions"
+
+type ParameterMapping struct {
+	// 原始取值，忽略大小写比较
+	Values []string `mapstructure:"values"`
+	To     string   `mapstructure:"to"`
+}
+
+type ParameterDimension struct {
+	// 维度名称，即 groupBy 中使用的名称，如 env
+	Name string `mapstructure:"name"`
+	// 对应的构建参数名称，不同 job 使用不同参数名时可以配置多个，取第一个存在的参数
+	Parameters []string `mapstructure:"parameters"`
+	// 是否将取值转为小写
+	Lowercase bool `mapstructure:"lowercase"`
+	// 取值归一，如 production、prd 都归一为 prod
+	Mappings []ParameterMapping `mapstructure:"mappings"`
+	// 构建没有该参数时使用的取值
+	Default string `mapstructure:"default"`
+}
+
+// Jenkins 构建的内置统计维度，/metrics 等接口与告警规则的 groupBy 共用
+const (
+	DimensionInstance = "instance"
+	DimensionJob      = "job"
+	DimensionTrigger  = "trigger"
+	DimensionUser     = "user"
+	// 原始分支名称，以及按 branchClasses 配置归类后的分支类别（如 release、feature）
+	DimensionBranch      = "branch"
+	DimensionBranchClass = "branchClass"
+	// 按 teams 配置中的 jobs 归属匹配的团队
+	DimensionTeam = "team"
+)
+
+// 内置的统计维度名称，参数维度不能与之重名，否则会被内置维度遮蔽
+var builtinDimensions = []string{DimensionInstance, DimensionJob, DimensionTrigger, DimensionUser, DimensionBranch, DimensionBranchClass, DimensionTeam}
+
+func IsBuiltinDimension(name string) bool {
+	return contains(builtinDimensions, name)
+}
+
+// 读取参数维度配置，未配置时返回空
+func GetParameterDimensions() ([]ParameterDimension, error) {
+	var dimensions []ParameterDimension
+	if !viper.IsSet(ParameterDimensionsConfigKey) {
+		return dimensions, nil
+	}
+	if err := viper.UnmarshalKey(ParameterDimensionsConfigKey, &dimensions); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(dimensions))
+	for _, dimension := range dimensions {
+		switch {
+		case dimension.Name == "":
+			return nil, fmt.Errorf("parameter dimension without name")
+		case IsBuiltinDimension(dimension.Name):
+			return nil, fmt.Errorf("parameter dimension %q conflicts with builtin dimension", dimension.Name)
+		case names[dimension.Name]:
+			return nil, fmt.Errorf("duplicate parameter dimension %q", dimension.Name)
+		}
+		names[dimension.Name] = true
+	}
+	return dimensions, nil
+}
+
+// 根据构建参数计算维度取值，参数名先精确匹配，再忽略大小写匹配
+func (p *ParameterDimension) ValueOf(parameters map[string]string) string {
+	value, ok := "", false
+	for _, name := range p.Parameters {
+		if value, ok = parameters[name]; ok {
+			break
+		}
+		for key, v := range parameters {
+			if strings.EqualFold(key, name) {
+				value, ok = v, true
+				break
+			}
+		}
+		if ok {
+			break
+		}
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return p.Default
+	}
+	if p.Lowercase {
+		value = strings.ToLower(value)
+	}
+	for _, mapping := range p.Mappings {
+		for _, from := range mapping.Values {
+			if strings.EqualFold(from, value) {
+				return mapping.To
+			}
+		}
+	}
+	return value
+}
@@ -0,0 +1,81 @@
+﻿package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestParameterDimensionValueOf(t *testing.T) {
+	env := ParameterDimension{
+		Name:       "env",
+		Parameters: []string{"DEPLOY_ENV", "ENV"},
+		Lowercase:  true,
+		Mappings: []ParameterMapping{
+			{Values: []string{"production", "PRD"}, To: "prod"},
+			{Values: []string{"staging"}, To: "stage"},
+		},
+		Default: "none",
+	}
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		want       string
+	}{
+		{"exact name", map[string]string{"DEPLOY_ENV": "dev"}, "dev"},
+		{"case-insensitive name", map[string]string{"deploy_env": "dev"}, "dev"},
+		{"first parameter wins", map[string]string{"ENV": "test", "DEPLOY_ENV": "dev"}, "dev"},
+		{"fallback parameter", map[string]string{"env": "test"}, "test"},
+		{"lowercase", map[string]string{"ENV": "UAT"}, "uat"},
+		{"mapping", map[string]string{"ENV": "Production"}, "prod"},
+		{"mapping ignores case", map[string]string{"ENV": "prd"}, "prod"},
+		{"trimmed", map[string]string{"ENV": "  staging "}, "stage"},
+		{"blank uses default", map[string]string{"ENV": "  "}, "none"},
+		{"missing uses default", map[string]string{"OTHER": "prod"}, "none"},
+		{"nil parameters", nil, "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := env.ValueOf(tt.parameters); got != tt.want {
+				t.Errorf("ValueOf(%v) = %q, want %q", tt.parameters, got, tt.want)
+			}
+		})
+	}
+
+	// 不转小写时保留原始取值，映射仍然忽略大小写
+	region := ParameterDimension{Parameters: []string{"REGION"}, Mappings: []ParameterMapping{{Values: []string{"cn-north"}, To: "north"}}}
+	if got := region.ValueOf(map[string]string{"REGION": "CN-South"}); got != "CN-South" {
+		t.Errorf("ValueOf without lowercase = %q", got)
+	}
+	if got := region.ValueOf(map[string]string{"REGION": "CN-NORTH"}); got != "north" {
+		t.Errorf("ValueOf mapping without lowercase = %q", got)
+	}
+}
+
+func TestGetParameterDimensions(t *testing.T) {
+	tests := []struct {
+		name       string
+		dimensions []map[string]interface{}
+		wantErr    bool
+	}{
+		{"valid", []map[string]interface{}{{"name": "env", "parameters": []string{"ENV"}}, {"name": "region"}}, false},
+		{"missing name", []map[string]interface{}{{"parameters": []string{"ENV"}}}, true},
+		{"builtin name", []map[string]interface{}{{"name": "team", "parameters": []string{"TEAM"}}}, true},
+		{"duplicate name", []map[string]interface{}{{"name": "env"}, {"name": "env"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.Set(ParameterDimensionsConfigKey, tt.dimensions)
+
+			dimensions, err := GetParameterDimensions()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetParameterDimensions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(dimensions) != len(tt.dimensions) {
+				t.Errorf("GetParameterDimensions() = %+v", dimensions)
+			}
+		})
+	}
+}
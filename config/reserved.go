@@ -0,0 +1,32 @@
+﻿package config
+
+import "strings"
+
+// 除以下保留的顶级配置项外，其余顶级配置项均视为 Jenkins 实例
+var reservedKeys = []string{
+	GitlabConfigKey,
+	TeamsConfigKey,
+	LeadTimeConfigKey,
+	StorageConfigKey,
+	CollectorConfigKey,
+	BranchClassesConfigKey,
+	FailureClassificationConfigKey,
+	ParameterDimensionsConfigKey,
+	DemoConfigKey,
+	AlertRulesConfigKey,
+	NotifyConfigKey,
+	SilencesConfigKey,
+	RunningBuildsConfigKey,
+	AnomalyDetectionConfigKey,
+	WorkingHoursConfigKey,
+}
+
+// viper 读取配置时会将 key 转为小写，这里忽略大小写比较
+func IsReservedKey(key string) bool {
+	for _, reservedKey := range reservedKeys {
+		if strings.EqualFold(reservedKey, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+﻿package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// 运行中构建的超时判定配置
+const RunningBuildsConfigKey = "runningBuilds"
+
+// 运行中构建的状态
+const (
+	RunningNormal  = "normal"
+	RunningOverrun = "overrun"
+	RunningStuck   = "stuck"
+	RunningStale   = "stale"
+)
+
+type RunningBuilds struct {
+	// 运行时长超过预计时长（Jenkins 根据最近成功构建估算）的倍数时视为超时，没有预计时长的构建不判断
+	OverrunFactor float64 `mapstructure:"overrunFactor"`
+	// 运行时长超过该值时视为卡住，不论预计时长
+	StuckAfter time.Duration `mapstructure:"stuckAfter"`
+	// 超过 StaleIntervals 个 Jenkins 轮询间隔没有被采集或推送更新的构建视为失联（如构建或 job 已被删除），
+	// 不再判断超时与卡住
+	StaleIntervals int `mapstructure:"staleIntervals"`
+
+	staleAfter time.Duration
+}
+
+func GetRunningBuilds() (*RunningBuilds, error) {
+	running := RunningBuilds{
+		OverrunFactor:  2,
+		StuckAfter:     6 * time.Hour,
+		StaleIntervals: 3,
+	}
+	if viper.IsSet(RunningBuildsConfigKey) {
+		if err := viper.UnmarshalKey(RunningBuildsConfigKey, &running); err != nil {
+			return nil, err
+		}
+	}
+
+	collector, err := GetCollector()
+	if err != nil {
+		return nil, err
+	}
+	running.staleAfter = time.Duration(running.StaleIntervals) * collector.JenkinsInterval
+	return &running, nil
+}
+
+// 构建最近一次更新的时间距离 now 超过 StaleIntervals 个轮询间隔，StaleIntervals 为 0 时不判断
+func (r *RunningBuilds) IsStale(updatedAt, now time.Time) bool {
+	return r.staleAfter > 0 && now.Sub(updatedAt) > r.staleAfter
+}
+
+// 根据已运行时长与预计时长判断构建状态，卡住优先于超时
+func (r *RunningBuilds) StatusOf(elapsed, estimated time.Duration) string {
+	switch {
+	case r.StuckAfter > 0 && elapsed > r.StuckAfter:
+		return RunningStuck
+	case r.OverrunFactor > 0 && estimated > 0 && elapsed > time.Duration(float64(estimated)*r.OverrunFactor):
+		return RunningOverrun
+	default:
+		return RunningNormal
+	}
+}
@@ -0,0 +1,22 @@
+﻿package config
+
+import "github.com/spf13/viper"
+
+// 静默接口配置
+const SilencesConfigKey = "silences"
+
+type Silences struct {
+	// 创建、提前结束静默时需要在 Authorization 请求头中携带的 Bearer Token，未配置时拒绝这些请求
+	Token string `mapstructure:"token"`
+}
+
+func GetSilences() (*Silences, error) {
+	var silences Silences
+	if !viper.IsSet(SilencesConfigKey) {
+		return &silences, nil
+	}
+	if err := viper.UnmarshalKey(SilencesConfigKey, &silences); err != nil {
+		return nil, err
+	}
+	return &silences, nil
+}
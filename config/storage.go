@@ -0,0 +1,63 @@
+﻿package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// 数据快照与后台采集配置
+const (
+	StorageConfigKey   = "storage"
+	CollectorConfigKey = "collector"
+)
+
+type Storage struct {
+	// 数据快照文件路径，为空时只保存在内存中
+	Path string `mapstructure:"path"`
+	// 数据保留天数
+	RetentionDays int `mapstructure:"retentionDays"`
+}
+
+type Collector struct {
+	// GitLab 流水线对账采集间隔，webhook 漏发的事件由对账补齐
+	GitlabInterval time.Duration `mapstructure:"gitlabInterval"`
+	// Jenkins 构建轮询间隔，Notification 插件漏发的事件由轮询补齐
+	JenkinsInterval time.Duration `mapstructure:"jenkinsInterval"`
+	// Jenkins 实例健康探测间隔
+	ProbeInterval time.Duration `mapstructure:"probeInterval"`
+	// 访问 Jenkins、GitLab 的方式：为空时直接访问；record 时同时把响应（凭据已脱敏）保存到 FixtureDir；
+	// replay 时不访问网络，全部响应从 FixtureDir 中读取，用于离线开发与复现问题
+	HTTPMode   string `mapstructure:"httpMode"`
+	FixtureDir string `mapstructure:"fixtureDir"`
+}
+
+func GetStorage() (*Storage, error) {
+	storage := Storage{
+		Path:          "./data/store.json",
+		RetentionDays: 90,
+	}
+	if !viper.IsSet(StorageConfigKey) {
+		return &storage, nil
+	}
+	if err := viper.UnmarshalKey(StorageConfigKey, &storage); err != nil {
+		return nil, err
+	}
+	return &storage, nil
+}
+
+func GetCollector() (*Collector, error) {
+	collector := Collector{
+		GitlabInterval:  30 * time.Minute,
+		JenkinsInterval: 5 * time.Minute,
+		ProbeInterval:   time.Minute,
+		FixtureDir:      "./data/fixtures",
+	}
+	if !viper.IsSet(CollectorConfigKey) {
+		return &collector, nil
+	}
+	if err := viper.UnmarshalKey(CollectorConfigKey, &collector); err != nil {
+		return nil, err
+	}
+	return &collector, nil
+}
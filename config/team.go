@@ -0,0 +1,80 @@
+﻿package config
+
+import (
+	"path"
+	"sort"
+
+	"github.com/spf13/viper"
+)
+
+// 团队配置，按团队名区分
+const TeamsConfigKey = "teams"
+
+type Team struct {
+	// 团队成员的 GitLab 用户名
+	Members []string `mapstructure:"members"`
+	// 团队负责的 GitLab 项目路径，支持 path.Match 通配符，如 pay/*
+	Projects []string `mapstructure:"projects"`
+	// 团队负责的 Jenkins job，匹配规则见 MatchJob
+	Jobs []string `mapstructure:"jobs"`
+}
+
+// 未匹配到任何团队时使用的团队名
+const UnassignedTeam = "未分配"
+
+// 读取团队配置，未配置时返回空 map
+func GetTeams() (map[string]Team, error) {
+	teams := make(map[string]Team)
+	if !viper.IsSet(TeamsConfigKey) {
+		return teams, nil
+	}
+	if err := viper.UnmarshalKey(TeamsConfigKey, &teams); err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+func sortedTeamNames(teams map[string]Team) []string {
+	names := make([]string, 0, len(teams))
+	for name := range teams {
+		names = append(names, name)
+	}
+	// 保证多个团队同时匹配时结果稳定
+	sort.Strings(names)
+	return names
+}
+
+// 先按项目归属匹配团队，匹配不到时再按作者所在团队匹配
+func TeamOf(teams map[string]Team, projectPath, username string) string {
+	names := sortedTeamNames(teams)
+
+	for _, name := range names {
+		for _, pattern := range teams[name].Projects {
+			if ok, _ := path.Match(pattern, projectPath); ok {
+				return name
+			}
+		}
+	}
+
+	for _, name := range names {
+		for _, member := range teams[name].Members {
+			if member == username {
+				return name
+			}
+		}
+	}
+
+	return UnassignedTeam
+}
+
+// 按 job 归属匹配团队
+func TeamOfJob(teams map[string]Team, jobName string) string {
+	for _, name := range sortedTeamNames(teams) {
+		for _, pattern := range teams[name].Jobs {
+			if MatchJob(pattern, jobName) {
+				return name
+			}
+		}
+	}
+	return UnassignedTeam
+}
@@ -0,0 +1,137 @@
+﻿package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// 工作时间配置，用于按时区统计构建时间分布以及找出非工作时间的发布
+const WorkingHoursConfigKey = "workingHours"
+
+// 非工作时间的原因
+const (
+	OffHoursWeekend = "weekend"
+	OffHoursHoliday = "holiday"
+	OffHoursEarly   = "beforeHours"
+	OffHoursLate    = "afterHours"
+)
+
+const clockLayout = "15:04"
+
+type WorkingHours struct {
+	// IANA 时区名称，如 Asia/Shanghai，为空时使用服务器本地时区
+	Timezone string `mapstructure:"timezone"`
+	// 工作日，取值为 mon、tue、wed、thu、fri、sat、sun
+	Days []string `mapstructure:"days"`
+	// 每个工作日的上下班时间，格式为 HH:MM
+	Start string `mapstructure:"start"`
+	End   string `mapstructure:"end"`
+	// 节假日，格式为 2006-01-02；调休上班的周末写在 ExtraWorkdays 中
+	Holidays      []string `mapstructure:"holidays"`
+	ExtraWorkdays []string `mapstructure:"extraWorkdays"`
+
+	location *time.Location
+	days     map[time.Weekday]bool
+	start    int
+	end      int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func GetWorkingHours() (*WorkingHours, error) {
+	workingHours := WorkingHours{
+		Days:  []string{"mon", "tue", "wed", "thu", "fri"},
+		Start: "09:00",
+		End:   "18:00",
+	}
+	if viper.IsSet(WorkingHoursConfigKey) {
+		if err := viper.UnmarshalKey(WorkingHoursConfigKey, &workingHours); err != nil {
+			return nil, err
+		}
+	}
+
+	workingHours.location = time.Local
+	if workingHours.Timezone != "" {
+		location, err := time.LoadLocation(workingHours.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", workingHours.Timezone, err)
+		}
+		workingHours.location = location
+	}
+
+	workingHours.days = make(map[time.Weekday]bool)
+	for _, day := range workingHours.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("invalid working day %q", day)
+		}
+		workingHours.days[weekday] = true
+	}
+
+	var err error
+	if workingHours.start, err = minuteOfDay(workingHours.Start); err != nil {
+		return nil, err
+	}
+	if workingHours.end, err = minuteOfDay(workingHours.End); err != nil {
+		return nil, err
+	}
+	if workingHours.end <= workingHours.start {
+		return nil, fmt.Errorf("working hours end %s must be after start %s", workingHours.End, workingHours.Start)
+	}
+	return &workingHours, nil
+}
+
+func minuteOfDay(clock string) (int, error) {
+	t, err := time.Parse(clockLayout, clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid working hours %q", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// 统计使用的时区
+func (w *WorkingHours) Location() *time.Location {
+	return w.location
+}
+
+// 判断时间是否在工作时间之外，返回原因；工作时间内返回空字符串
+func (w *WorkingHours) OffHoursReason(t time.Time) string {
+	local := t.In(w.location)
+	day := local.Format("2006-01-02")
+
+	if contains(w.Holidays, day) {
+		return OffHoursHoliday
+	}
+	if !w.days[local.Weekday()] && !contains(w.ExtraWorkdays, day) {
+		return OffHoursWeekend
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	switch {
+	case minute < w.start:
+		return OffHoursEarly
+	case minute >= w.end:
+		return OffHoursLate
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+﻿package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestOffHoursReason(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set(WorkingHoursConfigKey, map[string]interface{}{
+		"timezone":      "Asia/Shanghai",
+		"days":          []string{"Mon", "tue", "wed", "thu", "fri"},
+		"start":         "09:30",
+		"end":           "18:00",
+		"holidays":      []string{"2024-10-01"},
+		"extraWorkdays": []string{"2024-10-12"},
+	})
+	workingHours, err := GetWorkingHours()
+	if err != nil {
+		t.Fatalf("GetWorkingHours: %v", err)
+	}
+
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day string, clock string) time.Time {
+		t, err := time.ParseInLocation("2006-01-02 15:04", day+" "+clock, shanghai)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{"working time", at("2024-10-09", "10:00"), ""},
+		{"start is inclusive", at("2024-10-09", "09:30"), ""},
+		{"before start", at("2024-10-09", "09:29"), OffHoursEarly},
+		{"end is exclusive", at("2024-10-09", "18:00"), OffHoursLate},
+		{"weekend", at("2024-10-13", "10:00"), OffHoursWeekend},
+		{"holiday", at("2024-10-01", "10:00"), OffHoursHoliday},
+		{"extra workday", at("2024-10-12", "10:00"), ""},
+		{"extra workday after hours", at("2024-10-12", "20:00"), OffHoursLate},
+		// 按配置的时区判断：UTC 周三 02:00 是北京时间周三 10:00
+		{"converted to timezone", time.Date(2024, 10, 9, 2, 0, 0, 0, time.UTC), ""},
+		{"converted across midnight", time.Date(2024, 10, 18, 16, 30, 0, 0, time.UTC), OffHoursWeekend},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := workingHours.OffHoursReason(tt.at); got != tt.want {
+				t.Errorf("OffHoursReason(%s) = %q, want %q", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetWorkingHoursInvalid(t *testing.T) {
+	tests := []struct {
+		name         string
+		workingHours map[string]interface{}
+	}{
+		{"timezone", map[string]interface{}{"timezone": "Mars/Olympus"}},
+		{"day", map[string]interface{}{"days": []string{"monday"}}},
+		{"clock", map[string]interface{}{"start": "9am"}},
+		{"end before start", map[string]interface{}{"start": "18:00", "end": "09:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.Set(WorkingHoursConfigKey, tt.workingHours)
+			if _, err := GetWorkingHours(); err == nil {
+				t.Errorf("GetWorkingHours() with %v: expected error", tt.workingHours)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+﻿package alert
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/notify"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 发送告警通知，由 notify.Notifier 实现
+type Sender interface {
+	Notify(ctx context.Context, msg notify.Message) error
+}
+
+// 把告警按标签分组后合并发送通知，去掉静默与被抑制的告警，并按 groupWait、groupInterval、
+// repeatInterval 控制发送频率。分组状态只保存在内存中，重启后 firing 的告警会在 groupWait 后重新通知
+type Dispatcher struct {
+	store  *store.Store
+	sender Sender
+	config *config.Notify
+
+	mu     sync.Mutex
+	groups map[string]*alertGroup
+}
+
+type alertGroup struct {
+	labels    map[string]string
+	createdAt time.Time
+	// 最近一次通知的时间、内容，以及通知过的 firing 告警，这些告警恢复后需要再通知一次
+	notifiedAt     time.Time
+	notifiedKey    string
+	notifiedFiring map[string]bool
+}
+
+func NewDispatcher(s *store.Store, sender Sender, cfg *config.Notify) *Dispatcher {
+	return &Dispatcher{
+		store:  s,
+		sender: sender,
+		config: cfg,
+		groups: make(map[string]*alertGroup),
+	}
+}
+
+// 按 interval 定时检查需要发送的分组，直到 ctx 结束。interval 应明显小于 groupWait
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.Flush(ctx, now)
+		}
+	}
+}
+
+// 检查全部分组，发送到期的通知
+func (d *Dispatcher) Flush(ctx context.Context, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	alerts := d.store.Alerts()
+	muted := Mute(alerts, d.store.Silences(), d.config.InhibitRules, now)
+
+	// 本轮每个分组中需要通知的 firing 告警与已恢复告警
+	firing := make(map[string][]store.Alert)
+	resolved := make(map[string][]store.Alert)
+	for _, alert := range alerts {
+		if _, ok := muted[alert.Fingerprint]; ok {
+			continue
+		}
+		key, labels := d.groupOf(alert)
+		group, ok := d.groups[key]
+
+		switch alert.State {
+		case store.AlertFiring:
+			if !ok {
+				group = &alertGroup{labels: labels, createdAt: now, notifiedFiring: make(map[string]bool)}
+				d.groups[key] = group
+			}
+			firing[key] = append(firing[key], alert)
+		case store.AlertResolved:
+			// 只通知发送过 firing 通知的告警的恢复
+			if ok && group.notifiedFiring[alert.Fingerprint] {
+				resolved[key] = append(resolved[key], alert)
+			}
+		}
+	}
+
+	for key, group := range d.groups {
+		if len(firing[key]) == 0 && len(resolved[key]) == 0 {
+			// firing 的告警全部被静默、抑制，或者没有通知过就已经恢复
+			delete(d.groups, key)
+			continue
+		}
+
+		content := contentKey(firing[key], resolved[key])
+		switch {
+		case group.notifiedAt.IsZero():
+			if now.Sub(group.createdAt) < d.config.GroupWait {
+				continue
+			}
+		case content != group.notifiedKey:
+			if now.Sub(group.notifiedAt) < d.config.GroupInterval {
+				continue
+			}
+		default:
+			if len(firing[key]) == 0 || now.Sub(group.notifiedAt) < d.config.RepeatInterval {
+				continue
+			}
+		}
+
+		msg := notify.AlertGroupMessage(group.labels, firing[key], resolved[key])
+		if err := d.sender.Notify(ctx, msg); err != nil {
+			log.Errorf("发送告警分组[%s]的通知失败: %v", msg.Title, err)
+		}
+
+		group.notifiedAt = now
+		group.notifiedKey = content
+		group.notifiedFiring = make(map[string]bool, len(firing[key]))
+		for _, alert := range firing[key] {
+			group.notifiedFiring[alert.Fingerprint] = true
+		}
+		if len(firing[key]) == 0 {
+			delete(d.groups, key)
+		}
+	}
+}
+
+// 告警所属分组的 key 与分组标签，告警没有的标签取值为空
+func (d *Dispatcher) groupOf(alert store.Alert) (string, map[string]string) {
+	labels := make(map[string]string, len(d.config.GroupBy))
+	var key strings.Builder
+	for _, name := range d.config.GroupBy {
+		labels[name] = alert.Labels[name]
+		key.WriteString(name)
+		key.WriteByte('=')
+		key.WriteString(alert.Labels[name])
+		key.WriteByte(0)
+	}
+	return key.String(), labels
+}
+
+// 通知内容的摘要，firing 与已恢复的告警集合不变时认为是重复通知
+func contentKey(firing, resolved []store.Alert) string {
+	keys := make([]string, 0, len(firing)+len(resolved))
+	for _, alert := range firing {
+		keys = append(keys, "F"+alert.Fingerprint)
+	}
+	for _, alert := range resolved {
+		keys = append(keys, "R"+alert.Fingerprint)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
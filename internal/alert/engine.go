@@ -0,0 +1,240 @@
+﻿package alert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/dimension"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 已恢复的告警保留一段时间，便于查看最近恢复的告警
+const resolvedRetention = 24 * time.Hour
+
+// 告警自带的标签
+const (
+	LabelAlertName = "alertname"
+	LabelSeverity  = "severity"
+)
+
+// 根据 alertRules 配置评估构建数据，维护告警的 pending、firing、resolved 状态。
+// 告警状态保存在数据模型中，重启后继续沿用
+type Engine struct {
+	store *store.Store
+	mu    sync.Mutex
+}
+
+func NewEngine(s *store.Store) *Engine {
+	return &Engine{store: s}
+}
+
+// 一条规则在一个分组上的评估结果
+type result struct {
+	labels map[string]string
+	value  float64
+	active bool
+}
+
+// 评估全部规则并更新告警状态，每轮采集结束后调用
+func (e *Engine) Evaluate(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rules, err := config.GetAlertRules()
+	if err != nil {
+		log.Errorf("告警规则配置格式错误: %v", err)
+		return
+	}
+	dimensions, err := config.GetParameterDimensions()
+	if err != nil {
+		log.Errorf("参数维度配置格式错误: %v", err)
+		return
+	}
+	teams, err := config.GetTeams()
+	if err != nil {
+		log.Errorf("团队配置格式错误: %v", err)
+		return
+	}
+	branchClasses, err := config.GetBranchClasses()
+	if err != nil {
+		log.Errorf("分支类别配置格式错误: %v", err)
+		return
+	}
+	running, err := config.GetRunningBuilds()
+	if err != nil {
+		log.Errorf("运行中构建配置格式错误: %v", err)
+		return
+	}
+	detection, err := config.GetAnomalyDetection()
+	if err != nil {
+		log.Errorf("异常检测配置格式错误: %v", err)
+		return
+	}
+
+	resolver := &dimension.Resolver{BranchClasses: branchClasses, Teams: teams, Parameters: dimensions}
+
+	builds := e.store.JenkinsBuilds()
+	instances := e.store.JenkinsInstances()
+	previous := make(map[string]store.Alert)
+	for _, alert := range e.store.Alerts() {
+		previous[alert.Fingerprint] = alert
+	}
+
+	var alerts []store.Alert
+	active := make(map[string]bool)
+	// 工作时间配置只有异常检测规则需要，用到时才读取。读取失败时跳过这些规则，已有的告警保持原状态
+	var workingHours *config.WorkingHours
+	var workingHoursErr error
+	skipped := make(map[string]bool)
+	for i := range rules {
+		rule := &rules[i]
+		var results []result
+		switch rule.Type {
+		case config.AlertInstanceDown:
+			results = evaluateInstanceDown(rule, instances)
+		case config.AlertBuildOverrun, config.AlertBuildStuck:
+			results = evaluateRunningBuilds(rule, builds, dimensions, running, now)
+		case config.AlertDurationAnomaly, config.AlertFailureAnomaly:
+			if workingHours == nil && workingHoursErr == nil {
+				if workingHours, workingHoursErr = config.GetWorkingHours(); workingHoursErr != nil {
+					log.Errorf("工作时间配置格式错误，跳过异常检测告警规则: %v", workingHoursErr)
+				}
+			}
+			if workingHoursErr != nil {
+				skipped[rule.Name] = true
+				continue
+			}
+			results = evaluateAnomalies(rule, builds, dimensions, detection, workingHours.Location(), now)
+		default:
+			results = evaluateRule(rule, builds, resolver, now)
+		}
+		for _, r := range results {
+			if !r.active {
+				continue
+			}
+
+			labels := alertLabels(rule, r.labels)
+			fingerprint := fingerprintOf(labels)
+			alert, ok := previous[fingerprint]
+			if !ok || alert.State == store.AlertResolved {
+				alert = store.Alert{
+					Fingerprint: fingerprint,
+					State:       store.AlertPending,
+					ActiveAt:    now,
+				}
+			}
+			alert.Rule = rule.Name
+			alert.Severity = rule.Severity
+			alert.Labels = labels
+			alert.Value = r.value
+			alert.Threshold = rule.Threshold
+			alert.Annotations = renderAnnotations(rule, labels, r.value)
+			alert.EvaluatedAt = now
+			alert.ResolvedAt = nil
+
+			if alert.State == store.AlertPending && now.Sub(alert.ActiveAt) >= rule.For {
+				firedAt := now
+				alert.State = store.AlertFiring
+				alert.FiredAt = &firedAt
+				log.Warningf("告警触发: %s %v, 当前值 %.2f", rule.Name, r.labels, r.value)
+			}
+
+			active[fingerprint] = true
+			alerts = append(alerts, alert)
+		}
+	}
+
+	// 条件不再满足的告警：pending 直接丢弃，firing 变为 resolved，resolved 保留一段时间
+	for fingerprint, alert := range previous {
+		if active[fingerprint] {
+			continue
+		}
+		if skipped[alert.Rule] {
+			alerts = append(alerts, alert)
+			continue
+		}
+		switch alert.State {
+		case store.AlertFiring:
+			resolvedAt := now
+			alert.State = store.AlertResolved
+			alert.ResolvedAt = &resolvedAt
+			alert.EvaluatedAt = now
+			alerts = append(alerts, alert)
+			log.Printf("告警恢复: %s %v", alert.Rule, alert.Labels)
+		case store.AlertResolved:
+			if alert.ResolvedAt != nil && now.Sub(*alert.ResolvedAt) <= resolvedRetention {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+
+	e.store.ReplaceAlerts(alerts)
+}
+
+// 告警的标签：规则配置的标签、分组维度的取值，以及告警名称与级别
+func alertLabels(rule *config.AlertRule, groupLabels map[string]string) map[string]string {
+	labels := make(map[string]string, len(rule.Labels)+len(groupLabels)+2)
+	for name, value := range rule.Labels {
+		labels[name] = value
+	}
+	for name, value := range groupLabels {
+		labels[name] = value
+	}
+	labels[LabelAlertName] = rule.Name
+	labels[LabelSeverity] = rule.Severity
+	return labels
+}
+
+// 按标签名排序后计算哈希，相同标签的告警是同一条告警
+func fingerprintOf(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(labels[name])
+		b.WriteByte(0)
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// 渲染注解模板，模板错误时原样输出
+func renderAnnotations(rule *config.AlertRule, labels map[string]string, value float64) map[string]string {
+	if len(rule.Annotations) == 0 {
+		return nil
+	}
+
+	data := struct {
+		Value     float64
+		Threshold float64
+		Labels    map[string]string
+	}{value, rule.Threshold, labels}
+
+	annotations := make(map[string]string, len(rule.Annotations))
+	for name, text := range rule.Annotations {
+		annotations[name] = text
+		tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			continue
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err == nil {
+			annotations[name] = b.String()
+		}
+	}
+	return annotations
+}
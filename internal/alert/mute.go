@@ -0,0 +1,49 @@
+﻿package alert
+
+import (
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 告警被哪些静默（ID）与哪些告警（指纹）屏蔽
+type MuteStatus struct {
+	SilencedBy  []string `json:"silencedBy,omitempty"`
+	InhibitedBy []string `json:"inhibitedBy,omitempty"`
+}
+
+func (m MuteStatus) Muted() bool {
+	return len(m.SilencedBy) > 0 || len(m.InhibitedBy) > 0
+}
+
+// 计算每条告警的静默与抑制情况，只返回被屏蔽的告警。只有 firing 的告警可以抑制其他告警
+func Mute(alerts []store.Alert, silences []store.Silence, rules []config.InhibitRule, now time.Time) map[string]MuteStatus {
+	result := make(map[string]MuteStatus)
+
+	for _, alert := range alerts {
+		var status MuteStatus
+		for i := range silences {
+			if silences[i].Mutes(alert.Labels, now) {
+				status.SilencedBy = append(status.SilencedBy, silences[i].ID)
+			}
+		}
+
+		for _, source := range alerts {
+			if source.State != store.AlertFiring || source.Fingerprint == alert.Fingerprint {
+				continue
+			}
+			for i := range rules {
+				if rules[i].Inhibits(source.Labels, alert.Labels) {
+					status.InhibitedBy = append(status.InhibitedBy, source.Fingerprint)
+					break
+				}
+			}
+		}
+
+		if status.Muted() {
+			result[alert.Fingerprint] = status
+		}
+	}
+	return result
+}
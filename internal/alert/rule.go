@@ -0,0 +1,280 @@
+﻿package alert
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/anomaly"
+	"github.com/zuoyangs/go-devops-observability/internal/dimension"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 运行中构建的告警按构建区分
+const labelBuild = "build"
+
+// 按规则筛选构建并分组，分别计算每组是否满足告警条件
+func evaluateRule(rule *config.AlertRule, builds []store.JenkinsBuild, resolver *dimension.Resolver, now time.Time) []result {
+
+	type group struct {
+		labels   map[string]string
+		builds   []store.JenkinsBuild
+		expected bool
+	}
+	groups := make(map[string]*group)
+
+	// 显式配置的分组即使没有任何构建也要评估，用于发现长期没有成功部署的服务
+	if rule.Type == config.AlertNoSuccess {
+		for _, expected := range rule.ExpectedGroups {
+			labels := make(map[string]string, len(rule.GroupBy))
+			var key strings.Builder
+			for _, name := range rule.GroupBy {
+				labels[name] = expected[name]
+				key.WriteString(expected[name])
+				key.WriteByte(0)
+			}
+			groups[key.String()] = &group{labels: labels, expected: true}
+		}
+	}
+
+	for _, build := range builds {
+		if build.Building || !matchBuild(rule, build, resolver.Parameters) {
+			continue
+		}
+
+		labels := make(map[string]string, len(rule.GroupBy))
+		var key strings.Builder
+		for _, name := range rule.GroupBy {
+			value := resolver.Value(name, build)
+			labels[name] = value
+			key.WriteString(value)
+			key.WriteByte(0)
+		}
+
+		g, ok := groups[key.String()]
+		if !ok {
+			g = &group{labels: labels}
+			groups[key.String()] = g
+		}
+		g.builds = append(g.builds, build)
+	}
+
+	results := make([]result, 0, len(groups))
+	for _, g := range groups {
+		r := result{labels: g.labels}
+		switch rule.Type {
+		case config.AlertFailureRate:
+			r.value, r.active = failureRate(rule, g.builds, now)
+		case config.AlertConsecutiveFailures:
+			r.value, r.active = consecutiveFailures(rule, g.builds)
+		case config.AlertNoSuccess:
+			r.value, r.active = noSuccess(rule, g.builds, g.expected, now)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func matchBuild(rule *config.AlertRule, build store.JenkinsBuild, dimensions []config.ParameterDimension) bool {
+	if !rule.Selector.MatchBuild(build.JenkinsInstanceName, build.JobName, build.Branch) {
+		return false
+	}
+	for _, parameter := range rule.Selector.Parameters {
+		value := parameterValue(parameter.Name, build, dimensions)
+		matched := false
+		for _, expected := range parameter.Values {
+			if strings.EqualFold(expected, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// 优先按参数维度计算取值（包含归一化），没有对应的参数维度时直接取同名构建参数
+func parameterValue(name string, build store.JenkinsBuild, dimensions []config.ParameterDimension) string {
+	for i := range dimensions {
+		if dimensions[i].Name == name {
+			return dimensions[i].ValueOf(build.Parameters)
+		}
+	}
+	for key, value := range build.Parameters {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// 窗口内的失败率（百分比），与 /metrics 一致只统计成功与失败的构建
+func failureRate(rule *config.AlertRule, builds []store.JenkinsBuild, now time.Time) (float64, bool) {
+	sinceMillis := now.Add(-rule.Window).UnixMilli()
+
+	success, failure := 0, 0
+	for _, build := range builds {
+		if build.Timestamp < sinceMillis {
+			continue
+		}
+		switch build.Result {
+		case "SUCCESS":
+			success++
+		case "FAILURE":
+			failure++
+		}
+	}
+
+	total := success + failure
+	if total == 0 || total < rule.MinBuilds {
+		return 0, false
+	}
+	rate := math.Round(float64(failure)/float64(total)*100*1e2) / 1e2
+	return rate, rate > rule.Threshold
+}
+
+// 从最近一次构建往前数连续失败的次数，计算方式见 store.FailureStreak
+func consecutiveFailures(rule *config.AlertRule, builds []store.JenkinsBuild) (float64, bool) {
+	count := len(store.FailureStreak(builds))
+	return float64(count), count > 0 && float64(count) >= rule.Threshold
+}
+
+// 距离最近一次成功构建的小时数，窗口内没有成功构建时告警。
+// 只评估窗口开始之前就已经有构建的分组（或显式配置的分组），新建的 job 在满一个窗口之前不告警
+func noSuccess(rule *config.AlertRule, builds []store.JenkinsBuild, expected bool, now time.Time) (float64, bool) {
+	sinceMillis := now.Add(-rule.Window).UnixMilli()
+
+	var firstBuild, lastSuccess int64
+	for _, build := range builds {
+		if firstBuild == 0 || build.Timestamp < firstBuild {
+			firstBuild = build.Timestamp
+		}
+		if build.Result == "SUCCESS" && build.Timestamp > lastSuccess {
+			lastSuccess = build.Timestamp
+		}
+	}
+	if !expected && (firstBuild == 0 || firstBuild >= sinceMillis) {
+		return 0, false
+	}
+
+	// 保留的数据中没有成功构建时，从第一次构建算起，至少已经超过了窗口
+	hours := rule.Window.Hours()
+	if lastSuccess > 0 {
+		hours = now.Sub(time.UnixMilli(lastSuccess)).Hours()
+	} else if firstBuild > 0 {
+		hours = math.Max(hours, now.Sub(time.UnixMilli(firstBuild)).Hours())
+	}
+	hours = math.Round(hours*1e2) / 1e2
+	return hours, lastSuccess < sinceMillis
+}
+
+// 按实例评估健康探测与采集结果，还没有探测结果的实例不评估
+func evaluateInstanceDown(rule *config.AlertRule, instances []store.JenkinsInstanceStatus) []result {
+	var results []result
+	for _, instance := range instances {
+		if instance.LastProbeAt == nil || !rule.Selector.MatchInstance(instance.JenkinsInstanceName) {
+			continue
+		}
+		down := !instance.Reachable || !instance.AuthValid
+		if rule.Threshold > 0 && float64(instance.ConsecutiveErrorCount) >= rule.Threshold {
+			down = true
+		}
+		results = append(results, result{
+			labels: map[string]string{config.DimensionInstance: instance.JenkinsInstanceName},
+			value:  float64(instance.ConsecutiveErrorCount),
+			active: down,
+		})
+	}
+	return results
+}
+
+// 按构建评估运行中的构建是否超时或卡住，构建结束后告警恢复。
+// 超时告警的值为已运行时长与预计时长的比值，卡住告警的值为已运行的小时数
+func evaluateRunningBuilds(rule *config.AlertRule, builds []store.JenkinsBuild, dimensions []config.ParameterDimension, running *config.RunningBuilds, now time.Time) []result {
+	criteria := *running
+	if rule.Threshold > 0 {
+		if rule.Type == config.AlertBuildOverrun {
+			criteria.OverrunFactor = rule.Threshold
+		} else {
+			criteria.StuckAfter = time.Duration(rule.Threshold * float64(time.Hour))
+		}
+	}
+
+	var results []result
+	for _, build := range builds {
+		// 失联的构建实际状态未知，不判断超时与卡住
+		if !build.Building || running.IsStale(build.UpdatedAt, now) || !matchBuild(rule, build, dimensions) {
+			continue
+		}
+
+		elapsed := now.Sub(time.UnixMilli(build.Timestamp))
+		estimated := time.Duration(build.EstimatedDuration) * time.Millisecond
+		status := criteria.StatusOf(elapsed, estimated)
+
+		r := result{labels: map[string]string{
+			config.DimensionInstance: build.JenkinsInstanceName,
+			config.DimensionJob:      build.JobName,
+			labelBuild:               strconv.Itoa(build.Number),
+		}}
+		if rule.Type == config.AlertBuildOverrun {
+			if estimated > 0 {
+				r.value = math.Round(float64(elapsed)/float64(estimated)*1e2) / 1e2
+			}
+			// 卡住的构建同时也超时
+			r.active = status == config.RunningOverrun || (status == config.RunningStuck && r.value > criteria.OverrunFactor)
+		} else {
+			r.value = math.Round(elapsed.Hours()*1e2) / 1e2
+			r.active = status == config.RunningStuck
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// 按 job 评估窗口内偏高的构建时长或每日失败次数，告警的值为窗口内最高的异常分数。
+// 按小时、星期划分的季节以及每日失败次数都按 workingHours.timezone 换算
+func evaluateAnomalies(rule *config.AlertRule, builds []store.JenkinsBuild, dimensions []config.ParameterDimension, detection *config.AnomalyDetection, location *time.Location, now time.Time) []result {
+	criteria := *detection
+	if rule.Threshold > 0 {
+		criteria.Threshold = rule.Threshold
+	}
+	kind := anomaly.KindDuration
+	if rule.Type == config.AlertFailureAnomaly {
+		kind = anomaly.KindFailureCount
+	}
+
+	var matched []store.JenkinsBuild
+	for _, build := range builds {
+		if matchBuild(rule, build, dimensions) {
+			matched = append(matched, build)
+		}
+	}
+
+	type jobKey struct {
+		instance, job string
+	}
+	scores := make(map[jobKey]float64)
+	for _, item := range anomaly.Detect(matched, &criteria, now.Add(-rule.Window), now, location) {
+		if item.Kind != kind || item.Direction != anomaly.DirectionHigh {
+			continue
+		}
+		key := jobKey{item.JenkinsInstanceName, item.JobName}
+		if score, ok := scores[key]; !ok || item.Score > score {
+			scores[key] = item.Score
+		}
+	}
+
+	results := make([]result, 0, len(scores))
+	for key, score := range scores {
+		results = append(results, result{
+			labels: map[string]string{config.DimensionInstance: key.instance, config.DimensionJob: key.job},
+			value:  score,
+			active: true,
+		})
+	}
+	return results
+}
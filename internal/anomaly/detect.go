@@ -0,0 +1,260 @@
+﻿package anomaly
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 异常的类型
+const (
+	KindDuration     = "duration"
+	KindFailureCount = "failureCount"
+)
+
+// 偏离基线的方向
+const (
+	DirectionHigh = "high"
+	DirectionLow  = "low"
+)
+
+// 基线使用的季节分组，样本不足时依次退回到更粗的分组
+const (
+	SeasonWeekdayHour = "weekdayHour"
+	SeasonHour        = "hour"
+	SeasonWeekday     = "weekday"
+	SeasonAll         = "all"
+)
+
+// 每日失败次数离散程度的下限：按默认阈值，从不失败的 job 一天失败 2 次即视为异常
+const failureCountFloor = 0.5
+
+// 构建时长离散程度的下限占中位数的比例
+const durationFloorRatio = 0.05
+
+const dayLayout = "2006-01-02"
+
+type Anomaly struct {
+	Kind                string `json:"kind"`
+	JenkinsInstanceName string `json:"jenkinsInstanceName"`
+	JobName             string `json:"jobName"`
+	// 时长异常的构建，失败次数异常时为空
+	Number int    `json:"number,omitempty"`
+	URL    string `json:"url,omitempty"`
+	// 构建的开始时间，或失败次数所在日期的零点
+	Timestamp int64  `json:"timestamp"`
+	Day       string `json:"day,omitempty"`
+
+	// 构建时长（秒）或当天的失败次数，以及基线的中位数
+	Value     float64 `json:"value"`
+	Median    float64 `json:"median"`
+	Score     float64 `json:"score"`
+	Direction string  `json:"direction"`
+	// 基线使用的季节分组与样本数
+	Season  string `json:"season"`
+	Samples int    `json:"samples"`
+}
+
+// 检测 [since, now] 内的异常，每个 job 的基线使用此前 HistoryDays 天的数据。
+// 构建时长只比较成功的构建，按星期+小时分组；每日失败次数按星期分组，只标记偏高的情况。
+// loc 为按星期、小时分组与按天统计使用的时区
+func Detect(builds []store.JenkinsBuild, cfg *config.AnomalyDetection, since, now time.Time, loc *time.Location) []Anomaly {
+
+	type jobKey struct {
+		instance, job string
+	}
+	jobs := make(map[jobKey][]store.JenkinsBuild)
+	for _, build := range builds {
+		if build.Building {
+			continue
+		}
+		key := jobKey{build.JenkinsInstanceName, build.JobName}
+		jobs[key] = append(jobs[key], build)
+	}
+
+	var result []Anomaly
+	for _, history := range jobs {
+		sort.Slice(history, func(i, j int) bool {
+			return history[i].Timestamp < history[j].Timestamp
+		})
+		result = append(result, durationAnomalies(history, cfg, since, now, loc)...)
+		result = append(result, failureCountAnomalies(history, cfg, since, now, loc)...)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Timestamp != result[j].Timestamp {
+			return result[i].Timestamp > result[j].Timestamp
+		}
+		if result[i].JenkinsInstanceName != result[j].JenkinsInstanceName {
+			return result[i].JenkinsInstanceName < result[j].JenkinsInstanceName
+		}
+		return result[i].JobName < result[j].JobName
+	})
+	return result
+}
+
+// history 为同一个 job 按时间排序的构建
+func durationAnomalies(history []store.JenkinsBuild, cfg *config.AnomalyDetection, since, now time.Time, loc *time.Location) []Anomaly {
+
+	var successes []store.JenkinsBuild
+	for _, build := range history {
+		if build.Result == "SUCCESS" && build.Duration > 0 {
+			successes = append(successes, build)
+		}
+	}
+
+	var result []Anomaly
+	start := 0
+	for i, build := range successes {
+		startedAt := time.UnixMilli(build.Timestamp)
+		if startedAt.Before(since) || startedAt.After(now) {
+			continue
+		}
+
+		// 基线为此前 HistoryDays 天内的成功构建
+		historyStart := startedAt.AddDate(0, 0, -cfg.HistoryDays).UnixMilli()
+		for start < i && successes[start].Timestamp < historyStart {
+			start++
+		}
+
+		local := startedAt.In(loc)
+		season, values := seasonalValues(successes[start:i], cfg.MinSamples, func(other store.JenkinsBuild) (bool, bool) {
+			t := time.UnixMilli(other.Timestamp).In(loc)
+			return t.Hour() == local.Hour(), t.Weekday() == local.Weekday()
+		}, func(other store.JenkinsBuild) float64 {
+			return float64(other.Duration) / 1000
+		})
+		if values == nil {
+			continue
+		}
+
+		middle := median(values)
+		base := newBaseline(values, math.Max(middle*durationFloorRatio, 1))
+		value := float64(build.Duration) / 1000
+		score := base.score(value)
+		if math.Abs(score) < cfg.Threshold || middle == 0 || math.Abs(value-middle)/middle < cfg.MinDurationChange {
+			continue
+		}
+
+		direction := DirectionHigh
+		if score < 0 {
+			direction = DirectionLow
+		}
+		result = append(result, Anomaly{
+			Kind:                KindDuration,
+			JenkinsInstanceName: build.JenkinsInstanceName,
+			JobName:             build.JobName,
+			Number:              build.Number,
+			URL:                 build.URL,
+			Timestamp:           build.Timestamp,
+			Value:               value,
+			Median:              middle,
+			Score:               score,
+			Direction:           direction,
+			Season:              season,
+			Samples:             len(values),
+		})
+	}
+	return result
+}
+
+// 按星期+小时、小时、星期、全部的顺序选择样本数足够的季节分组，都不够时返回 nil。
+// match 返回样本与当前构建是否同一小时、是否同一星期
+func seasonalValues(samples []store.JenkinsBuild, minSamples int, match func(store.JenkinsBuild) (bool, bool), valueOf func(store.JenkinsBuild) float64) (string, []float64) {
+
+	groups := make(map[string][]float64)
+	for _, sample := range samples {
+		sameHour, sameWeekday := match(sample)
+		value := valueOf(sample)
+		if sameHour && sameWeekday {
+			groups[SeasonWeekdayHour] = append(groups[SeasonWeekdayHour], value)
+		}
+		if sameHour {
+			groups[SeasonHour] = append(groups[SeasonHour], value)
+		}
+		if sameWeekday {
+			groups[SeasonWeekday] = append(groups[SeasonWeekday], value)
+		}
+		groups[SeasonAll] = append(groups[SeasonAll], value)
+	}
+
+	for _, season := range []string{SeasonWeekdayHour, SeasonHour, SeasonWeekday, SeasonAll} {
+		if len(groups[season]) >= minSamples {
+			return season, groups[season]
+		}
+	}
+	return "", nil
+}
+
+// history 为同一个 job 按时间排序的构建
+func failureCountAnomalies(history []store.JenkinsBuild, cfg *config.AnomalyDetection, since, now time.Time, loc *time.Location) []Anomaly {
+	if len(history) == 0 {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for _, build := range history {
+		if build.Result == "FAILURE" {
+			counts[time.UnixMilli(build.Timestamp).In(loc).Format(dayLayout)]++
+		}
+	}
+
+	// job 第一次构建之前的日期不作为样本
+	firstDay := startOfDay(time.UnixMilli(history[0].Timestamp).In(loc))
+
+	var result []Anomaly
+	for day := startOfDay(since.In(loc)); !day.After(now); day = day.AddDate(0, 0, 1) {
+		value := float64(counts[day.Format(dayLayout)])
+		if value == 0 {
+			continue
+		}
+
+		var weekday, all []float64
+		for sample := day.AddDate(0, 0, -cfg.HistoryDays); sample.Before(day); sample = sample.AddDate(0, 0, 1) {
+			if sample.Before(firstDay) {
+				continue
+			}
+			count := float64(counts[sample.Format(dayLayout)])
+			if sample.Weekday() == day.Weekday() {
+				weekday = append(weekday, count)
+			}
+			all = append(all, count)
+		}
+
+		season, values := SeasonWeekday, weekday
+		if len(values) < cfg.MinSamples {
+			season, values = SeasonAll, all
+		}
+		if len(values) < cfg.MinSamples {
+			continue
+		}
+
+		base := newBaseline(values, failureCountFloor)
+		score := base.score(value)
+		if score < cfg.Threshold {
+			continue
+		}
+
+		result = append(result, Anomaly{
+			Kind:                KindFailureCount,
+			JenkinsInstanceName: history[0].JenkinsInstanceName,
+			JobName:             history[0].JobName,
+			Timestamp:           day.UnixMilli(),
+			Day:                 day.Format(dayLayout),
+			Value:               value,
+			Median:              base.median,
+			Score:               score,
+			Direction:           DirectionHigh,
+			Season:              season,
+			Samples:             len(values),
+		})
+	}
+	return result
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
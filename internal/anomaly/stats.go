@@ -0,0 +1,61 @@
+﻿package anomaly
+
+import (
+	"math"
+	"sort"
+)
+
+// 正态分布下 MAD 与标准差、平均绝对偏差与标准差的换算系数
+const (
+	madScale    = 1.4826
+	meanADScale = 1.253314
+)
+
+// 稳健的基线：中位数与换算为标准差量纲的离散程度
+type baseline struct {
+	median float64
+	scale  float64
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[middle]
+	}
+	return (sorted[middle-1] + sorted[middle]) / 2
+}
+
+// 以中位数与 MAD 计算基线。超过一半的样本相同时 MAD 为 0，改用平均绝对偏差；
+// 仍为 0 时使用 floor，避免任何微小的偏差都被视为异常
+func newBaseline(values []float64, floor float64) baseline {
+	b := baseline{median: median(values)}
+
+	deviations := make([]float64, len(values))
+	sum := 0.0
+	for i, value := range values {
+		deviations[i] = math.Abs(value - b.median)
+		sum += deviations[i]
+	}
+
+	b.scale = madScale * median(deviations)
+	if b.scale == 0 && len(values) > 0 {
+		b.scale = meanADScale * sum / float64(len(values))
+	}
+	if b.scale < floor {
+		b.scale = floor
+	}
+	return b
+}
+
+// 稳健 z 分数，保留两位小数
+func (b baseline) score(value float64) float64 {
+	if b.scale == 0 {
+		return 0
+	}
+	return math.Round((value-b.median)/b.scale*1e2) / 1e2
+}
@@ -0,0 +1,83 @@
+﻿package anomaly
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"empty", nil, 0},
+		{"single", []float64{7}, 7},
+		{"odd", []float64{5, 1, 3}, 3},
+		{"even", []float64{4, 1, 3, 2}, 2.5},
+		{"duplicates", []float64{2, 2, 9, 2}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append([]float64(nil), tt.values...)
+			if got := median(input); got != tt.want {
+				t.Errorf("median(%v) = %v, want %v", tt.values, got, tt.want)
+			}
+			// 不修改调用方的切片
+			for i := range input {
+				if input[i] != tt.values[i] {
+					t.Fatalf("median modified input: %v", input)
+				}
+			}
+		})
+	}
+}
+
+func TestNewBaseline(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     []float64
+		floor      float64
+		wantMedian float64
+		wantScale  float64
+	}{
+		// 偏差 2,1,0,1,2，MAD 为 1
+		{"mad", []float64{8, 9, 10, 11, 12}, 0, 10, madScale},
+		// 离群值不影响中位数与 MAD
+		{"outlier", []float64{8, 9, 10, 11, 1000}, 0, 10, madScale},
+		// 超过一半的样本相同，MAD 为 0，改用平均绝对偏差：(0+0+0+5)/4
+		{"mean absolute deviation", []float64{10, 10, 10, 15}, 0, 10, meanADScale * 5 / 4},
+		{"floor", []float64{10, 10, 10, 10}, 0.5, 10, 0.5},
+		{"floor above mad", []float64{8, 9, 10, 11, 12}, 3, 10, 3},
+		{"empty", nil, 1, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBaseline(tt.values, tt.floor)
+			if b.median != tt.wantMedian || math.Abs(b.scale-tt.wantScale) > 1e-9 {
+				t.Errorf("newBaseline(%v, %v) = %+v, want median %v scale %v", tt.values, tt.floor, b, tt.wantMedian, tt.wantScale)
+			}
+		})
+	}
+}
+
+func TestBaselineScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseline baseline
+		value    float64
+		want     float64
+	}{
+		{"above", baseline{median: 10, scale: 2}, 17, 3.5},
+		{"below", baseline{median: 10, scale: 2}, 4, -3},
+		{"rounded", baseline{median: 0, scale: 3}, 1, 0.33},
+		{"zero scale", baseline{median: 10}, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.baseline.score(tt.value); got != tt.want {
+				t.Errorf("score(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
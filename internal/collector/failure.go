@@ -0,0 +1,85 @@
+﻿package collector
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+)
+
+// 分类结果中保留的日志行最大长度
+const maxFailureLineLength = 500
+
+type failureRule struct {
+	category string
+	patterns []*regexp.Regexp
+}
+
+// 根据控制台日志对失败构建进行分类
+type failureClassifier struct {
+	tailBytes int
+	rules     []failureRule
+}
+
+// 编译配置中的规则，无法编译的正则表达式记录日志后跳过
+func newFailureClassifier(classification *config.FailureClassification) *failureClassifier {
+	classifier := &failureClassifier{tailBytes: classification.TailBytes}
+	for _, rule := range classification.Rules {
+		compiled := failureRule{category: rule.Category}
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				log.Warningf("失败分类规则[%s]的正则表达式无效: %s, %v", rule.Category, pattern, err)
+				continue
+			}
+			compiled.patterns = append(compiled.patterns, re)
+		}
+		classifier.rules = append(classifier.rules, compiled)
+	}
+	return classifier
+}
+
+// 返回第一个匹配的规则的类别以及匹配到的日志行，都不匹配时返回 unclassified 与日志的最后一行
+func (f *failureClassifier) classify(consoleLog string) (string, string) {
+	for _, rule := range f.rules {
+		for _, re := range rule.patterns {
+			if loc := re.FindStringIndex(consoleLog); loc != nil {
+				return rule.category, lineAt(consoleLog, loc[0])
+			}
+		}
+	}
+	return config.UnclassifiedFailure, lastLine(consoleLog)
+}
+
+// 取 offset 所在的整行
+func lineAt(text string, offset int) string {
+	start := strings.LastIndexByte(text[:offset], '\n') + 1
+	end := strings.IndexByte(text[offset:], '\n')
+	if end < 0 {
+		end = len(text)
+	} else {
+		end += offset
+	}
+	return truncateLine(text[start:end])
+}
+
+func lastLine(text string) string {
+	text = strings.TrimRight(text, "\r\n")
+	return truncateLine(text[strings.LastIndexByte(text, '\n')+1:])
+}
+
+// 按字节截断，截断位置落在多字节字符中间时向前退到字符边界
+func truncateLine(line string) string {
+	line = strings.TrimSpace(line)
+	if len(line) > maxFailureLineLength {
+		cut := maxFailureLineLength
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		line = line[:cut]
+	}
+	return line
+}
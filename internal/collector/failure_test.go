@@ -0,0 +1,89 @@
+﻿package collector
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+)
+
+func defaultClassifier(t *testing.T) *failureClassifier {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	classification, err := config.GetFailureClassification()
+	if err != nil {
+		t.Fatalf("GetFailureClassification: %v", err)
+	}
+	return newFailureClassifier(classification)
+}
+
+func TestClassifyDefaultRules(t *testing.T) {
+	classifier := defaultClassifier(t)
+	tests := []struct {
+		name         string
+		log          string
+		wantCategory string
+		wantLine     string
+	}{
+		{"abort", "Started by user admin\nAborted by admin\nFinished: ABORTED\n", "manual_abort", "Aborted by admin"},
+		{"oom", "[INFO] building\njava.lang.OutOfMemoryError: Java heap space\n", "out_of_memory", "java.lang.OutOfMemoryError: Java heap space"},
+		{"agent", "hudson.remoting.ChannelClosedException: Channel \"hudson.remoting.Channel@1\" is already closed", "agent_disconnect", "hudson.remoting.ChannelClosedException: Channel \"hudson.remoting.Channel@1\" is already closed"},
+		{"registry", "Step 1/5 : FROM registry.example.com/base\ntoomanyrequests: rate limit exceeded\n", "docker_registry_error", "toomanyrequests: rate limit exceeded"},
+		{"dependency", "[ERROR] Could not resolve dependencies for project app\n", "dependency_download_timeout", "[ERROR] Could not resolve dependencies for project app"},
+		{"compile", "src/app.ts(3,1): error TS2304: Cannot find name 'x'.\n", "compile_error", "src/app.ts(3,1): error TS2304: Cannot find name 'x'."},
+		{"unit test", "=== RUN   TestA\n--- FAIL: TestA (0.00s)\nFAIL\n", "unit_test_failure", "--- FAIL: TestA (0.00s)"},
+		{"no failures", "Tests run: 10, Failures: 0\nFinished: FAILURE\n", config.UnclassifiedFailure, "Finished: FAILURE"},
+		{"crlf", "step 1\r\nscript returned exit code 2\r\n", config.UnclassifiedFailure, "script returned exit code 2"},
+		{"empty", "", config.UnclassifiedFailure, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			category, line := classifier.classify(tt.log)
+			if category != tt.wantCategory || line != tt.wantLine {
+				t.Errorf("classify() = %q, %q, want %q, %q", category, line, tt.wantCategory, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestClassifyRuleOrder(t *testing.T) {
+	classifier := newFailureClassifier(&config.FailureClassification{Rules: []config.FailureRule{
+		{Category: "broken", Patterns: []string{"("}},
+		{Category: "quality_gate", Patterns: []string{"(", `Quality Gate failed`}},
+		{Category: "unit_test_failure", Patterns: []string{`--- FAIL:`}},
+	}})
+	if len(classifier.rules[0].patterns) != 0 || len(classifier.rules[1].patterns) != 1 {
+		t.Fatalf("invalid patterns should be skipped: %+v", classifier.rules)
+	}
+
+	// 先配置的规则优先，即使后面的规则匹配到更靠前的日志行
+	category, line := classifier.classify("--- FAIL: TestA\nQuality Gate failed: coverage 60%\n")
+	if category != "quality_gate" || line != "Quality Gate failed: coverage 60%" {
+		t.Errorf("classify() = %q, %q", category, line)
+	}
+}
+
+func TestTruncateLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want int
+	}{
+		{"short", "  error  ", len("error")},
+		{"ascii", strings.Repeat("a", maxFailureLineLength+10), maxFailureLineLength},
+		// 每个汉字 3 个字节，500 不是 3 的倍数，截断位置退到字符边界
+		{"multibyte", strings.Repeat("错", 200), maxFailureLineLength - maxFailureLineLength%3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateLine(tt.line)
+			if len(got) != tt.want || !utf8.ValidString(got) {
+				t.Errorf("truncateLine() returned %d bytes (valid=%v), want %d", len(got), utf8.ValidString(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+﻿package collector
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 每次对账向前多取一段时间，避免两次采集之间的时钟误差导致漏数据
+const gitlabSyncOverlap = 10 * time.Minute
+
+// 定时从 GitLab 拉取流水线写入数据模型，用于补齐 webhook 漏发或乱序的事件，同时计算新合并 MR 的评审数据
+type GitlabCollector struct {
+	store         *store.Store
+	gitlabService gitlab_api.GitlabAPIInterface
+
+	mu       sync.Mutex
+	lastSync map[string]time.Time
+}
+
+func NewGitlabCollector(s *store.Store, gitlabService gitlab_api.GitlabAPIInterface) *GitlabCollector {
+	return &GitlabCollector{
+		store:         s,
+		gitlabService: gitlabService,
+		lastSync:      make(map[string]time.Time),
+	}
+}
+
+// 启动后立即采集一次，之后按 interval 定时采集，直到 ctx 结束
+func (g *GitlabCollector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		g.CollectOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (g *GitlabCollector) CollectOnce(ctx context.Context) {
+	gitlabConfigs, err := config.GetGitlabInstances()
+	if err != nil {
+		log.Errorf("GitLab配置格式错误: %v", err)
+		return
+	}
+
+	for gitlabInstanceName, gitlabConfig := range gitlabConfigs {
+		if err := g.collectInstance(ctx, gitlabInstanceName, gitlabConfig); err != nil {
+			log.Errorf("采集GitLab实例[%s]的流水线失败: %v", gitlabInstanceName, err)
+		}
+	}
+}
+
+func (g *GitlabCollector) collectInstance(ctx context.Context, gitlabInstanceName string, gitlabConfig config.GitlabInstance) error {
+
+	base, err := GetGitlabConfig(gitlabInstanceName, gitlabConfig)
+	if err != nil {
+		return err
+	}
+
+	startedAt := time.Now()
+
+	// 首次采集流水线从当月第一天开始、MR 回溯 mergeRequestLookback，之后只取上次采集以来有更新的数据
+	g.mu.Lock()
+	since, ok := g.lastSync[gitlabInstanceName]
+	g.mu.Unlock()
+	mergedSince := since
+	if ok {
+		since = since.Add(-gitlabSyncOverlap)
+		mergedSince = since
+	} else {
+		since = time.Date(startedAt.Year(), startedAt.Month(), 1, 0, 0, 0, 0, startedAt.Location())
+		mergedSince = startedAt.Add(-mergeRequestLookback)
+	}
+
+	projects, err := GetGitlabProjects(ctx, g.gitlabService, base, gitlabConfig)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(projects))
+
+	for _, project := range projects {
+		wg.Add(1)
+
+		// 启动一个goroutine来采集每个项目的流水线
+		go func(project gitlab_api.GitlabProject) {
+			defer wg.Done()
+
+			pipelines, err := g.gitlabService.ListPipelines(ctx, &gitlab_api.GitlabPipelinesRequest{
+				GitlabBaseRequest: *base,
+				ProjectID:         strconv.Itoa(project.ID),
+				UpdatedAfter:      &since,
+			})
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			for _, pipeline := range pipelines {
+				g.store.UpsertGitlabPipeline(PipelineFromAPI(gitlabInstanceName, project, pipeline))
+			}
+
+			if err := g.collectMergeRequests(ctx, base, project, mergedSince); err != nil {
+				errCh <- err
+			}
+		}(project)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	if err, ok := <-errCh; ok {
+		return err
+	}
+
+	g.mu.Lock()
+	g.lastSync[gitlabInstanceName] = startedAt
+	g.mu.Unlock()
+
+	log.Printf("GitLab实例[%s]流水线与合并请求对账完成, 共%d个项目, 耗时%v", gitlabInstanceName, len(projects), time.Since(startedAt))
+	return nil
+}
+
+// 将 API 返回的流水线转换为数据模型，API 返回的 updated_at 是权威的状态时间
+func PipelineFromAPI(gitlabInstanceName string, project gitlab_api.GitlabProject, pipeline gitlab_api.GitlabPipeline) store.GitlabPipeline {
+	return store.GitlabPipeline{
+		GitlabInstanceName: gitlabInstanceName,
+		ProjectID:          project.ID,
+		ProjectPath:        project.PathWithNamespace,
+		PipelineID:         pipeline.ID,
+		Ref:                pipeline.Ref,
+		SHA:                pipeline.SHA,
+		Source:             pipeline.Source,
+		Status:             pipeline.Status,
+		Duration:           pipeline.Duration,
+		CreatedAt:          pipeline.CreatedAt,
+		FinishedAt:         pipeline.FinishedAt,
+		UpdatedAt:          pipeline.UpdatedAt,
+	}
+}
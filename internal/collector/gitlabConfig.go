@@ -0,0 +1,74 @@
+﻿package collector
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+)
+
+func GetGitlabConfig(gitlabInstanceName string, gitlabConfig config.GitlabInstance) (*gitlab_api.GitlabBaseRequest, error) {
+
+	if gitlabConfig.GitlabURL == "" {
+		return nil, errors.New("invalid gitlab config: missing or invalid gitlabURL")
+	}
+
+	if gitlabConfig.Token == "" {
+		return nil, errors.New("invalid gitlab config: missing or invalid token")
+	}
+
+	return &gitlab_api.GitlabBaseRequest{
+		GitlabName: gitlabInstanceName,
+		GitlabURL:  gitlabConfig.GitlabURL,
+		Token:      gitlabConfig.Token,
+		TokenType:  gitlabConfig.TokenType,
+	}, nil
+}
+
+// 按配置的 projects、groups 解析出需要采集的项目，两者均未配置时返回 token 所属成员的全部项目
+func GetGitlabProjects(c context.Context, gitlabService gitlab_api.GitlabAPIInterface, base *gitlab_api.GitlabBaseRequest, gitlabConfig config.GitlabInstance) ([]gitlab_api.GitlabProject, error) {
+
+	if len(gitlabConfig.Projects) == 0 && len(gitlabConfig.Groups) == 0 {
+		return gitlabService.ListProjects(c, &gitlab_api.GitlabProjectsRequest{
+			GitlabBaseRequest: *base,
+			Membership:        true,
+		})
+	}
+
+	seen := make(map[int]struct{})
+	var projects []gitlab_api.GitlabProject
+
+	for _, projectID := range gitlabConfig.Projects {
+		project, err := gitlabService.GetProject(c, &gitlab_api.GitlabProjectRequest{
+			GitlabBaseRequest: *base,
+			ProjectID:         projectID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[project.ID]; !ok {
+			seen[project.ID] = struct{}{}
+			projects = append(projects, *project)
+		}
+	}
+
+	for _, groupID := range gitlabConfig.Groups {
+		groupProjects, err := gitlabService.ListProjects(c, &gitlab_api.GitlabProjectsRequest{
+			GitlabBaseRequest: *base,
+			GroupID:           groupID,
+			IncludeSubgroups:  true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range groupProjects {
+			if _, ok := seen[project.ID]; !ok {
+				seen[project.ID] = struct{}{}
+				projects = append(projects, project)
+			}
+		}
+	}
+
+	return projects, nil
+}
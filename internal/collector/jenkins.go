@@ -0,0 +1,516 @@
+﻿package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/impl"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 已结束的构建最多尝试获取完整详情的次数，之后按已获取的部分保存，不再重试
+const maxDetailAttempts = 5
+
+// 定时轮询 Jenkins 的构建历史写入数据模型，同时补齐 Notification 插件推送时漏发的事件
+type JenkinsCollector struct {
+	store          *store.Store
+	jenkinsService *impl.JenkinsServiceImpl
+	// 每轮采集开始时根据配置重新编译
+	classifier *failureClassifier
+	// 每轮采集结束后调用，如告警规则评估
+	onCollected []func(time.Time)
+}
+
+// Jenkins 中的一个 job，Name 为包含文件夹路径的完整名称
+type jenkinsJob struct {
+	Name string
+	URL  string
+}
+
+func NewJenkinsCollector(s *store.Store, jenkinsService *impl.JenkinsServiceImpl) *JenkinsCollector {
+	return &JenkinsCollector{
+		store:          s,
+		jenkinsService: jenkinsService,
+	}
+}
+
+// 启动后立即采集一次，之后按 interval 定时采集，直到 ctx 结束
+func (j *JenkinsCollector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		j.CollectOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *JenkinsCollector) CollectOnce(ctx context.Context) {
+	// 配置错误的实例不影响其他实例的采集
+	jenkinsConfigs, invalid := LoadJenkinsInstances()
+	for jenkinsInstanceName, err := range invalid {
+		log.Errorf("Jenkins实例[%s]配置格式错误: %v", jenkinsInstanceName, err)
+	}
+
+	classification, err := config.GetFailureClassification()
+	if err != nil {
+		// 分类配置错误不影响构建采集
+		log.Errorf("失败分类配置格式错误，使用默认规则: %v", err)
+		classification = config.DefaultFailureClassification()
+	}
+	j.classifier = newFailureClassifier(classification)
+
+	for jenkinsInstanceName, jobsConfig := range jenkinsConfigs {
+		if err := j.collectInstance(ctx, jenkinsInstanceName, jobsConfig); err != nil {
+			log.Errorf("采集Jenkins实例[%s]的构建失败: %v", jenkinsInstanceName, err)
+		}
+	}
+
+	now := time.Now()
+	for _, fn := range j.onCollected {
+		fn(now)
+	}
+}
+
+// 注册每轮采集结束后的回调，需要在 Run 之前调用
+func (j *JenkinsCollector) OnCollected(fn func(time.Time)) {
+	j.onCollected = append(j.onCollected, fn)
+}
+
+func (j *JenkinsCollector) collectInstance(ctx context.Context, jenkinsInstanceName string, jobsConfig *jenkins_api.JenkinsJobsRequest) error {
+
+	startedAt := time.Now()
+
+	// 先采集队列，这样本轮采集到的新构建可以关联到刚离开队列的队列项
+	if err := j.collectQueue(ctx, jenkinsInstanceName, jobsConfig); err != nil {
+		log.Warningf("采集Jenkins实例[%s]的构建队列失败: %v", jenkinsInstanceName, err)
+	}
+
+	if err := j.collectNodes(ctx, jenkinsInstanceName, jobsConfig); err != nil {
+		log.Warningf("采集Jenkins实例[%s]的节点信息失败: %v", jenkinsInstanceName, err)
+	}
+
+	log.Printf("正在从Jenkins实例[%s]中获取Jobs信息...", jenkinsInstanceName)
+	jobs, err := j.listJobs(ctx, jobsConfig, jobsConfig.JenkinsURL, "")
+	if err != nil {
+		j.store.RecordJenkinsCollection(jenkinsInstanceName, store.JenkinsCollectionResult{
+			StartedAt: startedAt,
+			Duration:  time.Since(startedAt),
+			Error:     err.Error(),
+		})
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(jobs))
+
+	for _, job := range jobs {
+		wg.Add(1)
+
+		// 启动一个goroutine来处理每个job
+		go func(job jenkinsJob) {
+			defer wg.Done()
+
+			if err := j.collectJob(ctx, jenkinsInstanceName, jobsConfig, job); err != nil {
+				errCh <- err
+			}
+		}(job)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	// 单个 job 采集失败不影响其他 job，下一轮采集时会重试
+	failed := 0
+	for err := range errCh {
+		failed++
+		log.Warningf("采集Jenkins实例[%s]的job失败: %v", jenkinsInstanceName, err)
+	}
+
+	j.store.RecordJenkinsCollection(jenkinsInstanceName, store.JenkinsCollectionResult{
+		StartedAt:      startedAt,
+		Duration:       time.Since(startedAt),
+		JobCount:       len(jobs),
+		FailedJobCount: failed,
+	})
+
+	log.Printf("Jenkins实例[%s]采集完成, 共%d个job, 失败%d个, 耗时%v", jenkinsInstanceName, len(jobs), failed, time.Since(startedAt))
+	return nil
+}
+
+// 采集实例当前的构建队列
+func (j *JenkinsCollector) collectQueue(ctx context.Context, jenkinsInstanceName string, jobsConfig *jenkins_api.JenkinsJobsRequest) error {
+
+	queue, err := j.jenkinsService.GetQueue(ctx, &jenkins_api.JenkinsQueueRequest{
+		JenkinsURL: jobsConfig.JenkinsURL,
+		Username:   jobsConfig.JenkinsBaseRequest.Username,
+		Password:   jobsConfig.JenkinsBaseRequest.Password,
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	items := make([]store.JenkinsQueueItem, 0, len(queue.Items))
+	for _, item := range queue.Items {
+		jobName := jenkins_api.JobNameFromURL(item.Task.URL)
+		if jobName == "" {
+			jobName = item.Task.Name
+		}
+		items = append(items, store.JenkinsQueueItem{
+			QueueID:      item.ID,
+			JobName:      jobName,
+			JobURL:       item.Task.URL,
+			Why:          item.Why,
+			Label:        jenkins_api.QueueItemLabel(item.Why),
+			Blocked:      item.Blocked,
+			Buildable:    item.Buildable,
+			Stuck:        item.Stuck,
+			InQueueSince: item.InQueueSince,
+			LastSeenAt:   now,
+		})
+	}
+
+	j.store.ReplaceJenkinsQueue(jenkinsInstanceName, items)
+	return nil
+}
+
+// 采集实例的节点与执行器使用情况
+func (j *JenkinsCollector) collectNodes(ctx context.Context, jenkinsInstanceName string, jobsConfig *jenkins_api.JenkinsJobsRequest) error {
+
+	computers, err := j.jenkinsService.GetComputers(ctx, &jenkins_api.JenkinsComputerRequest{
+		JenkinsURL: jobsConfig.JenkinsURL,
+		Username:   jobsConfig.JenkinsBaseRequest.Username,
+		Password:   jobsConfig.JenkinsBaseRequest.Password,
+	})
+	if err != nil {
+		return err
+	}
+
+	nodes := make([]store.JenkinsNode, 0, len(computers.Computer))
+	for _, computer := range computers.Computer {
+		nodes = append(nodes, store.JenkinsNode{
+			Name:               computer.DisplayName,
+			Labels:             jenkins_api.ComputerLabels(computer),
+			Offline:            computer.Offline,
+			TemporarilyOffline: computer.TemporarilyOffline,
+			OfflineReason:      computer.OfflineCauseReason,
+			NumExecutors:       computer.NumExecutors,
+			BusyExecutors:      jenkins_api.BusyExecutors(computer),
+			DiskSpace:          jenkins_api.GetDiskSpace(computer.MonitorData),
+			ResponseTime:       jenkins_api.GetResponseTime(computer.MonitorData),
+		})
+	}
+
+	j.store.ReplaceJenkinsNodes(jenkinsInstanceName, nodes, time.Now())
+	return nil
+}
+
+// 文件夹、多分支流水线等容器类型的 job 下面还有子 job
+func isFolder(class string) bool {
+	return strings.HasSuffix(class, "Folder") || strings.HasSuffix(class, "MultiBranchProject")
+}
+
+// 递归列出文件夹下的全部 job
+func (j *JenkinsCollector) listJobs(ctx context.Context, jobsConfig *jenkins_api.JenkinsJobsRequest, folderURL, prefix string) ([]jenkinsJob, error) {
+
+	jobs, err := j.jenkinsService.GetAllJobs(ctx, &jenkins_api.JenkinsJobsRequest{
+		JenkinsURL:         strings.TrimSuffix(folderURL, "/"),
+		JenkinsBaseRequest: jobsConfig.JenkinsBaseRequest,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []jenkinsJob
+	for _, job := range jobs.Jobs {
+		if job.JobsURL == "" {
+			continue
+		}
+
+		if isFolder(job.Class) {
+			children, err := j.listJobs(ctx, jobsConfig, job.JobsURL, prefix+job.Name+"/")
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, children...)
+			continue
+		}
+
+		result = append(result, jenkinsJob{Name: prefix + job.Name, URL: job.JobsURL})
+	}
+
+	return result, nil
+}
+
+// 获取 job 的构建历史，只为尚未采集完成的构建获取详情。
+// 数据模型中仍在运行、但已不在构建历史中的构建（如结束通知丢失后被新构建挤出历史）单独获取详情
+func (j *JenkinsCollector) collectJob(ctx context.Context, jenkinsInstanceName string, jobsConfig *jenkins_api.JenkinsJobsRequest, job jenkinsJob) error {
+
+	buildsHistory, err := j.jenkinsService.GetBuildsHistory(ctx, &jenkins_api.JenkinsBuildsRequest{
+		JenkinsName: jenkinsInstanceName,
+		JobURL:      job.URL,
+		Username:    jobsConfig.JenkinsBaseRequest.Username,
+		Password:    jobsConfig.JenkinsBaseRequest.Password,
+	})
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[int]bool, len(buildsHistory.Builds))
+	for _, build := range buildsHistory.Builds {
+		listed[build.Number] = true
+		if j.store.IsJenkinsBuildCollected(jenkinsInstanceName, job.Name, build.Number) {
+			continue
+		}
+		j.collectBuild(ctx, jenkinsInstanceName, jobsConfig, job, build.URL)
+	}
+
+	for _, build := range j.store.RunningJenkinsBuilds(jenkinsInstanceName, job.Name) {
+		if listed[build.Number] || build.URL == "" {
+			continue
+		}
+		j.collectBuild(ctx, jenkinsInstanceName, jobsConfig, job, build.URL)
+	}
+
+	return nil
+}
+
+// 获取单个构建的详情、阶段、测试报告与失败原因并写入数据模型。
+// 获取失败不影响同一 job 的其它构建，下次采集时重试，最多尝试 maxDetailAttempts 次
+func (j *JenkinsCollector) collectBuild(ctx context.Context, jenkinsInstanceName string, jobsConfig *jenkins_api.JenkinsJobsRequest, job jenkinsJob, buildURL string) {
+
+	run, err := j.jenkinsService.GetBuildDetail(ctx, &jenkins_api.JenkinsBuildDetailRequest{
+		BuildURL: buildURL,
+		Username: jobsConfig.JenkinsBaseRequest.Username,
+		Password: jobsConfig.JenkinsBaseRequest.Password,
+	})
+	if err != nil {
+		log.Warningf("获取构建[%s]的详情失败: %v", buildURL, err)
+		return
+	}
+
+	build := buildFromRun(jenkinsInstanceName, job, run)
+	var testRuns []store.JenkinsTestCaseRun
+	if !run.Building {
+		if run.Class == jenkins_api.WorkflowRunClass {
+			stages, err := j.collectStages(ctx, jobsConfig, job, run)
+			if err != nil {
+				// 阶段信息获取失败时不标记为已采集完成，下次采集时重试
+				log.Warningf("获取构建[%s]的阶段信息失败: %v", run.URL, err)
+				build.Detailed = false
+			}
+			build.Stages = stages
+		}
+		tests, runs, err := j.collectTests(ctx, jobsConfig, run)
+		if err != nil {
+			// 测试报告暂时获取不到时不标记为已采集完成，下次采集时重试
+			log.Warningf("获取构建[%s]的测试报告失败: %v", run.URL, err)
+			build.Detailed = false
+		}
+		build.Tests, testRuns = tests, runs
+		if isFailedResult(run.Result) {
+			category, line, err := j.classifyFailure(ctx, jobsConfig, run)
+			if err != nil {
+				// 日志获取失败时不分类，也不标记为已采集完成，下次采集时重试
+				log.Warningf("获取构建[%s]的控制台日志失败: %v", run.URL, err)
+				build.Detailed = false
+			}
+			build.FailureCategory, build.FailureLine = category, line
+		}
+		if !build.Detailed {
+			build.DetailAttempts = j.store.JenkinsBuildDetailAttempts(jenkinsInstanceName, job.Name, run.Number) + 1
+			if build.DetailAttempts >= maxDetailAttempts {
+				log.Warningf("构建[%s]的详情连续%d次未能获取完整，不再重试", run.URL, build.DetailAttempts)
+				build.Detailed = true
+			}
+		}
+	}
+	if j.store.UpsertJenkinsBuild(build) && len(testRuns) > 0 {
+		j.store.AddJenkinsTestResults(build, testRuns)
+	}
+}
+
+// 获取流水线构建的阶段信息，优先使用 wfapi，不可用时使用 Blue Ocean 接口。
+// 两者都不可用时返回错误，由调用方决定是否重试
+func (j *JenkinsCollector) collectStages(ctx context.Context, jobsConfig *jenkins_api.JenkinsJobsRequest, job jenkinsJob, run *jenkins_api.JenkinsRun) ([]store.JenkinsStage, error) {
+
+	var stages []jenkins_api.JenkinsPipelineStage
+
+	pipelineRun, err := j.jenkinsService.GetPipelineStages(ctx, &jenkins_api.JenkinsPipelineStagesRequest{
+		BuildURL: run.URL,
+		Username: jobsConfig.JenkinsBaseRequest.Username,
+		Password: jobsConfig.JenkinsBaseRequest.Password,
+	})
+	if err == nil {
+		stages = pipelineRun.Stages
+	} else {
+		nodes, blueOceanErr := j.jenkinsService.GetBlueOceanNodes(ctx, &jenkins_api.JenkinsBlueOceanNodesRequest{
+			JenkinsURL: jobsConfig.JenkinsURL,
+			JobName:    job.Name,
+			Number:     run.Number,
+			Username:   jobsConfig.JenkinsBaseRequest.Username,
+			Password:   jobsConfig.JenkinsBaseRequest.Password,
+		})
+		if blueOceanErr != nil {
+			return nil, fmt.Errorf("wfapi: %v, blue ocean: %v", err, blueOceanErr)
+		}
+		stages = jenkins_api.StagesFromBlueOcean(nodes)
+	}
+
+	result := make([]store.JenkinsStage, 0, len(stages))
+	for _, stage := range stages {
+		result = append(result, store.JenkinsStage{
+			Name:          stage.Name,
+			Status:        stage.Status,
+			StartTime:     stage.StartTimeMillis,
+			Duration:      stage.DurationMillis,
+			PauseDuration: stage.PauseDurationMillis,
+		})
+	}
+	return result, nil
+}
+
+// 获取构建的测试报告，返回汇总信息与每个测试用例的结果；没有测试报告时返回 nil。
+// 网络错误或 5xx 时返回错误，由调用方决定是否重试，其它错误重试也不会成功，按没有测试报告处理
+func (j *JenkinsCollector) collectTests(ctx context.Context, jobsConfig *jenkins_api.JenkinsJobsRequest, run *jenkins_api.JenkinsRun) (*store.JenkinsTestSummary, []store.JenkinsTestCaseRun, error) {
+
+	report, err := j.jenkinsService.GetTestReport(ctx, &jenkins_api.JenkinsTestReportRequest{
+		BuildURL: run.URL,
+		Username: jobsConfig.JenkinsBaseRequest.Username,
+		Password: jobsConfig.JenkinsBaseRequest.Password,
+	})
+	if err != nil {
+		if impl.Retryable(err) {
+			return nil, nil, err
+		}
+		log.Warningf("获取构建[%s]的测试报告失败: %v", run.URL, err)
+		return nil, nil, nil
+	}
+	if report == nil {
+		return nil, nil, nil
+	}
+
+	summary, runs := testResultsFromReport(report)
+	return summary, runs, nil
+}
+
+// 汇总测试报告中各状态的用例数，并展开每个测试用例的结果
+func testResultsFromReport(report *jenkins_api.JenkinsTestReport) (*store.JenkinsTestSummary, []store.JenkinsTestCaseRun) {
+
+	summary := &store.JenkinsTestSummary{Duration: report.Duration}
+	cases := jenkins_api.AllTestCases(report)
+	runs := make([]store.JenkinsTestCaseRun, 0, len(cases))
+	for _, testCase := range cases {
+		status := jenkins_api.NormalizeTestStatus(testCase.Status)
+		switch status {
+		case jenkins_api.TestPassed:
+			summary.Passed++
+		case jenkins_api.TestFailed:
+			summary.Failed++
+		default:
+			summary.Skipped++
+		}
+		runs = append(runs, store.JenkinsTestCaseRun{
+			ClassName: testCase.ClassName,
+			Name:      testCase.Name,
+			Status:    status,
+			Duration:  testCase.Duration,
+		})
+	}
+	summary.Total = summary.Passed + summary.Failed + summary.Skipped
+
+	// 聚合报告的 suites 为空时只能使用报告给出的计数
+	if summary.Total == 0 {
+		summary.Passed = report.PassCount
+		summary.Failed = report.FailCount
+		summary.Skipped = report.SkipCount
+		summary.Total = report.PassCount + report.FailCount + report.SkipCount
+	}
+
+	return summary, runs
+}
+
+// 需要分析失败原因的构建结果
+func isFailedResult(result string) bool {
+	return result == "FAILURE" || result == "UNSTABLE" || result == "ABORTED"
+}
+
+// 读取控制台日志末尾并分类
+func (j *JenkinsCollector) classifyFailure(ctx context.Context, jobsConfig *jenkins_api.JenkinsJobsRequest, run *jenkins_api.JenkinsRun) (string, string, error) {
+
+	consoleLog, err := j.jenkinsService.GetConsoleTail(ctx, &jenkins_api.JenkinsConsoleRequest{
+		BuildURL:  run.URL,
+		Username:  jobsConfig.JenkinsBaseRequest.Username,
+		Password:  jobsConfig.JenkinsBaseRequest.Password,
+		TailBytes: j.classifier.tailBytes,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	category, line := j.classifier.classify(consoleLog)
+	return category, line, nil
+}
+
+// 将构建详情转换为数据模型
+func buildFromRun(jenkinsInstanceName string, job jenkinsJob, run *jenkins_api.JenkinsRun) store.JenkinsBuild {
+	build := store.JenkinsBuild{
+		JenkinsInstanceName: jenkinsInstanceName,
+		JobName:             job.Name,
+		JobURL:              job.URL,
+		Number:              run.Number,
+		URL:                 run.URL,
+		DisplayName:         run.DisplayName,
+		Building:            run.Building,
+		Result:              run.Result,
+		Timestamp:           run.Timestamp,
+		Duration:            run.Duration,
+		EstimatedDuration:   run.EstimatedDuration,
+		QueueID:             run.QueueID,
+		BuiltOn:             run.BuiltOn,
+		Source:              store.SourcePoll,
+		Detailed:            true,
+		UpdatedAt:           time.Now(),
+	}
+
+	// 安装了 Metrics 插件时直接使用插件记录的排队耗时，否则由数据模型根据队列项计算
+	if timeInQueue := jenkins_api.GetTimeInQueue(run.Actions); timeInQueue != nil {
+		build.QueueDuration = int(timeInQueue.QueuingDurationMillis)
+		build.QueueSource = store.QueueSourceMetrics
+	}
+
+	causes := jenkins_api.GetCauses(run.Actions)
+	if trigger, cause := jenkins_api.PrimaryCause(causes); cause != nil {
+		build.TriggerType = trigger
+		build.TriggeredBy = jenkins_api.CauseUserID(causes)
+		build.UpstreamJob = cause.UpstreamProject
+		build.UpstreamBuild = cause.UpstreamBuild
+	}
+
+	build.Branch = jenkins_api.GetBuildBranch(run.Actions)
+	if parameters := jenkins_api.GetParameters(run.Actions); len(parameters) > 0 {
+		build.Parameters = parameters
+	}
+
+	if sha, remoteUrls := jenkins_api.GetBuildRevision(run.Actions); sha != "" {
+		build.Commit = sha
+		if len(remoteUrls) > 0 {
+			build.SCMURL = remoteUrls[0]
+		}
+	}
+
+	return build
+}
@@ -0,0 +1,83 @@
+﻿package collector
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+)
+
+func GetJenkinsConfig(jenkinsConfig interface{}) (*jenkins_api.JenkinsJobsRequest, error) {
+
+	// 检查 jenkinsConfig 是否为 map
+	jenkinsConfigMap, ok := jenkinsConfig.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid jenkins config: not a map")
+	}
+
+	jenkinsURL, ok := jenkinsConfigMap["jenkinsurl"].(string)
+	if !ok || jenkinsURL == "" {
+		return nil, errors.New("invalid jenkins config: missing or invalid jenkinsurl")
+	}
+
+	jenkinsUsername, ok := jenkinsConfigMap["username"].(string)
+	if !ok || jenkinsUsername == "" {
+		return nil, errors.New("invalid jenkins config: missing or invalid username")
+	}
+
+	jenkinsPassword, ok := jenkinsConfigMap["password"].(string)
+	if !ok || jenkinsPassword == "" {
+		return nil, errors.New("invalid jenkins config: missing or invalid password")
+	}
+
+	baseRequest := jenkins_api.JenkinsBaseRequest{
+		Username: jenkinsUsername,
+		Password: jenkinsPassword,
+	}
+
+	return &jenkins_api.JenkinsJobsRequest{
+		JenkinsURL:         jenkinsURL,
+		JenkinsBaseRequest: baseRequest,
+	}, nil
+}
+
+// 读取全部 Jenkins 实例配置，gitlab 等保留配置项除外
+func GetJenkinsInstances() (map[string]*jenkins_api.JenkinsJobsRequest, error) {
+	instances, invalid := LoadJenkinsInstances()
+	if len(invalid) == 0 {
+		return instances, nil
+	}
+
+	// 多个实例配置错误时固定返回名称最小的一个
+	names := make([]string, 0, len(invalid))
+	for jenkinsInstanceName := range invalid {
+		names = append(names, jenkinsInstanceName)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("jenkins instance %s: %w", names[0], invalid[names[0]])
+}
+
+// 逐个读取 Jenkins 实例配置，配置错误的实例单独返回，不影响其他实例
+func LoadJenkinsInstances() (map[string]*jenkins_api.JenkinsJobsRequest, map[string]error) {
+	instances := make(map[string]*jenkins_api.JenkinsJobsRequest)
+	invalid := make(map[string]error)
+
+	for jenkinsInstanceName, jenkinsConfig := range viper.AllSettings() {
+		if config.IsReservedKey(jenkinsInstanceName) {
+			continue
+		}
+
+		jobsConfig, err := GetJenkinsConfig(jenkinsConfig)
+		if err != nil {
+			invalid[jenkinsInstanceName] = err
+			continue
+		}
+		jobsConfig.JenkinsBaseRequest.JenkinsName = jenkinsInstanceName
+		instances[jenkinsInstanceName] = jobsConfig
+	}
+
+	return instances, invalid
+}
@@ -0,0 +1,59 @@
+﻿package collector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+func TestTestResultsFromReport(t *testing.T) {
+	testCase := func(name, status string) jenkins_api.JenkinsTestCase {
+		return jenkins_api.JenkinsTestCase{ClassName: "pay.ApiTest", Name: name, Status: status, Duration: 0.5}
+	}
+	run := func(name, status string) store.JenkinsTestCaseRun {
+		return store.JenkinsTestCaseRun{ClassName: "pay.ApiTest", Name: name, Status: status, Duration: 0.5}
+	}
+	suite := func(cases ...jenkins_api.JenkinsTestCase) jenkins_api.JenkinsTestSuite {
+		return jenkins_api.JenkinsTestSuite{Name: "suite", Cases: cases}
+	}
+
+	child := jenkins_api.JenkinsTestReport{Suites: []jenkins_api.JenkinsTestSuite{suite(testCase("child", "FAILED"))}}
+	withChild := jenkins_api.JenkinsTestReport{Duration: 3, Suites: []jenkins_api.JenkinsTestSuite{suite(testCase("parent", "PASSED"))}}
+	withChild.ChildReports = append(withChild.ChildReports, struct {
+		Result jenkins_api.JenkinsTestReport `json:"result"`
+	}{Result: child})
+
+	tests := []struct {
+		name    string
+		report  jenkins_api.JenkinsTestReport
+		summary store.JenkinsTestSummary
+		runs    []store.JenkinsTestCaseRun
+	}{
+		{"statuses", jenkins_api.JenkinsTestReport{Duration: 2, Suites: []jenkins_api.JenkinsTestSuite{
+			suite(testCase("a", "PASSED"), testCase("b", "FIXED"), testCase("c", "FAILED")),
+			suite(testCase("d", "REGRESSION"), testCase("e", "SKIPPED")),
+		}}, store.JenkinsTestSummary{Total: 5, Passed: 2, Failed: 2, Skipped: 1, Duration: 2}, []store.JenkinsTestCaseRun{
+			run("a", jenkins_api.TestPassed), run("b", jenkins_api.TestPassed), run("c", jenkins_api.TestFailed),
+			run("d", jenkins_api.TestFailed), run("e", jenkins_api.TestSkipped),
+		}},
+		{"child reports", withChild, store.JenkinsTestSummary{Total: 2, Passed: 1, Failed: 1, Duration: 3}, []store.JenkinsTestCaseRun{
+			run("parent", jenkins_api.TestPassed), run("child", jenkins_api.TestFailed),
+		}},
+		{"report counts without suites", jenkins_api.JenkinsTestReport{PassCount: 7, FailCount: 2, SkipCount: 1, Duration: 4},
+			store.JenkinsTestSummary{Total: 10, Passed: 7, Failed: 2, Skipped: 1, Duration: 4}, []store.JenkinsTestCaseRun{}},
+		{"empty report", jenkins_api.JenkinsTestReport{}, store.JenkinsTestSummary{}, []store.JenkinsTestCaseRun{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, runs := testResultsFromReport(&tt.report)
+			if *summary != tt.summary {
+				t.Errorf("summary = %+v, want %+v", *summary, tt.summary)
+			}
+			if !reflect.DeepEqual(runs, tt.runs) {
+				t.Errorf("runs = %+v, want %+v", runs, tt.runs)
+			}
+		})
+	}
+}
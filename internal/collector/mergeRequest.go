@@ -0,0 +1,126 @@
+﻿package collector
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+	"github.com/zuoyangs/go-devops-observability/utils"
+)
+
+// 首次采集时回溯的 MR 合并时间范围，评审效率指标最多能统计到这么久以前
+const mergeRequestLookback = 12 * 7 * 24 * time.Hour
+
+// 采集 since 之后合并的 MR 的评审数据。已合并 MR 的评审数据不再变化，已经计算过的 MR 不再重复获取评论与 diff
+func (g *GitlabCollector) collectMergeRequests(ctx context.Context, base *gitlab_api.GitlabBaseRequest, project gitlab_api.GitlabProject, since time.Time) error {
+
+	projectID := strconv.Itoa(project.ID)
+	mergeRequests, err := g.gitlabService.ListMergeRequests(ctx, &gitlab_api.GitlabMergeRequestsRequest{
+		GitlabBaseRequest: *base,
+		ProjectID:         projectID,
+		State:             "merged",
+		UpdatedAfter:      &since,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, mr := range mergeRequests {
+		if mr.MergedAt == nil || g.store.HasGitlabMergeRequestReview(base.GitlabName, project.ID, mr.IID) {
+			continue
+		}
+
+		notes, err := g.gitlabService.ListMergeRequestNotes(ctx, &gitlab_api.GitlabMergeRequestNotesRequest{
+			GitlabBaseRequest: *base,
+			ProjectID:         projectID,
+			MergeRequestIID:   mr.IID,
+		})
+		if err != nil {
+			return err
+		}
+
+		diffs, err := g.gitlabService.ListMergeRequestDiffs(ctx, &gitlab_api.GitlabMergeRequestDiffsRequest{
+			GitlabBaseRequest: *base,
+			ProjectID:         projectID,
+			MergeRequestIID:   mr.IID,
+		})
+		if err != nil {
+			return err
+		}
+
+		review := calculateMergeRequestCycleTime(mr, notes, diffs)
+		review.GitlabInstanceName = base.GitlabName
+		review.ProjectID = project.ID
+		review.ProjectPath = project.PathWithNamespace
+		g.store.AddGitlabMergeRequestReview(review)
+	}
+
+	return nil
+}
+
+func isApprovalNote(note gitlab_api.GitlabNote) bool {
+	return note.System && strings.HasPrefix(note.Body, "approved this merge request")
+}
+
+func isPushNote(note gitlab_api.GitlabNote) bool {
+	return note.System && strings.HasPrefix(note.Body, "added ") && strings.Contains(note.Body, "commit")
+}
+
+func hoursBetween(from, to time.Time) float64 {
+	return utils.Round2(to.Sub(from).Hours())
+}
+
+// 计算单个已合并 MR 的评审数据。评审轮次：作者推送新提交后，评审人再次评论或批准即视为新的一轮
+func calculateMergeRequestCycleTime(mr gitlab_api.GitlabMergeRequest, notes []gitlab_api.GitlabNote, diffs []gitlab_api.GitlabDiff) store.GitlabMergeRequestReview {
+
+	item := store.GitlabMergeRequestReview{
+		IID:          mr.IID,
+		Author:       mr.Author.Username,
+		MergedAt:     *mr.MergedAt,
+		TimeToMerge:  hoursBetween(mr.CreatedAt, *mr.MergedAt),
+		FilesChanged: len(diffs),
+	}
+
+	inRound := false
+	for _, note := range notes {
+		reviewed := false
+		switch {
+		case isApprovalNote(note):
+			if item.TimeToApproval == nil {
+				hours := hoursBetween(mr.CreatedAt, note.CreatedAt)
+				item.TimeToApproval = &hours
+			}
+			item.Approved = true
+			reviewed = true
+		case isPushNote(note):
+			inRound = false
+		case !note.System && note.Author.ID != mr.Author.ID:
+			if item.TimeToFirstReview == nil {
+				hours := hoursBetween(mr.CreatedAt, note.CreatedAt)
+				item.TimeToFirstReview = &hours
+			}
+			reviewed = true
+		}
+
+		if reviewed && !inRound {
+			item.ReviewRounds++
+			inRound = true
+		}
+	}
+
+	for _, diff := range diffs {
+		for _, line := range strings.Split(diff.Diff, "\n") {
+			if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+				continue
+			}
+			if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+				item.LinesChanged++
+			}
+		}
+	}
+
+	return item
+}
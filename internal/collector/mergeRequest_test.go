@@ -0,0 +1,92 @@
+﻿package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+)
+
+func TestCalculateMergeRequestCycleTime(t *testing.T) {
+	created := time.Date(2024, 10, 14, 9, 0, 0, 0, time.UTC)
+	at := func(hours float64) time.Time {
+		return created.Add(time.Duration(hours * float64(time.Hour)))
+	}
+	author := gitlab_api.GitlabUser{ID: 1, Username: "alice"}
+	reviewer := gitlab_api.GitlabUser{ID: 2, Username: "bob"}
+	comment := func(user gitlab_api.GitlabUser, hours float64) gitlab_api.GitlabNote {
+		return gitlab_api.GitlabNote{Body: "LGTM?", Author: user, CreatedAt: at(hours)}
+	}
+	system := func(body string, hours float64) gitlab_api.GitlabNote {
+		return gitlab_api.GitlabNote{Body: body, Author: reviewer, System: true, CreatedAt: at(hours)}
+	}
+	hours := func(value float64) *float64 { return &value }
+
+	tests := []struct {
+		name              string
+		notes             []gitlab_api.GitlabNote
+		diffs             []gitlab_api.GitlabDiff
+		timeToFirstReview *float64
+		timeToApproval    *float64
+		rounds            int
+		approved          bool
+		lines             int
+	}{
+		{"no reviews", nil, nil, nil, nil, 0, false, 0},
+		{"author comments are not reviews", []gitlab_api.GitlabNote{comment(author, 1)}, nil, nil, nil, 0, false, 0},
+		{"review then approval in one round", []gitlab_api.GitlabNote{
+			comment(reviewer, 1.5),
+			comment(reviewer, 2),
+			system("approved this merge request", 3),
+		}, nil, hours(1.5), hours(3), 1, true, 0},
+		{"push starts a new round", []gitlab_api.GitlabNote{
+			comment(reviewer, 1),
+			system("added 2 commits\n\n* abc - fix", 2),
+			comment(reviewer, 4),
+			system("added 1 commit\n\n* def - fix", 5),
+			system("approved this merge request", 6),
+		}, nil, hours(1), hours(6), 3, true, 0},
+		{"other system notes are ignored", []gitlab_api.GitlabNote{
+			system("changed the description", 1),
+			system("marked this merge request as ready", 2),
+		}, nil, nil, nil, 0, false, 0},
+		{"lines changed skip file headers", nil, []gitlab_api.GitlabDiff{
+			{NewPath: "a.go", Diff: "--- a/a.go\n+++ b/a.go\n@@ -1,2 +1,2 @@\n-old\n+new\n context"},
+			{NewPath: "b.go", NewFile: true, Diff: "@@ -0,0 +1,2 @@\n+one\n+two"},
+		}, nil, nil, 0, false, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := at(8)
+			mr := gitlab_api.GitlabMergeRequest{IID: 7, Author: author, CreatedAt: created, MergedAt: &merged}
+
+			got := calculateMergeRequestCycleTime(mr, tt.notes, tt.diffs)
+			if got.IID != 7 || got.Author != "alice" || !got.MergedAt.Equal(merged) || got.TimeToMerge != 8 {
+				t.Errorf("unexpected merge request: %+v", got)
+			}
+			if !equalHours(got.TimeToFirstReview, tt.timeToFirstReview) || !equalHours(got.TimeToApproval, tt.timeToApproval) {
+				t.Errorf("timeToFirstReview = %v, timeToApproval = %v, want %v, %v", deref(got.TimeToFirstReview), deref(got.TimeToApproval), deref(tt.timeToFirstReview), deref(tt.timeToApproval))
+			}
+			if got.ReviewRounds != tt.rounds || got.Approved != tt.approved {
+				t.Errorf("rounds = %d, approved = %v, want %d, %v", got.ReviewRounds, got.Approved, tt.rounds, tt.approved)
+			}
+			if got.FilesChanged != len(tt.diffs) || got.LinesChanged != tt.lines {
+				t.Errorf("files = %d, lines = %d, want %d, %d", got.FilesChanged, got.LinesChanged, len(tt.diffs), tt.lines)
+			}
+		})
+	}
+}
+
+func equalHours(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func deref(value *float64) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
@@ -0,0 +1,97 @@
+﻿package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/impl"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 定时探测各 Jenkins 实例的可达性、认证有效性与版本，
+// 使实例配置错误或不可用时能在 /api/v1/instances 中直接看到原因
+type JenkinsProber struct {
+	store          *store.Store
+	jenkinsService *impl.JenkinsServiceImpl
+}
+
+func NewJenkinsProber(s *store.Store, jenkinsService *impl.JenkinsServiceImpl) *JenkinsProber {
+	return &JenkinsProber{
+		store:          s,
+		jenkinsService: jenkinsService,
+	}
+}
+
+// 启动后立即探测一次，之后按 interval 定时探测，直到 ctx 结束
+func (p *JenkinsProber) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.ProbeOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 并发探测全部实例，单个实例超时不影响其他实例
+func (p *JenkinsProber) ProbeOnce(ctx context.Context) {
+	jenkinsConfigs, _ := LoadJenkinsInstances()
+
+	var wg sync.WaitGroup
+	for jenkinsInstanceName, jobsConfig := range jenkinsConfigs {
+		wg.Add(1)
+		go func(jenkinsInstanceName string, jobsConfig *jenkins_api.JenkinsJobsRequest) {
+			defer wg.Done()
+
+			result := p.probe(ctx, jobsConfig)
+			if result.Error != "" {
+				log.Warningf("Jenkins实例[%s]健康检查失败: %s", jenkinsInstanceName, result.Error)
+			}
+			p.store.RecordJenkinsProbe(jenkinsInstanceName, result)
+		}(jenkinsInstanceName, jobsConfig)
+	}
+	wg.Wait()
+}
+
+func (p *JenkinsProber) probe(ctx context.Context, jobsConfig *jenkins_api.JenkinsJobsRequest) store.JenkinsProbeResult {
+
+	result := store.JenkinsProbeResult{
+		JenkinsURL: jobsConfig.JenkinsURL,
+		ProbedAt:   time.Now(),
+	}
+
+	info, err := p.jenkinsService.GetInstanceInfo(ctx, &jenkins_api.JenkinsInstanceInfoRequest{
+		JenkinsURL: jobsConfig.JenkinsURL,
+		Username:   jobsConfig.JenkinsBaseRequest.Username,
+		Password:   jobsConfig.JenkinsBaseRequest.Password,
+	})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Reachable = true
+	result.Version = info.Version
+	switch info.StatusCode {
+	case http.StatusOK:
+		result.AuthValid = true
+	case http.StatusUnauthorized, http.StatusForbidden:
+		result.Error = fmt.Sprintf("authentication failed: status %d", info.StatusCode)
+	default:
+		// 其他错误无法判断认证是否有效，按有效处理，只记录错误
+		result.AuthValid = true
+		result.Error = fmt.Sprintf("unexpected status %d", info.StatusCode)
+	}
+	return result
+}
@@ -0,0 +1,163 @@
+﻿package demo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+)
+
+// 模拟 Jenkins 实例的认证信息
+const (
+	demoUsername = "demo"
+	demoPassword = "demo-password"
+)
+
+// 演示数据生成器：为每个实例启动一个进程内的模拟 Jenkins 并生成构建历史，
+// 再把模拟实例写入配置，由正常的采集器采集，数据与真实采集走同一条链路
+type Generator struct {
+	cfg *config.Demo
+
+	mu      sync.Mutex
+	rnd     *rand.Rand
+	servers map[string]*fake.Server
+	jobs    []*job
+	queueID int
+}
+
+// 生成演示数据并启动模拟 Jenkins，配置中原有的 Jenkins 实例会被替换。使用完毕后需调用 Close
+func Start(cfg *config.Demo) (*Generator, error) {
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+	if cfg.Instances <= 0 || cfg.Folders <= 0 || cfg.JobsPerFolder <= 0 || cfg.Days <= 0 || cfg.BuildsPerDay <= 0 {
+		return nil, errors.New("invalid demo config")
+	}
+
+	g := &Generator{
+		cfg:     cfg,
+		rnd:     rand.New(rand.NewSource(cfg.Seed)),
+		servers: make(map[string]*fake.Server),
+		queueID: 1000,
+	}
+
+	now := time.Now()
+	for i := 1; i <= cfg.Instances; i++ {
+		jenkinsInstanceName := fmt.Sprintf("demo-jenkins-%d", i)
+		server := fake.NewServer()
+		server.SetCredentials(demoUsername, demoPassword)
+		g.servers[jenkinsInstanceName] = server
+
+		g.addJobs(server, i)
+		server.SetComputers(g.computers())
+	}
+
+	for _, j := range g.jobs {
+		g.generateHistory(j, now)
+	}
+	for _, server := range g.servers {
+		server.SetQueue(g.queue(server, now))
+	}
+
+	g.register()
+	log.Printf("已生成演示数据: %d 个 Jenkins 实例, %d 个 job", len(g.servers), len(g.jobs))
+	return g, nil
+}
+
+func (g *Generator) Close() {
+	for _, server := range g.servers {
+		server.Close()
+	}
+}
+
+// 用模拟实例替换配置中的 Jenkins 实例，保留其他配置项。
+// GitLab 实例与通知渠道会访问真实网络并携带凭据，演示模式下一并去掉
+func (g *Generator) register() {
+	settings := viper.AllSettings()
+	viper.Reset()
+	for key, value := range settings {
+		if !config.IsReservedKey(key) || key == strings.ToLower(config.GitlabConfigKey) {
+			continue
+		}
+		if key == strings.ToLower(config.NotifyConfigKey) {
+			if notify, ok := value.(map[string]interface{}); ok {
+				delete(notify, "channels")
+				delete(notify, "routes")
+			}
+		}
+		viper.Set(key, value)
+	}
+
+	for jenkinsInstanceName, server := range g.servers {
+		viper.Set(jenkinsInstanceName, map[string]interface{}{
+			"jenkinsurl": server.URL(),
+			"username":   demoUsername,
+			"password":   demoPassword,
+		})
+	}
+}
+
+// 按 interval 持续产生新的构建，使指标随时间变化，直到 ctx 结束
+func (g *Generator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			g.tick(now, interval)
+		}
+	}
+}
+
+func (g *Generator) tick(now time.Time, interval time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// 上一轮开始的构建在本轮结束，耗时为实际经过的时间
+	for _, j := range g.jobs {
+		if j.running != nil {
+			g.finishBuild(j, j.running, now)
+			j.running = nil
+		}
+	}
+
+	// hourWeight 在一天内的平均值约为 1
+	perInterval := g.cfg.BuildsPerDay * interval.Hours() / 24
+	for _, j := range g.jobs {
+		if j.kind == kindNightly || j.running != nil {
+			continue
+		}
+		if g.rnd.Float64() < perInterval*hourWeight(now)*workdayWeight(now) {
+			build := g.newBuild(j, now)
+			build.Building = true
+			build.Result = ""
+			j.server.AddBuild(j.name, build)
+			j.running = &build
+		}
+	}
+
+	for _, server := range g.servers {
+		server.SetQueue(g.queue(server, now))
+	}
+}
+
+func (g *Generator) finishBuild(j *job, build *fake.Build, now time.Time) {
+	build.Building = false
+	g.complete(j, build)
+	if elapsed := now.Sub(build.Timestamp); elapsed < build.Duration {
+		build.Duration = elapsed
+	}
+	j.server.AddBuild(j.name, *build)
+}
@@ -0,0 +1,430 @@
+﻿package demo
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+)
+
+// job 的类型，决定触发方式、分支、参数与构建时间的分布
+const (
+	// 代码提交触发的构建流水线
+	kindBuild = "build"
+	// 手动触发的参数化部署
+	kindDeploy = "deploy"
+	// 每天凌晨定时执行
+	kindNightly = "nightly"
+	// 构建成功后由上游触发的集成测试
+	kindIntegration = "integration-test"
+)
+
+var jobKinds = []string{kindBuild, kindDeploy, kindIntegration, kindNightly}
+
+var (
+	folderNames  = []string{"payment", "order", "user", "search", "gateway", "data", "mobile", "infra"}
+	serviceNames = []string{"api", "web", "worker", "admin", "scheduler", "sync"}
+	users        = []string{"zhangsan", "lisi", "wangwu", "zhaoliu", "sunqi", "zhouba"}
+	features     = []string{"login", "refund", "coupon", "export", "cache", "search-v2", "retry", "i18n"}
+	environments = []string{"test", "test", "staging", "prod"}
+)
+
+// 失败构建的控制台日志末尾，与默认的失败分类规则对应；最后一项不匹配任何规则
+var failureLogs = []string{
+	"[ERROR] COMPILATION ERROR :\n[ERROR] /src/main/java/App.java:[42,17] error: cannot find symbol",
+	"Tests run: 128, Failures: 3, Errors: 0, Skipped: 2\n[ERROR] There are test failures.",
+	"java.lang.OutOfMemoryError: Java heap space\nprocess exited with exit code 137",
+	"[ERROR] Failed to execute goal: Could not resolve dependencies for project: Read timed out",
+	"Error response from daemon: error pulling image: toomanyrequests: You have reached your pull rate limit",
+	"hudson.remoting.ChannelClosedException: Channel \"hudson.remoting.Channel@1a2b\": Remote call failed",
+	"script returned exit code 2",
+}
+
+type job struct {
+	server   *fake.Server
+	folder   string
+	name     string
+	service  string
+	kind     string
+	pipeline bool
+
+	// 正常情况下的成功率与平均耗时
+	successRate  float64
+	meanDuration time.Duration
+	// 上一次构建是否失败，用于生成连续失败
+	failing bool
+	number  int
+	// Run 中尚未结束的构建
+	running *fake.Build
+
+	tests []string
+}
+
+func (g *Generator) addJobs(server *fake.Server, instance int) {
+	for f := 0; f < g.cfg.Folders; f++ {
+		folder := folderNames[(instance*g.cfg.Folders+f)%len(folderNames)]
+		if (instance*g.cfg.Folders+f)/len(folderNames) > 0 {
+			folder = fmt.Sprintf("%s-%d", folder, (instance*g.cfg.Folders+f)/len(folderNames))
+		}
+		server.AddFolder(folder)
+
+		for i := 0; i < g.cfg.JobsPerFolder; i++ {
+			j := &job{
+				server:       server,
+				folder:       folder,
+				service:      serviceNames[(i/len(jobKinds))%len(serviceNames)],
+				kind:         jobKinds[i%len(jobKinds)],
+				successRate:  0.7 + g.rnd.Float64()*0.28,
+				meanDuration: time.Duration(60+g.rnd.Intn(20*60)) * time.Second,
+			}
+			j.name = fmt.Sprintf("%s/%s-%s", folder, j.service, j.kind)
+			j.pipeline = j.kind != kindNightly
+			if j.pipeline {
+				server.AddPipeline(j.name)
+			} else {
+				server.AddJob(j.name)
+			}
+			if j.kind == kindBuild || j.kind == kindIntegration {
+				for t := 0; t < 10+g.rnd.Intn(30); t++ {
+					j.tests = append(j.tests, fmt.Sprintf("Test%s%d", features[t%len(features)], t))
+				}
+			}
+			g.jobs = append(g.jobs, j)
+		}
+	}
+}
+
+// 按天生成构建历史：工作日的构建集中在上下午的工作时间，周末很少；定时任务每天凌晨执行一次
+func (g *Generator) generateHistory(j *job, now time.Time) {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -g.cfg.Days)
+
+	for day := start; day.Before(now); day = day.AddDate(0, 0, 1) {
+		var times []time.Time
+		if j.kind == kindNightly {
+			times = append(times, day.Add(2*time.Hour+time.Duration(g.rnd.Intn(600))*time.Second))
+		} else {
+			count := g.poisson(g.cfg.BuildsPerDay * workdayWeight(day))
+			for i := 0; i < count; i++ {
+				times = append(times, day.Add(g.workingTime()))
+			}
+		}
+		sortTimes(times)
+
+		for _, at := range times {
+			if at.After(now) {
+				continue
+			}
+			build := g.newBuild(j, at)
+			g.complete(j, &build)
+			// 还没结束的构建保持构建中，由 Run 在下一轮结束
+			if at.Add(build.Duration).After(now) {
+				build.Building = true
+				j.running = &build
+			}
+			j.server.AddBuild(j.name, build)
+		}
+	}
+}
+
+func sortTimes(times []time.Time) {
+	for i := 1; i < len(times); i++ {
+		for k := i; k > 0 && times[k].Before(times[k-1]); k-- {
+			times[k], times[k-1] = times[k-1], times[k]
+		}
+	}
+}
+
+// 一天中的构建时间，上午 10 点与下午 4 点前后最多，偶尔有深夜构建
+func (g *Generator) workingTime() time.Duration {
+	var hours float64
+	switch r := g.rnd.Float64(); {
+	case r < 0.05:
+		hours = g.rnd.Float64() * 24
+	case r < 0.45:
+		hours = 10 + g.rnd.NormFloat64()*1.2
+	default:
+		hours = 16 + g.rnd.NormFloat64()*1.8
+	}
+	hours = math.Min(math.Max(hours, 0), 23.99)
+	return time.Duration(hours * float64(time.Hour))
+}
+
+// 当前时刻产生构建的相对权重，用于持续生成构建
+func hourWeight(t time.Time) float64 {
+	switch hour := t.Hour(); {
+	case hour >= 9 && hour < 12, hour >= 14 && hour < 19:
+		return 2
+	case hour >= 12 && hour < 14, hour >= 19 && hour < 22:
+		return 0.8
+	default:
+		return 0.1
+	}
+}
+
+func workdayWeight(t time.Time) float64 {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return 0.15
+	}
+	return 1
+}
+
+func (g *Generator) poisson(mean float64) int {
+	limit := math.Exp(-mean)
+	count, p := 0, g.rnd.Float64()
+	for p > limit {
+		count++
+		p *= g.rnd.Float64()
+	}
+	return count
+}
+
+func (g *Generator) commit() string {
+	return fmt.Sprintf("%08x%08x%08x%08x%08x", g.rnd.Uint32(), g.rnd.Uint32(), g.rnd.Uint32(), g.rnd.Uint32(), g.rnd.Uint32())
+}
+
+// 生成构建的触发信息、分支与参数，结果由 complete 生成
+func (g *Generator) newBuild(j *job, at time.Time) fake.Build {
+	j.number++
+	g.queueID++
+
+	build := fake.Build{
+		Number:        j.number,
+		Timestamp:     at,
+		QueueID:       g.queueID,
+		BuiltOn:       fmt.Sprintf("linux-%d", 1+g.rnd.Intn(4)),
+		Commit:        g.commit(),
+		SCMURL:        fmt.Sprintf("https://gitlab.example.com/%s/%s.git", j.folder, j.service),
+		QueueDuration: time.Duration(g.rnd.ExpFloat64()*20*hourWeight(at)) * time.Second,
+	}
+
+	switch j.kind {
+	case kindBuild:
+		switch r := g.rnd.Float64(); {
+		case r < 0.4:
+			build.Branch = "main"
+		case r < 0.6:
+			build.Branch = "develop"
+		case r < 0.9:
+			build.Branch = "feature/" + features[g.rnd.Intn(len(features))]
+		case r < 0.97:
+			build.Branch = fmt.Sprintf("release/1.%d", 10+g.rnd.Intn(5))
+		default:
+			build.Branch = "hotfix/" + features[g.rnd.Intn(len(features))]
+		}
+		if g.rnd.Float64() < 0.8 {
+			build.Causes = []jenkins_api.Cause{fake.SCMCause()}
+		} else {
+			build.Causes = []jenkins_api.Cause{fake.UserCause(users[g.rnd.Intn(len(users))])}
+		}
+	case kindDeploy:
+		build.Branch = "main"
+		if g.rnd.Float64() < 0.3 {
+			build.Branch = fmt.Sprintf("release/1.%d", 10+g.rnd.Intn(5))
+		}
+		build.Causes = []jenkins_api.Cause{fake.UserCause(users[g.rnd.Intn(len(users))])}
+		build.Parameters = map[string]string{
+			"ENV":      environments[g.rnd.Intn(len(environments))],
+			"APP_NAME": j.service,
+			"VERSION":  fmt.Sprintf("1.%d.%d", 10+g.rnd.Intn(5), g.rnd.Intn(20)),
+		}
+	case kindIntegration:
+		build.Branch = "main"
+		upstream := fmt.Sprintf("%s/%s-%s", j.folder, j.service, kindBuild)
+		build.Causes = []jenkins_api.Cause{fake.UpstreamCause(upstream, 1+g.rnd.Intn(j.number+1))}
+	case kindNightly:
+		build.Branch = "main"
+		build.Causes = []jenkins_api.Cause{fake.TimerCause()}
+	}
+	return build
+}
+
+// 生成构建结果：失败后更容易继续失败，形成连续失败；同时生成耗时、日志、阶段与测试报告
+func (g *Generator) complete(j *job, build *fake.Build) {
+	failureRate := 1 - j.successRate
+	if j.failing {
+		failureRate = 0.55
+	}
+
+	failed := g.rnd.Float64() < failureRate
+	j.failing = failed
+
+	build.Result = "SUCCESS"
+	scale := math.Exp(g.rnd.NormFloat64() * 0.3)
+	build.Duration = time.Duration(float64(j.meanDuration) * scale)
+
+	failureLog := ""
+	if failed {
+		build.Result = "FAILURE"
+		build.Duration = build.Duration * 6 / 10
+		switch r := g.rnd.Float64(); {
+		case r < 0.05:
+			build.Result = "ABORTED"
+			failureLog = "Aborted by " + users[g.rnd.Intn(len(users))]
+		default:
+			failureLog = failureLogs[g.rnd.Intn(len(failureLogs))]
+		}
+	}
+	if j.pipeline {
+		build.Stages = g.stages(j, build)
+	}
+	if len(j.tests) > 0 && build.Result != "ABORTED" {
+		build.TestReport = g.testReport(j, build, failureLog == failureLogs[1])
+		// 只有不稳定测试失败时构建标记为 UNSTABLE
+		if build.TestReport.FailCount > 0 && build.Result == "SUCCESS" {
+			build.Result = "UNSTABLE"
+			failureLog = fmt.Sprintf("Tests run: %d, Failures: %d, Errors: 0, Skipped: %d", build.TestReport.TotalCount, build.TestReport.FailCount, build.TestReport.SkipCount)
+		}
+	}
+
+	build.Console = fmt.Sprintf("Started by %s\nRunning on %s in /var/jenkins/workspace/%s\n", causeText(build.Causes), build.BuiltOn, j.name)
+	if failureLog != "" {
+		build.Console += failureLog + "\n"
+	}
+	build.Console += "Finished: " + build.Result + "\n"
+}
+
+func causeText(causes []jenkins_api.Cause) string {
+	if len(causes) == 0 {
+		return "unknown"
+	}
+	return causes[0].ShortDesc
+}
+
+// 按固定比例拆分构建耗时，失败的构建在某个阶段失败，之后的阶段不再执行
+func (g *Generator) stages(j *job, build *fake.Build) []jenkins_api.JenkinsPipelineStage {
+	names := []string{"Checkout", "Build", "Test", "Archive"}
+	shares := []float64{0.05, 0.4, 0.4, 0.15}
+	if j.kind == kindDeploy {
+		names = []string{"Checkout", "Build Image", "Push Image", "Deploy"}
+		shares = []float64{0.05, 0.45, 0.2, 0.3}
+	}
+
+	failedAt := -1
+	if build.Result == "FAILURE" || build.Result == "ABORTED" {
+		failedAt = 1 + g.rnd.Intn(len(names)-1)
+	}
+
+	var stages []jenkins_api.JenkinsPipelineStage
+	start := build.Timestamp.UnixMilli()
+	for i, name := range names {
+		duration := int(float64(build.Duration.Milliseconds()) * shares[i])
+		stage := jenkins_api.JenkinsPipelineStage{
+			ID:              fmt.Sprintf("%d", 10+i),
+			Name:            name,
+			ExecNode:        build.BuiltOn,
+			Status:          jenkins_api.StageSuccess,
+			StartTimeMillis: start,
+			DurationMillis:  duration,
+		}
+		if i == failedAt {
+			stage.Status = jenkins_api.StageFailed
+			if build.Result == "ABORTED" {
+				stage.Status = jenkins_api.StageAborted
+			}
+		}
+		stages = append(stages, stage)
+		if i == failedAt {
+			break
+		}
+		start += int64(duration)
+	}
+	return stages
+}
+
+// 测试报告：测试失败导致的构建失败会有若干失败用例；第一个用例是不稳定测试，偶尔失败
+func (g *Generator) testReport(j *job, build *fake.Build, testFailure bool) *jenkins_api.JenkinsTestReport {
+	report := &jenkins_api.JenkinsTestReport{}
+	suite := jenkins_api.JenkinsTestSuite{Name: j.service}
+
+	for i, name := range j.tests {
+		testCase := jenkins_api.JenkinsTestCase{
+			ClassName: fmt.Sprintf("com.example.%s.%sTest", j.service, features[i%len(features)]),
+			Name:      name,
+			Status:    jenkins_api.TestPassed,
+			Duration:  math.Round(g.rnd.ExpFloat64()*200) / 100,
+		}
+		switch {
+		case i == 0 && g.rnd.Float64() < 0.15:
+			testCase.Status = jenkins_api.TestFailed
+		case testFailure && g.rnd.Float64() < 0.1:
+			testCase.Status = jenkins_api.TestFailed
+		case g.rnd.Float64() < 0.02:
+			testCase.Status = jenkins_api.TestSkipped
+		}
+		if testCase.Status == jenkins_api.TestFailed {
+			testCase.ErrorDetails = "expected: <200> but was: <500>"
+			report.FailCount++
+		} else if testCase.Status == jenkins_api.TestSkipped {
+			report.SkipCount++
+		} else {
+			report.PassCount++
+		}
+		suite.Duration += testCase.Duration
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	report.TotalCount = len(j.tests)
+	report.Duration = suite.Duration
+	report.Suites = []jenkins_api.JenkinsTestSuite{suite}
+	return report
+}
+
+// 每个实例 4 个 linux 节点与 1 个 windows 节点，windows 节点离线
+func (g *Generator) computers() []fake.Computer {
+	computers := []fake.Computer{
+		{Name: "built-in", NumExecutors: 2, BusyExecutors: g.rnd.Intn(2), DiskSpace: 50 << 30, ResponseTime: 3},
+	}
+	for i := 1; i <= 4; i++ {
+		computers = append(computers, fake.Computer{
+			Name:          fmt.Sprintf("linux-%d", i),
+			Labels:        []string{"linux", "docker"},
+			NumExecutors:  4,
+			BusyExecutors: g.rnd.Intn(5),
+			DiskSpace:     int64(5+g.rnd.Intn(100)) << 30,
+			ResponseTime:  int64(5 + g.rnd.Intn(50)),
+		})
+	}
+	computers = append(computers, fake.Computer{
+		Name:          "windows-1",
+		Labels:        []string{"windows"},
+		NumExecutors:  2,
+		Offline:       true,
+		OfflineReason: "Agent went offline during the build",
+		DiskSpace:     -1,
+		ResponseTime:  -1,
+	})
+	return computers
+}
+
+// 工作时间队列中会有几个等待执行器的构建
+func (g *Generator) queue(server *fake.Server, now time.Time) []fake.QueueItem {
+	var items []fake.QueueItem
+	count := g.poisson(3 * hourWeight(now))
+	for i := 0; i < count; i++ {
+		var candidates []*job
+		for _, j := range g.jobs {
+			if j.server == server {
+				candidates = append(candidates, j)
+			}
+		}
+		j := candidates[g.rnd.Intn(len(candidates))]
+		g.queueID++
+
+		item := fake.QueueItem{
+			ID:           g.queueID,
+			JobName:      j.name,
+			InQueueSince: now.Add(-time.Duration(g.rnd.Intn(600)) * time.Second),
+			Why:          "Waiting for next available executor on ‘linux’",
+			Buildable:    true,
+		}
+		if g.rnd.Float64() < 0.3 {
+			item.Why = fmt.Sprintf("Build #%d is already in progress (ETA: 3 min 20 sec)", j.number)
+			item.Buildable = false
+			item.Blocked = true
+		}
+		items = append(items, item)
+	}
+	return items
+}
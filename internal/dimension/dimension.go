@@ -0,0 +1,50 @@
+﻿package dimension
+
+import (
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 构建缺少维度对应的信息时的取值，如推送的构建在轮询补齐详情之前没有触发信息
+const Unknown = "unknown"
+
+// 计算构建在内置维度（见 config.Dimension* 常量）与参数维度上的取值，/metrics 等接口与告警规则共用。
+// 只需要填入用到的维度所需的配置
+type Resolver struct {
+	BranchClasses []config.BranchClass
+	Teams         map[string]config.Team
+	Parameters    []config.ParameterDimension
+}
+
+// 构建在 name 维度上的取值，取值为空时返回 Unknown。
+// name 需为内置维度或 Parameters 中的参数维度，由调用方在解析配置或请求时校验
+func (r *Resolver) Value(name string, build store.JenkinsBuild) string {
+	value := ""
+	switch name {
+	case config.DimensionInstance:
+		value = build.JenkinsInstanceName
+	case config.DimensionJob:
+		value = build.JobName
+	case config.DimensionTrigger:
+		value = build.TriggerType
+	case config.DimensionUser:
+		value = build.TriggeredBy
+	case config.DimensionBranch:
+		value = build.Branch
+	case config.DimensionBranchClass:
+		value = config.ClassOfBranch(r.BranchClasses, build.Branch)
+	case config.DimensionTeam:
+		value = config.TeamOfJob(r.Teams, build.JobName)
+	default:
+		for i := range r.Parameters {
+			if r.Parameters[i].Name == name {
+				value = r.Parameters[i].ValueOf(build.Parameters)
+				break
+			}
+		}
+	}
+	if value == "" {
+		return Unknown
+	}
+	return value
+}
@@ -0,0 +1,152 @@
+﻿package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/alert"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+func TestConsecutiveFailuresAlert(t *testing.T) {
+	env := newTestEnv(t)
+	viper.Set(config.AlertRulesConfigKey, []map[string]interface{}{{
+		"name":        "ConsecutiveFailures",
+		"type":        "consecutiveFailures",
+		"threshold":   3,
+		"groupBy":     []string{"job"},
+		"for":         "10m",
+		"severity":    "critical",
+		"annotations": map[string]string{"summary": "{{ .Labels.job }} failed {{ .Value }} times"},
+	}})
+
+	start := time.Now().Add(-time.Hour)
+	for number, result := range []string{"SUCCESS", "FAILURE", "FAILURE", "FAILURE"} {
+		env.jenkins.AddBuild("api", fake.Build{Number: number + 1, Result: result, Timestamp: start.Add(time.Duration(number) * time.Minute)})
+		env.jenkins.AddBuild("web", fake.Build{Number: number + 1, Result: "SUCCESS", Timestamp: start.Add(time.Duration(number) * time.Minute)})
+	}
+	env.collect(t, nil)
+
+	engine := alert.NewEngine(env.store)
+	now := time.Now()
+
+	engine.Evaluate(now)
+	alerts := env.alerts(t, "")
+	if len(alerts) != 1 || alerts[0].State != store.AlertPending || alerts[0].Labels["job"] != "api" {
+		t.Fatalf("expected 1 pending alert for api, got %+v", alerts)
+	}
+	if alerts[0].Value != 3 || alerts[0].Annotations["summary"] != "api failed 3 times" {
+		t.Errorf("unexpected alert: %+v", alerts[0])
+	}
+
+	// 持续满足 for 指定的时长后触发
+	engine.Evaluate(now.Add(11 * time.Minute))
+	if firing := env.alerts(t, store.AlertFiring); len(firing) != 1 || firing[0].FiredAt == nil {
+		t.Fatalf("expected 1 firing alert, got %+v", firing)
+	}
+
+	// 构建恢复后告警变为 resolved
+	env.jenkins.AddBuild("api", fake.Build{Number: 5, Result: "SUCCESS", Timestamp: start.Add(5 * time.Minute)})
+	env.collect(t, nil)
+	engine.Evaluate(now.Add(12 * time.Minute))
+	if resolved := env.alerts(t, store.AlertResolved); len(resolved) != 1 || resolved[0].ResolvedAt == nil {
+		t.Fatalf("expected 1 resolved alert, got %+v", resolved)
+	}
+
+	// 超过保留时长后不再返回
+	engine.Evaluate(now.Add(25 * time.Hour))
+	if alerts := env.alerts(t, ""); len(alerts) != 0 {
+		t.Errorf("expected resolved alert to expire, got %+v", alerts)
+	}
+}
+
+func TestAlertGroupByBuiltinDimensions(t *testing.T) {
+	env := newTestEnv(t)
+	viper.Set(config.AlertRulesConfigKey, []map[string]interface{}{{
+		"name":      "ConsecutiveFailures",
+		"type":      "consecutiveFailures",
+		"threshold": 2,
+		"groupBy":   []string{"job", "trigger", "user", "branchClass"},
+	}})
+
+	start := time.Now().Add(-time.Hour)
+	for number := 1; number <= 2; number++ {
+		env.jenkins.AddBuild("api", fake.Build{Number: number, Result: "FAILURE", Timestamp: start.Add(time.Duration(number) * time.Minute), Branch: "release/1.0", Causes: []jenkins_api.Cause{fake.UserCause("alice")}})
+	}
+	env.collect(t, nil)
+
+	alert.NewEngine(env.store).Evaluate(time.Now())
+	alerts := env.alerts(t, "")
+	if len(alerts) != 1 || alerts[0].Labels["trigger"] != "user" || alerts[0].Labels["user"] != "alice" || alerts[0].Labels["branchClass"] != "release" {
+		t.Fatalf("expected 1 alert labeled with trigger, user and branch class, got %+v", alerts)
+	}
+}
+
+func TestNoSuccessAlert(t *testing.T) {
+	env := newTestEnv(t)
+	rule := map[string]interface{}{
+		"name":    "NoSuccessfulDeploy",
+		"type":    "noSuccess",
+		"window":  "24h",
+		"groupBy": []string{"job"},
+		"selector": map[string]interface{}{
+			"jobs": []string{"*deploy*"},
+		},
+	}
+	viper.Set(config.AlertRulesConfigKey, []map[string]interface{}{rule})
+	env.jenkins.AddFolder("payment")
+	env.jenkins.AddJob("payment/idle-deploy")
+	env.collect(t, nil)
+
+	// 没有任何匹配的构建时不告警
+	engine := alert.NewEngine(env.store)
+	engine.Evaluate(time.Now())
+	if alerts := env.alerts(t, ""); len(alerts) != 0 {
+		t.Fatalf("expected no alerts without builds, got %+v", alerts)
+	}
+
+	// api-deploy 三天前成功过；web-deploy 是今天新建的 job，还没满一个窗口
+	now := time.Now()
+	env.jenkins.AddBuild("payment/api-deploy", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: now.Add(-72 * time.Hour)})
+	env.jenkins.AddBuild("payment/api-deploy", fake.Build{Number: 2, Result: "FAILURE", Timestamp: now.Add(-time.Hour)})
+	env.jenkins.AddBuild("payment/web-deploy", fake.Build{Number: 1, Result: "FAILURE", Timestamp: now.Add(-time.Hour)})
+	env.collect(t, nil)
+
+	engine.Evaluate(now)
+	alerts := env.alerts(t, store.AlertFiring)
+	if len(alerts) != 1 || alerts[0].Labels["job"] != "payment/api-deploy" || alerts[0].Value != 72 {
+		t.Fatalf("expected only payment/api-deploy to fire, got %+v", alerts)
+	}
+
+	// 显式配置的分组即使没有构建也要告警
+	rule["expectedGroups"] = []map[string]string{{"job": "payment/idle-deploy"}}
+	viper.Set(config.AlertRulesConfigKey, []map[string]interface{}{rule})
+	engine.Evaluate(now)
+	jobs := make(map[string]bool)
+	for _, alert := range env.alerts(t, store.AlertFiring) {
+		jobs[alert.Labels["job"]] = true
+	}
+	if len(jobs) != 2 || !jobs["payment/idle-deploy"] {
+		t.Fatalf("expected payment/idle-deploy to fire, got %v", jobs)
+	}
+}
+
+func (e *testEnv) alerts(t *testing.T, state string) []store.Alert {
+	t.Helper()
+	var response struct {
+		Alerts []store.Alert `json:"告警"`
+	}
+	path := "/api/v1/alerts"
+	if state != "" {
+		path += "?state=" + state
+	}
+	if code := e.get(t, path, &response); code != http.StatusOK {
+		t.Fatalf("GET %s: status %d", path, code)
+	}
+	return response.Alerts
+}
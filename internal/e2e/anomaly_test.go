@@ -0,0 +1,86 @@
+﻿package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/alert"
+	"github.com/zuoyangs/go-devops-observability/internal/anomaly"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+	"github.com/zuoyangs/go-devops-observability/internal/router"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+func TestAnomalies(t *testing.T) {
+	env := newTestEnv(t)
+	viper.Set(config.AlertRulesConfigKey, []map[string]interface{}{
+		{"name": "FailureSpike", "type": "failureAnomaly"},
+		{"name": "SlowBuild", "type": "durationAnomaly", "window": "2h"},
+	})
+
+	// 最近 6 周每天同一时间构建一次，时长稳定在 10 分钟左右；今天这次用了 25 分钟
+	now := time.Now()
+	today := now.Add(-time.Hour)
+	number := 0
+	for day := 42; day >= 1; day-- {
+		number++
+		jitter := time.Duration(day%5) * 10 * time.Second
+		env.jenkins.AddBuild("api", fake.Build{Number: number, Result: "SUCCESS", Timestamp: today.AddDate(0, 0, -day), Duration: 10*time.Minute + jitter})
+	}
+	env.jenkins.AddBuild("api", fake.Build{Number: number + 1, Result: "SUCCESS", Timestamp: today, Duration: 25 * time.Minute})
+
+	// web 平时偶尔失败一次，今天失败了 5 次
+	number = 0
+	for day := 42; day >= 1; day-- {
+		number++
+		result := "SUCCESS"
+		if day%7 == 3 {
+			result = "FAILURE"
+		}
+		env.jenkins.AddBuild("web", fake.Build{Number: number, Result: result, Timestamp: today.AddDate(0, 0, -day), Duration: time.Minute})
+	}
+	for i := 0; i < 5; i++ {
+		number++
+		env.jenkins.AddBuild("web", fake.Build{Number: number, Result: "FAILURE", Timestamp: today.Add(time.Duration(i) * time.Minute), Duration: time.Minute})
+	}
+	env.collect(t, nil)
+
+	var response struct {
+		Anomalies []router.BuildAnomaly `json:"构建异常"`
+	}
+	if code := env.get(t, "/metrics/anomalies", &response); code != http.StatusOK {
+		t.Fatalf("GET /metrics/anomalies: status %d", code)
+	}
+	found := make(map[string]router.BuildAnomaly)
+	for _, item := range response.Anomalies {
+		found[item.Kind+"/"+item.JobName] = item
+	}
+	if len(found) != 2 {
+		t.Fatalf("expected 2 anomalies, got %+v", response.Anomalies)
+	}
+
+	slow, ok := found[anomaly.KindDuration+"/api"]
+	if !ok || slow.Number != 43 || slow.Value != 1500 || slow.Direction != anomaly.DirectionHigh || slow.Season != anomaly.SeasonWeekdayHour || slow.Samples != 6 {
+		t.Errorf("unexpected duration anomaly: %+v", slow)
+	}
+	spike, ok := found[anomaly.KindFailureCount+"/web"]
+	if !ok || spike.Value != 5 || spike.Median != 0 || spike.Score < 3.5 {
+		t.Errorf("unexpected failure count anomaly: %+v", spike)
+	}
+
+	if code := env.get(t, "/metrics/anomalies?kind=duration", &response); code != http.StatusOK || len(response.Anomalies) != 1 {
+		t.Errorf("kind=duration: status %d, %+v", code, response.Anomalies)
+	}
+
+	alert.NewEngine(env.store).Evaluate(now)
+	fired := make(map[string]bool)
+	for _, item := range env.alerts(t, store.AlertFiring) {
+		fired[item.Rule+"/"+item.Labels["job"]] = true
+	}
+	if len(fired) != 2 || !fired["FailureSpike/web"] || !fired["SlowBuild/api"] {
+		t.Errorf("unexpected alerts: %v", fired)
+	}
+}
@@ -0,0 +1,60 @@
+﻿package e2e
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/demo"
+)
+
+func TestDemoGenerator(t *testing.T) {
+	env := newTestEnv(t)
+	viper.Set(config.GitlabConfigKey, map[string]interface{}{"gitlab1": map[string]interface{}{"gitlaburl": "https://gitlab.example.com", "token": "secret"}})
+	viper.Set(config.NotifyConfigKey, map[string]interface{}{
+		"channels":  []map[string]interface{}{{"name": "ops", "type": "webhook", "url": "https://hooks.example.com"}},
+		"groupWait": "1s",
+	})
+
+	generator, err := demo.Start(&config.Demo{Seed: 7, Instances: 2, Folders: 1, JobsPerFolder: 4, Days: 7, BuildsPerDay: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer generator.Close()
+
+	// 原有的 Jenkins 实例被模拟实例替换
+	if viper.IsSet(instanceName) {
+		t.Errorf("instance %s should be replaced", instanceName)
+	}
+	// 演示模式不访问真实的 GitLab 与通知渠道，其余配置保留
+	if viper.IsSet(config.GitlabConfigKey) || viper.IsSet(config.NotifyConfigKey+".channels") {
+		t.Errorf("gitlab and notify channels should be removed in demo mode")
+	}
+	if viper.GetString(config.NotifyConfigKey+".groupWait") != "1s" {
+		t.Errorf("other notify settings should be kept")
+	}
+
+	env.collect(t, nil)
+
+	var instances instancesResponse
+	env.get(t, "/api/v1/instances", &instances)
+	if len(instances.Instances) != 2 {
+		t.Fatalf("expected 2 demo instances, got %+v", instances.Instances)
+	}
+	for _, instance := range instances.Instances {
+		if instance.JobCount != 4 || instance.LastCollectionError != "" {
+			t.Errorf("unexpected instance: %+v", instance)
+		}
+	}
+
+	total := 0
+	for _, build := range env.store.JenkinsBuilds() {
+		if build.TriggerType == "" || build.Branch == "" {
+			t.Errorf("build without trigger or branch: %+v", build)
+		}
+		total++
+	}
+	if total == 0 {
+		t.Fatal("no builds generated")
+	}
+}
@@ -0,0 +1,3 @@
+﻿// 端到端测试：使用进程内的模拟 Jenkins（jenkins_api/fake）驱动真实的采集器、
+// 数据模型与 HTTP 路由，校验各接口计算出的指标
+package e2e
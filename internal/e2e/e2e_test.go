@@ -0,0 +1,103 @@
+﻿package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/internal/collector"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/impl"
+	"github.com/zuoyangs/go-devops-observability/internal/router"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// viper 会把配置 key 转为小写，实例名称使用小写以便直接比较
+const (
+	instanceName = "jenkins-e2e"
+	username     = "admin"
+	password     = "secret"
+)
+
+type testEnv struct {
+	jenkins *fake.Server
+	store   *store.Store
+	engine  *gin.Engine
+}
+
+// 启动模拟 Jenkins，并把它配置为唯一的 Jenkins 实例
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	jenkins := fake.NewServer()
+	jenkins.SetCredentials(username, password)
+	t.Cleanup(jenkins.Close)
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	setInstance(jenkins.URL(), username, password)
+
+	dataStore := store.New("", 90)
+	return &testEnv{
+		jenkins: jenkins,
+		store:   dataStore,
+		engine:  newEngine(dataStore),
+	}
+}
+
+func newEngine(dataStore *store.Store) *gin.Engine {
+	engine := gin.New()
+	router.SetupAPIRouters(engine, dataStore, nil)
+	return engine
+}
+
+func setInstance(jenkinsURL, user, pass string) {
+	viper.Set(instanceName, map[string]interface{}{
+		"jenkinsurl": jenkinsURL,
+		"username":   user,
+		"password":   pass,
+	})
+}
+
+// 执行一轮采集与健康探测，client 为空时使用默认 client
+func (e *testEnv) collect(t *testing.T, client *http.Client) {
+	t.Helper()
+	ctx := context.Background()
+	collector.NewJenkinsProber(e.store, impl.NewJenkinsServiceImpl(client)).ProbeOnce(ctx)
+	collector.NewJenkinsCollector(e.store, impl.NewJenkinsServiceImpl(client)).CollectOnce(ctx)
+}
+
+// 请求接口并解析响应，返回状态码
+func (e *testEnv) get(t *testing.T, path string, out interface{}) int {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	e.engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if out != nil && recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), out); err != nil {
+			t.Fatalf("GET %s: decode response: %v\n%s", path, err, recorder.Body.String())
+		}
+	}
+	return recorder.Code
+}
+
+// 今天（按 UTC 计算，与 /metrics 的统计窗口一致）已经结束的构建时间
+func todayAt(offset time.Duration) time.Time {
+	now := time.Now()
+	at := now.Add(-offset)
+	if start := now.Truncate(24 * time.Hour); at.Before(start) {
+		return start
+	}
+	return at
+}
+
+// 上个月的构建时间，不在 /metrics 的统计窗口内
+func lastMonth() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, 0, -3)
+}
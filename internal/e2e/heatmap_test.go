@@ -0,0 +1,157 @@
+﻿package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/alert"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+	"github.com/zuoyangs/go-devops-observability/internal/router"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+func TestHeatmapAndOffHoursBuilds(t *testing.T) {
+	env := newTestEnv(t)
+
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 上上周一零点（北京时间），之后一周内的构建都已经结束
+	now := time.Now().In(location)
+	monday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	monday = monday.AddDate(0, 0, -(int(monday.Weekday())+6)%7-7)
+	at := func(day, hour int) time.Time {
+		return monday.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour)
+	}
+	byUser := func(user string) []jenkins_api.Cause {
+		return []jenkins_api.Cause{fake.UserCause(user)}
+	}
+
+	viper.Set(config.WorkingHoursConfigKey, map[string]interface{}{
+		"timezone": "Asia/Shanghai",
+		"start":    "09:00",
+		"end":      "18:00",
+		"holidays": []string{at(2, 0).Format("2006-01-02")},
+	})
+	viper.Set(config.TeamsConfigKey, map[string]interface{}{
+		"platform": map[string]interface{}{"jobs": []string{"api"}},
+		"web":      map[string]interface{}{"jobs": []string{"web", "nightly"}},
+	})
+
+	env.jenkins.AddBuild("api", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: at(0, 10), Causes: byUser("alice")})
+	env.jenkins.AddBuild("api", fake.Build{Number: 2, Result: "FAILURE", Timestamp: at(0, 10).Add(30 * time.Minute), Causes: byUser("bob")})
+	env.jenkins.AddBuild("api", fake.Build{Number: 3, Result: "FAILURE", Timestamp: at(0, 21), Causes: byUser("bob")})
+	env.jenkins.AddBuild("api", fake.Build{Number: 4, Result: "SUCCESS", Timestamp: at(5, 11), Causes: byUser("alice")})
+	env.jenkins.AddBuild("web", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: at(2, 10), Causes: byUser("alice")})
+	env.jenkins.AddBuild("nightly", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: at(1, 2), Causes: []jenkins_api.Cause{fake.TimerCause()}})
+	env.collect(t, nil)
+
+	var heatmaps struct {
+		Heatmaps []router.BuildHeatmap `json:"构建时间热力图"`
+	}
+	if code := env.get(t, "/metrics/heatmap?groupBy=team", &heatmaps); code != http.StatusOK {
+		t.Fatalf("GET /metrics/heatmap: status %d", code)
+	}
+	if len(heatmaps.Heatmaps) != 2 || heatmaps.Heatmaps[0].Team != "platform" {
+		t.Fatalf("unexpected heatmaps: %+v", heatmaps.Heatmaps)
+	}
+	platform := heatmaps.Heatmaps[0]
+	if platform.Timezone != "Asia/Shanghai" || platform.TotalCount != 4 || platform.FailureCount != 2 {
+		t.Fatalf("unexpected platform heatmap: %+v", platform)
+	}
+	if platform.Builds[0][10] != 2 || platform.Failures[0][10] != 1 || platform.FailureRates[0][10] != 50 {
+		t.Fatalf("unexpected monday 10:00 cell: builds %d failures %d rate %v", platform.Builds[0][10], platform.Failures[0][10], platform.FailureRates[0][10])
+	}
+	if platform.Builds[0][21] != 1 || platform.Builds[5][11] != 1 {
+		t.Fatalf("unexpected off-hours cells: %v", platform.Builds)
+	}
+	if platform.OffHoursCount != 2 || platform.OffHoursRate != 50 {
+		t.Fatalf("off hours = %d (%v%%), want 2 (50%%)", platform.OffHoursCount, platform.OffHoursRate)
+	}
+
+	var report struct {
+		OffHours struct {
+			Timezone string                     `json:"timezone"`
+			Builds   []router.OffHoursBuild     `json:"builds"`
+			Users    []router.OffHoursUserStats `json:"users"`
+		} `json:"非工作时间构建"`
+	}
+	if code := env.get(t, "/metrics/off-hours?days=30", &report); code != http.StatusOK {
+		t.Fatalf("GET /metrics/off-hours: status %d", code)
+	}
+	// 定时触发的 nightly 默认不计入，最近的排在前面
+	var reasons []string
+	for _, build := range report.OffHours.Builds {
+		reasons = append(reasons, build.JobName+"#"+build.Reason+"@"+build.TriggeredBy)
+	}
+	want := []string{"api#weekend@alice", "web#holiday@alice", "api#afterHours@bob"}
+	if len(reasons) != len(want) {
+		t.Fatalf("off-hours builds = %v, want %v", reasons, want)
+	}
+	for i := range want {
+		if reasons[i] != want[i] {
+			t.Fatalf("off-hours builds = %v, want %v", reasons, want)
+		}
+	}
+	if first := report.OffHours.Builds[0]; first.Weekday != "sat" || first.Team != "platform" || first.LocalTime != at(5, 11).Format(time.RFC3339) {
+		t.Fatalf("unexpected off-hours build: %+v", first)
+	}
+	users := report.OffHours.Users
+	if len(users) != 2 || users[0].TriggeredBy != "alice" || users[0].TotalCount != 2 || users[1].FailureCount != 1 {
+		t.Fatalf("unexpected off-hours users: %+v", users)
+	}
+
+	if code := env.get(t, "/metrics/off-hours?days=30&includeScheduled=true&team=web", &report); code != http.StatusOK {
+		t.Fatalf("GET /metrics/off-hours: status %d", code)
+	}
+	if len(report.OffHours.Builds) != 2 || report.OffHours.Builds[1].Reason != config.OffHoursEarly {
+		t.Fatalf("unexpected scheduled off-hours builds: %+v", report.OffHours.Builds)
+	}
+}
+
+func TestAlertsWithInvalidWorkingHours(t *testing.T) {
+	env := newTestEnv(t)
+	viper.Set(config.AlertRulesConfigKey, []map[string]interface{}{
+		{"name": "ConsecutiveFailures", "type": "consecutiveFailures", "threshold": 2, "groupBy": []string{"job"}},
+		{"name": "FailureSpike", "type": "failureAnomaly"},
+	})
+
+	// web 过去 6 周每天成功一次，今天失败了 5 次
+	now := time.Now()
+	today := now.Add(-time.Hour)
+	number := 0
+	for day := 42; day >= 1; day-- {
+		number++
+		env.jenkins.AddBuild("web", fake.Build{Number: number, Result: "SUCCESS", Timestamp: today.AddDate(0, 0, -day), Duration: time.Minute})
+	}
+	for i := 0; i < 5; i++ {
+		number++
+		env.jenkins.AddBuild("web", fake.Build{Number: number, Result: "FAILURE", Timestamp: today.Add(time.Duration(i) * time.Minute), Duration: time.Minute})
+	}
+	env.collect(t, nil)
+
+	engine := alert.NewEngine(env.store)
+	engine.Evaluate(now)
+	if firing := env.alerts(t, store.AlertFiring); len(firing) != 2 {
+		t.Fatalf("expected 2 firing alerts, got %+v", firing)
+	}
+
+	// 工作时间配置错误只跳过异常检测规则，已触发的告警保持原状态，其它规则照常评估
+	viper.Set(config.WorkingHoursConfigKey, map[string]interface{}{"timezone": "Mars/Olympus"})
+	env.jenkins.AddBuild("web", fake.Build{Number: number + 1, Result: "SUCCESS", Timestamp: today.Add(10 * time.Minute), Duration: time.Minute})
+	env.collect(t, nil)
+	engine.Evaluate(now.Add(time.Minute))
+
+	states := make(map[string]string)
+	for _, item := range env.alerts(t, "") {
+		states[item.Rule] = item.State
+	}
+	if states["ConsecutiveFailures"] != store.AlertResolved || states["FailureSpike"] != store.AlertFiring {
+		t.Errorf("unexpected alert states: %v", states)
+	}
+}
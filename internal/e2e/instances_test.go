@@ -0,0 +1,95 @@
+﻿package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+	"github.com/zuoyangs/go-devops-observability/internal/router"
+)
+
+type instancesResponse struct {
+	Instances []router.InstanceHealth `json:"Jenkins实例"`
+}
+
+func (e *testEnv) instance(t *testing.T) router.InstanceHealth {
+	t.Helper()
+	var response instancesResponse
+	if code := e.get(t, "/api/v1/instances", &response); code != http.StatusOK {
+		t.Fatalf("GET /api/v1/instances: status %d", code)
+	}
+	if len(response.Instances) != 1 {
+		t.Fatalf("expected 1 instance, got %+v", response.Instances)
+	}
+	return response.Instances[0]
+}
+
+func TestInstanceHealthy(t *testing.T) {
+	env := newTestEnv(t)
+	env.jenkins.SetVersion("2.452.1")
+	env.jenkins.AddBuild("a", fake.Build{Number: 1, Result: "SUCCESS"})
+	env.jenkins.AddBuild("folder/b", fake.Build{Number: 1, Result: "SUCCESS"})
+
+	env.collect(t, nil)
+
+	instance := env.instance(t)
+	if instance.Status != router.InstanceHealthy || !instance.Reachable || !instance.AuthValid {
+		t.Errorf("unexpected status: %+v", instance)
+	}
+	if instance.Version != "2.452.1" {
+		t.Errorf("version: %q", instance.Version)
+	}
+	if instance.JobCount != 2 || instance.LastSuccessfulCollectionAt == nil {
+		t.Errorf("collection: %+v", instance)
+	}
+}
+
+func TestInstanceAuthError(t *testing.T) {
+	env := newTestEnv(t)
+	env.jenkins.AddBuild("a", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: todayAt(time.Second)})
+	setInstance(env.jenkins.URL(), username, "wrong")
+
+	env.collect(t, nil)
+
+	instance := env.instance(t)
+	if instance.Status != router.InstanceUnauthorized || !instance.Reachable || instance.AuthValid {
+		t.Errorf("unexpected status: %+v", instance)
+	}
+	if instance.LastCollectionError == "" || instance.TotalCollectionErrors != 1 || instance.LastSuccessfulCollectionAt != nil {
+		t.Errorf("collection should fail: %+v", instance)
+	}
+
+	var response metricsResponse
+	env.get(t, "/metrics", &response)
+	if len(response.Stats) != 0 {
+		t.Errorf("expected no stats, got %+v", response.Stats)
+	}
+}
+
+func TestInstanceLatencyTimeout(t *testing.T) {
+	env := newTestEnv(t)
+	env.jenkins.SetLatency(200 * time.Millisecond)
+
+	env.collect(t, &http.Client{Timeout: 50 * time.Millisecond})
+
+	instance := env.instance(t)
+	if instance.Status != router.InstanceUnreachable || instance.ProbeError == "" {
+		t.Errorf("unexpected status: %+v", instance)
+	}
+	if instance.ConsecutiveErrorCount != 1 {
+		t.Errorf("collection should fail: %+v", instance)
+	}
+}
+
+func TestInstanceMisconfigured(t *testing.T) {
+	env := newTestEnv(t)
+	setInstance(env.jenkins.URL(), username, "")
+
+	env.collect(t, nil)
+
+	instance := env.instance(t)
+	if instance.Status != router.InstanceMisconfigured || instance.ConfigError == "" {
+		t.Errorf("unexpected status: %+v", instance)
+	}
+}
@@ -0,0 +1,299 @@
+﻿package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+	"github.com/zuoyangs/go-devops-observability/internal/router"
+)
+
+type metricsResponse struct {
+	Stats []router.JenkinsJobStatsExtended `json:"持续集成发布稳定性指标"`
+}
+
+func (r metricsResponse) find(t *testing.T, jobName string) router.JenkinsJobStatsExtended {
+	t.Helper()
+	for _, stats := range r.Stats {
+		if stats.JobName == jobName {
+			return stats
+		}
+	}
+	t.Fatalf("job %s not found in %+v", jobName, r.Stats)
+	return router.JenkinsJobStatsExtended{}
+}
+
+func TestMetricsCountsAndRates(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 3 次成功、1 次失败；上个月的构建与构建中的构建不参与统计
+	for number, result := range map[int]string{1: "SUCCESS", 2: "FAILURE", 3: "SUCCESS", 4: "SUCCESS"} {
+		env.jenkins.AddBuild("api", fake.Build{Number: number, Result: result, Timestamp: todayAt(time.Duration(10-number) * time.Second), Duration: time.Minute})
+	}
+	env.jenkins.AddBuild("api", fake.Build{Number: 5, Building: true, Timestamp: todayAt(0)})
+	env.jenkins.AddBuild("api", fake.Build{Number: 6, Result: "FAILURE", Timestamp: lastMonth()})
+
+	// 文件夹中的 job，中止的构建不计入成功或失败
+	env.jenkins.AddBuild("team/web", fake.Build{Number: 1, Result: "FAILURE", Timestamp: todayAt(3 * time.Second)})
+	env.jenkins.AddBuild("team/web", fake.Build{Number: 2, Result: "ABORTED", Timestamp: todayAt(2 * time.Second)})
+	env.jenkins.AddBuild("team/web", fake.Build{Number: 3, Result: "FAILURE", Timestamp: todayAt(time.Second)})
+
+	env.collect(t, nil)
+
+	var response metricsResponse
+	if code := env.get(t, "/metrics", &response); code != http.StatusOK {
+		t.Fatalf("GET /metrics: status %d", code)
+	}
+	if len(response.Stats) != 2 {
+		t.Fatalf("expected 2 jobs, got %+v", response.Stats)
+	}
+
+	api := response.find(t, "api")
+	if api.JenkinsInstanceName != instanceName {
+		t.Errorf("api: instance %q", api.JenkinsInstanceName)
+	}
+	if api.TodaySuccessCount != 3 || api.TodayFailureCount != 1 || api.TodayTotalCount != 4 {
+		t.Errorf("api today: %+v", api)
+	}
+	if api.TodaySuccessRate != 75 || api.TodayFailureRate != 25 {
+		t.Errorf("api today rates: %v %v", api.TodaySuccessRate, api.TodayFailureRate)
+	}
+	if api.CurrentMonthTotalCount != 4 || api.CurrentMonthSuccessRate != 75 {
+		t.Errorf("api month: %+v", api)
+	}
+
+	web := response.find(t, "team/web")
+	if web.TodaySuccessCount != 0 || web.TodayFailureCount != 2 || web.TodayFailureRate != 100 {
+		t.Errorf("team/web today: %+v", web)
+	}
+}
+
+func TestMetricsGroupByTrigger(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.jenkins.AddBuild("deploy", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: todayAt(3 * time.Second), Causes: []jenkins_api.Cause{fake.UserCause("alice")}})
+	env.jenkins.AddBuild("deploy", fake.Build{Number: 2, Result: "FAILURE", Timestamp: todayAt(2 * time.Second), Causes: []jenkins_api.Cause{fake.UserCause("bob")}})
+	env.jenkins.AddBuild("nightly", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: todayAt(time.Second), Causes: []jenkins_api.Cause{fake.TimerCause()}})
+
+	env.collect(t, nil)
+
+	var response metricsResponse
+	if code := env.get(t, "/metrics?groupBy=trigger", &response); code != http.StatusOK {
+		t.Fatalf("GET /metrics: status %d", code)
+	}
+
+	counts := make(map[string][2]int)
+	for _, stats := range response.Stats {
+		if stats.JobName != "" {
+			t.Errorf("job should not be a dimension: %+v", stats)
+		}
+		counts[stats.TriggerType] = [2]int{stats.TodaySuccessCount, stats.TodayFailureCount}
+	}
+	if counts["user"] != [2]int{1, 1} || counts["timer"] != [2]int{1, 0} || len(counts) != 2 {
+		t.Errorf("unexpected trigger stats: %v", counts)
+	}
+
+	if code := env.get(t, "/metrics?groupBy=nonexistent", nil); code != http.StatusBadRequest {
+		t.Errorf("invalid groupBy: expected 400, got %d", code)
+	}
+}
+
+func TestMetricsGroupByParameter(t *testing.T) {
+	env := newTestEnv(t)
+	viper.Set(config.ParameterDimensionsConfigKey, []map[string]interface{}{
+		{"name": "env", "parameters": []string{"ENV"}, "lowercase": true, "default": "unknown"},
+		{"name": "app", "parameters": []string{"APP_NAME"}},
+	})
+
+	env.jenkins.AddBuild("deploy", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: todayAt(3 * time.Second), Parameters: map[string]string{"ENV": "PROD", "APP_NAME": "api"}})
+	env.jenkins.AddBuild("deploy", fake.Build{Number: 2, Result: "FAILURE", Timestamp: todayAt(2 * time.Second), Parameters: map[string]string{"ENV": "prod", "APP_NAME": "api"}})
+	env.jenkins.AddBuild("deploy", fake.Build{Number: 3, Result: "SUCCESS", Timestamp: todayAt(time.Second), Parameters: map[string]string{"APP_NAME": "web"}})
+	env.collect(t, nil)
+
+	var response struct {
+		Stats []struct {
+			Parameters        map[string]string `json:"parameters"`
+			TodaySuccessCount int               `json:"todaySuccessCount"`
+			TodayFailureCount int               `json:"todayFailureCount"`
+		} `json:"持续集成发布稳定性指标"`
+	}
+	if code := env.get(t, "/metrics?groupBy=env,app", &response); code != http.StatusOK {
+		t.Fatalf("GET /metrics: status %d", code)
+	}
+	counts := make(map[string][2]int)
+	for _, stats := range response.Stats {
+		counts[stats.Parameters["env"]+"/"+stats.Parameters["app"]] = [2]int{stats.TodaySuccessCount, stats.TodayFailureCount}
+	}
+	if counts["prod/api"] != [2]int{1, 1} || counts["unknown/web"] != [2]int{1, 0} || len(counts) != 2 {
+		t.Errorf("unexpected parameter stats: %v", counts)
+	}
+
+	// 参数维度不能与内置维度重名
+	viper.Set(config.ParameterDimensionsConfigKey, []map[string]interface{}{
+		{"name": "team", "parameters": []string{"TEAM"}},
+		{"name": "app", "parameters": []string{"APP_NAME"}},
+	})
+	if code := env.get(t, "/metrics?groupBy=app", nil); code != http.StatusBadRequest {
+		t.Errorf("reserved parameter dimension: expected 400, got %d", code)
+	}
+}
+
+func TestMetricsSkipsFailingJob(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.jenkins.AddBuild("good", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: todayAt(time.Second)})
+	env.jenkins.AddBuild("broken", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: todayAt(time.Second)})
+	env.jenkins.FailPath("/job/broken/", http.StatusInternalServerError)
+
+	env.collect(t, nil)
+
+	var response metricsResponse
+	env.get(t, "/metrics", &response)
+	if len(response.Stats) != 1 || response.Stats[0].JobName != "good" {
+		t.Fatalf("expected only the good job, got %+v", response.Stats)
+	}
+
+	// 故障恢复后下一轮采集补齐
+	env.jenkins.ClearFailures()
+	env.collect(t, nil)
+	env.get(t, "/metrics", &response)
+	if len(response.Stats) != 2 {
+		t.Fatalf("expected both jobs after recovery, got %+v", response.Stats)
+	}
+}
+
+func TestCollectorRetriesIncompleteBuilds(t *testing.T) {
+	env := newTestEnv(t)
+
+	stages := []jenkins_api.JenkinsPipelineStage{{Name: "Build", Status: jenkins_api.StageSuccess, DurationMillis: 60000}}
+	env.jenkins.AddPipeline("deploy")
+	env.jenkins.AddBuild("deploy", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: todayAt(time.Second), Stages: stages})
+	env.jenkins.AddBuild("deploy", fake.Build{Number: 2, Result: "SUCCESS", Timestamp: todayAt(2 * time.Second), Stages: stages})
+	env.jenkins.AddBuild("deploy", fake.Build{Number: 3, Result: "SUCCESS", Timestamp: todayAt(3 * time.Second), Stages: stages})
+	// 构建 #3 的详情获取失败不影响更早的构建，构建 #1 的阶段信息获取失败
+	env.jenkins.FailPath("/job/deploy/3/", http.StatusInternalServerError)
+	env.jenkins.FailPath("/job/deploy/1/wfapi/", http.StatusInternalServerError)
+
+	env.collect(t, nil)
+
+	var stageStats struct {
+		Stats []router.PipelineStageStats `json:"流水线阶段指标"`
+	}
+	env.get(t, "/metrics/stages", &stageStats)
+	if len(stageStats.Stats) != 1 || stageStats.Stats[0].RunCount != 1 {
+		t.Fatalf("expected only build #2 stages, got %+v", stageStats.Stats)
+	}
+
+	// 故障恢复后下一轮采集补齐 #3 以及 #1 的阶段信息
+	env.jenkins.ClearFailures()
+	env.collect(t, nil)
+	env.get(t, "/metrics/stages", &stageStats)
+	if len(stageStats.Stats) != 1 || stageStats.Stats[0].RunCount != 3 {
+		t.Fatalf("expected stages of all 3 builds after recovery, got %+v", stageStats.Stats)
+	}
+}
+
+func TestCollectorRetriesTestReports(t *testing.T) {
+	env := newTestEnv(t)
+
+	report := &jenkins_api.JenkinsTestReport{Suites: []jenkins_api.JenkinsTestSuite{{Name: "suite", Cases: []jenkins_api.JenkinsTestCase{
+		{ClassName: "api.Test", Name: "ok", Status: "PASSED"},
+		{ClassName: "api.Test", Name: "broken", Status: "FAILED"},
+	}}}}
+	env.jenkins.AddBuild("api", fake.Build{Number: 1, Result: "UNSTABLE", Timestamp: todayAt(time.Second), TestReport: report})
+	// 没有测试报告的构建不重试
+	env.jenkins.AddBuild("api", fake.Build{Number: 2, Result: "SUCCESS", Timestamp: todayAt(2 * time.Second)})
+	env.jenkins.FailPath("/job/api/1/testReport/", http.StatusBadGateway)
+	env.collect(t, nil)
+
+	var response struct {
+		Stats []router.JobTestStats `json:"测试结果指标"`
+	}
+	env.get(t, "/metrics/tests?groupBy=job", &response)
+	if len(response.Stats) != 0 {
+		t.Fatalf("expected no test results while the report fails, got %+v", response.Stats)
+	}
+
+	env.jenkins.ClearFailures()
+	env.collect(t, nil)
+	env.get(t, "/metrics/tests?groupBy=job", &response)
+	if len(response.Stats) != 1 || response.Stats[0].BuildCount != 1 || response.Stats[0].LatestBuild.Failed != 1 {
+		t.Fatalf("expected the test report of build #1 after recovery, got %+v", response.Stats)
+	}
+}
+
+func TestFailureClassification(t *testing.T) {
+	env := newTestEnv(t)
+	// 配置的规则排在默认规则之前，默认规则仍然生效
+	viper.Set(config.FailureClassificationConfigKey, map[string]interface{}{
+		"rules": []map[string]interface{}{{"category": "quality_gate", "patterns": []string{"Quality gate failed"}}},
+	})
+
+	env.jenkins.AddBuild("api", fake.Build{Number: 1, Result: "FAILURE", Timestamp: todayAt(time.Second), Console: "step 1\nQuality gate failed\n"})
+	env.jenkins.AddBuild("api", fake.Build{Number: 2, Result: "FAILURE", Timestamp: todayAt(2 * time.Second), Console: "error: connect ETIMEDOUT 10.0.0.1:443\n"})
+	env.jenkins.FailPath("/job/api/2/consoleText", http.StatusBadGateway)
+	env.collect(t, nil)
+
+	var response struct {
+		Stats []router.FailureReasonStats `json:"构建失败原因分布"`
+	}
+	env.get(t, "/metrics/failures", &response)
+	if len(response.Stats) != 1 || response.Stats[0].FailureCount != 1 || response.Stats[0].Categories[0].Category != "quality_gate" {
+		t.Fatalf("unexpected failure reasons: %+v", response.Stats)
+	}
+
+	// 控制台日志获取失败的构建在下一轮采集时重新分类
+	env.jenkins.ClearFailures()
+	env.collect(t, nil)
+	env.get(t, "/metrics/failures", &response)
+	categories := make(map[string]int)
+	for _, category := range response.Stats[0].Categories {
+		categories[category.Category] = category.Count
+	}
+	if categories["quality_gate"] != 1 || categories["dependency_download_timeout"] != 1 {
+		t.Fatalf("unexpected failure categories after retry: %+v", response.Stats[0].Categories)
+	}
+}
+
+func TestFailureClassificationWithInvalidConfig(t *testing.T) {
+	env := newTestEnv(t)
+	viper.Set(config.FailureClassificationConfigKey, map[string]interface{}{"tailBytes": "large"})
+
+	env.jenkins.AddBuild("api", fake.Build{Number: 1, Result: "FAILURE", Timestamp: todayAt(time.Second), Console: "error: connect ETIMEDOUT 10.0.0.1:443\n"})
+	env.collect(t, nil)
+
+	// 配置格式错误时仍然采集构建，并按默认规则分类
+	var response struct {
+		Stats []router.FailureReasonStats `json:"构建失败原因分布"`
+	}
+	env.get(t, "/metrics/failures", &response)
+	if len(response.Stats) != 1 || response.Stats[0].Categories[0].Category != "dependency_download_timeout" {
+		t.Fatalf("unexpected failure reasons: %+v", response.Stats)
+	}
+}
+
+func TestCollectorStopsRetryingBuildDetails(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.jenkins.AddBuild("api", fake.Build{Number: 1, Result: "FAILURE", Timestamp: todayAt(time.Second), Console: "Quality gate failed\n"})
+	env.jenkins.FailPath("/job/api/1/consoleText", http.StatusBadGateway)
+
+	// 控制台日志一直获取失败时，最多尝试 5 次
+	for i := 0; i < 7; i++ {
+		env.collect(t, nil)
+	}
+	if requests := env.jenkins.Requests("/job/api/1/consoleText"); requests != 5 {
+		t.Fatalf("expected 5 console requests, got %d", requests)
+	}
+
+	// 放弃后即使故障恢复也不再获取
+	env.jenkins.ClearFailures()
+	env.collect(t, nil)
+	if requests := env.jenkins.Requests("/job/api/1/consoleText"); requests != 5 {
+		t.Fatalf("expected no console requests after giving up, got %d", requests-5)
+	}
+}
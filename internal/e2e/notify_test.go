@@ -0,0 +1,227 @@
+﻿package e2e
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/notify"
+	"github.com/zuoyangs/go-devops-observability/internal/notify/fake"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 按配置创建 notifier，重试间隔缩短为 1ms
+func newNotifier(t *testing.T, channels []map[string]interface{}, routes []map[string]interface{}) (*notify.Notifier, string) {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	deadLetterPath := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	viper.Set(config.NotifyConfigKey, map[string]interface{}{
+		"attempts":       3,
+		"backoff":        "1ms",
+		"deadLetterPath": deadLetterPath,
+		"channels":       channels,
+		"routes":         routes,
+	})
+	cfg, err := config.GetNotify()
+	if err != nil {
+		t.Fatalf("GetNotify: %v", err)
+	}
+	notifier, err := notify.New(cfg, nil)
+	if err != nil {
+		t.Fatalf("notify.New: %v", err)
+	}
+	return notifier, deadLetterPath
+}
+
+func newRobot(t *testing.T) *fake.Robot {
+	robot := fake.NewRobot()
+	t.Cleanup(robot.Close)
+	return robot
+}
+
+func TestNotifyRobotSigning(t *testing.T) {
+	dingTalk, feishu := newRobot(t), newRobot(t)
+	notifier, _ := newNotifier(t, []map[string]interface{}{
+		{"name": "dingtalk", "type": "dingtalk", "url": dingTalk.URL() + "/robot/send?access_token=abc", "secret": "SECdingtalk"},
+		{"name": "feishu", "type": "feishu", "url": feishu.URL() + "/open-apis/bot/v2/hook/abc", "secret": "feishu-secret"},
+	}, []map[string]interface{}{
+		{"channels": []string{"dingtalk", "feishu"}},
+	})
+
+	msg := notify.Message{Kind: config.MessageReport, Title: "构建日报", Text: "今日构建 42 次", Labels: map[string]string{"team": "platform"}}
+	if err := notifier.Notify(context.Background(), msg); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	requests := dingTalk.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 dingtalk request, got %d", len(requests))
+	}
+	query := requests[0].Query
+	if query.Get("access_token") != "abc" || query.Get("sign") != notify.DingTalkSign(query.Get("timestamp"), "SECdingtalk") {
+		t.Errorf("unexpected dingtalk signature: %v", query)
+	}
+	var dingTalkBody struct {
+		Msgtype  string `json:"msgtype"`
+		Markdown struct {
+			Title string `json:"title"`
+			Text  string `json:"text"`
+		} `json:"markdown"`
+	}
+	json.Unmarshal(requests[0].Body, &dingTalkBody)
+	if dingTalkBody.Msgtype != "markdown" || dingTalkBody.Markdown.Title != "构建日报" ||
+		!strings.Contains(dingTalkBody.Markdown.Text, "今日构建 42 次") || !strings.Contains(dingTalkBody.Markdown.Text, "**team**: platform") {
+		t.Errorf("unexpected dingtalk body: %s", requests[0].Body)
+	}
+
+	requests = feishu.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 feishu request, got %d", len(requests))
+	}
+	var feishuBody struct {
+		Timestamp string `json:"timestamp"`
+		Sign      string `json:"sign"`
+		MsgType   string `json:"msg_type"`
+	}
+	json.Unmarshal(requests[0].Body, &feishuBody)
+	if feishuBody.MsgType != "interactive" || feishuBody.Sign != notify.FeishuSign(feishuBody.Timestamp, "feishu-secret") {
+		t.Errorf("unexpected feishu body: %s", requests[0].Body)
+	}
+}
+
+func TestNotifyRouting(t *testing.T) {
+	oncall, platform, webhook := newRobot(t), newRobot(t), newRobot(t)
+	notifier, _ := newNotifier(t, []map[string]interface{}{
+		{"name": "oncall", "type": "wecom", "url": oncall.URL()},
+		{"name": "platform", "type": "slack", "url": platform.URL()},
+		{"name": "audit", "type": "webhook", "url": webhook.URL(), "secret": "hook-secret"},
+	}, []map[string]interface{}{
+		{"kinds": []string{"alert"}, "match": map[string]string{"severity": "critical"}, "channels": []string{"oncall"}},
+		{"match": map[string]string{"team": "platform"}, "channels": []string{"platform", "audit"}},
+		{"kinds": []string{"alert"}, "channels": []string{"audit"}},
+	})
+
+	warning := notify.AlertGroupMessage(map[string]string{"alertname": "ConsecutiveFailures"}, []store.Alert{{
+		Rule:        "ConsecutiveFailures",
+		State:       store.AlertFiring,
+		Labels:      map[string]string{"alertname": "ConsecutiveFailures", "severity": "warning", "team": "platform", "job": "api"},
+		Annotations: map[string]string{"summary": "api 已连续失败 3 次"},
+	}}, nil)
+	if err := notifier.Notify(context.Background(), warning); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(oncall.Requests()) != 0 || len(platform.Requests()) != 1 || len(webhook.Requests()) != 1 {
+		t.Fatalf("unexpected routing: oncall=%d platform=%d audit=%d", len(oncall.Requests()), len(platform.Requests()), len(webhook.Requests()))
+	}
+
+	var slackBody struct {
+		Text string `json:"text"`
+	}
+	json.Unmarshal(platform.Requests()[0].Body, &slackBody)
+	if !strings.HasPrefix(slackBody.Text, "*[FIRING:1] alertname=ConsecutiveFailures*") {
+		t.Errorf("unexpected slack text: %q", slackBody.Text)
+	}
+
+	request := webhook.Requests()[0]
+	if request.Header.Get(notify.SignatureHeader) != "sha256="+notify.WebhookSign(request.Body, "hook-secret") {
+		t.Errorf("unexpected webhook signature: %s", request.Header.Get(notify.SignatureHeader))
+	}
+	var payload struct {
+		Kind   string        `json:"kind"`
+		Alerts []store.Alert `json:"alerts"`
+	}
+	json.Unmarshal(request.Body, &payload)
+	if payload.Kind != config.MessageAlert || len(payload.Alerts) != 1 || payload.Alerts[0].Labels["job"] != "api" {
+		t.Errorf("unexpected webhook payload: %s", request.Body)
+	}
+}
+
+func TestNotifyRetryAndDeadLetter(t *testing.T) {
+	robot := newRobot(t)
+	notifier, deadLetterPath := newNotifier(t, []map[string]interface{}{
+		{"name": "dingtalk", "type": "dingtalk", "url": robot.URL()},
+	}, []map[string]interface{}{
+		{"channels": []string{"dingtalk"}},
+	})
+	msg := notify.Message{Kind: config.MessageReport, Title: "构建日报"}
+
+	// 前两次失败，第三次重试成功
+	robot.FailNext(2)
+	if err := notifier.Notify(context.Background(), msg); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if n := len(robot.Requests()); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+
+	// 机器人返回业务错误时同样重试，全部失败后写入死信
+	robot.SetResponse(http.StatusOK, `{"errcode":310000,"errmsg":"sign not match"}`)
+	if err := notifier.Notify(context.Background(), msg); err == nil || !strings.Contains(err.Error(), "sign not match") {
+		t.Fatalf("expected sign error, got %v", err)
+	}
+
+	f, err := os.Open(deadLetterPath)
+	if err != nil {
+		t.Fatalf("open dead letter: %v", err)
+	}
+	defer f.Close()
+	var letters []notify.DeadLetter
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var letter notify.DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			t.Fatalf("decode dead letter: %v", err)
+		}
+		letters = append(letters, letter)
+	}
+	if len(letters) != 1 || letters[0].Channel != "dingtalk" || letters[0].Attempts != 3 || letters[0].Message.Title != "构建日报" {
+		t.Errorf("unexpected dead letters: %+v", letters)
+	}
+}
+
+func TestNotifyEmail(t *testing.T) {
+	server, err := fake.NewSMTPServer()
+	if err != nil {
+		t.Fatalf("NewSMTPServer: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	notifier, _ := newNotifier(t, []map[string]interface{}{
+		{"name": "email", "type": "email", "smtp": map[string]interface{}{
+			"host":     "127.0.0.1",
+			"port":     server.Addr().Port,
+			"username": "devops",
+			"password": "secret",
+			"from":     "devops@example.com",
+			"to":       []string{"ops@example.com", "dev@example.com"},
+		}},
+	}, []map[string]interface{}{
+		{"kinds": []string{"report"}, "channels": []string{"email"}},
+	})
+
+	// 告警不会路由到邮件
+	notifier.Notify(context.Background(), notify.Message{Kind: config.MessageAlert, Title: "告警"})
+	if err := notifier.Notify(context.Background(), notify.Message{Kind: config.MessageReport, Title: "构建日报", Text: "今日构建 42 次"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	mail := server.Mail()
+	if len(mail) != 1 {
+		t.Fatalf("expected 1 mail, got %+v", mail)
+	}
+	if mail[0].From != "devops@example.com" || len(mail[0].To) != 2 || mail[0].Username != "devops" {
+		t.Errorf("unexpected envelope: %+v", mail[0])
+	}
+	if !strings.Contains(mail[0].Data, "Subject: =?UTF-8?b?") || !strings.Contains(mail[0].Data, "今日构建 42 次") {
+		t.Errorf("unexpected mail data: %s", mail[0].Data)
+	}
+}
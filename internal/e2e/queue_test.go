@@ -0,0 +1,90 @@
+﻿package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+	"github.com/zuoyangs/go-devops-observability/internal/router"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+func TestQueueAndAgents(t *testing.T) {
+	env := newTestEnv(t)
+	env.jenkins.AddJob("api")
+	env.jenkins.SetQueue([]fake.QueueItem{
+		{ID: 11, JobName: "api", Why: "Waiting for next available executor on ‘linux’", InQueueSince: time.Now().Add(-time.Minute), Buildable: true},
+		{ID: 12, JobName: "api", Why: "Build #3 is already in progress", InQueueSince: time.Now().Add(-2 * time.Minute), Blocked: true},
+	})
+	env.jenkins.SetComputers([]fake.Computer{
+		{Name: "built-in", NumExecutors: 2, BusyExecutors: 1, DiskSpace: 10 << 30, ResponseTime: 5},
+		{Name: "agent-1", Labels: []string{"linux"}, NumExecutors: 4, BusyExecutors: 4, DiskSpace: 1 << 30, ResponseTime: 20},
+		{Name: "agent-2", Labels: []string{"linux"}, NumExecutors: 4, Offline: true, OfflineReason: "disconnected", DiskSpace: -1, ResponseTime: -1},
+	})
+
+	env.collect(t, nil)
+
+	var queue struct {
+		Metrics struct {
+			Queues []router.JenkinsQueueStats `json:"queues"`
+		} `json:"构建队列指标"`
+	}
+	if code := env.get(t, "/metrics/queue", &queue); code != http.StatusOK {
+		t.Fatalf("GET /metrics/queue: status %d", code)
+	}
+	if len(queue.Metrics.Queues) != 1 {
+		t.Fatalf("expected 1 queue, got %+v", queue.Metrics.Queues)
+	}
+	if stats := queue.Metrics.Queues[0]; stats.Length != 2 || stats.BlockedCount != 1 || stats.ByLabel["linux"] != 1 {
+		t.Errorf("unexpected queue stats: %+v", stats)
+	}
+
+	// 轮询看到的队列项开始执行后，排队耗时只是采样得到的
+	env.jenkins.SetQueue(nil)
+	env.jenkins.AddBuild("api", fake.Build{Number: 1, Result: "SUCCESS", QueueID: 11, Timestamp: time.Now(), Duration: time.Minute})
+	env.collect(t, nil)
+
+	var waits struct {
+		Metrics struct {
+			Jobs []router.QueueWaitStats `json:"jobs"`
+		} `json:"构建队列指标"`
+	}
+	if code := env.get(t, "/metrics/queue", &waits); code != http.StatusOK {
+		t.Fatalf("GET /metrics/queue: status %d", code)
+	}
+	if len(waits.Metrics.Jobs) != 1 {
+		t.Fatalf("expected 1 job, got %+v", waits.Metrics.Jobs)
+	}
+	if stats := waits.Metrics.Jobs[0]; stats.BuildCount != 1 || stats.QueueKnownCount != 1 || !stats.QueueSampled {
+		t.Errorf("unexpected queue wait stats: %+v", stats)
+	}
+
+	var agents struct {
+		Metrics struct {
+			Nodes   []store.JenkinsNode       `json:"nodes"`
+			Offline []router.NodeOfflineStats `json:"offline"`
+		} `json:"构建节点指标"`
+	}
+	if code := env.get(t, "/metrics/agents", &agents); code != http.StatusOK {
+		t.Fatalf("GET /metrics/agents: status %d", code)
+	}
+	if len(agents.Metrics.Nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %+v", agents.Metrics.Nodes)
+	}
+	for _, node := range agents.Metrics.Nodes {
+		switch node.Name {
+		case "agent-1":
+			if node.BusyExecutors != 4 || node.DiskSpace != 1<<30 || len(node.Labels) != 1 {
+				t.Errorf("agent-1: %+v", node)
+			}
+		case "agent-2":
+			if !node.Offline || node.OfflineReason != "disconnected" || node.DiskSpace != -1 {
+				t.Errorf("agent-2: %+v", node)
+			}
+		}
+	}
+	if len(agents.Metrics.Offline) != 1 || !agents.Metrics.Offline[0].CurrentlyOffline {
+		t.Errorf("unexpected offline stats: %+v", agents.Metrics.Offline)
+	}
+}
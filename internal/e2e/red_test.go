@@ -0,0 +1,90 @@
+﻿package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+	"github.com/zuoyangs/go-devops-observability/internal/router"
+)
+
+func TestRedJobs(t *testing.T) {
+	env := newTestEnv(t)
+	start := time.Now().Add(-48 * time.Hour)
+	at := func(hours int) time.Time {
+		return start.Add(time.Duration(hours) * time.Hour)
+	}
+	byUser := func(user string) []jenkins_api.Cause {
+		return []jenkins_api.Cause{fake.UserCause(user)}
+	}
+
+	// api 从 #2 开始失败，中止的 #3 不打断连续失败
+	env.jenkins.AddBuild("api", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: at(1), Commit: "c1"})
+	env.jenkins.AddBuild("api", fake.Build{Number: 2, Result: "FAILURE", Timestamp: at(10), Causes: byUser("alice")})
+	env.jenkins.AddBuild("api", fake.Build{Number: 3, Result: "ABORTED", Timestamp: at(11), Causes: byUser("carol")})
+	env.jenkins.AddBuild("api", fake.Build{Number: 4, Result: "FAILURE", Timestamp: at(12), Causes: byUser("bob")})
+	env.jenkins.AddBuild("api", fake.Build{Number: 5, Result: "FAILURE", Timestamp: at(13), Causes: byUser("alice")})
+	env.jenkins.AddBuild("api", fake.Build{Number: 6, Building: true, Timestamp: at(14)})
+
+	// web 失败得更早，且没有成功过
+	env.jenkins.AddBuild("team/web", fake.Build{Number: 1, Result: "FAILURE", Timestamp: at(2)})
+
+	// 最近一次构建成功的 job 不在列表中
+	env.jenkins.AddBuild("ok", fake.Build{Number: 1, Result: "FAILURE", Timestamp: at(1)})
+	env.jenkins.AddBuild("ok", fake.Build{Number: 2, Result: "SUCCESS", Timestamp: at(2)})
+	// UNSTABLE 结束连续失败
+	env.jenkins.AddBuild("flaky", fake.Build{Number: 1, Result: "FAILURE", Timestamp: at(1)})
+	env.jenkins.AddBuild("flaky", fake.Build{Number: 2, Result: "UNSTABLE", Timestamp: at(2)})
+
+	env.collect(t, nil)
+
+	var response struct {
+		Jobs []router.RedJob `json:"当前失败的job"`
+	}
+	if code := env.get(t, "/metrics/red", &response); code != http.StatusOK {
+		t.Fatalf("GET /metrics/red: status %d", code)
+	}
+	if len(response.Jobs) != 2 || response.Jobs[0].JobName != "team/web" || response.Jobs[1].JobName != "api" {
+		t.Fatalf("unexpected red jobs: %+v", response.Jobs)
+	}
+
+	web := response.Jobs[0]
+	if web.ConsecutiveFailures != 1 || web.LastGoodBuild != nil || web.RedSeconds < 45*3600 {
+		t.Errorf("web: %+v", web)
+	}
+
+	api := response.Jobs[1]
+	if api.ConsecutiveFailures != 3 || api.RedSince != at(10).UnixMilli() {
+		t.Errorf("api streak: %+v", api)
+	}
+	if api.LastGoodBuild == nil || api.LastGoodBuild.Number != 1 || api.LastGoodBuild.Commit != "c1" {
+		t.Errorf("api last good build: %+v", api.LastGoodBuild)
+	}
+	if len(api.FailedBuilds) != 3 || api.FailedBuilds[0].Number != 5 {
+		t.Errorf("api failed builds: %+v", api.FailedBuilds)
+	}
+	if len(api.TriggeredBy) != 2 || api.TriggeredBy[0] != "alice" || api.TriggeredBy[1] != "bob" {
+		t.Errorf("api triggered by: %v", api.TriggeredBy)
+	}
+
+	if code := env.get(t, "/metrics/red?minFailures=2", &response); code != http.StatusOK || len(response.Jobs) != 1 {
+		t.Errorf("minFailures=2: status %d, %+v", code, response.Jobs)
+	}
+
+	// 分支稳定性指标中的连续失败次数与红灯 job 的计算方式一致
+	var branches struct {
+		Stats []router.BranchStabilityStats `json:"分支发布稳定性指标"`
+	}
+	if code := env.get(t, "/metrics/branches?groupBy=job", &branches); code != http.StatusOK {
+		t.Fatalf("GET /metrics/branches: status %d", code)
+	}
+	streaks := make(map[string]int)
+	for _, stats := range branches.Stats {
+		streaks[stats.JobName] = stats.ConsecutiveFailures
+	}
+	if streaks["api"] != 3 || streaks["team/web"] != 1 || streaks["ok"] != 0 || streaks["flaky"] != 0 {
+		t.Errorf("unexpected branch streaks: %v", streaks)
+	}
+}
@@ -0,0 +1,74 @@
+﻿package e2e
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+	"github.com/zuoyangs/go-devops-observability/internal/replay"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	env := newTestEnv(t)
+	env.jenkins.AddBuild("api", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: todayAt(2 * time.Second), Console: "token=" + password})
+	env.jenkins.AddBuild("api", fake.Build{Number: 2, Result: "FAILURE", Timestamp: todayAt(time.Second), Console: "ERROR: password " + password})
+
+	dir := t.TempDir()
+	recordTransport, err := replay.NewTransport(replay.ModeRecord, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.collect(t, &http.Client{Transport: recordTransport})
+
+	var recorded metricsResponse
+	env.get(t, "/metrics", &recorded)
+	if len(recorded.Stats) != 1 || recorded.Stats[0].TodayTotalCount != 2 {
+		t.Fatalf("unexpected recorded stats: %+v", recorded.Stats)
+	}
+
+	// fixture 中不能出现凭据
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(string(data), password) {
+			t.Errorf("fixture %s contains credentials", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 关闭模拟 Jenkins 后从 fixture 回放，采集结果应与录制时一致
+	env.jenkins.Close()
+	env.store = store.New("", 90)
+	env.engine = newEngine(env.store)
+
+	replayTransport, err := replay.NewTransport(replay.ModeReplay, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.collect(t, &http.Client{Transport: replayTransport})
+
+	var replayed metricsResponse
+	env.get(t, "/metrics", &replayed)
+	if len(replayed.Stats) != 1 || replayed.Stats[0] != recorded.Stats[0] {
+		t.Errorf("replayed stats %+v differ from recorded %+v", replayed.Stats, recorded.Stats)
+	}
+
+	for _, build := range env.store.JenkinsBuilds() {
+		if build.Number == 2 && build.FailureCategory == "" {
+			t.Errorf("console log should be replayed: %+v", build)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+﻿package e2e
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+	"github.com/zuoyangs/go-devops-observability/internal/report"
+)
+
+func TestDailyReport(t *testing.T) {
+	env := newTestEnv(t)
+
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 上上周三（北京时间）的构建，在周四 09:00 发送日报
+	now := time.Now().In(location)
+	wednesday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	wednesday = wednesday.AddDate(0, 0, -(int(wednesday.Weekday())+4)%7-7)
+	at := func(hour int) time.Time {
+		return wednesday.Add(time.Duration(hour) * time.Hour)
+	}
+
+	viper.Set(config.WorkingHoursConfigKey, map[string]interface{}{"timezone": "Asia/Shanghai"})
+	viper.Set(config.TeamsConfigKey, map[string]interface{}{
+		"platform": map[string]interface{}{"jobs": []string{"api"}},
+	})
+
+	env.jenkins.AddBuild("api", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: at(10)})
+	env.jenkins.AddBuild("api", fake.Build{Number: 2, Result: "FAILURE", Timestamp: at(11)})
+	env.jenkins.AddBuild("api", fake.Build{Number: 3, Result: "FAILURE", Timestamp: at(23)})
+	env.jenkins.AddBuild("api", fake.Build{Number: 4, Result: "FAILURE", Timestamp: at(32)})
+	env.jenkins.AddBuild("web", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: at(12)})
+	env.jenkins.AddBuild("web", fake.Build{Number: 2, Building: true, Timestamp: at(13)})
+	env.collect(t, nil)
+
+	sender := &recordingSender{}
+	daily := report.NewDaily(env.store, sender, &config.Notify{DailyReport: "09:00"})
+	ctx := context.Background()
+	due := at(24 + 9)
+
+	daily.Check(ctx, due.Add(-2*time.Minute))
+	daily.Check(ctx, due.Add(-time.Minute))
+	if len(sender.messages) != 0 {
+		t.Fatalf("expected no report before 09:00, got %+v", sender.messages)
+	}
+
+	daily.Check(ctx, due)
+	daily.Check(ctx, due.Add(time.Minute))
+	if len(sender.messages) != 2 {
+		t.Fatalf("expected global and platform reports once, got %+v", sender.messages)
+	}
+
+	global, platform := sender.messages[0], sender.messages[1]
+	title := "构建日报 " + wednesday.Format("2006-01-02")
+	if global.Kind != config.MessageReport || global.Title != title || global.Labels[report.LabelTeam] != "" {
+		t.Errorf("unexpected global report: %+v", global)
+	}
+	for _, want := range []string{"构建 4 次，成功 2 次，失败 2 次，成功率 50.0%", "非工作时间构建 1 次", "- api（" + instanceName + "）失败 2 次"} {
+		if !strings.Contains(global.Text, want) {
+			t.Errorf("global report missing %q:\n%s", want, global.Text)
+		}
+	}
+	if platform.Title != title+" platform" || platform.Labels[report.LabelTeam] != "platform" || !strings.Contains(platform.Text, "构建 3 次，成功 1 次，失败 2 次") {
+		t.Errorf("unexpected platform report: %+v", platform)
+	}
+}
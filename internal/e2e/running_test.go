@@ -0,0 +1,133 @@
+﻿package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/alert"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/fake"
+	"github.com/zuoyangs/go-devops-observability/internal/router"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+func TestRunningBuilds(t *testing.T) {
+	env := newTestEnv(t)
+	viper.Set(config.RunningBuildsConfigKey, map[string]interface{}{"overrunFactor": 2, "stuckAfter": "6h"})
+	viper.Set(config.AlertRulesConfigKey, []map[string]interface{}{
+		{"name": "BuildOverrun", "type": "buildOverrun"},
+		{"name": "BuildStuck", "type": "buildStuck", "threshold": 5},
+	})
+
+	now := time.Now()
+	// 运行中构建的 Duration 为 Jenkins 估算的预计时长
+	env.jenkins.AddBuild("api", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: now.Add(-2 * time.Hour), Duration: 10 * time.Minute})
+	env.jenkins.AddBuild("api", fake.Build{Number: 2, Building: true, Timestamp: now.Add(-25 * time.Minute), Duration: 10 * time.Minute})
+	env.jenkins.AddBuild("web", fake.Build{Number: 7, Building: true, Timestamp: now.Add(-5*time.Hour - 30*time.Minute), Duration: 4 * time.Hour})
+	env.jenkins.AddBuild("docs", fake.Build{Number: 3, Building: true, Timestamp: now.Add(-time.Minute), Duration: 5 * time.Minute})
+	env.jenkins.AddBuild("nightly", fake.Build{Number: 1, Building: true, Timestamp: now.Add(-7 * time.Hour)})
+	env.collect(t, nil)
+
+	var response struct {
+		Summary router.RunningBuildsSummary `json:"运行中的构建"`
+	}
+	if code := env.get(t, "/metrics/running", &response); code != http.StatusOK {
+		t.Fatalf("GET /metrics/running: status %d", code)
+	}
+	summary := response.Summary
+	if summary.RunningCount != 4 || summary.OverrunCount != 1 || summary.StuckCount != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	statuses := make(map[string]*router.RunningBuild)
+	for _, build := range summary.Builds {
+		statuses[build.JobName] = build
+	}
+	if summary.Builds[0].JobName != "nightly" || statuses["nightly"].Status != config.RunningStuck {
+		t.Errorf("nightly: %+v", statuses["nightly"])
+	}
+	if api := statuses["api"]; api.Status != config.RunningOverrun || api.EstimatedSeconds != 600 || api.Progress < 240 {
+		t.Errorf("api: %+v", api)
+	}
+	if statuses["web"].Status != config.RunningNormal || statuses["docs"].Status != config.RunningNormal {
+		t.Errorf("web: %+v, docs: %+v", statuses["web"], statuses["docs"])
+	}
+
+	if code := env.get(t, "/metrics/running?status=stuck", &response); code != http.StatusOK || len(response.Summary.Builds) != 1 {
+		t.Errorf("status=stuck: status %d, %+v", code, response.Summary.Builds)
+	}
+
+	// 卡住告警的阈值覆盖为 5 小时，web 也视为卡住
+	engine := alert.NewEngine(env.store)
+	engine.Evaluate(now)
+	fired := make(map[string]string)
+	for _, item := range env.alerts(t, store.AlertFiring) {
+		fired[item.Rule+"/"+item.Labels["job"]] = item.Labels["build"]
+	}
+	// nightly 没有预计时长，不判断超时
+	if len(fired) != 3 || fired["BuildOverrun/api"] != "2" || fired["BuildStuck/nightly"] != "1" || fired["BuildStuck/web"] != "7" {
+		t.Errorf("unexpected alerts: %v", fired)
+	}
+
+	// 构建结束后告警恢复
+	env.jenkins.AddBuild("api", fake.Build{Number: 2, Result: "SUCCESS", Timestamp: now.Add(-25 * time.Minute), Duration: 26 * time.Minute})
+	env.collect(t, nil)
+	engine.Evaluate(now.Add(time.Minute))
+	for _, item := range env.alerts(t, store.AlertResolved) {
+		if item.Rule == "BuildOverrun" && item.Labels["job"] == "api" {
+			return
+		}
+	}
+	t.Errorf("expected BuildOverrun/api to be resolved")
+}
+
+func TestStaleRunningBuilds(t *testing.T) {
+	env := newTestEnv(t)
+	viper.Set(config.AlertRulesConfigKey, []map[string]interface{}{
+		{"name": "BuildStuck", "type": "buildStuck"},
+	})
+	// job 接口只返回最近 2 个构建
+	env.jenkins.SetHistoryLimit(2)
+
+	now := time.Now()
+	env.jenkins.AddBuild("api", fake.Build{Number: 1, Building: true, Timestamp: now.Add(-30 * time.Minute)})
+	env.collect(t, nil)
+
+	// #1 的结束通知丢失，之后又被新构建挤出构建历史，采集时单独获取它的详情
+	env.jenkins.AddBuild("api", fake.Build{Number: 1, Result: "SUCCESS", Timestamp: now.Add(-30 * time.Minute), Duration: 20 * time.Minute})
+	env.jenkins.AddBuild("api", fake.Build{Number: 2, Result: "SUCCESS", Timestamp: now.Add(-5 * time.Minute)})
+	env.jenkins.AddBuild("api", fake.Build{Number: 3, Result: "SUCCESS", Timestamp: now.Add(-time.Minute)})
+	env.collect(t, nil)
+
+	// 已删除的 job 上的构建不会再被采集，超过 3 个轮询间隔（默认 5 分钟）没有更新后视为失联
+	env.store.UpsertJenkinsBuild(store.JenkinsBuild{
+		JenkinsInstanceName: instanceName,
+		JobName:             "deleted",
+		Number:              5,
+		Building:            true,
+		Timestamp:           now.Add(-8 * time.Hour).UnixMilli(),
+		Source:              store.SourcePush,
+		UpdatedAt:           now.Add(-time.Hour),
+	})
+
+	var response struct {
+		Summary router.RunningBuildsSummary `json:"运行中的构建"`
+	}
+	if code := env.get(t, "/metrics/running", &response); code != http.StatusOK {
+		t.Fatalf("GET /metrics/running: status %d", code)
+	}
+	summary := response.Summary
+	if summary.RunningCount != 0 || summary.StuckCount != 0 || summary.StaleCount != 1 || len(summary.Builds) != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if build := summary.Builds[0]; build.JobName != "deleted" || build.Status != config.RunningStale {
+		t.Errorf("unexpected stale build: %+v", build)
+	}
+
+	// 失联的构建不触发卡住告警
+	alert.NewEngine(env.store).Evaluate(now)
+	if alerts := env.alerts(t, ""); len(alerts) != 0 {
+		t.Errorf("unexpected alerts: %+v", alerts)
+	}
+}
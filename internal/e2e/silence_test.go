@@ -0,0 +1,254 @@
+﻿package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/alert"
+	"github.com/zuoyangs/go-devops-observability/internal/notify"
+	"github.com/zuoyangs/go-devops-observability/internal/router"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 记录发送的通知
+type recordingSender struct {
+	messages []notify.Message
+}
+
+func (r *recordingSender) Notify(ctx context.Context, msg notify.Message) error {
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+// 取出并清空已记录的通知标题
+func (r *recordingSender) titles() []string {
+	var titles []string
+	for _, msg := range r.messages {
+		titles = append(titles, msg.Title)
+	}
+	r.messages = nil
+	return titles
+}
+
+func firingAlert(rule string, labels map[string]string) store.Alert {
+	all := map[string]string{"alertname": rule, "severity": "warning"}
+	for name, value := range labels {
+		all[name] = value
+	}
+	var fingerprint strings.Builder
+	fingerprint.WriteString(rule)
+	for _, name := range []string{"instance", "job"} {
+		fingerprint.WriteString("/" + labels[name])
+	}
+	return store.Alert{Fingerprint: fingerprint.String(), Rule: rule, State: store.AlertFiring, Labels: all}
+}
+
+func TestAlertGroupingSilenceAndInhibition(t *testing.T) {
+	env := newTestEnv(t)
+	viper.Set(config.NotifyConfigKey, map[string]interface{}{
+		"groupBy":        []string{"alertname"},
+		"groupWait":      "30s",
+		"groupInterval":  "5m",
+		"repeatInterval": "4h",
+		"inhibitRules": []map[string]interface{}{{
+			"sourceMatch": map[string]string{"alertname": "JenkinsInstanceDown"},
+			"targetMatch": map[string]string{"alertname": "ConsecutiveFailures"},
+			"equal":       []string{"instance"},
+		}},
+	})
+	cfg, err := config.GetNotify()
+	if err != nil {
+		t.Fatalf("GetNotify: %v", err)
+	}
+
+	env.store.ReplaceAlerts([]store.Alert{
+		firingAlert("JenkinsInstanceDown", map[string]string{"instance": "a"}),
+		firingAlert("ConsecutiveFailures", map[string]string{"instance": "a", "job": "x"}),
+		firingAlert("ConsecutiveFailures", map[string]string{"instance": "b", "job": "y"}),
+		firingAlert("ConsecutiveFailures", map[string]string{"instance": "b", "job": "z"}),
+	})
+
+	sender := &recordingSender{}
+	dispatcher := alert.NewDispatcher(env.store, sender, cfg)
+	ctx := context.Background()
+	now := time.Now()
+
+	// groupWait 内不发送，之后每个分组合并为一条通知，实例 a 上的 job 告警被抑制
+	dispatcher.Flush(ctx, now)
+	if titles := sender.titles(); len(titles) != 0 {
+		t.Fatalf("expected no notification within groupWait, got %v", titles)
+	}
+	dispatcher.Flush(ctx, now.Add(31*time.Second))
+	titles := sender.titles()
+	if len(titles) != 2 || !contains(titles, "[FIRING:2] alertname=ConsecutiveFailures") || !contains(titles, "[FIRING:1] alertname=JenkinsInstanceDown") {
+		t.Fatalf("unexpected notifications: %v", titles)
+	}
+
+	// 通过接口创建静默，需要携带 silences.token，正则表达式无效时返回 400
+	viper.Set(config.SilencesConfigKey, map[string]interface{}{"token": "silence-token"})
+	postSilence := func(token string, matchers []store.Matcher) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(router.SilenceRequest{
+			Matchers:  matchers,
+			Duration:  "1h",
+			CreatedBy: "oncall",
+			Comment:   "z 正在修复",
+		})
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/silences", bytes.NewReader(body))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		env.engine.ServeHTTP(recorder, request)
+		return recorder
+	}
+	matchers := []store.Matcher{{Name: "job", Value: "z|w", Operator: store.MatchRegexp}}
+	if recorder := postSilence("", matchers); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("POST /api/v1/silences without token: status %d", recorder.Code)
+	}
+	if recorder := postSilence("wrong", matchers); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("POST /api/v1/silences with wrong token: status %d", recorder.Code)
+	}
+	if recorder := postSilence("silence-token", []store.Matcher{{Name: "job", Value: "(z", Operator: store.MatchRegexp}}); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("POST /api/v1/silences with invalid regexp: status %d", recorder.Code)
+	}
+	recorder := postSilence("silence-token", matchers)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("POST /api/v1/silences: status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var created struct {
+		Silence router.SilenceStatus `json:"静默"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &created)
+	if created.Silence.ID == "" || created.Silence.State != store.SilenceActive {
+		t.Fatalf("unexpected silence: %+v", created.Silence)
+	}
+
+	var alerts struct {
+		Alerts []router.AlertStatus `json:"告警"`
+	}
+	if code := env.get(t, "/api/v1/alerts?muted=true", &alerts); code != http.StatusOK {
+		t.Fatalf("GET /api/v1/alerts: status %d", code)
+	}
+	if len(alerts.Alerts) != 2 {
+		t.Fatalf("expected 2 muted alerts, got %+v", alerts.Alerts)
+	}
+	for _, item := range alerts.Alerts {
+		switch item.Labels["job"] {
+		case "x":
+			if len(item.InhibitedBy) != 1 || len(item.SilencedBy) != 0 {
+				t.Errorf("x should be inhibited: %+v", item)
+			}
+		case "z":
+			if len(item.SilencedBy) != 1 || item.SilencedBy[0] != created.Silence.ID {
+				t.Errorf("z should be silenced: %+v", item)
+			}
+		}
+	}
+
+	// 分组内容变化后至少间隔 groupInterval 才发送，内容不变时不重复发送
+	dispatcher.Flush(ctx, now.Add(2*time.Minute))
+	if titles := sender.titles(); len(titles) != 0 {
+		t.Fatalf("expected no notification within groupInterval, got %v", titles)
+	}
+	dispatcher.Flush(ctx, now.Add(6*time.Minute))
+	if titles := sender.titles(); len(titles) != 1 || titles[0] != "[FIRING:1] alertname=ConsecutiveFailures" {
+		t.Fatalf("unexpected notifications: %v", titles)
+	}
+	dispatcher.Flush(ctx, now.Add(7*time.Minute))
+	if titles := sender.titles(); len(titles) != 0 {
+		t.Fatalf("expected no duplicate notification, got %v", titles)
+	}
+
+	// 提前结束静默后 z 重新参与通知
+	request := httptest.NewRequest(http.MethodDelete, "/api/v1/silences/"+created.Silence.ID, nil)
+	recorder = httptest.NewRecorder()
+	env.engine.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("DELETE silence without token: status %d", recorder.Code)
+	}
+	request.Header.Set("Authorization", "Bearer silence-token")
+	recorder = httptest.NewRecorder()
+	env.engine.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("DELETE silence: status %d", recorder.Code)
+	}
+	dispatcher.Flush(ctx, now.Add(12*time.Minute))
+	if titles := sender.titles(); len(titles) != 1 || titles[0] != "[FIRING:2] alertname=ConsecutiveFailures" {
+		t.Fatalf("unexpected notifications: %v", titles)
+	}
+
+	// 实例恢复后发送一次恢复通知，被抑制的 x 在 groupInterval 之后重新参与通知
+	alertsNow := env.store.Alerts()
+	for i := range alertsNow {
+		if alertsNow[i].Rule == "JenkinsInstanceDown" {
+			resolvedAt := now.Add(15 * time.Minute)
+			alertsNow[i].State = store.AlertResolved
+			alertsNow[i].ResolvedAt = &resolvedAt
+		}
+	}
+	env.store.ReplaceAlerts(alertsNow)
+	dispatcher.Flush(ctx, now.Add(16*time.Minute))
+	if titles := sender.titles(); len(titles) != 1 || titles[0] != "[RESOLVED] alertname=JenkinsInstanceDown" {
+		t.Fatalf("expected resolved notification, got %v", titles)
+	}
+	dispatcher.Flush(ctx, now.Add(18*time.Minute))
+	if titles := sender.titles(); len(titles) != 1 || titles[0] != "[FIRING:3] alertname=ConsecutiveFailures" {
+		t.Fatalf("unexpected notifications: %v", titles)
+	}
+
+	// 没有变化的分组每隔 repeatInterval 重复提醒
+	dispatcher.Flush(ctx, now.Add(4*time.Hour))
+	if titles := sender.titles(); len(titles) != 0 {
+		t.Fatalf("expected no notification within repeatInterval, got %v", titles)
+	}
+	dispatcher.Flush(ctx, now.Add(4*time.Hour+18*time.Minute))
+	if titles := sender.titles(); len(titles) != 1 || titles[0] != "[FIRING:3] alertname=ConsecutiveFailures" {
+		t.Fatalf("unexpected repeat notifications: %v", titles)
+	}
+}
+
+func TestSilencesPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	s := store.New(path, 90)
+	now := time.Now()
+	silence, err := s.AddSilence(store.Silence{
+		Matchers:  []store.Matcher{{Name: "instance", Operator: store.MatchRegexp, Value: "a|b"}},
+		StartsAt:  now,
+		EndsAt:    now.Add(time.Hour),
+		CreatedBy: "oncall",
+		Comment:   "maintenance",
+	})
+	if err != nil {
+		t.Fatalf("AddSilence: %v", err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded := store.New(path, 90)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	silences := loaded.Silences()
+	if len(silences) != 1 || silences[0].ID != silence.ID || !silences[0].Mutes(map[string]string{"instance": "b"}, now) || silences[0].Mutes(map[string]string{"instance": "ab"}, now) {
+		t.Fatalf("unexpected silences after reload: %+v", silences)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
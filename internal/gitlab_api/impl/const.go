@@ -0,0 +1,11 @@
+﻿package impl
+
+import "time"
+
+const (
+	apiPrefix      = "/api/v4"
+	perPage        = 100
+	maxRetries     = 3
+	requestTimeout = time.Second * 10
+	retryBackoff   = time.Second
+)
@@ -1 +1,162 @@
 ﻿package impl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+)
+
+func NewGitlabServiceImpl(client *http.Client) *GitlabServiceImpl {
+	if client == nil {
+		client = &http.Client{
+			Timeout: requestTimeout,
+		}
+	}
+	return &GitlabServiceImpl{client: client, backoff: retryBackoff}
+}
+
+type GitlabServiceImpl struct {
+	client *http.Client
+	// 第 n 次重试前等待 n 倍的 backoff
+	backoff time.Duration
+}
+
+var _ gitlab_api.GitlabAPIInterface = (*GitlabServiceImpl)(nil)
+
+// 项目、group 既可以用数字 ID 也可以用完整路径表示，路径需要整体转义
+func escapeID(id string) string {
+	return url.PathEscape(id)
+}
+
+func setAuth(req *http.Request, base *gitlab_api.GitlabBaseRequest) error {
+	switch base.TokenType {
+	case "", gitlab_api.TokenTypePersonal, gitlab_api.TokenTypeProject:
+		// personal access token 与 project access token 均通过 PRIVATE-TOKEN 头认证
+		req.Header.Set("PRIVATE-TOKEN", base.Token)
+	default:
+		return fmt.Errorf("unsupported gitlab token type: %s", base.TokenType)
+	}
+	return nil
+}
+
+// 网络错误、限流以及服务端错误可以重试，其余错误直接返回
+func retryable(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// 发送 GET 请求并返回响应头和响应体，失败时最多重试 maxRetries 次
+func (g *GitlabServiceImpl) get(c context.Context, base *gitlab_api.GitlabBaseRequest, path string, query url.Values) (http.Header, []byte, error) {
+
+	if base == nil {
+		return nil, nil, errors.New("config is nil")
+	}
+
+	rawURL := strings.TrimRight(base.GitlabURL, "/") + apiPrefix + path
+	if len(query) > 0 {
+		rawURL += "?" + query.Encode()
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-c.Done():
+				return nil, nil, c.Err()
+			case <-time.After(g.backoff * time.Duration(attempt)):
+			}
+		}
+
+		req, err := http.NewRequestWithContext(c, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		if err := setAuth(req, base); err != nil {
+			return nil, nil, err
+		}
+
+		resp, err := g.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		bodyBytes, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+			return resp.Header, bodyBytes, nil
+		}
+
+		lastErr = fmt.Errorf("gitlab api %s: unexpected status %d: %s", path, resp.StatusCode, truncate(bodyBytes, 200))
+		if !retryable(resp) {
+			return nil, nil, lastErr
+		}
+	}
+
+	return nil, nil, lastErr
+}
+
+// 获取单个对象
+func (g *GitlabServiceImpl) getOne(c context.Context, base *gitlab_api.GitlabBaseRequest, path string, query url.Values, out interface{}) error {
+	_, bodyBytes, err := g.get(c, base, path, query)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bodyBytes, out)
+}
+
+// 逐页获取列表数据，直到 X-Next-Page 响应头为空
+func getAll[T any](c context.Context, g *GitlabServiceImpl, base *gitlab_api.GitlabBaseRequest, path string, query url.Values) ([]T, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("per_page", strconv.Itoa(perPage))
+
+	var items []T
+	page := "1"
+	for page != "" {
+		query.Set("page", page)
+
+		header, bodyBytes, err := g.get(c, base, path, query)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageItems []T
+		if err := json.Unmarshal(bodyBytes, &pageItems); err != nil {
+			return nil, err
+		}
+		items = append(items, pageItems...)
+
+		page = header.Get("X-Next-Page")
+	}
+
+	return items, nil
+}
+
+func setTime(query url.Values, key string, t *time.Time) {
+	if t != nil {
+		query.Set(key, t.UTC().Format(time.RFC3339))
+	}
+}
+
+func truncate(b []byte, n int) string {
+	if len(b) > n {
+		return string(b[:n]) + "..."
+	}
+	return string(b)
+}
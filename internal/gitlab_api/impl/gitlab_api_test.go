@@ -0,0 +1,178 @@
+﻿package impl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+)
+
+// 依次返回 statuses 中的状态码，之后一直返回 200，并记录请求次数
+type scriptedServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests int
+}
+
+func newScriptedServer(t *testing.T, statuses ...int) *scriptedServer {
+	s := &scriptedServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		status := http.StatusOK
+		if s.requests < len(s.statuses) {
+			status = s.statuses[s.requests]
+		}
+		s.requests++
+		s.mu.Unlock()
+
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"id": 1, "name": "api"}`)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *scriptedServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// 缩短重试等待时间的 service
+func newFastRetryService() *GitlabServiceImpl {
+	service := NewGitlabServiceImpl(nil)
+	service.backoff = time.Millisecond
+	return service
+}
+
+func TestGetRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantErr      bool
+		wantRequests int
+	}{
+		{"success", nil, false, 1},
+		{"server error then success", []int{http.StatusInternalServerError}, false, 2},
+		{"rate limited then success", []int{http.StatusTooManyRequests, http.StatusBadGateway}, false, 3},
+		{"retries exhausted", []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}, true, maxRetries},
+		{"not found is not retried", []int{http.StatusNotFound}, true, 1},
+		{"unauthorized is not retried", []int{http.StatusUnauthorized}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newScriptedServer(t, tt.statuses...)
+			service := newFastRetryService()
+
+			project, err := service.GetProject(context.Background(), &gitlab_api.GitlabProjectRequest{
+				GitlabBaseRequest: gitlab_api.GitlabBaseRequest{GitlabURL: server.URL, Token: "token"},
+				ProjectID:         "1",
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetProject() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && project.Name != "api" {
+				t.Errorf("GetProject() = %+v", project)
+			}
+			if got := server.requestCount(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestGetRetryCanceled(t *testing.T) {
+	server := newScriptedServer(t, http.StatusInternalServerError)
+	service := NewGitlabServiceImpl(nil)
+
+	// 重试等待期间 context 结束时立即返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := service.GetProject(ctx, &gitlab_api.GitlabProjectRequest{
+		GitlabBaseRequest: gitlab_api.GitlabBaseRequest{GitlabURL: server.URL, Token: "token"},
+		ProjectID:         "1",
+	})
+	if err != context.DeadlineExceeded || server.requestCount() != 1 {
+		t.Errorf("GetProject() error = %v after %d requests", err, server.requestCount())
+	}
+}
+
+func TestGetAllPagination(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  gitlab_api.GitlabProjectsRequest
+		pages    int
+		wantPath string
+	}{
+		{"single page", gitlab_api.GitlabProjectsRequest{Membership: true}, 1, "/api/v4/projects"},
+		{"three pages", gitlab_api.GitlabProjectsRequest{}, 3, "/api/v4/projects"},
+		{"group path is escaped", gitlab_api.GitlabProjectsRequest{GroupID: "pay/core", IncludeSubgroups: true}, 2, "/api/v4/groups/pay%2Fcore/projects"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.EscapedPath() != tt.wantPath || r.Header.Get("PRIVATE-TOKEN") != "token" || r.URL.Query().Get("per_page") != strconv.Itoa(perPage) {
+					t.Errorf("unexpected request: %s %v", r.URL, r.Header)
+				}
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				pages = append(pages, r.URL.Query().Get("page"))
+				if page < tt.pages {
+					w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+				}
+				fmt.Fprintf(w, `[{"id": %d}, {"id": %d}]`, page*10+1, page*10+2)
+			}))
+			defer server.Close()
+
+			tt.request.GitlabBaseRequest = gitlab_api.GitlabBaseRequest{GitlabURL: server.URL + "/", Token: "token"}
+			projects, err := NewGitlabServiceImpl(nil).ListProjects(context.Background(), &tt.request)
+			if err != nil {
+				t.Fatalf("ListProjects: %v", err)
+			}
+			if len(pages) != tt.pages || len(projects) != 2*tt.pages {
+				t.Fatalf("fetched pages %v, projects %+v", pages, projects)
+			}
+			if last := projects[len(projects)-1]; last.ID != tt.pages*10+2 {
+				t.Errorf("last project = %+v", last)
+			}
+		})
+	}
+}
+
+func TestGetAllStopsOnPageError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("X-Next-Page", "2")
+		fmt.Fprint(w, `[{"id": 1}]`)
+	}))
+	defer server.Close()
+
+	projects, err := newFastRetryService().ListProjects(context.Background(), &gitlab_api.GitlabProjectsRequest{
+		GitlabBaseRequest: gitlab_api.GitlabBaseRequest{GitlabURL: server.URL, Token: "token"},
+	})
+	if err == nil || projects != nil {
+		t.Errorf("ListProjects() = %+v, %v, want error", projects, err)
+	}
+}
+
+func TestUnsupportedTokenType(t *testing.T) {
+	server := newScriptedServer(t)
+	_, err := NewGitlabServiceImpl(nil).GetProject(context.Background(), &gitlab_api.GitlabProjectRequest{
+		GitlabBaseRequest: gitlab_api.GitlabBaseRequest{GitlabURL: server.URL, Token: "token", TokenType: "oauth"},
+		ProjectID:         "1",
+	})
+	if err == nil || server.requestCount() != 0 {
+		t.Errorf("GetProject() error = %v after %d requests", err, server.requestCount())
+	}
+}
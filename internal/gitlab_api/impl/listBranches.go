@@ -0,0 +1,24 @@
+﻿package impl
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+)
+
+// 获取项目的分支列表
+func (g *GitlabServiceImpl) ListBranches(c context.Context, config *gitlab_api.GitlabBranchesRequest) ([]gitlab_api.GitlabBranch, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	query := url.Values{}
+	if config.Search != "" {
+		query.Set("search", config.Search)
+	}
+
+	return getAll[gitlab_api.GitlabBranch](c, g, &config.GitlabBaseRequest, "/projects/"+escapeID(config.ProjectID)+"/repository/branches", query)
+}
@@ -0,0 +1,56 @@
+﻿package impl
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+)
+
+// 获取项目的提交记录
+func (g *GitlabServiceImpl) ListCommits(c context.Context, config *gitlab_api.GitlabCommitsRequest) ([]gitlab_api.GitlabCommit, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	query := url.Values{}
+	if config.RefName != "" {
+		query.Set("ref_name", config.RefName)
+	}
+	setTime(query, "since", config.Since)
+	setTime(query, "until", config.Until)
+
+	return getAll[gitlab_api.GitlabCommit](c, g, &config.GitlabBaseRequest, "/projects/"+escapeID(config.ProjectID)+"/repository/commits", query)
+}
+
+// 比较两个 revision 之间的提交
+func (g *GitlabServiceImpl) CompareCommits(c context.Context, config *gitlab_api.GitlabCompareRequest) (*gitlab_api.GitlabCompare, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	query := url.Values{}
+	query.Set("from", config.From)
+	query.Set("to", config.To)
+
+	var compare gitlab_api.GitlabCompare
+	if err := g.getOne(c, &config.GitlabBaseRequest, "/projects/"+escapeID(config.ProjectID)+"/repository/compare", query, &compare); err != nil {
+		return nil, err
+	}
+
+	return &compare, nil
+}
+
+// 获取引入指定提交的合并请求
+func (g *GitlabServiceImpl) ListCommitMergeRequests(c context.Context, config *gitlab_api.GitlabCommitMergeRequestsRequest) ([]gitlab_api.GitlabMergeRequest, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	path := "/projects/" + escapeID(config.ProjectID) + "/repository/commits/" + config.SHA + "/merge_requests"
+	return getAll[gitlab_api.GitlabMergeRequest](c, g, &config.GitlabBaseRequest, path, nil)
+}
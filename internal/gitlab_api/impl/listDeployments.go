@@ -0,0 +1,33 @@
+﻿package impl
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+)
+
+// 获取项目的部署记录
+func (g *GitlabServiceImpl) ListDeployments(c context.Context, config *gitlab_api.GitlabDeploymentsRequest) ([]gitlab_api.GitlabDeployment, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	query := url.Values{}
+	if config.Environment != "" {
+		query.Set("environment", config.Environment)
+	}
+	if config.Status != "" {
+		query.Set("status", config.Status)
+	}
+	// GitLab 要求按 updated_at 过滤时同时按 updated_at 排序
+	if config.UpdatedAfter != nil || config.UpdatedBefore != nil {
+		query.Set("order_by", "updated_at")
+	}
+	setTime(query, "updated_after", config.UpdatedAfter)
+	setTime(query, "updated_before", config.UpdatedBefore)
+
+	return getAll[gitlab_api.GitlabDeployment](c, g, &config.GitlabBaseRequest, "/projects/"+escapeID(config.ProjectID)+"/deployments", query)
+}
@@ -0,0 +1,38 @@
+﻿package impl
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+)
+
+// 获取 GitLab group 列表
+func (g *GitlabServiceImpl) ListGroups(c context.Context, config *gitlab_api.GitlabGroupsRequest) ([]gitlab_api.GitlabGroup, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	query := url.Values{}
+	query.Set("all_available", "true")
+	if config.Search != "" {
+		query.Set("search", config.Search)
+	}
+
+	return getAll[gitlab_api.GitlabGroup](c, g, &config.GitlabBaseRequest, "/groups", query)
+}
+
+// 获取指定 group 下的全部子孙 group
+func (g *GitlabServiceImpl) ListSubgroups(c context.Context, config *gitlab_api.GitlabSubgroupsRequest) ([]gitlab_api.GitlabGroup, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+	if config.GroupID == "" {
+		return nil, errors.New("group id is empty")
+	}
+
+	return getAll[gitlab_api.GitlabGroup](c, g, &config.GitlabBaseRequest, "/groups/"+escapeID(config.GroupID)+"/descendant_groups", nil)
+}
@@ -0,0 +1,56 @@
+﻿package impl
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+)
+
+// 获取项目的合并请求列表
+func (g *GitlabServiceImpl) ListMergeRequests(c context.Context, config *gitlab_api.GitlabMergeRequestsRequest) ([]gitlab_api.GitlabMergeRequest, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	query := url.Values{}
+	if config.State != "" {
+		query.Set("state", config.State)
+	}
+	if config.TargetBranch != "" {
+		query.Set("target_branch", config.TargetBranch)
+	}
+	setTime(query, "updated_after", config.UpdatedAfter)
+	setTime(query, "updated_before", config.UpdatedBefore)
+
+	return getAll[gitlab_api.GitlabMergeRequest](c, g, &config.GitlabBaseRequest, "/projects/"+escapeID(config.ProjectID)+"/merge_requests", query)
+}
+
+// 获取合并请求的评论及系统事件，按创建时间升序
+func (g *GitlabServiceImpl) ListMergeRequestNotes(c context.Context, config *gitlab_api.GitlabMergeRequestNotesRequest) ([]gitlab_api.GitlabNote, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	query := url.Values{}
+	query.Set("sort", "asc")
+	query.Set("order_by", "created_at")
+
+	path := "/projects/" + escapeID(config.ProjectID) + "/merge_requests/" + strconv.Itoa(config.MergeRequestIID) + "/notes"
+	return getAll[gitlab_api.GitlabNote](c, g, &config.GitlabBaseRequest, path, query)
+}
+
+// 获取合并请求的文件变更
+func (g *GitlabServiceImpl) ListMergeRequestDiffs(c context.Context, config *gitlab_api.GitlabMergeRequestDiffsRequest) ([]gitlab_api.GitlabDiff, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	path := "/projects/" + escapeID(config.ProjectID) + "/merge_requests/" + strconv.Itoa(config.MergeRequestIID) + "/diffs"
+	return getAll[gitlab_api.GitlabDiff](c, g, &config.GitlabBaseRequest, path, nil)
+}
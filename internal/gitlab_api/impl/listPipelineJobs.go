@@ -0,0 +1,26 @@
+﻿package impl
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+)
+
+// 获取流水线下的 job 列表
+func (g *GitlabServiceImpl) ListPipelineJobs(c context.Context, config *gitlab_api.GitlabJobsRequest) ([]gitlab_api.GitlabJob, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	query := url.Values{}
+	for _, scope := range config.Scope {
+		query.Add("scope[]", scope)
+	}
+
+	path := "/projects/" + escapeID(config.ProjectID) + "/pipelines/" + strconv.Itoa(config.PipelineID) + "/jobs"
+	return getAll[gitlab_api.GitlabJob](c, g, &config.GitlabBaseRequest, path, query)
+}
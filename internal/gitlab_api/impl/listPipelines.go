@@ -0,0 +1,49 @@
+﻿package impl
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+)
+
+// 获取项目的流水线列表
+func (g *GitlabServiceImpl) ListPipelines(c context.Context, config *gitlab_api.GitlabPipelinesRequest) ([]gitlab_api.GitlabPipeline, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	query := url.Values{}
+	if config.Ref != "" {
+		query.Set("ref", config.Ref)
+	}
+	if config.Status != "" {
+		query.Set("status", config.Status)
+	}
+	if config.Source != "" {
+		query.Set("source", config.Source)
+	}
+	setTime(query, "updated_after", config.UpdatedAfter)
+	setTime(query, "updated_before", config.UpdatedBefore)
+
+	return getAll[gitlab_api.GitlabPipeline](c, g, &config.GitlabBaseRequest, "/projects/"+escapeID(config.ProjectID)+"/pipelines", query)
+}
+
+// 获取单条流水线详情
+func (g *GitlabServiceImpl) GetPipeline(c context.Context, config *gitlab_api.GitlabPipelineRequest) (*gitlab_api.GitlabPipeline, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	var pipeline gitlab_api.GitlabPipeline
+	path := "/projects/" + escapeID(config.ProjectID) + "/pipelines/" + strconv.Itoa(config.PipelineID)
+	if err := g.getOne(c, &config.GitlabBaseRequest, path, nil, &pipeline); err != nil {
+		return nil, err
+	}
+
+	return &pipeline, nil
+}
@@ -0,0 +1,50 @@
+﻿package impl
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+)
+
+// 获取 GitLab 项目列表，指定 group 时可包含子 group 下的项目
+func (g *GitlabServiceImpl) ListProjects(c context.Context, config *gitlab_api.GitlabProjectsRequest) ([]gitlab_api.GitlabProject, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	query := url.Values{}
+	query.Set("archived", "false")
+	query.Set("simple", "false")
+	if config.Search != "" {
+		query.Set("search", config.Search)
+	}
+
+	if config.GroupID != "" {
+		query.Set("include_subgroups", strconv.FormatBool(config.IncludeSubgroups))
+		return getAll[gitlab_api.GitlabProject](c, g, &config.GitlabBaseRequest, "/groups/"+escapeID(config.GroupID)+"/projects", query)
+	}
+
+	if config.Membership {
+		query.Set("membership", "true")
+	}
+	return getAll[gitlab_api.GitlabProject](c, g, &config.GitlabBaseRequest, "/projects", query)
+}
+
+// 获取单个 GitLab 项目
+func (g *GitlabServiceImpl) GetProject(c context.Context, config *gitlab_api.GitlabProjectRequest) (*gitlab_api.GitlabProject, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	var project gitlab_api.GitlabProject
+	if err := g.getOne(c, &config.GitlabBaseRequest, "/projects/"+escapeID(config.ProjectID), nil, &project); err != nil {
+		return nil, err
+	}
+
+	return &project, nil
+}
@@ -1 +1,53 @@
 ﻿package gitlab_api
+
+import (
+	"context"
+)
+
+type GitlabAPIInterface interface {
+
+	//查询 GitLab 项目列表，指定 GroupID 时只返回该 group（可包含子 group）下的项目
+	ListProjects(context.Context, *GitlabProjectsRequest) ([]GitlabProject, error)
+
+	//查询单个项目
+	GetProject(context.Context, *GitlabProjectRequest) (*GitlabProject, error)
+
+	//查询 group 列表
+	ListGroups(context.Context, *GitlabGroupsRequest) ([]GitlabGroup, error)
+
+	//查询指定 group 下的全部子孙 group
+	ListSubgroups(context.Context, *GitlabSubgroupsRequest) ([]GitlabGroup, error)
+
+	//查询项目的分支列表
+	ListBranches(context.Context, *GitlabBranchesRequest) ([]GitlabBranch, error)
+
+	//查询项目的提交记录
+	ListCommits(context.Context, *GitlabCommitsRequest) ([]GitlabCommit, error)
+
+	//比较两个 revision，返回两者之间的提交
+	CompareCommits(context.Context, *GitlabCompareRequest) (*GitlabCompare, error)
+
+	//查询引入指定提交的合并请求
+	ListCommitMergeRequests(context.Context, *GitlabCommitMergeRequestsRequest) ([]GitlabMergeRequest, error)
+
+	//查询项目的合并请求列表
+	ListMergeRequests(context.Context, *GitlabMergeRequestsRequest) ([]GitlabMergeRequest, error)
+
+	//查询合并请求的评论及系统事件，按创建时间升序
+	ListMergeRequestNotes(context.Context, *GitlabMergeRequestNotesRequest) ([]GitlabNote, error)
+
+	//查询合并请求的文件变更
+	ListMergeRequestDiffs(context.Context, *GitlabMergeRequestDiffsRequest) ([]GitlabDiff, error)
+
+	//查询项目的流水线列表
+	ListPipelines(context.Context, *GitlabPipelinesRequest) ([]GitlabPipeline, error)
+
+	//查询单条流水线详情（包含 duration 等列表接口不返回的字段）
+	GetPipeline(context.Context, *GitlabPipelineRequest) (*GitlabPipeline, error)
+
+	//查询流水线下的 job 列表
+	ListPipelineJobs(context.Context, *GitlabJobsRequest) ([]GitlabJob, error)
+
+	//查询项目的部署记录
+	ListDeployments(context.Context, *GitlabDeploymentsRequest) ([]GitlabDeployment, error)
+}
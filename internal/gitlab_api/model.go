@@ -0,0 +1,294 @@
+﻿package gitlab_api
+
+import "time"
+
+// 访问令牌类型
+const (
+	TokenTypePersonal = "personal"
+	TokenTypeProject  = "project"
+)
+
+type GitlabBaseRequest struct {
+	GitlabName string `json:"gitlabName"`
+	GitlabURL  string `json:"gitlabURL"`
+	Token      string `json:"token"`
+	TokenType  string `json:"tokenType"`
+}
+
+// projects & groups
+type GitlabProjectsRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	GroupID           string            `json:"groupID"`
+	IncludeSubgroups  bool              `json:"includeSubgroups"`
+	Search            string            `json:"search"`
+	Membership        bool              `json:"membership"`
+}
+
+type GitlabProjectRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	ProjectID         string            `json:"projectID"`
+}
+
+type GitlabGroupsRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	Search            string            `json:"search"`
+}
+
+type GitlabSubgroupsRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	GroupID           string            `json:"groupID"`
+}
+
+type GitlabNamespace struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	FullPath string `json:"full_path"`
+}
+
+type GitlabProject struct {
+	ID                int             `json:"id"`
+	Name              string          `json:"name"`
+	NameWithNamespace string          `json:"name_with_namespace"`
+	Path              string          `json:"path"`
+	PathWithNamespace string          `json:"path_with_namespace"`
+	DefaultBranch     string          `json:"default_branch"`
+	WebURL            string          `json:"web_url"`
+	Archived          bool            `json:"archived"`
+	LastActivityAt    time.Time       `json:"last_activity_at"`
+	Namespace         GitlabNamespace `json:"namespace"`
+}
+
+type GitlabGroup struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	FullPath string `json:"full_path"`
+	ParentID int    `json:"parent_id"`
+	WebURL   string `json:"web_url"`
+}
+
+// branches & commits
+type GitlabBranchesRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	ProjectID         string            `json:"projectID"`
+	Search            string            `json:"search"`
+}
+
+type GitlabCommitsRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	ProjectID         string            `json:"projectID"`
+	RefName           string            `json:"refName"`
+	Since             *time.Time        `json:"since"`
+	Until             *time.Time        `json:"until"`
+}
+
+type GitlabCommit struct {
+	ID             string     `json:"id"`
+	ShortID        string     `json:"short_id"`
+	Title          string     `json:"title"`
+	Message        string     `json:"message"`
+	AuthorName     string     `json:"author_name"`
+	AuthorEmail    string     `json:"author_email"`
+	AuthoredDate   *time.Time `json:"authored_date"`
+	CommitterName  string     `json:"committer_name"`
+	CommitterEmail string     `json:"committer_email"`
+	CommittedDate  *time.Time `json:"committed_date"`
+	CreatedAt      *time.Time `json:"created_at"`
+	ParentIDs      []string   `json:"parent_ids"`
+	WebURL         string     `json:"web_url"`
+}
+
+type GitlabCompareRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	ProjectID         string            `json:"projectID"`
+	From              string            `json:"from"`
+	To                string            `json:"to"`
+}
+
+// compare 接口返回 From（不含）到 To（含）之间的提交
+type GitlabCompare struct {
+	Commit  *GitlabCommit  `json:"commit"`
+	Commits []GitlabCommit `json:"commits"`
+}
+
+type GitlabCommitMergeRequestsRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	ProjectID         string            `json:"projectID"`
+	SHA               string            `json:"sha"`
+}
+
+type GitlabBranch struct {
+	Name      string       `json:"name"`
+	Merged    bool         `json:"merged"`
+	Protected bool         `json:"protected"`
+	Default   bool         `json:"default"`
+	WebURL    string       `json:"web_url"`
+	Commit    GitlabCommit `json:"commit"`
+}
+
+// merge requests
+type GitlabMergeRequestsRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	ProjectID         string            `json:"projectID"`
+	State             string            `json:"state"`
+	TargetBranch      string            `json:"targetBranch"`
+	UpdatedAfter      *time.Time        `json:"updatedAfter"`
+	UpdatedBefore     *time.Time        `json:"updatedBefore"`
+}
+
+type GitlabUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+type GitlabMergeRequest struct {
+	ID              int         `json:"id"`
+	IID             int         `json:"iid"`
+	ProjectID       int         `json:"project_id"`
+	Title           string      `json:"title"`
+	State           string      `json:"state"`
+	Draft           bool        `json:"draft"`
+	SourceBranch    string      `json:"source_branch"`
+	TargetBranch    string      `json:"target_branch"`
+	Author          GitlabUser  `json:"author"`
+	MergedBy        *GitlabUser `json:"merged_by"`
+	SHA             string      `json:"sha"`
+	MergeCommitSHA  string      `json:"merge_commit_sha"`
+	SquashCommitSHA string      `json:"squash_commit_sha"`
+	UserNotesCount  int         `json:"user_notes_count"`
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+	MergedAt        *time.Time  `json:"merged_at"`
+	ClosedAt        *time.Time  `json:"closed_at"`
+	WebURL          string      `json:"web_url"`
+}
+
+type GitlabMergeRequestNotesRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	ProjectID         string            `json:"projectID"`
+	MergeRequestIID   int               `json:"mergeRequestIID"`
+}
+
+// 评论与系统事件（如 approved、added commits）都以 note 的形式返回，System 为 true 表示系统事件
+type GitlabNote struct {
+	ID         int        `json:"id"`
+	Type       string     `json:"type"`
+	Body       string     `json:"body"`
+	Author     GitlabUser `json:"author"`
+	System     bool       `json:"system"`
+	Resolvable bool       `json:"resolvable"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type GitlabMergeRequestDiffsRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	ProjectID         string            `json:"projectID"`
+	MergeRequestIID   int               `json:"mergeRequestIID"`
+}
+
+type GitlabDiff struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	Diff        string `json:"diff"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+}
+
+// pipelines & jobs
+type GitlabPipelinesRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	ProjectID         string            `json:"projectID"`
+	Ref               string            `json:"ref"`
+	Status            string            `json:"status"`
+	Source            string            `json:"source"`
+	UpdatedAfter      *time.Time        `json:"updatedAfter"`
+	UpdatedBefore     *time.Time        `json:"updatedBefore"`
+}
+
+type GitlabPipelineRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	ProjectID         string            `json:"projectID"`
+	PipelineID        int               `json:"pipelineID"`
+}
+
+type GitlabPipeline struct {
+	ID         int         `json:"id"`
+	IID        int         `json:"iid"`
+	ProjectID  int         `json:"project_id"`
+	SHA        string      `json:"sha"`
+	Ref        string      `json:"ref"`
+	Status     string      `json:"status"`
+	Source     string      `json:"source"`
+	Duration   int         `json:"duration"`
+	User       *GitlabUser `json:"user"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	StartedAt  *time.Time  `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at"`
+	WebURL     string      `json:"web_url"`
+}
+
+type GitlabJobsRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	ProjectID         string            `json:"projectID"`
+	PipelineID        int               `json:"pipelineID"`
+	Scope             []string          `json:"scope"`
+}
+
+type GitlabJob struct {
+	ID             int         `json:"id"`
+	Name           string      `json:"name"`
+	Stage          string      `json:"stage"`
+	Status         string      `json:"status"`
+	Ref            string      `json:"ref"`
+	AllowFailure   bool        `json:"allow_failure"`
+	FailureReason  string      `json:"failure_reason"`
+	Duration       float64     `json:"duration"`
+	QueuedDuration float64     `json:"queued_duration"`
+	User           *GitlabUser `json:"user"`
+	CreatedAt      time.Time   `json:"created_at"`
+	StartedAt      *time.Time  `json:"started_at"`
+	FinishedAt     *time.Time  `json:"finished_at"`
+	WebURL         string      `json:"web_url"`
+	Pipeline       struct {
+		ID        int    `json:"id"`
+		ProjectID int    `json:"project_id"`
+		Ref       string `json:"ref"`
+		SHA       string `json:"sha"`
+		Status    string `json:"status"`
+	} `json:"pipeline"`
+}
+
+// deployments
+type GitlabDeploymentsRequest struct {
+	GitlabBaseRequest GitlabBaseRequest `json:"gitlabBaseRequest"`
+	ProjectID         string            `json:"projectID"`
+	Environment       string            `json:"environment"`
+	Status            string            `json:"status"`
+	UpdatedAfter      *time.Time        `json:"updatedAfter"`
+	UpdatedBefore     *time.Time        `json:"updatedBefore"`
+}
+
+type GitlabEnvironment struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	ExternalURL string `json:"external_url"`
+}
+
+type GitlabDeployment struct {
+	ID          int               `json:"id"`
+	IID         int               `json:"iid"`
+	Ref         string            `json:"ref"`
+	SHA         string            `json:"sha"`
+	Status      string            `json:"status"`
+	User        GitlabUser        `json:"user"`
+	Environment GitlabEnvironment `json:"environment"`
+	Deployable  *GitlabJob        `json:"deployable"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	FinishedAt  *time.Time        `json:"finished_at"`
+}
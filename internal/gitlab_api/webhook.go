@@ -0,0 +1,165 @@
+﻿package gitlab_api
+
+import (
+	"strings"
+	"time"
+)
+
+// webhook 相关请求头
+const (
+	HookTokenHeader     = "X-Gitlab-Token"
+	HookEventUUIDHeader = "X-Gitlab-Event-UUID"
+	HookEventHeader     = "X-Gitlab-Event"
+)
+
+// 项目 webhook 中的时间格式为 "2006-01-02 15:04:05 UTC"，system hook 中为 RFC3339，两种格式都需要兼容
+type HookTime struct {
+	time.Time
+}
+
+var hookTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+}
+
+func (t *HookTime) UnmarshalJSON(b []byte) error {
+	value := strings.Trim(string(b), `"`)
+	if value == "" || value == "null" {
+		return nil
+	}
+
+	var lastErr error
+	for _, layout := range hookTimeLayouts {
+		parsed, err := time.Parse(layout, value)
+		if err == nil {
+			t.Time = parsed
+			return nil
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// 返回非零时间的指针，零值返回 nil
+func (t *HookTime) Ptr() *time.Time {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	value := t.Time
+	return &value
+}
+
+// 所有事件共有的字段，用于判断事件类型。system hook 中部分事件只有 event_name
+type GitlabHookHeader struct {
+	ObjectKind string `json:"object_kind"`
+	EventName  string `json:"event_name"`
+}
+
+func (h GitlabHookHeader) Kind() string {
+	if h.ObjectKind != "" {
+		return h.ObjectKind
+	}
+	return h.EventName
+}
+
+type GitlabHookProject struct {
+	ID                int    `json:"id"`
+	Name              string `json:"name"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	WebURL            string `json:"web_url"`
+}
+
+type GitlabHookUser struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+// push 与 tag_push 事件
+type GitlabPushHook struct {
+	ObjectKind        string            `json:"object_kind"`
+	Before            string            `json:"before"`
+	After             string            `json:"after"`
+	Ref               string            `json:"ref"`
+	CheckoutSHA       string            `json:"checkout_sha"`
+	UserUsername      string            `json:"user_username"`
+	ProjectID         int               `json:"project_id"`
+	Project           GitlabHookProject `json:"project"`
+	TotalCommitsCount int               `json:"total_commits_count"`
+}
+
+type GitlabMergeRequestHook struct {
+	ObjectKind       string            `json:"object_kind"`
+	User             GitlabHookUser    `json:"user"`
+	Project          GitlabHookProject `json:"project"`
+	ObjectAttributes struct {
+		ID             int      `json:"id"`
+		IID            int      `json:"iid"`
+		Title          string   `json:"title"`
+		State          string   `json:"state"`
+		Action         string   `json:"action"`
+		SourceBranch   string   `json:"source_branch"`
+		TargetBranch   string   `json:"target_branch"`
+		MergeCommitSHA string   `json:"merge_commit_sha"`
+		AuthorID       int      `json:"author_id"`
+		CreatedAt      HookTime `json:"created_at"`
+		UpdatedAt      HookTime `json:"updated_at"`
+		URL            string   `json:"url"`
+	} `json:"object_attributes"`
+}
+
+type GitlabPipelineHook struct {
+	ObjectKind       string            `json:"object_kind"`
+	User             GitlabHookUser    `json:"user"`
+	Project          GitlabHookProject `json:"project"`
+	ObjectAttributes struct {
+		ID         int      `json:"id"`
+		IID        int      `json:"iid"`
+		Ref        string   `json:"ref"`
+		Tag        bool     `json:"tag"`
+		SHA        string   `json:"sha"`
+		Source     string   `json:"source"`
+		Status     string   `json:"status"`
+		Duration   *float64 `json:"duration"`
+		CreatedAt  HookTime `json:"created_at"`
+		FinishedAt HookTime `json:"finished_at"`
+		URL        string   `json:"url"`
+	} `json:"object_attributes"`
+}
+
+// job 事件的 object_kind 为 build
+type GitlabJobHook struct {
+	ObjectKind          string            `json:"object_kind"`
+	Ref                 string            `json:"ref"`
+	SHA                 string            `json:"sha"`
+	BuildID             int               `json:"build_id"`
+	BuildName           string            `json:"build_name"`
+	BuildStage          string            `json:"build_stage"`
+	BuildStatus         string            `json:"build_status"`
+	BuildCreatedAt      HookTime          `json:"build_created_at"`
+	BuildStartedAt      HookTime          `json:"build_started_at"`
+	BuildFinishedAt     HookTime          `json:"build_finished_at"`
+	BuildDuration       *float64          `json:"build_duration"`
+	BuildQueuedDuration *float64          `json:"build_queued_duration"`
+	BuildAllowFailure   bool              `json:"build_allow_failure"`
+	BuildFailureReason  string            `json:"build_failure_reason"`
+	PipelineID          int               `json:"pipeline_id"`
+	ProjectID           int               `json:"project_id"`
+	User                GitlabHookUser    `json:"user"`
+	Project             GitlabHookProject `json:"project"`
+}
+
+type GitlabDeploymentHook struct {
+	ObjectKind      string            `json:"object_kind"`
+	Status          string            `json:"status"`
+	StatusChangedAt HookTime          `json:"status_changed_at"`
+	DeploymentID    int               `json:"deployment_id"`
+	DeployableID    int               `json:"deployable_id"`
+	Environment     string            `json:"environment"`
+	Ref             string            `json:"ref"`
+	ShortSHA        string            `json:"short_sha"`
+	CommitTitle     string            `json:"commit_title"`
+	User            GitlabHookUser    `json:"user"`
+	Project         GitlabHookProject `json:"project"`
+}
@@ -0,0 +1,156 @@
+﻿package jenkins_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Jenkins 构建详情中的 actions 是不同插件写入的异构对象，通过 _class 区分
+const (
+	BuildDataClass         = "hudson.plugins.git.util.BuildData"
+	ParametersActionClass  = "hudson.model.ParametersAction"
+	TimeInQueueActionClass = "jenkins.metrics.impl.TimeInQueueAction"
+)
+
+// 将 action 重新编码后解析为指定结构体
+func decodeAction(action interface{}, out interface{}) bool {
+	actionBytes, err := json.Marshal(action)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(actionBytes, out) == nil
+}
+
+func actionClass(action interface{}) string {
+	actionMap, ok := action.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	class, _ := actionMap["_class"].(string)
+	return class
+}
+
+// 从 actions 中提取 git 插件写入的 BuildData，一次构建检出多个仓库时会有多个
+func GetBuildData(actions []interface{}) []BuildData {
+	var buildData []BuildData
+	for _, action := range actions {
+		if actionClass(action) != BuildDataClass {
+			continue
+		}
+		var data BuildData
+		if decodeAction(action, &data) {
+			buildData = append(buildData, data)
+		}
+	}
+	return buildData
+}
+
+// 提取构建所使用的 git revision。优先取 BuildData.LastBuiltRevision，
+// 取不到时再从同一 git 插件 action 的 BuildsData（revision>SHA1）中取，返回 SHA1 以及对应仓库地址
+func GetBuildRevision(actions []interface{}) (string, []string) {
+	for _, data := range GetBuildData(actions) {
+		if data.LastBuiltRevision.SHA1 != "" {
+			return data.LastBuiltRevision.SHA1, data.RemoteUrls
+		}
+	}
+
+	for _, action := range actions {
+		if actionClass(action) != BuildDataClass {
+			continue
+		}
+		var data BuildsData
+		if decodeAction(action, &data) && data.RevisionSHA1 != "" {
+			return data.RevisionSHA1, nil
+		}
+	}
+
+	return "", nil
+}
+
+// 提取构建所使用的分支，优先取 BuildData.LastBuiltRevision，取不到时再从 BuildsData 中取。
+// 返回的分支名称已去掉 origin/ 等远程前缀
+func GetBuildBranch(actions []interface{}) string {
+	for _, data := range GetBuildData(actions) {
+		for _, branch := range data.LastBuiltRevision.Branch {
+			if branch.Name != "" {
+				return NormalizeBranch(branch.Name)
+			}
+		}
+	}
+
+	for _, action := range actions {
+		if actionClass(action) != BuildDataClass {
+			continue
+		}
+		var data BuildsData
+		if decodeAction(action, &data) && data.RevisionBranch.Name != "" {
+			return NormalizeBranch(data.RevisionBranch.Name)
+		}
+	}
+
+	return ""
+}
+
+// 去掉分支名称中的引用与远程前缀，如 refs/remotes/origin/main、origin/main、refs/heads/main 都归一为 main
+func NormalizeBranch(branch string) string {
+	for _, prefix := range []string{"refs/remotes/origin/", "remotes/origin/", "refs/heads/", "origin/"} {
+		if strings.HasPrefix(branch, prefix) {
+			return strings.TrimPrefix(branch, prefix)
+		}
+	}
+	return branch
+}
+
+type ParameterValue struct {
+	Class string      `json:"_class"`
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// 提取 ParametersAction 中的构建参数。密码类参数不返回取值，没有取值的参数（如文件参数）跳过
+func GetParameters(actions []interface{}) map[string]string {
+	parameters := make(map[string]string)
+	for _, action := range actions {
+		if actionClass(action) != ParametersActionClass {
+			continue
+		}
+		var data struct {
+			Parameters []ParameterValue `json:"parameters"`
+		}
+		if !decodeAction(action, &data) {
+			continue
+		}
+		for _, parameter := range data.Parameters {
+			if parameter.Value == nil || strings.Contains(parameter.Class, "Password") {
+				continue
+			}
+			parameters[parameter.Name] = fmt.Sprint(parameter.Value)
+		}
+	}
+	return parameters
+}
+
+// Metrics 插件记录的构建在队列中的耗时，单位为毫秒
+type TimeInQueueAction struct {
+	Class                   string `json:"_class"`
+	BlockedDurationMillis   int64  `json:"blockedDurationMillis"`
+	BuildableDurationMillis int64  `json:"buildableDurationMillis"`
+	WaitingDurationMillis   int64  `json:"waitingDurationMillis"`
+	QueuingDurationMillis   int64  `json:"queuingDurationMillis"`
+	ExecutingTimeMillis     int64  `json:"executingTimeMillis"`
+}
+
+// 提取 Metrics 插件写入的排队耗时，未安装插件时返回 nil
+func GetTimeInQueue(actions []interface{}) *TimeInQueueAction {
+	for _, action := range actions {
+		if actionClass(action) != TimeInQueueActionClass {
+			continue
+		}
+		var data TimeInQueueAction
+		if decodeAction(action, &data) {
+			return &data
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+﻿package jenkins_api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNormalizeBranch(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"main", "main"},
+		{"origin/main", "main"},
+		{"refs/heads/main", "main"},
+		{"refs/remotes/origin/release/1.2", "release/1.2"},
+		{"remotes/origin/feature/login", "feature/login"},
+		// 只去掉一层前缀，其它远程名称保持不变
+		{"origin/origin/main", "origin/main"},
+		{"upstream/main", "upstream/main"},
+		{"feature/origin/x", "feature/origin/x"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeBranch(tt.branch); got != tt.want {
+			t.Errorf("NormalizeBranch(%q) = %q, want %q", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestGetBuildBranch(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions string
+		want    string
+	}{
+		{"last built revision", `[{"_class":"hudson.model.CauseAction"},{"_class":"hudson.plugins.git.util.BuildData","lastBuiltRevision":{"SHA1":"abc","branch":[{"SHA1":"abc","name":"refs/remotes/origin/main"}]}}]`, "main"},
+		{"builds data", `[{"_class":"hudson.plugins.git.util.BuildData","revision>SHA1":"abc","revision>branch":{"SHA1":"abc","name":"origin/dev"}}]`, "dev"},
+		{"other plugin", `[{"_class":"com.example.ScmAction","lastBuiltRevision":{"branch":[{"name":"origin/main"}]}}]`, ""},
+		{"no actions", `[]`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actions []interface{}
+			if err := json.Unmarshal([]byte(tt.actions), &actions); err != nil {
+				t.Fatal(err)
+			}
+			if got := GetBuildBranch(actions); got != tt.want {
+				t.Errorf("GetBuildBranch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+﻿package jenkins_api
+
+import (
+	"strings"
+)
+
+const CauseActionClass = "hudson.model.CauseAction"
+
+// 构建的触发方式
+const (
+	TriggerUser           = "user"
+	TriggerSCM            = "scm"
+	TriggerTimer          = "timer"
+	TriggerUpstream       = "upstream"
+	TriggerRemote         = "remote"
+	TriggerReplay         = "replay"
+	TriggerBranchIndexing = "branchIndexing"
+	TriggerOther          = "other"
+)
+
+// 同一次构建可能有多个触发原因，例如回放构建同时带有 ReplayCause 与 UserIdCause，
+// 按这个顺序取第一个匹配的作为构建的触发方式
+var triggerPriority = []string{
+	TriggerReplay,
+	TriggerUser,
+	TriggerUpstream,
+	TriggerRemote,
+	TriggerSCM,
+	TriggerTimer,
+	TriggerBranchIndexing,
+	TriggerOther,
+}
+
+// 从 actions 中提取 CauseAction 里的全部触发原因
+func GetCauses(actions []interface{}) []Cause {
+	var causes []Cause
+	for _, action := range actions {
+		if actionClass(action) != CauseActionClass {
+			continue
+		}
+		var data struct {
+			Causes []Cause `json:"causes"`
+		}
+		if decodeAction(action, &data) {
+			causes = append(causes, data.Causes...)
+		}
+	}
+	return causes
+}
+
+// 根据触发原因的类名判断触发方式
+func CauseTriggerType(cause Cause) string {
+	class := cause.Class
+	switch {
+	case strings.HasSuffix(class, "ReplayCause"):
+		return TriggerReplay
+	case strings.HasSuffix(class, "Cause$UserIdCause"), strings.HasSuffix(class, "Cause$UserCause"):
+		return TriggerUser
+	case strings.HasSuffix(class, "Cause$UpstreamCause"):
+		return TriggerUpstream
+	case strings.HasSuffix(class, "Cause$RemoteCause"):
+		return TriggerRemote
+	case strings.HasSuffix(class, "BranchIndexingCause"):
+		return TriggerBranchIndexing
+	case strings.HasSuffix(class, "SCMTrigger$SCMTriggerCause"),
+		strings.HasSuffix(class, "BranchEventCause"),
+		strings.Contains(class, "WebHookCause"),
+		strings.Contains(class, "PushCause"):
+		return TriggerSCM
+	case strings.HasSuffix(class, "TimerTrigger$TimerTriggerCause"):
+		return TriggerTimer
+	default:
+		return TriggerOther
+	}
+}
+
+// 返回构建的触发方式与触发原因，没有触发原因时返回空
+func PrimaryCause(causes []Cause) (string, *Cause) {
+	if len(causes) == 0 {
+		return "", nil
+	}
+
+	for _, trigger := range triggerPriority {
+		for i := range causes {
+			if CauseTriggerType(causes[i]) == trigger {
+				return trigger, &causes[i]
+			}
+		}
+	}
+	return TriggerOther, &causes[0]
+}
+
+// 触发构建的用户，回放构建的用户在 UserIdCause 中
+func CauseUserID(causes []Cause) string {
+	for _, cause := range causes {
+		if cause.UserID != "" {
+			return cause.UserID
+		}
+	}
+	return ""
+}
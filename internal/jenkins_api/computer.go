@@ -0,0 +1,59 @@
+﻿package jenkins_api
+
+// 节点监控数据 monitorData 以监控器的类名为 key
+const (
+	DiskSpaceMonitorClass    = "hudson.node_monitors.DiskSpaceMonitor"
+	ResponseTimeMonitorClass = "hudson.node_monitors.ResponseTimeMonitor"
+)
+
+type DiskSpaceMonitor struct {
+	Path string `json:"path"`
+	// 剩余空间，单位为字节
+	Size int64 `json:"size"`
+}
+
+type ResponseTimeMonitor struct {
+	// 平均响应时间，单位为毫秒
+	Average int64 `json:"average"`
+}
+
+// 节点剩余磁盘空间，节点离线或监控器未返回数据时返回 -1
+func GetDiskSpace(monitorData map[string]interface{}) int64 {
+	var data DiskSpaceMonitor
+	if monitorData[DiskSpaceMonitorClass] == nil || !decodeAction(monitorData[DiskSpaceMonitorClass], &data) {
+		return -1
+	}
+	return data.Size
+}
+
+// 节点平均响应时间，节点离线或监控器未返回数据时返回 -1
+func GetResponseTime(monitorData map[string]interface{}) int64 {
+	var data ResponseTimeMonitor
+	if monitorData[ResponseTimeMonitorClass] == nil || !decodeAction(monitorData[ResponseTimeMonitorClass], &data) {
+		return -1
+	}
+	return data.Average
+}
+
+// 正在执行构建的执行器数量
+func BusyExecutors(computer JenkinsComputer) int {
+	busy := 0
+	for _, executor := range computer.Executors {
+		if !executor.Idle {
+			busy++
+		}
+	}
+	return busy
+}
+
+// 节点的标签，不包含节点名称本身（Jenkins 会把节点名称作为隐含标签返回）
+func ComputerLabels(computer JenkinsComputer) []string {
+	var labels []string
+	for _, label := range computer.AssignedLabels {
+		if label.Name == "" || label.Name == computer.DisplayName {
+			continue
+		}
+		labels = append(labels, label.Name)
+	}
+	return labels
+}
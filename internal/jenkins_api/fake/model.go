@@ -0,0 +1,107 @@
+﻿package fake
+
+import (
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+)
+
+// job 的类型，对应 Jenkins 返回的 _class
+const (
+	FreeStyleProjectClass = "hudson.model.FreeStyleProject"
+	WorkflowJobClass      = "org.jenkinsci.plugins.workflow.job.WorkflowJob"
+	FolderClass           = "com.cloudbees.hudson.plugins.folder.Folder"
+
+	FreeStyleBuildClass = "hudson.model.FreeStyleBuild"
+)
+
+// 脚本化的一次构建，未设置的字段不会出现在构建详情中
+type Build struct {
+	Number int
+	// Building 为 true 时 Result 为空
+	Result    string
+	Building  bool
+	Timestamp time.Time
+	Duration  time.Duration
+	QueueID   int
+	BuiltOn   string
+
+	// 写入 CauseAction
+	Causes []jenkins_api.Cause
+	// 写入 ParametersAction
+	Parameters map[string]string
+	// 写入 git 插件的 BuildData
+	Branch string
+	Commit string
+	SCMURL string
+	// 写入 Metrics 插件的 TimeInQueueAction
+	QueueDuration time.Duration
+
+	// consoleText 的内容
+	Console string
+	// 流水线 job 的阶段，通过 wfapi/describe 返回
+	Stages []jenkins_api.JenkinsPipelineStage
+	// 为 nil 时 testReport 返回 404
+	TestReport *jenkins_api.JenkinsTestReport
+}
+
+// 构建队列中的一项
+type QueueItem struct {
+	ID           int
+	JobName      string
+	Why          string
+	InQueueSince time.Time
+	Blocked      bool
+	Buildable    bool
+	Stuck        bool
+}
+
+// Jenkins 节点
+type Computer struct {
+	Name               string
+	Labels             []string
+	NumExecutors       int
+	BusyExecutors      int
+	Offline            bool
+	TemporarilyOffline bool
+	OfflineReason      string
+	// 剩余磁盘空间（字节）与平均响应时间（毫秒），小于 0 时不返回监控数据
+	DiskSpace    int64
+	ResponseTime int64
+}
+
+// 用户触发的构建原因
+func UserCause(userID string) jenkins_api.Cause {
+	return jenkins_api.Cause{
+		Class:     "hudson.model.Cause$UserIdCause",
+		ShortDesc: "Started by user " + userID,
+		UserID:    userID,
+		UserName:  userID,
+	}
+}
+
+// 定时触发的构建原因
+func TimerCause() jenkins_api.Cause {
+	return jenkins_api.Cause{
+		Class:     "hudson.triggers.TimerTrigger$TimerTriggerCause",
+		ShortDesc: "Started by timer",
+	}
+}
+
+// 代码变更触发的构建原因
+func SCMCause() jenkins_api.Cause {
+	return jenkins_api.Cause{
+		Class:     "hudson.triggers.SCMTrigger$SCMTriggerCause",
+		ShortDesc: "Started by an SCM change",
+	}
+}
+
+// 上游构建触发的构建原因
+func UpstreamCause(project string, build int) jenkins_api.Cause {
+	return jenkins_api.Cause{
+		Class:           "hudson.model.Cause$UpstreamCause",
+		ShortDesc:       "Started by upstream project",
+		UpstreamProject: project,
+		UpstreamBuild:   build,
+	}
+}
@@ -0,0 +1,551 @@
+﻿package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+)
+
+const defaultVersion = "2.440.3"
+
+// 进程内的 Jenkins 模拟服务，基于 httptest 实现，提供根目录、文件夹、job、构建、
+// 构建队列与节点等接口，构建历史、失败、延迟与认证错误均可通过脚本设置。
+// 用于在没有真实 Jenkins 的情况下端到端验证采集与指标计算
+type Server struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	username string
+	password string
+	version  string
+	latency  time.Duration
+	// job 接口返回的构建数量上限，为 0 时返回全部构建
+	historyLimit int
+	failures     map[string]int
+	requests     map[string]int
+	folders      map[string]bool
+	jobs         map[string]*job
+	queue        []QueueItem
+	computers    []Computer
+}
+
+type job struct {
+	class  string
+	builds map[int]*Build
+}
+
+// 启动模拟服务，使用完毕后需调用 Close
+func NewServer() *Server {
+	s := &Server{
+		version:  defaultVersion,
+		failures: make(map[string]int),
+		requests: make(map[string]int),
+		folders:  make(map[string]bool),
+		jobs:     make(map[string]*job),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// 设置后所有请求都需要使用该用户名与密码进行 basic 认证，否则返回 401
+func (s *Server) SetCredentials(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.username, s.password = username, password
+}
+
+// 设置 X-Jenkins 响应头中的版本
+func (s *Server) SetVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+// 每个请求在响应前等待的时间
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// job 接口只返回最近的 limit 个构建，与 Jenkins 的 builds 字段最多返回 100 个构建一致
+func (s *Server) SetHistoryLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.historyLimit = limit
+}
+
+// 路径以 prefix 开头的请求直接返回 status，如 FailPath("/job/app/", 500)
+func (s *Server) FailPath(prefix string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[prefix] = status
+}
+
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = make(map[string]int)
+}
+
+// 路径以 prefix 开头的请求次数
+func (s *Server) Requests(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for path, n := range s.requests {
+		if strings.HasPrefix(path, prefix) {
+			count += n
+		}
+	}
+	return count
+}
+
+// 添加文件夹，name 为以 / 分隔的完整路径，父文件夹会自动创建
+func (s *Server) AddFolder(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addFolder(name)
+}
+
+// 调用方需持有锁
+func (s *Server) addFolder(name string) {
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		s.folders[strings.Join(parts[:i+1], "/")] = true
+	}
+}
+
+// 添加自由风格 job，name 中包含 / 时自动创建所在的文件夹
+func (s *Server) AddJob(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addJob(name, FreeStyleProjectClass)
+}
+
+// 添加流水线 job，构建详情的 _class 为 WorkflowRun，阶段通过 wfapi 返回
+func (s *Server) AddPipeline(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addJob(name, WorkflowJobClass)
+}
+
+// 调用方需持有锁
+func (s *Server) addJob(name, class string) *job {
+	if i := strings.LastIndex(name, "/"); i > 0 {
+		s.addFolder(name[:i])
+	}
+	j := &job{class: class, builds: make(map[int]*Build)}
+	s.jobs[name] = j
+	return j
+}
+
+// 添加或替换 job 的一次构建，job 不存在时按自由风格 job 创建
+func (s *Server) AddBuild(jobName string, build Build) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[jobName]
+	if !ok {
+		j = s.addJob(jobName, FreeStyleProjectClass)
+	}
+	if build.Timestamp.IsZero() {
+		build.Timestamp = time.Now()
+	}
+	j.builds[build.Number] = &build
+}
+
+func (s *Server) SetQueue(items []QueueItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = items
+}
+
+func (s *Server) SetComputers(computers []Computer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.computers = computers
+}
+
+// job 的访问地址，以 / 结尾
+func (s *Server) JobURL(name string) string {
+	return s.server.URL + jobPath(name)
+}
+
+// 构建的访问地址，以 / 结尾
+func (s *Server) BuildURL(jobName string, number int) string {
+	return s.JobURL(jobName) + strconv.Itoa(number) + "/"
+}
+
+func jobPath(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "/") {
+		b.WriteString("/job/")
+		b.WriteString(part)
+	}
+	b.WriteString("/")
+	return b.String()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+
+	// 采集端拼接地址时可能出现 //，Jenkins 会忽略多余的 /
+	path := r.URL.Path
+	for strings.Contains(path, "//") {
+		path = strings.ReplaceAll(path, "//", "/")
+	}
+
+	s.mu.Lock()
+	s.requests[path]++
+	latency := s.latency
+	version := s.version
+	username, password := s.username, s.password
+	status := 0
+	for prefix, code := range s.failures {
+		if strings.HasPrefix(path, prefix) {
+			status = code
+		}
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	w.Header().Set("X-Jenkins", version)
+
+	if username != "" {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != username || pass != password {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch strings.Join(segments, "/") {
+	case "api/json":
+		s.writeFolder(w, "")
+		return
+	case "queue/api/json":
+		s.writeQueue(w)
+		return
+	case "computer/api/json":
+		s.writeComputers(w)
+		return
+	}
+
+	// /job/a/job/b/... 解析出完整的 job 名称
+	var names []string
+	for len(segments) >= 2 && segments[0] == "job" {
+		names = append(names, segments[1])
+		segments = segments[2:]
+	}
+	name := strings.Join(names, "/")
+	rest := strings.Join(segments, "/")
+
+	if s.folders[name] && rest == "api/json" {
+		s.writeFolder(w, name)
+		return
+	}
+	j, ok := s.jobs[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if rest == "api/json" {
+		s.writeJob(w, name, j)
+		return
+	}
+
+	number, err := strconv.Atoi(segments[0])
+	if err != nil || j.builds[number] == nil {
+		http.NotFound(w, r)
+		return
+	}
+	build := j.builds[number]
+
+	switch strings.Join(segments[1:], "/") {
+	case "api/json":
+		s.writeJSON(w, s.run(name, j, build))
+	case "consoleText":
+		// 支持 Range 请求，便于验证只读取日志末尾
+		w.Header().Set("Content-Type", "text/plain;charset=UTF-8")
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(build.Console))
+	case "wfapi/describe":
+		if j.class != WorkflowJobClass {
+			http.NotFound(w, r)
+			return
+		}
+		s.writeJSON(w, jenkins_api.JenkinsPipelineRun{
+			ID:              strconv.Itoa(build.Number),
+			Name:            "#" + strconv.Itoa(build.Number),
+			Status:          build.Result,
+			StartTimeMillis: build.Timestamp.UnixMilli(),
+			DurationMillis:  int(build.Duration.Milliseconds()),
+			Stages:          build.Stages,
+		})
+	case "testReport/api/json":
+		if build.TestReport == nil {
+			http.NotFound(w, r)
+			return
+		}
+		s.writeJSON(w, build.TestReport)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+// 调用方需持有锁。name 为空表示根目录
+func (s *Server) writeFolder(w http.ResponseWriter, name string) {
+	prefix := ""
+	if name != "" {
+		prefix = name + "/"
+	}
+
+	var jobs []jenkins_api.JenkinsJobs
+	for folder := range s.folders {
+		if isChild(prefix, folder) {
+			jobs = append(jobs, jenkins_api.JenkinsJobs{Class: FolderClass, Name: folder[len(prefix):], JobsURL: s.JobURL(folder)})
+		}
+	}
+	for jobName, j := range s.jobs {
+		if isChild(prefix, jobName) {
+			jobs = append(jobs, jenkins_api.JenkinsJobs{Class: j.class, Name: jobName[len(prefix):], JobsURL: s.JobURL(jobName)})
+		}
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].Name < jobs[k].Name
+	})
+
+	class := "hudson.model.Hudson"
+	if name != "" {
+		class = FolderClass
+	}
+	s.writeJSON(w, map[string]interface{}{
+		"_class": class,
+		"mode":   "NORMAL",
+		"jobs":   jobs,
+	})
+}
+
+func isChild(prefix, name string) bool {
+	return strings.HasPrefix(name, prefix) && !strings.Contains(name[len(prefix):], "/")
+}
+
+// 调用方需持有锁。构建按编号倒序返回，与 Jenkins 一致
+func (s *Server) writeJob(w http.ResponseWriter, name string, j *job) {
+	type buildRef struct {
+		Class  string `json:"_class"`
+		Number int    `json:"number"`
+		URL    string `json:"url"`
+	}
+
+	builds := make([]buildRef, 0, len(j.builds))
+	for number := range j.builds {
+		builds = append(builds, buildRef{Class: buildClass(j), Number: number, URL: s.BuildURL(name, number)})
+	}
+	sort.Slice(builds, func(i, k int) bool {
+		return builds[i].Number > builds[k].Number
+	})
+	if s.historyLimit > 0 && len(builds) > s.historyLimit {
+		builds = builds[:s.historyLimit]
+	}
+
+	parts := strings.Split(name, "/")
+	s.writeJSON(w, map[string]interface{}{
+		"_class":    j.class,
+		"name":      parts[len(parts)-1],
+		"fullName":  name,
+		"url":       s.JobURL(name),
+		"buildable": true,
+		"builds":    builds,
+	})
+}
+
+func buildClass(j *job) string {
+	if j.class == WorkflowJobClass {
+		return jenkins_api.WorkflowRunClass
+	}
+	return FreeStyleBuildClass
+}
+
+// 调用方需持有锁
+func (s *Server) run(name string, j *job, build *Build) jenkins_api.JenkinsRun {
+	run := jenkins_api.JenkinsRun{
+		Class:           buildClass(j),
+		Building:        build.Building,
+		DisplayName:     "#" + strconv.Itoa(build.Number),
+		FullDisplayName: name + " #" + strconv.Itoa(build.Number),
+		ID:              strconv.Itoa(build.Number),
+		Number:          build.Number,
+		QueueID:         build.QueueID,
+		BuiltOn:         build.BuiltOn,
+		Timestamp:       build.Timestamp.UnixMilli(),
+		URL:             s.BuildURL(name, build.Number),
+		Actions:         []interface{}{},
+	}
+	if !build.Building {
+		run.Result = build.Result
+		run.Duration = int(build.Duration.Milliseconds())
+	}
+	run.EstimatedDuration = int(build.Duration.Milliseconds())
+
+	if len(build.Causes) > 0 {
+		run.Actions = append(run.Actions, map[string]interface{}{
+			"_class": jenkins_api.CauseActionClass,
+			"causes": build.Causes,
+		})
+	}
+
+	if len(build.Parameters) > 0 {
+		var parameters []jenkins_api.ParameterValue
+		for parameterName, value := range build.Parameters {
+			parameters = append(parameters, jenkins_api.ParameterValue{
+				Class: "hudson.model.StringParameterValue",
+				Name:  parameterName,
+				Value: value,
+			})
+		}
+		sort.Slice(parameters, func(i, k int) bool {
+			return parameters[i].Name < parameters[k].Name
+		})
+		run.Actions = append(run.Actions, map[string]interface{}{
+			"_class":     jenkins_api.ParametersActionClass,
+			"parameters": parameters,
+		})
+	}
+
+	if build.Commit != "" || build.Branch != "" {
+		var data jenkins_api.BuildData
+		data.Class = jenkins_api.BuildDataClass
+		data.LastBuiltRevision.SHA1 = build.Commit
+		if build.Branch != "" {
+			data.LastBuiltRevision.Branch = append(data.LastBuiltRevision.Branch, struct {
+				SHA1 string `json:"SHA1"`
+				Name string `json:"name"`
+			}{SHA1: build.Commit, Name: "origin/" + build.Branch})
+		}
+		if build.SCMURL != "" {
+			data.RemoteUrls = []string{build.SCMURL}
+		}
+		run.Actions = append(run.Actions, data)
+	}
+
+	if build.QueueDuration > 0 {
+		run.Actions = append(run.Actions, jenkins_api.TimeInQueueAction{
+			Class:                 jenkins_api.TimeInQueueActionClass,
+			QueuingDurationMillis: build.QueueDuration.Milliseconds(),
+			WaitingDurationMillis: build.QueueDuration.Milliseconds(),
+			ExecutingTimeMillis:   build.Duration.Milliseconds(),
+		})
+	}
+
+	return run
+}
+
+// 调用方需持有锁
+func (s *Server) writeQueue(w http.ResponseWriter) {
+	items := make([]jenkins_api.JenkinsQueueItem, 0, len(s.queue))
+	for _, queued := range s.queue {
+		var item jenkins_api.JenkinsQueueItem
+		item.Class = "hudson.model.Queue$WaitingItem"
+		if queued.Buildable {
+			item.Class = "hudson.model.Queue$BuildableItem"
+		}
+		if queued.Blocked {
+			item.Class = "hudson.model.Queue$BlockedItem"
+		}
+		item.ID = queued.ID
+		item.InQueueSince = queued.InQueueSince.UnixMilli()
+		item.Why = queued.Why
+		item.Blocked = queued.Blocked
+		item.Buildable = queued.Buildable
+		item.Stuck = queued.Stuck
+		item.Task.Class = FreeStyleProjectClass
+		if j, ok := s.jobs[queued.JobName]; ok {
+			item.Task.Class = j.class
+		}
+		parts := strings.Split(queued.JobName, "/")
+		item.Task.Name = parts[len(parts)-1]
+		item.Task.URL = s.JobURL(queued.JobName)
+		items = append(items, item)
+	}
+	s.writeJSON(w, jenkins_api.JenkinsQueueResponse{Class: "hudson.model.Queue", Items: items})
+}
+
+// 调用方需持有锁
+func (s *Server) writeComputers(w http.ResponseWriter) {
+	response := jenkins_api.JenkinsComputerResponse{Class: "hudson.model.ComputerSet"}
+	for _, node := range s.computers {
+		var computer jenkins_api.JenkinsComputer
+		computer.Class = "hudson.slaves.SlaveComputer"
+		if node.Name == "built-in" {
+			computer.Class = "hudson.model.Hudson$MasterComputer"
+		}
+		computer.DisplayName = node.Name
+		computer.Offline = node.Offline || node.TemporarilyOffline
+		computer.TemporarilyOffline = node.TemporarilyOffline
+		computer.OfflineCauseReason = node.OfflineReason
+		computer.NumExecutors = node.NumExecutors
+		computer.Idle = node.BusyExecutors == 0
+
+		// Jenkins 会把节点名称作为隐含标签返回
+		for _, label := range append([]string{node.Name}, node.Labels...) {
+			computer.AssignedLabels = append(computer.AssignedLabels, struct {
+				Name string `json:"name"`
+			}{Name: label})
+		}
+		for i := 0; i < node.NumExecutors; i++ {
+			computer.Executors = append(computer.Executors, struct {
+				Idle bool `json:"idle"`
+			}{Idle: i >= node.BusyExecutors})
+		}
+
+		computer.MonitorData = make(map[string]interface{})
+		if node.DiskSpace >= 0 && !computer.Offline {
+			computer.MonitorData[jenkins_api.DiskSpaceMonitorClass] = jenkins_api.DiskSpaceMonitor{Path: "/var/jenkins", Size: node.DiskSpace}
+		}
+		if node.ResponseTime >= 0 && !computer.Offline {
+			computer.MonitorData[jenkins_api.ResponseTimeMonitorClass] = jenkins_api.ResponseTimeMonitor{Average: node.ResponseTime}
+		}
+
+		if !computer.Offline {
+			response.TotalExecutors += node.NumExecutors
+			response.BusyExecutors += node.BusyExecutors
+		}
+		response.Computer = append(response.Computer, computer)
+	}
+	s.writeJSON(w, response)
+}
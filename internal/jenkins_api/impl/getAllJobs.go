@@ -5,10 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"time"
 
 	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
 )
@@ -22,7 +21,7 @@ func (j *JenkinsServiceImpl) GetAllJobs(c context.Context, config *jenkins_api.J
 
 	req, err := http.NewRequest("GET", config.JenkinsURL+"/api/json?pretty=true", nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	req.Header.Set("Accept", AcceptHeader)
@@ -30,11 +29,7 @@ func (j *JenkinsServiceImpl) GetAllJobs(c context.Context, config *jenkins_api.J
 	req.Header.Set("Connection", ConnectionHeader)
 	req.SetBasicAuth(config.JenkinsBaseRequest.Username, config.JenkinsBaseRequest.Password)
 
-	j.client = &http.Client{
-		Timeout: time.Second * 10,
-	}
-
-	resp, err := j.client.Do(req)
+	resp, err := j.httpClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +37,12 @@ func (j *JenkinsServiceImpl) GetAllJobs(c context.Context, config *jenkins_api.J
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	// 认证失败时 Jenkins 返回的是 HTML 页面，直接解析会得到难以理解的 JSON 错误
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jenkins api %s: unexpected status %d", config.JenkinsURL, resp.StatusCode)
 	}
 
 	var JenkinsJobsResponse jenkins_api.JenkinsJobsResponse
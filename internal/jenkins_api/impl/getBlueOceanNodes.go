@@ -0,0 +1,63 @@
+﻿package impl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+)
+
+// 通过 Blue Ocean 接口获取流水线构建的节点信息，文件夹中的 job 对应多级 pipelines 路径
+func (j *JenkinsServiceImpl) GetBlueOceanNodes(c context.Context, config *jenkins_api.JenkinsBlueOceanNodesRequest) ([]jenkins_api.JenkinsBlueOceanNode, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	var pipelinePath strings.Builder
+	for _, name := range strings.Split(config.JobName, "/") {
+		pipelinePath.WriteString("/pipelines/" + url.PathEscape(name))
+	}
+	nodesURL := strings.TrimSuffix(config.JenkinsURL, "/") + "/blue/rest/organizations/jenkins" + pipelinePath.String() + "/runs/" + strconv.Itoa(config.Number) + "/nodes/"
+
+	req, err := http.NewRequestWithContext(c, "GET", nodesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", AcceptHeader)
+	req.Header.Set("Accept-Language", AcceptLanguageHeader)
+	req.Header.Set("Connection", ConnectionHeader)
+	req.SetBasicAuth(config.Username, config.Password)
+
+	resp, err := j.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jenkins api %s: unexpected status %d", nodesURL, resp.StatusCode)
+	}
+
+	var nodes []jenkins_api.JenkinsBlueOceanNode
+	err = json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
@@ -0,0 +1,54 @@
+﻿package impl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+)
+
+// 获取 Jenkins 中单次构建的详情信息，包含 actions
+func (j *JenkinsServiceImpl) GetBuildDetail(c context.Context, config *jenkins_api.JenkinsBuildDetailRequest) (*jenkins_api.JenkinsRun, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	req, err := http.NewRequestWithContext(c, "GET", config.BuildURL+"api/json", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", AcceptHeader)
+	req.Header.Set("Accept-Language", AcceptLanguageHeader)
+	req.Header.Set("Connection", ConnectionHeader)
+	req.SetBasicAuth(config.Username, config.Password)
+
+	resp, err := j.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jenkins api %s: unexpected status %d", config.BuildURL, resp.StatusCode)
+	}
+
+	var jenkinsRun jenkins_api.JenkinsRun
+	err = json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&jenkinsRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jenkinsRun, nil
+}
@@ -6,9 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"log"
 	"net/http"
-	"time"
 
 	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
 )
@@ -21,9 +19,8 @@ func (j *JenkinsServiceImpl) GetBuildsHistory(c context.Context, config *jenkins
 	}
 
 	req, err := http.NewRequest("GET", config.JobURL+"/api/json?pretty=true", nil)
-
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	req.Header.Set("Accept", AcceptHeader)
@@ -31,11 +28,7 @@ func (j *JenkinsServiceImpl) GetBuildsHistory(c context.Context, config *jenkins
 	req.Header.Set("Connection", ConnectionHeader)
 	req.SetBasicAuth(config.Username, config.Password)
 
-	j.client = &http.Client{
-		Timeout: time.Second * 10,
-	}
-
-	resp, err := j.client.Do(req)
+	resp, err := j.httpClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -43,7 +36,7 @@ func (j *JenkinsServiceImpl) GetBuildsHistory(c context.Context, config *jenkins
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	var jenkinsBuildsResponse jenkins_api.JenkinsBuildsResponse
@@ -0,0 +1,55 @@
+﻿package impl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+)
+
+// 获取 Jenkins 实例的节点列表，depth=1 时才会返回每个执行器的空闲状态
+func (j *JenkinsServiceImpl) GetComputers(c context.Context, config *jenkins_api.JenkinsComputerRequest) (*jenkins_api.JenkinsComputerResponse, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	req, err := http.NewRequestWithContext(c, "GET", strings.TrimSuffix(config.JenkinsURL, "/")+"/computer/api/json?depth=1", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", AcceptHeader)
+	req.Header.Set("Accept-Language", AcceptLanguageHeader)
+	req.Header.Set("Connection", ConnectionHeader)
+	req.SetBasicAuth(config.Username, config.Password)
+
+	resp, err := j.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jenkins api %s: unexpected status %d", config.JenkinsURL, resp.StatusCode)
+	}
+
+	var jenkinsComputers jenkins_api.JenkinsComputerResponse
+	err = json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&jenkinsComputers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jenkinsComputers, nil
+}
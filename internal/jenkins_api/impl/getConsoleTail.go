@@ -0,0 +1,87 @@
+﻿package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+)
+
+// 获取构建的控制台日志。只需要末尾时用 Range 头请求最后 TailBytes 个字节，
+// Jenkins（或前面的反向代理）不支持 Range 时返回完整日志，这里边读边丢弃，只在内存中保留末尾。
+// 日志可能很大，使用比其它接口更长的超时时间
+func (j *JenkinsServiceImpl) GetConsoleTail(c context.Context, config *jenkins_api.JenkinsConsoleRequest) (string, error) {
+
+	if config == nil {
+		return "", errors.New("config is nil")
+	}
+
+	req, err := http.NewRequestWithContext(c, "GET", config.BuildURL+"consoleText", nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Accept-Language", AcceptLanguageHeader)
+	req.Header.Set("Connection", ConnectionHeader)
+	if config.TailBytes > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=-%d", config.TailBytes))
+	}
+	req.SetBasicAuth(config.Username, config.Password)
+
+	resp, err := j.consoleClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		// 日志为空
+		return "", nil
+	default:
+		return "", &StatusError{URL: req.URL.String(), StatusCode: resp.StatusCode}
+	}
+
+	if config.TailBytes <= 0 {
+		bodyBytes, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		return string(bodyBytes), nil
+	}
+
+	tail := &tailBuffer{limit: config.TailBytes}
+	if _, err := io.Copy(tail, resp.Body); err != nil {
+		return "", err
+	}
+	// 截取的起点可能落在多字节字符中间，丢掉开头不完整的字符
+	buf := tail.buf
+	for len(buf) > 0 && !utf8.RuneStart(buf[0]) {
+		buf = buf[1:]
+	}
+	return string(buf), nil
+}
+
+// 只保留最后 limit 个字节的 writer
+type tailBuffer struct {
+	limit int
+	buf   []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) >= t.limit {
+		t.buf = append(t.buf[:0], p[len(p)-t.limit:]...)
+		return n, nil
+	}
+	if overflow := len(t.buf) + len(p) - t.limit; overflow > 0 {
+		t.buf = append(t.buf[:0], t.buf[overflow:]...)
+	}
+	t.buf = append(t.buf, p...)
+	return n, nil
+}
@@ -0,0 +1,61 @@
+﻿package impl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+)
+
+// 探测 Jenkins 实例是否可达、认证是否有效，并获取 Jenkins 版本。
+// 只有网络错误才返回 error，认证失败等情况通过 StatusCode 区分
+func (j *JenkinsServiceImpl) GetInstanceInfo(c context.Context, config *jenkins_api.JenkinsInstanceInfoRequest) (*jenkins_api.JenkinsInstanceInfo, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	req, err := http.NewRequestWithContext(c, "GET", strings.TrimSuffix(config.JenkinsURL, "/")+"/api/json?tree=mode", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", AcceptHeader)
+	req.Header.Set("Accept-Language", AcceptLanguageHeader)
+	req.Header.Set("Connection", ConnectionHeader)
+	req.SetBasicAuth(config.Username, config.Password)
+
+	resp, err := j.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// 认证失败时 Jenkins 同样会返回 X-Jenkins 响应头
+	info := &jenkins_api.JenkinsInstanceInfo{
+		StatusCode: resp.StatusCode,
+		Version:    resp.Header.Get("X-Jenkins"),
+	}
+	if resp.StatusCode != http.StatusOK {
+		return info, nil
+	}
+
+	// 反向代理等返回的非 Jenkins 页面无法解析
+	var jenkinsJobs jenkins_api.JenkinsJobsResponse
+	err = json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&jenkinsJobs)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
@@ -0,0 +1,54 @@
+﻿package impl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+)
+
+// 通过 Pipeline REST API 获取流水线构建的阶段信息，需要安装 Pipeline Stage View 插件
+func (j *JenkinsServiceImpl) GetPipelineStages(c context.Context, config *jenkins_api.JenkinsPipelineStagesRequest) (*jenkins_api.JenkinsPipelineRun, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	req, err := http.NewRequestWithContext(c, "GET", config.BuildURL+"wfapi/describe", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", AcceptHeader)
+	req.Header.Set("Accept-Language", AcceptLanguageHeader)
+	req.Header.Set("Connection", ConnectionHeader)
+	req.SetBasicAuth(config.Username, config.Password)
+
+	resp, err := j.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jenkins api %s: unexpected status %d", config.BuildURL, resp.StatusCode)
+	}
+
+	var pipelineRun jenkins_api.JenkinsPipelineRun
+	err = json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&pipelineRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pipelineRun, nil
+}
@@ -0,0 +1,55 @@
+﻿package impl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+)
+
+// 获取 Jenkins 实例当前的构建队列，包含每个队列项的等待原因与入队时间
+func (j *JenkinsServiceImpl) GetQueue(c context.Context, config *jenkins_api.JenkinsQueueRequest) (*jenkins_api.JenkinsQueueResponse, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	req, err := http.NewRequestWithContext(c, "GET", strings.TrimSuffix(config.JenkinsURL, "/")+"/queue/api/json", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", AcceptHeader)
+	req.Header.Set("Accept-Language", AcceptLanguageHeader)
+	req.Header.Set("Connection", ConnectionHeader)
+	req.SetBasicAuth(config.Username, config.Password)
+
+	resp, err := j.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jenkins api %s: unexpected status %d", config.JenkinsURL, resp.StatusCode)
+	}
+
+	var jenkinsQueue jenkins_api.JenkinsQueueResponse
+	err = json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&jenkinsQueue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jenkinsQueue, nil
+}
@@ -0,0 +1,57 @@
+﻿package impl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+)
+
+// 获取构建的 JUnit 测试报告，没有归档测试结果的构建返回 404，此时返回 nil
+func (j *JenkinsServiceImpl) GetTestReport(c context.Context, config *jenkins_api.JenkinsTestReportRequest) (*jenkins_api.JenkinsTestReport, error) {
+
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	req, err := http.NewRequestWithContext(c, "GET", config.BuildURL+"testReport/api/json", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", AcceptHeader)
+	req.Header.Set("Accept-Language", AcceptLanguageHeader)
+	req.Header.Set("Connection", ConnectionHeader)
+	req.SetBasicAuth(config.Username, config.Password)
+
+	resp, err := j.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: req.URL.String(), StatusCode: resp.StatusCode}
+	}
+
+	var testReport jenkins_api.JenkinsTestReport
+	err = json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&testReport)
+	if err != nil {
+		return nil, err
+	}
+
+	return &testReport, nil
+}
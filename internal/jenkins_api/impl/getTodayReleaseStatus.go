@@ -6,7 +6,6 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"time"
 
 	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
 )
@@ -15,7 +14,7 @@ func (j *JenkinsServiceImpl) GetTodayReleaseStatus(c context.Context, config *je
 
 	req, err := http.NewRequest("GET", config.BuildsURL+"/api/json?pretty=true", nil)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
 	req.Header.Set("Accept", AcceptHeader)
@@ -23,11 +22,7 @@ func (j *JenkinsServiceImpl) GetTodayReleaseStatus(c context.Context, config *je
 	req.Header.Set("Connection", ConnectionHeader)
 	req.SetBasicAuth(config.Username, config.Password)
 
-	j.client = &http.Client{
-		Timeout: time.Second * 10,
-	}
-
-	resp, err := j.client.Do(req)
+	resp, err := j.httpClient().Do(req)
 	if err != nil {
 		return "resp:%s", err
 	}
@@ -36,7 +31,7 @@ func (j *JenkinsServiceImpl) GetTodayReleaseStatus(c context.Context, config *je
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
 	var data jenkins_api.JenkinsRun
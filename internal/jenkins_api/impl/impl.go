@@ -1,11 +1,59 @@
 ﻿package impl
 
-import "net/http"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
 
 func NewJenkinsServiceImpl(client *http.Client) *JenkinsServiceImpl {
-	return &JenkinsServiceImpl{}
+	return &JenkinsServiceImpl{client: client}
 }
 
 type JenkinsServiceImpl struct {
 	client *http.Client
 }
+
+// 未注入 client 时使用默认超时的 client。不修改 j.client，以便多个 goroutine 共用同一个 JenkinsServiceImpl
+func (j *JenkinsServiceImpl) httpClient() *http.Client {
+	if j.client != nil {
+		return j.client
+	}
+	return &http.Client{
+		Timeout: time.Second * 10,
+	}
+}
+
+// 获取控制台日志的超时时间
+const consoleTimeout = 60 * time.Second
+
+// 与 httpClient 相同，但超时时间不短于 consoleTimeout
+func (j *JenkinsServiceImpl) consoleClient() *http.Client {
+	client := *j.httpClient()
+	if client.Timeout != 0 && client.Timeout < consoleTimeout {
+		client.Timeout = consoleTimeout
+	}
+	return &client
+}
+
+// Jenkins 返回非预期状态码时的错误
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("jenkins api %s: unexpected status %d", e.URL, e.StatusCode)
+}
+
+// 网络错误与 5xx 是暂时性的，稍后重试可能成功；其它状态码与响应解析错误重试也不会成功
+func Retryable(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode >= http.StatusInternalServerError
+}
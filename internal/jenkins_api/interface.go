@@ -12,6 +12,30 @@ type JenkinsAPIInterface interface {
 	//通过 Jenkins 所有 job 列表，获取指定 job 的构建历史列表
 	GetBuildsHistory(context.Context, *JenkinsBuildsRequest) (*JenkinsBuildsResponse, error)
 
+	//获取单次构建的详情，包含 git revision 等 actions 信息
+	GetBuildDetail(context.Context, *JenkinsBuildDetailRequest) (*JenkinsRun, error)
+
+	//探测 Jenkins 实例的可达性、认证有效性与版本
+	GetInstanceInfo(context.Context, *JenkinsInstanceInfoRequest) (*JenkinsInstanceInfo, error)
+
+	//获取 Jenkins 实例当前的构建队列
+	GetQueue(context.Context, *JenkinsQueueRequest) (*JenkinsQueueResponse, error)
+
+	//获取 Jenkins 实例的节点列表，包含执行器与节点监控数据
+	GetComputers(context.Context, *JenkinsComputerRequest) (*JenkinsComputerResponse, error)
+
+	//通过 Pipeline REST API（wfapi）获取流水线构建的各阶段耗时与状态
+	GetPipelineStages(context.Context, *JenkinsPipelineStagesRequest) (*JenkinsPipelineRun, error)
+
+	//通过 Blue Ocean 接口获取流水线构建的节点（阶段）信息，未安装 Pipeline Stage View 插件时使用
+	GetBlueOceanNodes(context.Context, *JenkinsBlueOceanNodesRequest) ([]JenkinsBlueOceanNode, error)
+
+	//获取构建的 JUnit 测试报告，构建没有测试报告时返回 nil
+	GetTestReport(context.Context, *JenkinsTestReportRequest) (*JenkinsTestReport, error)
+
+	//获取构建控制台日志的末尾部分，用于失败原因分类
+	GetConsoleTail(context.Context, *JenkinsConsoleRequest) (string, error)
+
 	//通过 job 的构建历史列表，获取指定 job 的当天发布状态
 	GetTodayReleaseStatus(context.Context, *JenkinsTodayReleaseStatusRequest) (*JenkinsTodayReleaseStatusResponse, error)
 
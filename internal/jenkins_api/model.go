@@ -7,8 +7,8 @@ type JenkinsJobs struct {
 	JobsURL string `json:"url"`
 }
 type JenkinsJobsResponse struct {
-	_class string        `json:"_class"`
-	Jobs   []JenkinsJobs `json:"jobs"`
+	Class string        `json:"_class"`
+	Jobs  []JenkinsJobs `json:"jobs"`
 }
 
 type JenkinsJobsRequest struct {
@@ -121,7 +121,7 @@ type Builds struct {
 type Build struct {
 	Number          int    `json:"number"`
 	URL             string `json:"url"`
-	ID              string `json:"number"`
+	ID              string `json:"id"`
 	Building        bool   `json:"building"`
 	FullDisplayName string `json:"fullDisplayName"`
 	Result          string `json:"result"`
@@ -135,11 +135,25 @@ type JenkinsTodayReleaseStatusRequest struct {
 	Password  string `json:"password"`
 }
 
+// getBuildDetail
+type JenkinsBuildDetailRequest struct {
+	BuildURL string `json:"buildURL"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
 type Cause struct {
 	Class     string `json:"_class"`
 	ShortDesc string `json:"shortDescription"`
 	UserID    string `json:"userId"`
 	UserName  string `json:"userName"`
+	// 上游构建触发
+	UpstreamProject string `json:"upstreamProject"`
+	UpstreamBuild   int    `json:"upstreamBuild"`
+	UpstreamURL     string `json:"upstreamUrl"`
+	// 远程 API 触发
+	Addr string `json:"addr"`
+	Note string `json:"note"`
 }
 
 type BuildsData struct {
@@ -187,6 +201,7 @@ type JenkinsRun struct {
 	KeepLog           bool          `json:"keepLog"`
 	Number            int           `json:"number"`
 	QueueID           int           `json:"queueId"`
+	BuiltOn           string        `json:"builtOn"`
 	Result            string        `json:"result"`
 	Timestamp         int64         `json:"timestamp"`
 	URL               string        `json:"url"`
@@ -212,3 +227,168 @@ type JenkinsTodayReleaseStatusResponse struct {
 	Todaytimestamp     int64                `json:"todaytimestamp"`
 	TodayReleaseStatus []TodayReleaseStatus `json:"todayReleaseStatus"`
 }
+
+// getQueue
+type JenkinsQueueRequest struct {
+	JenkinsURL string `json:"jenkinsURL"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+}
+
+type JenkinsQueueItem struct {
+	Class        string `json:"_class"`
+	ID           int    `json:"id"`
+	InQueueSince int64  `json:"inQueueSince"`
+	Why          string `json:"why"`
+	Blocked      bool   `json:"blocked"`
+	Buildable    bool   `json:"buildable"`
+	Stuck        bool   `json:"stuck"`
+	Task         struct {
+		Class string `json:"_class"`
+		Name  string `json:"name"`
+		URL   string `json:"url"`
+	} `json:"task"`
+}
+
+type JenkinsQueueResponse struct {
+	Class string             `json:"_class"`
+	Items []JenkinsQueueItem `json:"items"`
+}
+
+// getComputers
+type JenkinsComputerRequest struct {
+	JenkinsURL string `json:"jenkinsURL"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+}
+
+type JenkinsComputer struct {
+	Class              string `json:"_class"`
+	DisplayName        string `json:"displayName"`
+	Offline            bool   `json:"offline"`
+	TemporarilyOffline bool   `json:"temporarilyOffline"`
+	OfflineCauseReason string `json:"offlineCauseReason"`
+	Idle               bool   `json:"idle"`
+	NumExecutors       int    `json:"numExecutors"`
+	AssignedLabels     []struct {
+		Name string `json:"name"`
+	} `json:"assignedLabels"`
+	Executors []struct {
+		Idle bool `json:"idle"`
+	} `json:"executors"`
+	MonitorData map[string]interface{} `json:"monitorData"`
+}
+
+type JenkinsComputerResponse struct {
+	Class          string            `json:"_class"`
+	BusyExecutors  int               `json:"busyExecutors"`
+	TotalExecutors int               `json:"totalExecutors"`
+	Computer       []JenkinsComputer `json:"computer"`
+}
+
+// getPipelineStages
+type JenkinsPipelineStagesRequest struct {
+	BuildURL string `json:"buildURL"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type JenkinsPipelineStage struct {
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	ExecNode            string `json:"execNode"`
+	Status              string `json:"status"`
+	StartTimeMillis     int64  `json:"startTimeMillis"`
+	DurationMillis      int    `json:"durationMillis"`
+	PauseDurationMillis int    `json:"pauseDurationMillis"`
+}
+
+type JenkinsPipelineRun struct {
+	ID                  string                 `json:"id"`
+	Name                string                 `json:"name"`
+	Status              string                 `json:"status"`
+	StartTimeMillis     int64                  `json:"startTimeMillis"`
+	DurationMillis      int                    `json:"durationMillis"`
+	QueueDurationMillis int                    `json:"queueDurationMillis"`
+	PauseDurationMillis int                    `json:"pauseDurationMillis"`
+	Stages              []JenkinsPipelineStage `json:"stages"`
+}
+
+// getBlueOceanNodes
+type JenkinsBlueOceanNodesRequest struct {
+	JenkinsURL string `json:"jenkinsURL"`
+	// 包含文件夹路径的完整 job 名称
+	JobName  string `json:"jobName"`
+	Number   int    `json:"number"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type JenkinsBlueOceanNode struct {
+	Class            string `json:"_class"`
+	ID               string `json:"id"`
+	DisplayName      string `json:"displayName"`
+	Type             string `json:"type"`
+	Result           string `json:"result"`
+	State            string `json:"state"`
+	StartTime        string `json:"startTime"`
+	DurationInMillis int    `json:"durationInMillis"`
+}
+
+// getTestReport
+type JenkinsTestReportRequest struct {
+	BuildURL string `json:"buildURL"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type JenkinsTestCase struct {
+	ClassName    string  `json:"className"`
+	Name         string  `json:"name"`
+	Status       string  `json:"status"`
+	Duration     float64 `json:"duration"`
+	ErrorDetails string  `json:"errorDetails"`
+}
+
+type JenkinsTestSuite struct {
+	Name     string            `json:"name"`
+	Duration float64           `json:"duration"`
+	Cases    []JenkinsTestCase `json:"cases"`
+}
+
+type JenkinsTestReport struct {
+	Class      string             `json:"_class"`
+	FailCount  int                `json:"failCount"`
+	PassCount  int                `json:"passCount"`
+	SkipCount  int                `json:"skipCount"`
+	TotalCount int                `json:"totalCount"`
+	Duration   float64            `json:"duration"`
+	Suites     []JenkinsTestSuite `json:"suites"`
+	// 矩阵项目等聚合报告中，各子构建的测试结果在 childReports 中
+	ChildReports []struct {
+		Result JenkinsTestReport `json:"result"`
+	} `json:"childReports"`
+}
+
+// getConsoleTail
+type JenkinsConsoleRequest struct {
+	BuildURL string `json:"buildURL"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// 只保留日志末尾的字节数，小于等于 0 时保留全部
+	TailBytes int `json:"tailBytes"`
+}
+
+// getInstanceInfo
+type JenkinsInstanceInfoRequest struct {
+	JenkinsURL string `json:"jenkinsURL"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+}
+
+// Jenkins 实例的健康检查结果，认证失败时 StatusCode 为 401 或 403
+type JenkinsInstanceInfo struct {
+	StatusCode int `json:"statusCode"`
+	// 响应头 X-Jenkins 中的 Jenkins 版本
+	Version string `json:"version"`
+}
@@ -0,0 +1,32 @@
+﻿package jenkins_api
+
+// Notification 插件（或通用构建后 webhook）推送的构建事件
+const (
+	PhaseQueued    = "QUEUED"
+	PhaseStarted   = "STARTED"
+	PhaseCompleted = "COMPLETED"
+	PhaseFinalized = "FINALIZED"
+)
+
+type JenkinsNotification struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	// job 的相对路径，如 job/folder/job/app/
+	URL   string `json:"url"`
+	Build struct {
+		FullURL   string `json:"full_url"`
+		Number    int    `json:"number"`
+		QueueID   int    `json:"queue_id"`
+		Timestamp int64  `json:"timestamp"`
+		Duration  int    `json:"duration"`
+		Phase     string `json:"phase"`
+		Status    string `json:"status"`
+		URL       string `json:"url"`
+		SCM       struct {
+			URL    string `json:"url"`
+			Branch string `json:"branch"`
+			Commit string `json:"commit"`
+		} `json:"scm"`
+		Parameters map[string]interface{} `json:"parameters"`
+	} `json:"build"`
+}
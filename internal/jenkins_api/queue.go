@@ -0,0 +1,39 @@
+﻿package jenkins_api
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// 队列项的 why 中带有等待的节点标签，例如
+// "Waiting for next available executor on ‘linux’"、"There are no nodes with the label ‘docker’"
+var queueLabelPattern = regexp.MustCompile(`(?:executor on|with the label) [‘'"](.+?)[’'"]`)
+
+// 从队列项的 why 中解析出等待的节点标签，没有指定标签时返回空字符串
+func QueueItemLabel(why string) string {
+	match := queueLabelPattern.FindStringSubmatch(why)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// 从 job 地址中解析出包含文件夹路径的完整 job 名称，
+// 如 http://jenkins/job/folder/job/app/ 或 job/folder/job/app/ 解析为 folder/app
+func JobNameFromURL(jobURL string) string {
+	var names []string
+	segments := strings.Split(strings.Trim(jobURL, "/"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] != "job" {
+			continue
+		}
+		name, err := url.PathUnescape(segments[i+1])
+		if err != nil {
+			name = segments[i+1]
+		}
+		names = append(names, name)
+		i++
+	}
+	return strings.Join(names, "/")
+}
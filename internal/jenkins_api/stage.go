@@ -0,0 +1,61 @@
+﻿package jenkins_api
+
+import (
+	"time"
+)
+
+// 流水线阶段状态，统一使用 wfapi 的取值
+const (
+	StageSuccess     = "SUCCESS"
+	StageFailed      = "FAILED"
+	StageUnstable    = "UNSTABLE"
+	StageAborted     = "ABORTED"
+	StageNotExecuted = "NOT_EXECUTED"
+	StageInProgress  = "IN_PROGRESS"
+)
+
+// 流水线构建的类型，只有这类构建才有阶段信息
+const WorkflowRunClass = "org.jenkinsci.plugins.workflow.job.WorkflowRun"
+
+// Blue Ocean 的 startTime 格式，如 2024-01-02T03:04:05.678+0000
+const blueOceanTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+// 将 Blue Ocean 节点转换为 wfapi 的阶段格式，只保留 STAGE 与 PARALLEL 类型的节点
+func StagesFromBlueOcean(nodes []JenkinsBlueOceanNode) []JenkinsPipelineStage {
+	var stages []JenkinsPipelineStage
+	for _, node := range nodes {
+		if node.Type != "STAGE" && node.Type != "PARALLEL" {
+			continue
+		}
+
+		stage := JenkinsPipelineStage{
+			ID:             node.ID,
+			Name:           node.DisplayName,
+			Status:         blueOceanStageStatus(node),
+			DurationMillis: node.DurationInMillis,
+		}
+		if startTime, err := time.Parse(blueOceanTimeLayout, node.StartTime); err == nil {
+			stage.StartTimeMillis = startTime.UnixMilli()
+		}
+		stages = append(stages, stage)
+	}
+	return stages
+}
+
+func blueOceanStageStatus(node JenkinsBlueOceanNode) string {
+	if node.State != "" && node.State != "FINISHED" && node.State != "SKIPPED" && node.State != "NOT_BUILT" {
+		return StageInProgress
+	}
+	switch node.Result {
+	case "SUCCESS":
+		return StageSuccess
+	case "FAILURE":
+		return StageFailed
+	case "UNSTABLE":
+		return StageUnstable
+	case "ABORTED":
+		return StageAborted
+	default:
+		return StageNotExecuted
+	}
+}
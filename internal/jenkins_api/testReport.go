@@ -0,0 +1,35 @@
+﻿package jenkins_api
+
+// 测试用例状态，FIXED 与 REGRESSION 是 Jenkins 结合上一次构建给出的状态，这里归并为通过与失败
+const (
+	TestPassed  = "PASSED"
+	TestFailed  = "FAILED"
+	TestSkipped = "SKIPPED"
+)
+
+func NormalizeTestStatus(status string) string {
+	switch status {
+	case "PASSED", "FIXED":
+		return TestPassed
+	case "FAILED", "REGRESSION":
+		return TestFailed
+	default:
+		return TestSkipped
+	}
+}
+
+// 展开测试报告中的全部测试用例，包含聚合报告的子报告
+func AllTestCases(report *JenkinsTestReport) []JenkinsTestCase {
+	if report == nil {
+		return nil
+	}
+
+	var cases []JenkinsTestCase
+	for _, suite := range report.Suites {
+		cases = append(cases, suite.Cases...)
+	}
+	for i := range report.ChildReports {
+		cases = append(cases, AllTestCases(&report.ChildReports[i].Result)...)
+	}
+	return cases
+}
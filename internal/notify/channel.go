@@ -0,0 +1,71 @@
+﻿package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+)
+
+// 通知渠道，text 为按渠道模板渲染后的正文
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg *Message, text string) error
+}
+
+func newChannel(cfg config.NotifyChannel, client *http.Client) (Channel, error) {
+	switch cfg.Type {
+	case config.ChannelDingTalk:
+		return &dingTalk{name: cfg.Name, url: cfg.URL, secret: cfg.Secret, client: client}, nil
+	case config.ChannelWeCom:
+		return &weCom{name: cfg.Name, url: cfg.URL, client: client}, nil
+	case config.ChannelFeishu:
+		return &feishu{name: cfg.Name, url: cfg.URL, secret: cfg.Secret, client: client}, nil
+	case config.ChannelSlack:
+		return &slack{name: cfg.Name, url: cfg.URL, client: client}, nil
+	case config.ChannelEmail:
+		return &email{name: cfg.Name, smtp: cfg.SMTP}, nil
+	case config.ChannelWebhook:
+		return &webhook{name: cfg.Name, url: cfg.URL, secret: cfg.Secret, client: client}, nil
+	}
+	return nil, fmt.Errorf("unknown channel type %q", cfg.Type)
+}
+
+// 以 JSON 发送 POST 请求，非 2xx 时返回错误，否则返回响应体
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}, header http.Header) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return respBody, nil
+}
@@ -0,0 +1,85 @@
+﻿package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 钉钉群机器人，配置了加签密钥时在地址上附加 timestamp 与 sign
+type dingTalk struct {
+	name   string
+	url    string
+	secret string
+	client *http.Client
+}
+
+func (d *dingTalk) Name() string {
+	return d.name
+}
+
+func (d *dingTalk) Send(ctx context.Context, msg *Message, text string) error {
+	target := d.url
+	if d.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		target = appendQuery(target, url.Values{
+			"timestamp": {timestamp},
+			"sign":      {DingTalkSign(timestamp, d.secret)},
+		})
+	}
+
+	body := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": msg.Title,
+			"text":  text,
+		},
+	}
+	respBody, err := postJSON(ctx, d.client, target, body, nil)
+	if err != nil {
+		return err
+	}
+	return checkErrcode(respBody)
+}
+
+// 钉钉加签：以密钥对 "timestamp\n密钥" 做 HMAC-SHA256 后 Base64 编码
+func DingTalkSign(timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func appendQuery(rawURL string, values url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	for name, value := range values {
+		query[name] = value
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// 钉钉与企业微信机器人在 HTTP 200 的响应中通过 errcode 返回错误
+func checkErrcode(respBody []byte) error {
+	var resp struct {
+		Errcode int    `json:"errcode"`
+		Errmsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if resp.Errcode != 0 {
+		return fmt.Errorf("errcode %d: %s", resp.Errcode, resp.Errmsg)
+	}
+	return nil
+}
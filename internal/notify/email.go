@@ -0,0 +1,101 @@
+﻿package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+)
+
+// 连接 SMTP 服务器的默认超时，ctx 带有更早的截止时间时以 ctx 为准
+const smtpTimeout = 30 * time.Second
+
+// 邮件渠道，正文为纯文本。服务器支持 STARTTLS 时自动启用，配置了用户名时使用 PLAIN 认证
+type email struct {
+	name string
+	smtp config.SMTP
+}
+
+func (e *email) Name() string {
+	return e.name
+}
+
+func (e *email) Send(ctx context.Context, msg *Message, text string) error {
+	port := e.smtp.Port
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(e.smtp.Host, strconv.Itoa(port))
+
+	deadline := time.Now().Add(smtpTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, e.smtp.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: e.smtp.Host}); err != nil {
+			return err
+		}
+	}
+	if e.smtp.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.smtp.Username, e.smtp.Password, e.smtp.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(e.smtp.From); err != nil {
+		return err
+	}
+	for _, to := range e.smtp.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("rcpt %s: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(e.compose(msg, text)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (e *email) compose(msg *Message, text string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.smtp.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.smtp.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,91 @@
+﻿package fake
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+)
+
+// 进程内的群机器人与 webhook 模拟服务，记录收到的请求，响应与失败可通过脚本设置。
+// 用于在不访问钉钉、飞书、企业微信、Slack 的情况下验证通知的签名与消息格式
+type Robot struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	body     string
+	failures int
+	requests []Request
+}
+
+type Request struct {
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// 启动模拟服务，默认返回 200 与钉钉、企业微信、飞书都能识别的成功响应，使用完毕后需调用 Close
+func NewRobot() *Robot {
+	r := &Robot{
+		status: http.StatusOK,
+		body:   `{"errcode":0,"errmsg":"ok","code":0,"msg":"success"}`,
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	return r
+}
+
+func (r *Robot) URL() string {
+	return r.server.URL
+}
+
+func (r *Robot) Close() {
+	r.server.Close()
+}
+
+// 设置之后请求的响应
+func (r *Robot) SetResponse(status int, body string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+	r.body = body
+}
+
+// 之后的 n 个请求返回 503
+func (r *Robot) FailNext(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = n
+}
+
+// 收到的全部请求，包括失败的请求
+func (r *Robot) Requests() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Request(nil), r.requests...)
+}
+
+func (r *Robot) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests = append(r.requests, Request{
+		Path:   req.URL.Path,
+		Query:  req.URL.Query(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+
+	if r.failures > 0 {
+		r.failures--
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(r.status)
+	io.WriteString(w, r.body)
+}
@@ -0,0 +1,150 @@
+﻿package fake
+
+import (
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// 进程内的 SMTP 模拟服务，只实现发送邮件所需的命令，接受任意 PLAIN 认证，不支持 STARTTLS
+type SMTPServer struct {
+	listener net.Listener
+
+	mu   sync.Mutex
+	mail []Mail
+	wg   sync.WaitGroup
+}
+
+type Mail struct {
+	From string
+	To   []string
+	// 认证时使用的用户名，未认证时为空
+	Username string
+	// 包含邮件头的原始内容
+	Data string
+}
+
+// 在本地随机端口启动，使用完毕后需调用 Close
+func NewSMTPServer() (*SMTPServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &SMTPServer{listener: listener}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *SMTPServer) Addr() *net.TCPAddr {
+	return s.listener.Addr().(*net.TCPAddr)
+}
+
+func (s *SMTPServer) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// 收到的全部邮件
+func (s *SMTPServer) Mail() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail(nil), s.mail...)
+}
+
+func (s *SMTPServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *SMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(line string) bool {
+		return text.PrintfLine("%s", line) == nil
+	}
+
+	if !reply("220 localhost ESMTP fake") {
+		return
+	}
+
+	var mail Mail
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(command) {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250-AUTH PLAIN")
+			reply("250 8BITMIME")
+		case "HELO", "NOOP":
+			reply("250 OK")
+		case "AUTH":
+			mail.Username = plainUsername(arg)
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			mail.From = addressOf(arg)
+			reply("250 OK")
+		case "RCPT":
+			mail.To = append(mail.To, addressOf(arg))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			lines, err := text.ReadDotLines()
+			if err != nil {
+				return
+			}
+			mail.Data = strings.Join(lines, "\n")
+			s.mu.Lock()
+			s.mail = append(s.mail, mail)
+			s.mu.Unlock()
+			mail = Mail{Username: mail.Username}
+			reply("250 OK")
+		case "RSET":
+			mail = Mail{Username: mail.Username}
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// MAIL FROM:<a@b> BODY=8BITMIME 与 RCPT TO:<a@b> 中的地址
+func addressOf(arg string) string {
+	_, address, _ := strings.Cut(arg, ":")
+	address, _, _ = strings.Cut(strings.TrimSpace(address), " ")
+	return strings.Trim(address, "<>")
+}
+
+// AUTH PLAIN <base64(\x00username\x00password)>
+func plainUsername(arg string) string {
+	_, encoded, _ := strings.Cut(arg, " ")
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(string(decoded), "\x00")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
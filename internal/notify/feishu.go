@@ -0,0 +1,67 @@
+﻿package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 飞书群机器人，以消息卡片发送 Markdown；配置了签名校验密钥时在请求体中附加 timestamp 与 sign
+type feishu struct {
+	name   string
+	url    string
+	secret string
+	client *http.Client
+}
+
+func (f *feishu) Name() string {
+	return f.name
+}
+
+func (f *feishu) Send(ctx context.Context, msg *Message, text string) error {
+	body := map[string]interface{}{
+		"msg_type": "interactive",
+		"card": map[string]interface{}{
+			"header": map[string]interface{}{
+				"title": map[string]string{"tag": "plain_text", "content": msg.Title},
+			},
+			"elements": []map[string]string{
+				{"tag": "markdown", "content": text},
+			},
+		},
+	}
+	if f.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		body["timestamp"] = timestamp
+		body["sign"] = FeishuSign(timestamp, f.secret)
+	}
+
+	respBody, err := postJSON(ctx, f.client, f.url, body, nil)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("code %d: %s", resp.Code, resp.Msg)
+	}
+	return nil
+}
+
+// 飞书签名：以 "timestamp\n密钥" 为 key 对空字符串做 HMAC-SHA256 后 Base64 编码
+func FeishuSign(timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,122 @@
+﻿package notify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 一条通知，按 Kind 与 Labels 路由到渠道，正文由各渠道的模板渲染
+type Message struct {
+	Kind   string            `json:"kind"`
+	Title  string            `json:"title"`
+	Text   string            `json:"text"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// 告警通知为分组内的全部告警
+	Alerts []store.Alert `json:"alerts,omitempty"`
+	At     time.Time     `json:"at"`
+}
+
+// 一个分组内的告警合并为一条通知，Labels 为分组内全部告警共有的标签，用于路由
+func AlertGroupMessage(groupLabels map[string]string, firing, resolved []store.Alert) Message {
+	alerts := append(append([]store.Alert(nil), firing...), resolved...)
+
+	var title strings.Builder
+	if len(firing) > 0 {
+		fmt.Fprintf(&title, "[FIRING:%d]", len(firing))
+	} else {
+		title.WriteString("[RESOLVED]")
+	}
+	for _, label := range sortedLabels(groupLabels) {
+		fmt.Fprintf(&title, " %s=%s", label.Name, label.Value)
+	}
+
+	var text strings.Builder
+	for _, alert := range firing {
+		fmt.Fprintf(&text, "- %s\n", alertText(alert))
+	}
+	if len(resolved) > 0 {
+		if len(firing) > 0 {
+			text.WriteString("\n已恢复:\n")
+		}
+		for _, alert := range resolved {
+			fmt.Fprintf(&text, "- %s\n", alertText(alert))
+		}
+	}
+
+	var at time.Time
+	for _, alert := range alerts {
+		if alert.EvaluatedAt.After(at) {
+			at = alert.EvaluatedAt
+		}
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	return Message{
+		Kind:   config.MessageAlert,
+		Title:  title.String(),
+		Text:   strings.TrimSuffix(text.String(), "\n"),
+		Labels: commonLabels(alerts),
+		Alerts: alerts,
+		At:     at,
+	}
+}
+
+func alertText(alert store.Alert) string {
+	var text strings.Builder
+	if summary := alert.Annotations["summary"]; summary != "" {
+		text.WriteString(summary)
+	} else {
+		fmt.Fprintf(&text, "%s 当前值 %v，阈值 %v", alert.Rule, alert.Value, alert.Threshold)
+	}
+	if description := alert.Annotations["description"]; description != "" {
+		text.WriteString("\n\n")
+		text.WriteString(description)
+	}
+	return text.String()
+}
+
+func commonLabels(alerts []store.Alert) map[string]string {
+	if len(alerts) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(alerts[0].Labels))
+	for name, value := range alerts[0].Labels {
+		labels[name] = value
+	}
+	for _, alert := range alerts[1:] {
+		for name, value := range labels {
+			if alert.Labels[name] != value {
+				delete(labels, name)
+			}
+		}
+	}
+	return labels
+}
+
+// 按标签名排序，便于模板中稳定输出
+func (m Message) SortedLabels() []Label {
+	return sortedLabels(m.Labels)
+}
+
+func sortedLabels(m map[string]string) []Label {
+	labels := make([]Label, 0, len(m))
+	for name, value := range m {
+		labels = append(labels, Label{Name: name, Value: value})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return labels
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
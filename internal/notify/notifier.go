@@ -0,0 +1,191 @@
+﻿package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+)
+
+// 按 notify 配置把消息路由到渠道发送，发送失败时按指数退避重试，
+// 重试后仍失败的消息写入死信文件，便于排查与补发
+type Notifier struct {
+	channels map[string]*notifyChannel
+	routes   []config.NotifyRoute
+
+	attempts       int
+	backoff        time.Duration
+	deadLetterPath string
+
+	// 保护死信文件的写入
+	mu sync.Mutex
+}
+
+type notifyChannel struct {
+	Channel
+	tmpl *template.Template
+}
+
+// 死信文件中的一行
+type DeadLetter struct {
+	Time     time.Time `json:"time"`
+	Channel  string    `json:"channel"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Message  Message   `json:"message"`
+}
+
+// client 为空时使用默认 client
+func New(cfg *config.Notify, client *http.Client) (*Notifier, error) {
+	n := &Notifier{
+		channels:       make(map[string]*notifyChannel, len(cfg.Channels)),
+		routes:         cfg.Routes,
+		attempts:       cfg.Attempts,
+		backoff:        cfg.Backoff,
+		deadLetterPath: cfg.DeadLetterPath,
+	}
+	if n.attempts <= 0 {
+		n.attempts = 1
+	}
+
+	for _, channelConfig := range cfg.Channels {
+		channel, err := newChannel(channelConfig, client)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := parseTemplate(channelConfig)
+		if err != nil {
+			return nil, fmt.Errorf("notify channel %s: %w", channelConfig.Name, err)
+		}
+		n.channels[channelConfig.Name] = &notifyChannel{Channel: channel, tmpl: tmpl}
+	}
+	return n, nil
+}
+
+// 注册自定义渠道，同名渠道会被替换
+func (n *Notifier) AddChannel(channel Channel, tmpl *template.Template) {
+	if tmpl == nil {
+		tmpl = template.Must(template.New(channel.Name()).Parse(defaultMarkdownTemplate))
+	}
+	n.channels[channel.Name()] = &notifyChannel{Channel: channel, tmpl: tmpl}
+}
+
+// 消息匹配的全部渠道，按路由配置的顺序去重
+func (n *Notifier) Route(msg *Message) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for i := range n.routes {
+		if !n.routes[i].MatchMessage(msg.Kind, msg.Labels) {
+			continue
+		}
+		for _, name := range n.routes[i].Channels {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// 发送到路由匹配的全部渠道，返回发送失败的渠道的错误
+func (n *Notifier) Notify(ctx context.Context, msg Message) error {
+	if msg.At.IsZero() {
+		msg.At = time.Now()
+	}
+
+	var errs []error
+	for _, name := range n.Route(&msg) {
+		channel, ok := n.channels[name]
+		if !ok {
+			continue
+		}
+		if err := n.send(ctx, channel, &msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) send(ctx context.Context, channel *notifyChannel, msg *Message) error {
+	text, err := render(channel.tmpl, msg)
+	if err != nil {
+		// 模板错误重试也不会成功
+		n.deadLetter(channel.Name(), msg, 0, err)
+		return err
+	}
+
+	backoff := n.backoff
+	attempt := 0
+	for {
+		attempt++
+		err = channel.Send(ctx, msg, text)
+		if err == nil {
+			return nil
+		}
+		log.Warningf("通知渠道[%s]第%d次发送失败: %v", channel.Name(), attempt, err)
+		if attempt >= n.attempts {
+			break
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff *= 2
+	}
+
+	n.deadLetter(channel.Name(), msg, attempt, err)
+	return err
+}
+
+func (n *Notifier) deadLetter(channel string, msg *Message, attempts int, sendErr error) {
+	log.Errorf("通知渠道[%s]发送失败，写入死信: %s: %v", channel, msg.Title, sendErr)
+	if n.deadLetterPath == "" {
+		return
+	}
+
+	line, err := json.Marshal(DeadLetter{
+		Time:     time.Now(),
+		Channel:  channel,
+		Attempts: attempts,
+		Error:    sendErr.Error(),
+		Message:  *msg,
+	})
+	if err != nil {
+		log.Errorf("序列化死信失败: %v", err)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(n.deadLetterPath), 0o755); err != nil {
+		log.Errorf("创建死信目录失败: %v", err)
+		return
+	}
+	f, err := os.OpenFile(n.deadLetterPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Errorf("打开死信文件失败: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Errorf("写入死信文件失败: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+﻿package notify
+
+import (
+	"context"
+	"net/http"
+)
+
+// Slack Incoming Webhook，正文为 mrkdwn
+type slack struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+func (s *slack) Name() string {
+	return s.name
+}
+
+func (s *slack) Send(ctx context.Context, msg *Message, text string) error {
+	_, err := postJSON(ctx, s.client, s.url, map[string]string{"text": text}, nil)
+	return err
+}
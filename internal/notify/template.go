@@ -0,0 +1,55 @@
+﻿package notify
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+)
+
+// 钉钉、企业微信、飞书都支持 Markdown 的标题、加粗与列表
+const defaultMarkdownTemplate = `### {{ .Title }}
+{{ .Text }}
+{{ range .SortedLabels }}
+- **{{ .Name }}**: {{ .Value }}{{ end }}
+
+> {{ .At.Format "2006-01-02 15:04:05" }}`
+
+// Slack 使用 mrkdwn，加粗为单个星号
+const slackTemplate = `*{{ .Title }}*
+{{ .Text }}
+{{ range .SortedLabels }}
+• *{{ .Name }}*: {{ .Value }}{{ end }}`
+
+const emailTemplate = `{{ .Text }}
+{{ range .SortedLabels }}
+{{ .Name }}: {{ .Value }}{{ end }}
+
+{{ .At.Format "2006-01-02 15:04:05" }}`
+
+func defaultTemplate(channelType string) string {
+	switch channelType {
+	case config.ChannelSlack:
+		return slackTemplate
+	case config.ChannelEmail, config.ChannelWebhook:
+		return emailTemplate
+	default:
+		return defaultMarkdownTemplate
+	}
+}
+
+func parseTemplate(channel config.NotifyChannel) (*template.Template, error) {
+	text := channel.Template
+	if text == "" {
+		text = defaultTemplate(channel.Type)
+	}
+	return template.New(channel.Name).Option("missingkey=zero").Parse(text)
+}
+
+func render(tmpl *template.Template, msg *Message) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, msg); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,52 @@
+﻿package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// 签名请求头，值为 sha256=<请求体的 HMAC-SHA256 十六进制>
+const SignatureHeader = "X-Signature-256"
+
+// 通用 webhook，以 JSON 发送完整的消息，配置了密钥时附加签名请求头
+type webhook struct {
+	name   string
+	url    string
+	secret string
+	client *http.Client
+}
+
+type webhookPayload struct {
+	*Message
+	// 按渠道模板渲染后的正文
+	Rendered string `json:"rendered"`
+}
+
+func (w *webhook) Name() string {
+	return w.name
+}
+
+func (w *webhook) Send(ctx context.Context, msg *Message, text string) error {
+	payload := webhookPayload{Message: msg, Rendered: text}
+
+	header := http.Header{}
+	if w.secret != "" {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		header.Set(SignatureHeader, "sha256="+WebhookSign(data, w.secret))
+	}
+	_, err := postJSON(ctx, w.client, w.url, payload, header)
+	return err
+}
+
+func WebhookSign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
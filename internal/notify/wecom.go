@@ -0,0 +1,31 @@
+﻿package notify
+
+import (
+	"context"
+	"net/http"
+)
+
+// 企业微信群机器人，key 包含在地址中，没有加签
+type weCom struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+func (w *weCom) Name() string {
+	return w.name
+}
+
+func (w *weCom) Send(ctx context.Context, msg *Message, text string) error {
+	body := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": text,
+		},
+	}
+	respBody, err := postJSON(ctx, w.client, w.url, body, nil)
+	if err != nil {
+		return err
+	}
+	return checkErrcode(respBody)
+}
@@ -0,0 +1,263 @@
+﻿package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 采集端访问 Jenkins、GitLab 的方式
+const (
+	// 直接访问
+	ModeLive = ""
+	// 正常访问，同时把每个响应保存为 fixture
+	ModeRecord = "record"
+	// 不访问网络，全部响应从 fixture 中读取
+	ModeReplay = "replay"
+)
+
+// 脱敏后替换凭据的文本
+const redacted = "REDACTED"
+
+// 作为凭据传递的查询参数
+var secretParams = []string{"private_token", "access_token", "token", "password"}
+
+// 随采集时间变化的查询参数，不参与 fixture 的匹配，否则回放时增量采集永远匹配不到
+var volatileParams = []string{"since", "until", "updated_after", "updated_before", "created_after", "created_before"}
+
+// 不保存的响应头
+var secretHeaders = []string{"Set-Cookie", "Authorization", "Private-Token", "X-Gitlab-Token"}
+
+// 一次请求的录制结果，以 JSON 格式保存，便于在问题报告中直接查看与修改
+type fixture struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	// 文本响应保存在 Body 中，二进制响应以 base64 保存在 BodyBytes 中
+	Body      string `json:"body,omitempty"`
+	BodyBytes []byte `json:"bodyBytes,omitempty"`
+}
+
+// 根据 mode 包装 next，next 为空时使用 http.DefaultTransport
+func NewTransport(mode, dir string, next http.RoundTripper) (http.RoundTripper, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	switch mode {
+	case ModeLive:
+		return next, nil
+	case ModeRecord:
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+		return &recorder{dir: dir, next: next}, nil
+	case ModeReplay:
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+		return &replayer{dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("invalid http mode %q", mode)
+	}
+}
+
+type recorder struct {
+	dir  string
+	next http.RoundTripper
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// 压缩的响应无法脱敏，不保存
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		log.Warningf("请求[%s %s]的响应经过 %s 压缩，无法脱敏，不保存 fixture", req.Method, scrubURL(req.URL, requestSecrets(req)), encoding)
+		return resp, nil
+	}
+
+	secrets := requestSecrets(req)
+	f := fixture{
+		Method: req.Method,
+		URL:    scrubURL(req.URL, secrets),
+		Status: resp.StatusCode,
+		Header: resp.Header.Clone(),
+	}
+	for _, header := range secretHeaders {
+		f.Header.Del(header)
+	}
+	if utf8.Valid(body) {
+		f.Body = scrub(string(body), secrets)
+	} else {
+		f.BodyBytes = scrubBytes(body, secrets)
+	}
+
+	// 录制失败不影响正常采集
+	if err := writeFixture(r.dir, req, f); err != nil {
+		log.Warningf("保存请求[%s %s]的 fixture 失败: %v", f.Method, f.URL, err)
+	}
+	return resp, nil
+}
+
+type replayer struct {
+	dir string
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	data, err := os.ReadFile(fixturePath(r.dir, req))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("replay: no fixture for %s %s", req.Method, scrubURL(req.URL, requestSecrets(req)))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("replay: invalid fixture for %s: %w", f.URL, err)
+	}
+
+	body := f.BodyBytes
+	if body == nil {
+		body = []byte(f.Body)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        f.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func writeFixture(dir string, req *http.Request, f fixture) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := fixturePath(dir, req)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fixture 按主机分目录保存，文件名为请求方法与规范化地址的哈希
+func fixturePath(dir string, req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + fixtureKey(req.URL)))
+	host := strings.NewReplacer(":", "_", "/", "_").Replace(req.URL.Host)
+	return filepath.Join(dir, host, hex.EncodeToString(sum[:8])+".json")
+}
+
+// 用于匹配的地址：去掉凭据与随时间变化的查询参数，参数按名称排序，多余的 / 合并
+func fixtureKey(u *url.URL) string {
+	query := u.Query()
+	for _, name := range append(secretParams, volatileParams...) {
+		query.Del(name)
+	}
+
+	path := u.Path
+	for strings.Contains(path, "//") {
+		path = strings.ReplaceAll(path, "//", "/")
+	}
+
+	key := u.Scheme + "://" + u.Host + path
+	if len(query) > 0 {
+		key += "?" + query.Encode()
+	}
+	return key
+}
+
+// 请求中携带的非空凭据：basic 认证密码、GitLab token 以及查询参数中的 token
+func requestSecrets(req *http.Request) []string {
+	var secrets []string
+	add := func(secret string) {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	if _, password, ok := req.BasicAuth(); ok {
+		add(password)
+	}
+	add(req.Header.Get("PRIVATE-TOKEN"))
+	if req.URL.User != nil {
+		if password, ok := req.URL.User.Password(); ok {
+			add(password)
+		}
+	}
+	query := req.URL.Query()
+	for _, name := range secretParams {
+		add(query.Get(name))
+	}
+
+	// 先替换较长的凭据，避免短凭据是长凭据子串时替换不完整
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	return secrets
+}
+
+func scrubURL(u *url.URL, secrets []string) string {
+	clean := *u
+	clean.User = nil
+	query := clean.Query()
+	for _, name := range secretParams {
+		if query.Has(name) {
+			query.Set(name, redacted)
+		}
+	}
+	clean.RawQuery = query.Encode()
+	return scrub(clean.String(), secrets)
+}
+
+// 替换全部凭据。凭据很短时可能误伤响应中的其它内容，但不能因此把凭据写入 fixture
+func scrub(text string, secrets []string) string {
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, redacted)
+	}
+	return text
+}
+
+func scrubBytes(body []byte, secrets []string) []byte {
+	for _, secret := range secrets {
+		body = bytes.ReplaceAll(body, []byte(secret), []byte(redacted))
+	}
+	return body
+}
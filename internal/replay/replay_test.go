@@ -0,0 +1,77 @@
+﻿package replay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestRecorderScrubsSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/binary":
+			w.Write([]byte("\xff\xfe secret=pw \xff"))
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write([]byte("\x1f\x8b pw"))
+		default:
+			io.WriteString(w, `{"user":"admin","password":"pw"}`)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		password string
+		recorded bool
+		want     string
+	}{
+		{"short secret", "/text", "pw", true, `{"user":"admin","password":"REDACTED"}`},
+		{"empty secret", "/text", "", true, `{"user":"admin","password":"pw"}`},
+		{"binary body", "/binary", "pw", true, "\xff\xfe secret=REDACTED \xff"},
+		{"compressed body", "/gzip", "pw", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			transport, err := NewTransport(ModeRecord, dir, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, _ := http.NewRequest("GET", server.URL+tt.path, nil)
+			req.SetBasicAuth("admin", tt.password)
+			// 禁止自动解压，模拟采集端自行设置 Accept-Encoding 的情况
+			req.Header.Set("Accept-Encoding", "gzip")
+			resp, err := (&http.Client{Transport: transport}).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			// 回放的响应即 fixture 中保存的内容
+			data, err := os.ReadFile(fixturePath(dir, req))
+			if !tt.recorded {
+				if err == nil {
+					t.Fatalf("fixture should not be recorded: %s", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			replayer, _ := NewTransport(ModeReplay, dir, nil)
+			replayed, err := replayer.RoundTrip(req.Clone(req.Context()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(replayed.Body)
+			if string(body) != tt.want {
+				t.Errorf("replayed body = %q, want %q", body, tt.want)
+			}
+		})
+	}
+}
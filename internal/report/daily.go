@@ -0,0 +1,227 @@
+﻿package report
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/notify"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 日报中列出的失败次数最多的 job 数量
+const topFailingJobs = 5
+
+// 日报消息的标签，路由可以按 team 把团队日报发到团队的渠道
+const (
+	LabelReport = "report"
+	LabelTeam   = "team"
+	ReportDaily = "daily"
+)
+
+// 发送报表通知，由 notify.Notifier 实现
+type Sender interface {
+	Notify(ctx context.Context, msg notify.Message) error
+}
+
+// 每天在 notify.dailyReport 时刻汇总前一天的 Jenkins 构建，发送一条全局日报，
+// 配置了团队时再为每个有构建的团队各发送一条（未分配团队的构建只计入全局日报）。只在两次检查之间跨过发送时刻时发送，
+// 重启不会重复发送，但停机期间错过的日报也不会补发
+type Daily struct {
+	store  *store.Store
+	sender Sender
+	config *config.Notify
+
+	mu        sync.Mutex
+	checkedAt time.Time
+}
+
+func NewDaily(s *store.Store, sender Sender, cfg *config.Notify) *Daily {
+	return &Daily{store: s, sender: sender, config: cfg}
+}
+
+// 按 interval 定时检查是否到了发送时刻，直到 ctx 结束。interval 决定发送时间的精度
+func (d *Daily) Run(ctx context.Context, interval time.Duration) {
+	if d.config.DailyReport == "" {
+		return
+	}
+	d.Check(ctx, time.Now())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.Check(ctx, now)
+		}
+	}
+}
+
+// 上次检查到 now 之间跨过了发送时刻时，发送前一天的日报
+func (d *Daily) Check(ctx context.Context, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	previous := d.checkedAt
+	d.checkedAt = now
+	if d.config.DailyReport == "" || previous.IsZero() {
+		return
+	}
+
+	workingHours, err := config.GetWorkingHours()
+	if err != nil {
+		log.Errorf("工作时间配置格式错误，跳过构建日报: %v", err)
+		return
+	}
+	clock, err := time.Parse("15:04", d.config.DailyReport)
+	if err != nil {
+		log.Errorf("构建日报发送时间格式错误: %v", err)
+		return
+	}
+
+	local := now.In(workingHours.Location())
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	due := today.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute)
+	if !previous.Before(due) || now.Before(due) {
+		return
+	}
+
+	teams, err := config.GetTeams()
+	if err != nil {
+		log.Errorf("团队配置格式错误，构建日报不区分团队: %v", err)
+		teams = nil
+	}
+
+	for _, msg := range Messages(d.store.JenkinsBuilds(), teams, workingHours, today.AddDate(0, 0, -1), now) {
+		if err := d.sender.Notify(ctx, msg); err != nil {
+			log.Errorf("发送构建日报[%s]失败: %v", msg.Title, err)
+		}
+	}
+}
+
+// 一天内的构建汇总
+type summary struct {
+	total    int
+	success  int
+	failure  int
+	offHours int
+	// 按实例与 job 统计的失败次数
+	failures map[[2]string]int
+}
+
+func (s *summary) add(build store.JenkinsBuild, workingHours *config.WorkingHours) {
+	s.total++
+	switch build.Result {
+	case "SUCCESS":
+		s.success++
+	case "FAILURE":
+		s.failure++
+		if s.failures == nil {
+			s.failures = make(map[[2]string]int)
+		}
+		s.failures[[2]string{build.JenkinsInstanceName, build.JobName}]++
+	}
+	if workingHours.OffHoursReason(time.UnixMilli(build.Timestamp)) != "" {
+		s.offHours++
+	}
+}
+
+func (s *summary) text() string {
+	var text strings.Builder
+	rate := 0.0
+	if s.total > 0 {
+		rate = float64(s.success) / float64(s.total) * 100
+	}
+	fmt.Fprintf(&text, "构建 %d 次，成功 %d 次，失败 %d 次，成功率 %.1f%%\n", s.total, s.success, s.failure, rate)
+	fmt.Fprintf(&text, "非工作时间构建 %d 次", s.offHours)
+
+	type failingJob struct {
+		key   [2]string
+		count int
+	}
+	jobs := make([]failingJob, 0, len(s.failures))
+	for key, count := range s.failures {
+		jobs = append(jobs, failingJob{key: key, count: count})
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].count != jobs[j].count {
+			return jobs[i].count > jobs[j].count
+		}
+		if jobs[i].key[1] != jobs[j].key[1] {
+			return jobs[i].key[1] < jobs[j].key[1]
+		}
+		return jobs[i].key[0] < jobs[j].key[0]
+	})
+	if len(jobs) > topFailingJobs {
+		jobs = jobs[:topFailingJobs]
+	}
+	if len(jobs) > 0 {
+		text.WriteString("\n\n失败最多的 job:")
+		for _, job := range jobs {
+			fmt.Fprintf(&text, "\n- %s（%s）失败 %d 次", job.key[1], job.key[0], job.count)
+		}
+	}
+	return text.String()
+}
+
+// 汇总 day 当天（按 workingHours.timezone）已结束的构建，生成全局日报与各团队的日报
+func Messages(builds []store.JenkinsBuild, teams map[string]config.Team, workingHours *config.WorkingHours, day, at time.Time) []notify.Message {
+	location := workingHours.Location()
+	local := day.In(location)
+	from := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+	fromMillis, toMillis := from.UnixMilli(), from.AddDate(0, 0, 1).UnixMilli()
+
+	var total summary
+	byTeam := make(map[string]*summary)
+	for _, build := range builds {
+		if build.Building || build.Timestamp < fromMillis || build.Timestamp >= toMillis {
+			continue
+		}
+		total.add(build, workingHours)
+		if len(teams) == 0 {
+			continue
+		}
+		team := config.TeamOfJob(teams, build.JobName)
+		if team == config.UnassignedTeam {
+			continue
+		}
+		if byTeam[team] == nil {
+			byTeam[team] = &summary{}
+		}
+		byTeam[team].add(build, workingHours)
+	}
+
+	title := "构建日报 " + from.Format("2006-01-02")
+	messages := []notify.Message{{
+		Kind:   config.MessageReport,
+		Title:  title,
+		Text:   total.text(),
+		Labels: map[string]string{LabelReport: ReportDaily},
+		At:     at,
+	}}
+
+	names := make([]string, 0, len(byTeam))
+	for name := range byTeam {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		messages = append(messages, notify.Message{
+			Kind:   config.MessageReport,
+			Title:  title + " " + name,
+			Text:   byTeam[name].text(),
+			Labels: map[string]string{LabelReport: ReportDaily, LabelTeam: name},
+			At:     at,
+		})
+	}
+	return messages
+}
@@ -0,0 +1,180 @@
+﻿package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/dimension"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 默认按实例+job 统计，与原有指标保持一致
+const defaultGroupBy = config.DimensionInstance + "," + config.DimensionJob
+
+// 一组统计维度的取值，未参与分组的维度为空。嵌入到各统计结果中输出
+type BuildDimensions struct {
+	JenkinsInstanceName string              `json:"jenkinsInstanceName,omitempty"`
+	JobName             string              `json:"jobName,omitempty"`
+	TriggerType         string              `json:"triggerType,omitempty"`
+	TriggeredBy         string              `json:"triggeredBy,omitempty"`
+	Branch              string              `json:"branch,omitempty"`
+	BranchClass         string              `json:"branchClass,omitempty"`
+	Team                string              `json:"team,omitempty"`
+	Parameters          dimensionParameters `json:"parameters,omitempty"`
+}
+
+// 构建参数维度的取值，按 groupBy 中的顺序把参数名与取值交替拼接成一个字符串，
+// BuildDimensions 因此可以直接作为 map 的 key
+type dimensionParameters string
+
+// 构建参数中不会出现的分隔符
+const parameterSeparator = "\x00"
+
+// 按 name、value 交替排列的参数名与取值
+func (d dimensionParameters) pairs() []string {
+	if d == "" {
+		return nil
+	}
+	return strings.Split(string(d), parameterSeparator)
+}
+
+// 输出为 {"env":"prod","app":"api"}，保持 groupBy 中的顺序
+func (d dimensionParameters) MarshalJSON() ([]byte, error) {
+	pairs := d.pairs()
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		nameBytes, _ := json.Marshal(pairs[i])
+		valueBytes, _ := json.Marshal(pairs[i+1])
+		buf.Write(nameBytes)
+		buf.WriteByte(':')
+		buf.Write(valueBytes)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Jenkins 构建统计的维度，通过 groupBy 查询参数组合，如 groupBy=job,trigger
+type groupBy struct {
+	// 内置维度与参数维度的名称，按 groupBy 中的顺序排列
+	dimensions []string
+	resolver   dimension.Resolver
+}
+
+// 解析 groupBy 查询参数，未传时使用 defaultValue。
+// 除内置维度外，还可以使用 parameterDimensions 配置中声明的构建参数维度，如 groupBy=env,app
+func parseGroupBy(c *gin.Context, defaultValue string) (groupBy, error) {
+	value := c.DefaultQuery("groupBy", defaultValue)
+
+	var g groupBy
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case config.DimensionInstance, config.DimensionJob, config.DimensionTrigger, config.DimensionUser, config.DimensionBranch:
+		case config.DimensionBranchClass:
+			classes, err := config.GetBranchClasses()
+			if err != nil {
+				return g, errors.New("invalid branchClasses config")
+			}
+			g.resolver.BranchClasses = classes
+		case config.DimensionTeam:
+			teams, err := config.GetTeams()
+			if err != nil {
+				return g, errors.New("invalid teams config")
+			}
+			g.resolver.Teams = teams
+		case "":
+			continue
+		default:
+			parameter, err := parameterDimension(name)
+			if err != nil {
+				return g, err
+			}
+			g.resolver.Parameters = append(g.resolver.Parameters, *parameter)
+		}
+		g.dimensions = append(g.dimensions, name)
+	}
+	return g, nil
+}
+
+// 查找配置中声明的构建参数维度
+func parameterDimension(name string) (*config.ParameterDimension, error) {
+	dimensions, err := config.GetParameterDimensions()
+	if err != nil {
+		return nil, errors.New("invalid parameterDimensions config")
+	}
+	for i := range dimensions {
+		if dimensions[i].Name == name {
+			return &dimensions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("invalid groupBy dimension %q", name)
+}
+
+// 构建在所选维度上的取值
+func (g groupBy) dimensionsOf(build store.JenkinsBuild) BuildDimensions {
+	var dimensions BuildDimensions
+	var pairs []string
+	for _, name := range g.dimensions {
+		value := g.resolver.Value(name, build)
+		switch name {
+		case config.DimensionInstance:
+			dimensions.JenkinsInstanceName = value
+		case config.DimensionJob:
+			dimensions.JobName = value
+		case config.DimensionTrigger:
+			dimensions.TriggerType = value
+		case config.DimensionUser:
+			dimensions.TriggeredBy = value
+		case config.DimensionBranch:
+			dimensions.Branch = value
+		case config.DimensionBranchClass:
+			dimensions.BranchClass = value
+		case config.DimensionTeam:
+			dimensions.Team = value
+		default:
+			pairs = append(pairs, name, value)
+		}
+	}
+	dimensions.Parameters = dimensionParameters(strings.Join(pairs, parameterSeparator))
+	return dimensions
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return dimension.Unknown
+	}
+	return value
+}
+
+// 按字段定义的顺序逐个比较，用于稳定排序
+func (d BuildDimensions) less(other BuildDimensions) bool {
+	left := append([]string{d.JenkinsInstanceName, d.JobName, d.TriggerType, d.TriggeredBy, d.Branch, d.BranchClass, d.Team}, d.Parameters.values()...)
+	right := append([]string{other.JenkinsInstanceName, other.JobName, other.TriggerType, other.TriggeredBy, other.Branch, other.BranchClass, other.Team}, other.Parameters.values()...)
+	for i := range left {
+		if i >= len(right) {
+			return false
+		}
+		if left[i] != right[i] {
+			return left[i] < right[i]
+		}
+	}
+	return len(left) < len(right)
+}
+
+func (d dimensionParameters) values() []string {
+	pairs := d.pairs()
+	values := make([]string, 0, len(pairs)/2)
+	for i := 1; i < len(pairs); i += 2 {
+		values = append(values, pairs[i])
+	}
+	return values
+}
@@ -0,0 +1,50 @@
+﻿package router
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/dimension"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+func TestDimensionsOfParameters(t *testing.T) {
+	g := groupBy{
+		dimensions: []string{config.DimensionJob, "env", "app"},
+		resolver: dimension.Resolver{Parameters: []config.ParameterDimension{
+			{Name: "app", Parameters: []string{"APP_NAME"}},
+			{Name: "env", Parameters: []string{"ENV"}},
+		}},
+	}
+	build := func(parameters map[string]string) store.JenkinsBuild {
+		return store.JenkinsBuild{JobName: "deploy", Parameters: parameters}
+	}
+
+	counts := make(map[BuildDimensions]int)
+	counts[g.dimensionsOf(build(map[string]string{"ENV": "prod", "APP_NAME": "api"}))]++
+	counts[g.dimensionsOf(build(map[string]string{"APP_NAME": "api", "ENV": "prod"}))]++
+	counts[g.dimensionsOf(build(map[string]string{"APP_NAME": "web"}))]++
+	if len(counts) != 2 {
+		t.Fatalf("expected 2 groups, got %v", counts)
+	}
+
+	tests := []struct {
+		parameters map[string]string
+		want       string
+	}{
+		{map[string]string{"ENV": "prod", "APP_NAME": "api"}, `{"jobName":"deploy","parameters":{"env":"prod","app":"api"}}`},
+		{map[string]string{"APP_NAME": "web"}, `{"jobName":"deploy","parameters":{"env":"unknown","app":"web"}}`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(g.dimensionsOf(build(tt.parameters)))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("json.Marshal() = %s, %v, want %s", got, err, tt.want)
+		}
+	}
+
+	got, _ := json.Marshal(groupBy{dimensions: []string{config.DimensionJob}}.dimensionsOf(build(nil)))
+	if want := `{"jobName":"deploy"}`; string(got) != want {
+		t.Errorf("json.Marshal() without parameter dimensions = %s, want %s", got, want)
+	}
+}
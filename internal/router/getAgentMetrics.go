@@ -0,0 +1,234 @@
+﻿package router
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+	"github.com/zuoyangs/go-devops-observability/utils"
+)
+
+// 默认统计最近 24 小时，按小时聚合
+const (
+	defaultAgentHours  = 24
+	defaultAgentBucket = time.Hour
+)
+
+// 单个标签在一段时间内的执行器使用率
+type LabelUtilizationStats struct {
+	Provider            string  `json:"provider"`
+	JenkinsInstanceName string  `json:"jenkinsInstanceName"`
+	Label               string  `json:"label"`
+	Utilization         float64 `json:"utilization"`
+	PeakBusyExecutors   int     `json:"peakBusyExecutors"`
+	MaxTotalExecutors   int     `json:"maxTotalExecutors"`
+
+	Buckets []UtilizationBucket `json:"buckets"`
+}
+
+type UtilizationBucket struct {
+	Start             time.Time `json:"start"`
+	Samples           int       `json:"samples"`
+	Utilization       float64   `json:"utilization"`
+	AvgBusyExecutors  float64   `json:"avgBusyExecutors"`
+	AvgTotalExecutors float64   `json:"avgTotalExecutors"`
+	PeakBusyExecutors int       `json:"peakBusyExecutors"`
+	OfflineNodes      int       `json:"offlineNodes"`
+}
+
+// 单个节点在统计窗口内的离线情况
+type NodeOfflineStats struct {
+	Provider            string     `json:"provider"`
+	JenkinsInstanceName string     `json:"jenkinsInstanceName"`
+	Name                string     `json:"name"`
+	OutageCount         int        `json:"outageCount"`
+	OfflineSeconds      float64    `json:"offlineSeconds"`
+	OfflineRate         float64    `json:"offlineRate"`
+	CurrentlyOffline    bool       `json:"currentlyOffline"`
+	OfflineSince        *time.Time `json:"offlineSince,omitempty"`
+	LastReason          string     `json:"lastReason,omitempty"`
+}
+
+func getAgentMetricsHandler(c *gin.Context) {
+
+	hours := defaultAgentHours
+	if value := c.Query("hours"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid hours"})
+			return
+		}
+		hours = parsed
+	}
+
+	bucket := defaultAgentBucket
+	if value := c.Query("bucket"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < time.Minute {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bucket"})
+			return
+		}
+		bucket = parsed
+	}
+
+	now := time.Now()
+	since := now.Add(-time.Duration(hours) * time.Hour)
+
+	nodes := dataStore.JenkinsNodes()
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].JenkinsInstanceName != nodes[j].JenkinsInstanceName {
+			return nodes[i].JenkinsInstanceName < nodes[j].JenkinsInstanceName
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+
+	c.JSON(http.StatusOK, gin.H{"构建节点指标": gin.H{
+		"nodes":       nodes,
+		"utilization": calculateLabelUtilization(dataStore.JenkinsUtilization(since), bucket),
+		"offline":     calculateNodeOffline(dataStore.JenkinsNodeOutages(since), since, now),
+	}})
+}
+
+// 按实例+标签聚合采样，使用率为忙碌执行器数之和除以执行器总数之和
+func calculateLabelUtilization(samples []store.JenkinsUtilizationSample, bucket time.Duration) []*LabelUtilizationStats {
+
+	type accumulator struct {
+		samples, busy, total, peak, offline int
+	}
+	type labelKey struct {
+		instance, label string
+	}
+
+	resultMap := make(map[labelKey]*LabelUtilizationStats)
+	buckets := make(map[labelKey]map[time.Time]*accumulator)
+	totals := make(map[labelKey]*accumulator)
+
+	for _, sample := range samples {
+		key := labelKey{sample.JenkinsInstanceName, sample.Label}
+		if _, ok := resultMap[key]; !ok {
+			label := sample.Label
+			if label == "" {
+				label = anyLabel
+			}
+			resultMap[key] = &LabelUtilizationStats{
+				Provider:            ProviderJenkins,
+				JenkinsInstanceName: sample.JenkinsInstanceName,
+				Label:               label,
+			}
+			buckets[key] = make(map[time.Time]*accumulator)
+			totals[key] = &accumulator{}
+		}
+
+		start := sample.SampledAt.Truncate(bucket)
+		acc, ok := buckets[key][start]
+		if !ok {
+			acc = &accumulator{}
+			buckets[key][start] = acc
+		}
+		for _, a := range []*accumulator{acc, totals[key]} {
+			a.samples++
+			a.busy += sample.BusyExecutors
+			a.total += sample.TotalExecutors
+			if sample.BusyExecutors > a.peak {
+				a.peak = sample.BusyExecutors
+			}
+			if sample.OfflineNodes > a.offline {
+				a.offline = sample.OfflineNodes
+			}
+		}
+
+		stats := resultMap[key]
+		if sample.TotalExecutors > stats.MaxTotalExecutors {
+			stats.MaxTotalExecutors = sample.TotalExecutors
+		}
+	}
+
+	var result []*LabelUtilizationStats
+	for key, stats := range resultMap {
+		stats.Utilization = percentage(totals[key].busy, totals[key].total)
+		stats.PeakBusyExecutors = totals[key].peak
+
+		for start, acc := range buckets[key] {
+			stats.Buckets = append(stats.Buckets, UtilizationBucket{
+				Start:             start,
+				Samples:           acc.samples,
+				Utilization:       percentage(acc.busy, acc.total),
+				AvgBusyExecutors:  utils.Round2(float64(acc.busy) / float64(acc.samples)),
+				AvgTotalExecutors: utils.Round2(float64(acc.total) / float64(acc.samples)),
+				PeakBusyExecutors: acc.peak,
+				OfflineNodes:      acc.offline,
+			})
+		}
+		sort.Slice(stats.Buckets, func(i, j int) bool {
+			return stats.Buckets[i].Start.Before(stats.Buckets[j].Start)
+		})
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].JenkinsInstanceName != result[j].JenkinsInstanceName {
+			return result[i].JenkinsInstanceName < result[j].JenkinsInstanceName
+		}
+		return result[i].Label < result[j].Label
+	})
+	return result
+}
+
+// 统计每个节点在 [since, now] 内的离线次数与离线时长，跨越窗口边界的离线区间只计算窗口内的部分
+func calculateNodeOffline(outages []store.JenkinsNodeOutage, since, now time.Time) []*NodeOfflineStats {
+
+	window := now.Sub(since)
+	resultMap := make(map[string]*NodeOfflineStats)
+
+	sort.Slice(outages, func(i, j int) bool {
+		return outages[i].StartedAt.Before(outages[j].StartedAt)
+	})
+
+	for _, outage := range outages {
+		key := outage.JenkinsInstanceName + "/" + outage.Name
+		stats, ok := resultMap[key]
+		if !ok {
+			stats = &NodeOfflineStats{
+				Provider:            ProviderJenkins,
+				JenkinsInstanceName: outage.JenkinsInstanceName,
+				Name:                outage.Name,
+			}
+			resultMap[key] = stats
+		}
+
+		start := outage.StartedAt
+		if start.Before(since) {
+			start = since
+		}
+		end := now
+		if outage.EndedAt != nil {
+			end = *outage.EndedAt
+		} else {
+			stats.CurrentlyOffline = true
+			startedAt := outage.StartedAt
+			stats.OfflineSince = &startedAt
+		}
+
+		stats.OutageCount++
+		stats.OfflineSeconds += end.Sub(start).Seconds()
+		if outage.Reason != "" {
+			stats.LastReason = outage.Reason
+		}
+	}
+
+	var result []*NodeOfflineStats
+	for _, stats := range resultMap {
+		stats.OfflineSeconds = utils.Round2(stats.OfflineSeconds)
+		if window > 0 {
+			stats.OfflineRate = utils.Round2(stats.OfflineSeconds / window.Seconds() * 100)
+		}
+		result = append(result, stats)
+	}
+	// 离线时间最长的排在前面
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].OfflineSeconds > result[j].OfflineSeconds
+	})
+	return result
+}
@@ -0,0 +1,68 @@
+﻿package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/alert"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 告警及其静默、抑制情况，被静默或抑制的告警不发送通知
+type AlertStatus struct {
+	store.Alert
+	alert.MuteStatus
+}
+
+// 列出当前的告警，可以通过 state、severity、rule 查询参数筛选，如 state=firing；
+// muted=false 时只返回没有被静默或抑制的告警
+func getAlertsHandler(c *gin.Context) {
+
+	state := c.Query("state")
+	switch state {
+	case "", store.AlertPending, store.AlertFiring, store.AlertResolved:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid state"})
+		return
+	}
+	severity := c.Query("severity")
+	rule := c.Query("rule")
+	mutedFilter := c.Query("muted")
+	switch mutedFilter {
+	case "", "true", "false":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid muted"})
+		return
+	}
+
+	notifyConfig, err := config.GetNotify()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "通知配置格式错误"})
+		return
+	}
+
+	all := dataStore.Alerts()
+	muted := alert.Mute(all, dataStore.Silences(), notifyConfig.InhibitRules, time.Now())
+
+	alerts := make([]AlertStatus, 0)
+	for _, item := range all {
+		if state != "" && item.State != state {
+			continue
+		}
+		if severity != "" && item.Severity != severity {
+			continue
+		}
+		if rule != "" && item.Rule != rule {
+			continue
+		}
+		status := muted[item.Fingerprint]
+		if mutedFilter != "" && status.Muted() != (mutedFilter == "true") {
+			continue
+		}
+		alerts = append(alerts, AlertStatus{Alert: item, MuteStatus: status})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"告警": alerts})
+}
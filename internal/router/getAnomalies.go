@@ -0,0 +1,73 @@
+﻿package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/anomaly"
+)
+
+// 默认列出最近 1 天的异常
+const defaultAnomalyDays = 1
+
+type BuildAnomaly struct {
+	Provider string `json:"provider"`
+	anomaly.Anomaly
+	Team string `json:"team"`
+}
+
+// 与各 job 自身历史基线相比构建时长或每日失败次数异常的情况，最近的在前。
+// 可以通过 kind（duration、failureCount）、instance、team 筛选
+func getAnomaliesHandler(c *gin.Context) {
+
+	days, err := positiveQuery(c, "days", defaultAnomalyDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	kind := c.Query("kind")
+	switch kind {
+	case "", anomaly.KindDuration, anomaly.KindFailureCount:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid kind"})
+		return
+	}
+	instance := c.Query("instance")
+	team := c.Query("team")
+
+	detection, err := config.GetAnomalyDetection()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "异常检测配置格式错误"})
+		return
+	}
+	workingHours, err := config.GetWorkingHours()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "工作时间配置格式错误"})
+		return
+	}
+	teams, err := config.GetTeams()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "团队配置格式错误"})
+		return
+	}
+
+	now := time.Now()
+	anomalies := make([]BuildAnomaly, 0)
+	for _, item := range anomaly.Detect(dataStore.JenkinsBuilds(), detection, now.AddDate(0, 0, -days), now, workingHours.Location()) {
+		if kind != "" && item.Kind != kind {
+			continue
+		}
+		if instance != "" && item.JenkinsInstanceName != instance {
+			continue
+		}
+		itemTeam := config.TeamOfJob(teams, item.JobName)
+		if team != "" && itemTeam != team {
+			continue
+		}
+		anomalies = append(anomalies, BuildAnomaly{Provider: ProviderJenkins, Anomaly: item, Team: itemTeam})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"构建异常": anomalies})
+}
@@ -0,0 +1,106 @@
+﻿package router
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 默认统计最近 30 天，按实例+job+分支类别统计
+const (
+	defaultBranchDays    = 30
+	defaultBranchGroupBy = config.DimensionInstance + "," + config.DimensionJob + "," + config.DimensionBranchClass
+)
+
+// 按分支统计的发布稳定性，release 分支的失败比 feature 分支的失败更值得关注
+type BranchStabilityStats struct {
+	Provider string `json:"provider"`
+	BuildDimensions
+
+	TotalCount    int     `json:"totalCount"`
+	SuccessCount  int     `json:"successCount"`
+	FailureCount  int     `json:"failureCount"`
+	UnstableCount int     `json:"unstableCount"`
+	AbortedCount  int     `json:"abortedCount"`
+	SuccessRate   float64 `json:"successRate"`
+	FailureRate   float64 `json:"failureRate"`
+
+	// 最近一次构建的结果，以及截至最近一次构建的连续失败次数（计算方式见 store.FailureStreak）
+	LastResult          string `json:"lastResult"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+}
+
+func getBranchMetricsHandler(c *gin.Context) {
+
+	dimensions, err := parseGroupBy(c, defaultBranchGroupBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	days, err := positiveQuery(c, "days", defaultBranchDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sinceMillis := time.Now().AddDate(0, 0, -days).UnixMilli()
+
+	c.JSON(http.StatusOK, gin.H{"分支发布稳定性指标": calculateBranchStability(dataStore.JenkinsBuilds(), dimensions, sinceMillis)})
+}
+
+func calculateBranchStability(builds []store.JenkinsBuild, dimensions groupBy, sinceMillis int64) []*BranchStabilityStats {
+
+	// 按时间先后处理，最近一次构建的结果为 LastResult
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].Timestamp < builds[j].Timestamp
+	})
+
+	resultMap := make(map[BuildDimensions]*BranchStabilityStats)
+	groupBuilds := make(map[BuildDimensions][]store.JenkinsBuild)
+	for _, build := range builds {
+		if build.Building || build.Timestamp < sinceMillis {
+			continue
+		}
+
+		key := dimensions.dimensionsOf(build)
+		stats, ok := resultMap[key]
+		if !ok {
+			stats = &BranchStabilityStats{
+				Provider:        ProviderJenkins,
+				BuildDimensions: key,
+			}
+			resultMap[key] = stats
+		}
+
+		groupBuilds[key] = append(groupBuilds[key], build)
+		stats.TotalCount++
+		stats.LastResult = build.Result
+		switch build.Result {
+		case "SUCCESS":
+			stats.SuccessCount++
+		case "FAILURE":
+			stats.FailureCount++
+		case "UNSTABLE":
+			stats.UnstableCount++
+		case "ABORTED":
+			stats.AbortedCount++
+		}
+	}
+
+	result := make([]*BranchStabilityStats, 0, len(resultMap))
+	for key, stats := range resultMap {
+		stats.ConsecutiveFailures = len(store.FailureStreak(groupBuilds[key]))
+		stats.SuccessRate = percentage(stats.SuccessCount, stats.TotalCount)
+		stats.FailureRate = percentage(stats.FailureCount, stats.TotalCount)
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BuildDimensions.less(result[j].BuildDimensions)
+	})
+	return result
+}
@@ -0,0 +1,130 @@
+﻿package router
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 默认统计最近 30 天的失败构建，每组最多列出 20 个未分类的失败构建供人工分拣
+const (
+	defaultFailureDays         = 30
+	defaultUnclassifiedSamples = 20
+)
+
+// 失败原因分布
+type FailureReasonStats struct {
+	Provider string `json:"provider"`
+	BuildDimensions
+
+	FailureCount int                       `json:"failureCount"`
+	Categories   []FailureCategoryCount    `json:"categories"`
+	Unclassified []UnclassifiedFailedBuild `json:"unclassified"`
+}
+
+type FailureCategoryCount struct {
+	Category string  `json:"category"`
+	Count    int     `json:"count"`
+	Rate     float64 `json:"rate"`
+}
+
+type UnclassifiedFailedBuild struct {
+	JenkinsInstanceName string `json:"jenkinsInstanceName"`
+	JobName             string `json:"jobName"`
+	Number              int    `json:"number"`
+	URL                 string `json:"url"`
+	Result              string `json:"result"`
+	Timestamp           int64  `json:"timestamp"`
+	LastLine            string `json:"lastLine"`
+}
+
+// 失败原因分布，支持 groupBy 参数，例如 groupBy=team 按团队统计
+func getFailureMetricsHandler(c *gin.Context) {
+
+	dimensions, err := parseGroupBy(c, defaultGroupBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	days, err := positiveQuery(c, "days", defaultFailureDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sinceMillis := time.Now().AddDate(0, 0, -days).UnixMilli()
+
+	c.JSON(http.StatusOK, gin.H{"构建失败原因分布": calculateFailureReasons(dataStore.JenkinsBuilds(), dimensions, sinceMillis)})
+}
+
+func calculateFailureReasons(builds []store.JenkinsBuild, dimensions groupBy, sinceMillis int64) []*FailureReasonStats {
+
+	// 最近的未分类失败排在前面
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].Timestamp > builds[j].Timestamp
+	})
+
+	resultMap := make(map[BuildDimensions]*FailureReasonStats)
+	counts := make(map[BuildDimensions]map[string]int)
+
+	for _, build := range builds {
+		// 还没有获取到控制台日志的构建不参与统计
+		if build.Building || build.FailureCategory == "" || build.Timestamp < sinceMillis {
+			continue
+		}
+
+		key := dimensions.dimensionsOf(build)
+		stats, ok := resultMap[key]
+		if !ok {
+			stats = &FailureReasonStats{
+				Provider:        ProviderJenkins,
+				BuildDimensions: key,
+			}
+			resultMap[key] = stats
+			counts[key] = make(map[string]int)
+		}
+
+		stats.FailureCount++
+		counts[key][build.FailureCategory]++
+
+		if build.FailureCategory == config.UnclassifiedFailure && len(stats.Unclassified) < defaultUnclassifiedSamples {
+			stats.Unclassified = append(stats.Unclassified, UnclassifiedFailedBuild{
+				JenkinsInstanceName: build.JenkinsInstanceName,
+				JobName:             build.JobName,
+				Number:              build.Number,
+				URL:                 build.URL,
+				Result:              build.Result,
+				Timestamp:           build.Timestamp,
+				LastLine:            build.FailureLine,
+			})
+		}
+	}
+
+	result := make([]*FailureReasonStats, 0, len(resultMap))
+	for key, stats := range resultMap {
+		for category, count := range counts[key] {
+			stats.Categories = append(stats.Categories, FailureCategoryCount{
+				Category: category,
+				Count:    count,
+				Rate:     percentage(count, stats.FailureCount),
+			})
+		}
+		// 占比最高的类别排在前面
+		sort.Slice(stats.Categories, func(i, j int) bool {
+			if stats.Categories[i].Count != stats.Categories[j].Count {
+				return stats.Categories[i].Count > stats.Categories[j].Count
+			}
+			return stats.Categories[i].Category < stats.Categories[j].Category
+		})
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BuildDimensions.less(result[j].BuildDimensions)
+	})
+	return result
+}
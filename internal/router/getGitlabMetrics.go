@@ -0,0 +1,106 @@
+﻿package router
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// GitLab 流水线发布稳定性指标，统计口径与 JenkinsJobStatsExtended 一致
+type GitlabPipelineStatsExtended struct {
+	Provider           string `json:"provider"`
+	GitlabInstanceName string `json:"gitlabInstanceName"`
+	ProjectID          int    `json:"projectId"`
+	ProjectPath        string `json:"projectPath"`
+	Ref                string `json:"ref"`
+	Source             string `json:"source"`
+
+	// 当天的统计信息
+	TodaySuccessCount int     `json:"todaySuccessCount"`
+	TodayFailureCount int     `json:"todayFailureCount"`
+	TodayTotalCount   int     `json:"todayTotalCount"`
+	TodaySuccessRate  float64 `json:"todaySuccessRate"`
+	TodayFailureRate  float64 `json:"todayFailureRate"`
+
+	// 当前月份的统计信息
+	CurrentMonthSuccessCount int     `json:"currentMonthSuccessCount"`
+	CurrentMonthFailureCount int     `json:"currentMonthFailureCount"`
+	CurrentMonthTotalCount   int     `json:"currentMonthTotalCount"`
+	CurrentMonthSuccessRate  float64 `json:"currentMonthSuccessRate"`
+	CurrentMonthFailureRate  float64 `json:"currentMonthFailureRate"`
+}
+
+// 流水线数据由 GitLab webhook 实时写入，并由后台采集定时对账
+func getGitlabMetricsHandler(c *gin.Context) {
+
+	stats := calculateGitlabPipelineStats(dataStore.GitlabPipelines())
+
+	c.JSON(http.StatusOK, gin.H{"GitLab流水线发布稳定性指标": stats})
+}
+
+// 按 实例/项目/ref/触发来源 统计当天及当月的流水线成功率、失败率
+func calculateGitlabPipelineStats(pipelines []store.GitlabPipeline) []*GitlabPipelineStatsExtended {
+
+	millis, todayMillis, firstDayOfMonthMillis := statsWindow()
+
+	resultMap := make(map[string]*GitlabPipelineStatsExtended)
+	var keys []string
+
+	for _, pipeline := range pipelines {
+
+		createdMillis := pipeline.CreatedAt.UnixMilli()
+		if createdMillis < firstDayOfMonthMillis || createdMillis > millis {
+			continue
+		}
+
+		var success, failure int
+		switch pipeline.Status {
+		case "success":
+			success = 1
+		case "failed":
+			failure = 1
+		default:
+			// running、canceled、skipped 等状态不计入成功率，只有这类流水线的 ref 不单独成行
+			continue
+		}
+
+		key := pipeline.GitlabInstanceName + "/" + pipeline.ProjectPath + "/" + pipeline.Ref + "/" + pipeline.Source
+		stats, ok := resultMap[key]
+		if !ok {
+			stats = &GitlabPipelineStatsExtended{
+				Provider:           ProviderGitlab,
+				GitlabInstanceName: pipeline.GitlabInstanceName,
+				ProjectID:          pipeline.ProjectID,
+				ProjectPath:        pipeline.ProjectPath,
+				Ref:                pipeline.Ref,
+				Source:             pipeline.Source,
+			}
+			resultMap[key] = stats
+			keys = append(keys, key)
+		}
+
+		if createdMillis >= todayMillis {
+			stats.TodaySuccessCount += success
+			stats.TodayFailureCount += failure
+			stats.TodayTotalCount = stats.TodaySuccessCount + stats.TodayFailureCount
+			stats.TodaySuccessRate = percentage(stats.TodaySuccessCount, stats.TodayTotalCount)
+			stats.TodayFailureRate = percentage(stats.TodayFailureCount, stats.TodayTotalCount)
+		}
+
+		stats.CurrentMonthSuccessCount += success
+		stats.CurrentMonthFailureCount += failure
+		stats.CurrentMonthTotalCount = stats.CurrentMonthSuccessCount + stats.CurrentMonthFailureCount
+		stats.CurrentMonthSuccessRate = percentage(stats.CurrentMonthSuccessCount, stats.CurrentMonthTotalCount)
+		stats.CurrentMonthFailureRate = percentage(stats.CurrentMonthFailureCount, stats.CurrentMonthTotalCount)
+	}
+
+	sort.Strings(keys)
+	stats := make([]*GitlabPipelineStatsExtended, 0, len(keys))
+	for _, key := range keys {
+		stats = append(stats, resultMap[key])
+	}
+
+	return stats
+}
@@ -0,0 +1,87 @@
+﻿package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+func TestCalculateGitlabPipelineStats(t *testing.T) {
+	now := time.Now()
+	_, todayMillis, monthMillis := statsWindow()
+	month := time.UnixMilli(monthMillis)
+	// 每月第一天，当月第一刻也在当天的统计窗口内
+	monthIsToday := monthMillis >= todayMillis
+
+	pipeline := func(ref, source, status string, created time.Time) store.GitlabPipeline {
+		return store.GitlabPipeline{GitlabInstanceName: "gitlab", ProjectID: 1, ProjectPath: "pay/api", Ref: ref, Source: source, Status: status, CreatedAt: created}
+	}
+	type counts struct {
+		ref, source                                            string
+		todaySuccess, todayFailure, monthSuccess, monthFailure int
+		todayRate, monthRate                                   float64
+	}
+	tests := []struct {
+		name      string
+		pipelines []store.GitlabPipeline
+		want      []counts
+	}{
+		{"zero pipelines", nil, []counts{}},
+		{"success rate", []store.GitlabPipeline{
+			pipeline("main", "push", "success", now),
+			pipeline("main", "push", "success", now),
+			pipeline("main", "push", "failed", now),
+		}, []counts{{"main", "push", 2, 1, 2, 1, 66.67, 66.67}}},
+		{"unfinished pipelines are not counted", []store.GitlabPipeline{
+			pipeline("main", "push", "success", now),
+			pipeline("main", "push", "running", now),
+			pipeline("main", "push", "canceled", now),
+			pipeline("dev", "push", "running", now),
+		}, []counts{{"main", "push", 1, 0, 1, 0, 100, 100}}},
+		{"grouped by ref and source", []store.GitlabPipeline{
+			pipeline("main", "schedule", "failed", now),
+			pipeline("main", "push", "success", now),
+			pipeline("dev", "push", "failed", now),
+		}, []counts{{"dev", "push", 0, 1, 0, 1, 0, 0}, {"main", "push", 1, 0, 1, 0, 100, 100}, {"main", "schedule", 0, 1, 0, 1, 0, 0}}},
+		{"month and today windows", []store.GitlabPipeline{
+			pipeline("main", "push", "success", now),
+			pipeline("main", "push", "failed", month),
+			pipeline("main", "push", "failed", month.Add(-time.Millisecond)),
+			pipeline("main", "push", "failed", time.Now().Add(time.Hour)),
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == nil {
+				// 上月以及未来的流水线不计入
+				if monthIsToday {
+					want = []counts{{"main", "push", 1, 1, 1, 1, 50, 50}}
+				} else {
+					want = []counts{{"main", "push", 1, 0, 1, 1, 100, 50}}
+				}
+			}
+
+			stats := calculateGitlabPipelineStats(tt.pipelines)
+			if stats == nil || len(stats) != len(want) {
+				t.Fatalf("calculateGitlabPipelineStats() = %+v, want %d rows", stats, len(want))
+			}
+			for i, w := range want {
+				got := stats[i]
+				if got.Provider != ProviderGitlab || got.Ref != w.ref || got.Source != w.source {
+					t.Errorf("row %d = %+v, want ref %s source %s", i, got, w.ref, w.source)
+				}
+				if got.TodaySuccessCount != w.todaySuccess || got.TodayFailureCount != w.todayFailure || got.TodayTotalCount != w.todaySuccess+w.todayFailure || got.TodaySuccessRate != w.todayRate {
+					t.Errorf("row %d today = %d/%d/%d %.2f%%, want %+v", i, got.TodaySuccessCount, got.TodayFailureCount, got.TodayTotalCount, got.TodaySuccessRate, w)
+				}
+				if got.CurrentMonthSuccessCount != w.monthSuccess || got.CurrentMonthFailureCount != w.monthFailure || got.CurrentMonthSuccessRate != w.monthRate {
+					t.Errorf("row %d month = %d/%d %.2f%%, want %+v", i, got.CurrentMonthSuccessCount, got.CurrentMonthFailureCount, got.CurrentMonthSuccessRate, w)
+				}
+				if total := got.CurrentMonthTotalCount; total > 0 && got.CurrentMonthSuccessRate+got.CurrentMonthFailureRate < 99.99 {
+					t.Errorf("row %d month rates do not add up: %+v", i, got)
+				}
+			}
+		})
+	}
+}
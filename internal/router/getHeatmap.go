@@ -0,0 +1,129 @@
+﻿package router
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 默认统计最近 30 天的构建
+const defaultHeatmapDays = 30
+
+// 热力图的行从周一开始，与国内的排班习惯一致
+var heatmapWeekdays = [7]string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+// 按星期 × 小时统计的构建次数与失败率，Builds[0][9] 表示周一 09:00~09:59 开始的构建
+type BuildHeatmap struct {
+	Provider string `json:"provider"`
+	BuildDimensions
+
+	Timezone      string         `json:"timezone"`
+	Weekdays      [7]string      `json:"weekdays"`
+	TotalCount    int            `json:"totalCount"`
+	FailureCount  int            `json:"failureCount"`
+	OffHoursCount int            `json:"offHoursCount"`
+	OffHoursRate  float64        `json:"offHoursRate"`
+	Builds        [7][24]int     `json:"builds"`
+	Failures      [7][24]int     `json:"failures"`
+	FailureRates  [7][24]float64 `json:"failureRates"`
+}
+
+// 构建时间热力图，时间按 workingHours.timezone 换算。
+// 支持 groupBy 参数，例如 groupBy=team 或 groupBy=env 按团队或部署环境参数分别统计；
+// deployOnly=true 时只统计 leadTime.deployJobs 配置的部署 job
+func getHeatmapHandler(c *gin.Context) {
+
+	dimensions, err := parseGroupBy(c, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	days, err := positiveQuery(c, "days", defaultHeatmapDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workingHours, err := config.GetWorkingHours()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "工作时间配置格式错误"})
+		return
+	}
+	leadTimeConfig, err := config.GetLeadTime()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "交付周期配置格式错误"})
+		return
+	}
+	deployOnly := c.Query("deployOnly") == "true"
+
+	sinceMillis := time.Now().AddDate(0, 0, -days).UnixMilli()
+
+	var builds []store.JenkinsBuild
+	for _, build := range dataStore.JenkinsBuilds() {
+		if build.Building || build.Timestamp < sinceMillis {
+			continue
+		}
+		if deployOnly && !leadTimeConfig.IsDeployJob(build.JobName) {
+			continue
+		}
+		builds = append(builds, build)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"构建时间热力图": calculateHeatmaps(builds, dimensions, workingHours)})
+}
+
+func calculateHeatmaps(builds []store.JenkinsBuild, dimensions groupBy, workingHours *config.WorkingHours) []*BuildHeatmap {
+
+	location := workingHours.Location()
+	resultMap := make(map[BuildDimensions]*BuildHeatmap)
+
+	for _, build := range builds {
+		key := dimensions.dimensionsOf(build)
+		heatmap, ok := resultMap[key]
+		if !ok {
+			heatmap = &BuildHeatmap{
+				Provider:        ProviderJenkins,
+				BuildDimensions: key,
+				Timezone:        location.String(),
+				Weekdays:        heatmapWeekdays,
+			}
+			resultMap[key] = heatmap
+		}
+
+		startedAt := time.UnixMilli(build.Timestamp)
+		local := startedAt.In(location)
+		// time.Weekday 从周日开始，换算为从周一开始的下标
+		day := (int(local.Weekday()) + 6) % 7
+		hour := local.Hour()
+
+		heatmap.TotalCount++
+		heatmap.Builds[day][hour]++
+		if build.Result == "FAILURE" {
+			heatmap.FailureCount++
+			heatmap.Failures[day][hour]++
+		}
+		if workingHours.OffHoursReason(startedAt) != "" {
+			heatmap.OffHoursCount++
+		}
+	}
+
+	result := make([]*BuildHeatmap, 0, len(resultMap))
+	for _, heatmap := range resultMap {
+		for day := range heatmap.Builds {
+			for hour, count := range heatmap.Builds[day] {
+				heatmap.FailureRates[day][hour] = percentage(heatmap.Failures[day][hour], count)
+			}
+		}
+		heatmap.OffHoursRate = percentage(heatmap.OffHoursCount, heatmap.TotalCount)
+		result = append(result, heatmap)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BuildDimensions.less(result[j].BuildDimensions)
+	})
+	return result
+}
@@ -0,0 +1,86 @@
+﻿package router
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/internal/collector"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// Jenkins 实例的整体状态
+const (
+	InstanceHealthy       = "healthy"
+	InstanceMisconfigured = "misconfigured"
+	InstanceUnreachable   = "unreachable"
+	InstanceUnauthorized  = "unauthorized"
+	// 实例可达，但最近一次采集失败
+	InstanceDegraded = "degraded"
+	// 服务刚启动，还没有探测结果
+	InstancePending = "pending"
+)
+
+type InstanceHealth struct {
+	Provider string `json:"provider"`
+	Status   string `json:"status"`
+	// 配置格式错误时的原因，此时没有探测与采集结果
+	ConfigError string `json:"configError,omitempty"`
+	store.JenkinsInstanceStatus
+}
+
+// 列出配置中的全部 Jenkins 实例及其健康状态、版本与最近一次采集情况
+func getInstancesHandler(c *gin.Context) {
+
+	jenkinsConfigs, invalid := collector.LoadJenkinsInstances()
+
+	statuses := make(map[string]store.JenkinsInstanceStatus)
+	for _, status := range dataStore.JenkinsInstances() {
+		statuses[status.JenkinsInstanceName] = status
+	}
+
+	var instances []*InstanceHealth
+	for jenkinsInstanceName, err := range invalid {
+		instances = append(instances, &InstanceHealth{
+			Provider:    ProviderJenkins,
+			Status:      InstanceMisconfigured,
+			ConfigError: err.Error(),
+			JenkinsInstanceStatus: store.JenkinsInstanceStatus{
+				JenkinsInstanceName: jenkinsInstanceName,
+			},
+		})
+	}
+	for jenkinsInstanceName, jobsConfig := range jenkinsConfigs {
+		status, ok := statuses[jenkinsInstanceName]
+		if !ok {
+			status = store.JenkinsInstanceStatus{JenkinsInstanceName: jenkinsInstanceName}
+		}
+		// 以当前配置为准，配置修改后探测结果中的地址可能已过期
+		status.JenkinsURL = jobsConfig.JenkinsURL
+		instances = append(instances, &InstanceHealth{
+			Provider:              ProviderJenkins,
+			Status:                instanceStatus(status),
+			JenkinsInstanceStatus: status,
+		})
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].JenkinsInstanceName < instances[j].JenkinsInstanceName
+	})
+
+	c.JSON(http.StatusOK, gin.H{"Jenkins实例": instances})
+}
+
+func instanceStatus(status store.JenkinsInstanceStatus) string {
+	switch {
+	case status.LastProbeAt == nil && status.LastCollectionAt == nil:
+		return InstancePending
+	case status.LastProbeAt != nil && !status.Reachable:
+		return InstanceUnreachable
+	case status.LastProbeAt != nil && !status.AuthValid:
+		return InstanceUnauthorized
+	case status.LastCollectionError != "":
+		return InstanceDegraded
+	}
+	return InstanceHealthy
+}
@@ -0,0 +1,416 @@
+﻿package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/collector"
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+	gitlab_impl "github.com/zuoyangs/go-devops-observability/internal/gitlab_api/impl"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 默认统计最近 30 天的生产部署
+const defaultLeadTimeDays = 30
+
+// 提交查询缓存的最大条目数，超过后清空重新缓存
+const maxCommitCacheEntries = 20000
+
+// 单个提交从编写到上线的各阶段耗时，单位为小时
+type CommitLeadTime struct {
+	SHA        string     `json:"sha"`
+	Title      string     `json:"title"`
+	Author     string     `json:"author"`
+	AuthoredAt time.Time  `json:"authoredAt"`
+	MergedAt   *time.Time `json:"mergedAt"`
+	BuiltAt    time.Time  `json:"builtAt"`
+	DeployedAt time.Time  `json:"deployedAt"`
+
+	// 编写 -> 合并 -> 构建 -> 部署完成
+	AuthoredToMergedHours *float64 `json:"authoredToMergedHours"`
+	MergedToBuiltHours    *float64 `json:"mergedToBuiltHours"`
+	BuiltToDeployedHours  float64  `json:"builtToDeployedHours"`
+	LeadTimeHours         float64  `json:"leadTimeHours"`
+}
+
+// 单次生产部署包含的提交及交付周期
+type DeploymentLeadTime struct {
+	Provider            string    `json:"provider"`
+	JenkinsInstanceName string    `json:"jenkinsInstanceName"`
+	JobName             string    `json:"jobName"`
+	BuildNumber         int       `json:"buildNumber"`
+	BuildURL            string    `json:"buildUrl"`
+	GitlabInstanceName  string    `json:"gitlabInstanceName"`
+	ProjectPath         string    `json:"projectPath"`
+	PreviousSHA         string    `json:"previousSha"`
+	SHA                 string    `json:"sha"`
+	BuiltAt             time.Time `json:"builtAt"`
+	DeployedAt          time.Time `json:"deployedAt"`
+
+	CommitCount      int              `json:"commitCount"`
+	LeadTimeHours    PercentileStats  `json:"leadTimeHours"`
+	MaxLeadTimeHours float64          `json:"maxLeadTimeHours"`
+	Commits          []CommitLeadTime `json:"commits"`
+}
+
+// 计算交付周期所需的上下文
+type leadTimeContext struct {
+	leadTimeConfig *config.LeadTime
+	gitlabBases    map[string]*gitlab_api.GitlabBaseRequest
+	since          time.Time
+	commits        *commitCache
+}
+
+// 两个 revision 之间的提交以及提交所属 MR 的合并时间不会再变化，缓存查询结果，
+// 每次请求只需要为新的部署查询 GitLab
+type commitCache struct {
+	mu       sync.Mutex
+	compares map[string][]gitlab_api.GitlabCommit
+	mergedAt map[string]*time.Time
+}
+
+func newCommitCache() *commitCache {
+	return &commitCache{
+		compares: make(map[string][]gitlab_api.GitlabCommit),
+		mergedAt: make(map[string]*time.Time),
+	}
+}
+
+var commitLookups = newCommitCache()
+
+// 返回 from 到 to 之间的提交
+func (cc *commitCache) compare(c context.Context, gitlabService gitlab_api.GitlabAPIInterface, base *gitlab_api.GitlabBaseRequest, projectPath, from, to string) ([]gitlab_api.GitlabCommit, error) {
+	key := base.GitlabName + "/" + projectPath + "/" + from + "..." + to
+	cc.mu.Lock()
+	commits, ok := cc.compares[key]
+	cc.mu.Unlock()
+	if ok {
+		return commits, nil
+	}
+
+	compare, err := gitlabService.CompareCommits(c, &gitlab_api.GitlabCompareRequest{
+		GitlabBaseRequest: *base,
+		ProjectID:         projectPath,
+		From:              from,
+		To:                to,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cc.mu.Lock()
+	if len(cc.compares) >= maxCommitCacheEntries {
+		cc.compares = make(map[string][]gitlab_api.GitlabCommit)
+	}
+	cc.compares[key] = compare.Commits
+	cc.mu.Unlock()
+	return compare.Commits, nil
+}
+
+// 返回包含该提交的 MR 中最早的合并时间，提交没有经过 MR 合并时返回 nil
+func (cc *commitCache) mergedTime(c context.Context, gitlabService gitlab_api.GitlabAPIInterface, base *gitlab_api.GitlabBaseRequest, projectPath, sha string) (*time.Time, error) {
+	key := base.GitlabName + "/" + projectPath + "/" + sha
+	cc.mu.Lock()
+	mergedAt, ok := cc.mergedAt[key]
+	cc.mu.Unlock()
+	if ok {
+		return mergedAt, nil
+	}
+
+	mergeRequests, err := gitlabService.ListCommitMergeRequests(c, &gitlab_api.GitlabCommitMergeRequestsRequest{
+		GitlabBaseRequest: *base,
+		ProjectID:         projectPath,
+		SHA:               sha,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一提交可能出现在多个 MR 中，取最早合并的一个
+	for _, mr := range mergeRequests {
+		if mr.MergedAt == nil {
+			continue
+		}
+		if mergedAt == nil || mr.MergedAt.Before(*mergedAt) {
+			merged := *mr.MergedAt
+			mergedAt = &merged
+		}
+	}
+
+	cc.mu.Lock()
+	if len(cc.mergedAt) >= maxCommitCacheEntries {
+		cc.mergedAt = make(map[string]*time.Time)
+	}
+	cc.mergedAt[key] = mergedAt
+	cc.mu.Unlock()
+	return mergedAt, nil
+}
+
+// 部署构建的 revision 由后台采集从构建详情中写入数据模型，这里不再实时请求 Jenkins
+func getLeadTimeMetricsHandler(c *gin.Context) {
+
+	days := defaultLeadTimeDays
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid days"})
+			return
+		}
+		days = parsed
+	}
+
+	leadTimeConfig, err := config.GetLeadTime()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "交付周期配置格式错误"})
+		return
+	}
+
+	gitlabConfigs, err := config.GetGitlabInstances()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "GitLab配置格式错误"})
+		return
+	}
+
+	gitlabBases := make(map[string]*gitlab_api.GitlabBaseRequest)
+	for gitlabInstanceName, gitlabConfig := range gitlabConfigs {
+		base, err := collector.GetGitlabConfig(gitlabInstanceName, gitlabConfig)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "GitLab配置格式错误"})
+			return
+		}
+		gitlabBases[gitlabInstanceName] = base
+	}
+
+	gitlabService := gitlab_impl.NewGitlabServiceImpl(httpClient)
+	lc := &leadTimeContext{
+		leadTimeConfig: leadTimeConfig,
+		gitlabBases:    gitlabBases,
+		since:          time.Now().AddDate(0, 0, -days),
+		commits:        commitLookups,
+	}
+
+	// 按 实例/job 汇总成功的部署构建，统计窗口之前的部署也需要，用于确定窗口内第一次部署的提交范围
+	type jobKey struct {
+		instance, job string
+	}
+	jobBuilds := make(map[jobKey][]store.JenkinsBuild)
+	for _, build := range dataStore.JenkinsBuilds() {
+		if build.Building || build.Result != "SUCCESS" || !leadTimeConfig.IsDeployJob(build.JobName) {
+			continue
+		}
+		key := jobKey{build.JenkinsInstanceName, build.JobName}
+		jobBuilds[key] = append(jobBuilds[key], build)
+	}
+
+	var wg sync.WaitGroup
+	resultCh := make(chan []DeploymentLeadTime, len(jobBuilds))
+	errCh := make(chan error, len(jobBuilds))
+
+	for _, builds := range jobBuilds {
+		wg.Add(1)
+
+		// 启动一个goroutine来处理每个部署 job
+		go func(builds []store.JenkinsBuild) {
+			defer wg.Done()
+
+			result, err := getJobDeploymentLeadTimes(c.Request.Context(), gitlabService, lc, builds)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			resultCh <- result
+		}(builds)
+	}
+
+	wg.Wait()
+	close(resultCh)
+	close(errCh)
+
+	if err, ok := <-errCh; ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	deployments := make([]DeploymentLeadTime, 0)
+	for result := range resultCh {
+		deployments = append(deployments, result...)
+	}
+
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].DeployedAt.After(deployments[j].DeployedAt)
+	})
+
+	c.JSON(http.StatusOK, gin.H{"提交到生产交付周期指标": deployments})
+}
+
+// 计算部署 job 在统计窗口内每次成功部署的交付周期，提交范围为上一次成功部署的 revision 到本次部署的 revision
+func getJobDeploymentLeadTimes(c context.Context, gitlabService gitlab_api.GitlabAPIInterface, lc *leadTimeContext, builds []store.JenkinsBuild) ([]DeploymentLeadTime, error) {
+
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].Number < builds[j].Number
+	})
+
+	var deployments []DeploymentLeadTime
+	for i := 1; i < len(builds); i++ {
+		previous, current := builds[i-1], builds[i]
+
+		builtAt := time.UnixMilli(current.Timestamp)
+		if builtAt.Before(lc.since) {
+			continue
+		}
+
+		if previous.Commit == "" || current.Commit == "" {
+			log.Warningf("Jenkins实例[%s]的job[%s]构建#%d未找到git revision, 跳过交付周期统计", current.JenkinsInstanceName, current.JobName, current.Number)
+			continue
+		}
+
+		var remoteUrls []string
+		if current.SCMURL != "" {
+			remoteUrls = append(remoteUrls, current.SCMURL)
+		}
+		gitlabInstanceName, projectPath, err := resolveGitlabProject(lc, current.JobName, remoteUrls)
+		if err != nil {
+			log.Warningf("Jenkins实例[%s]的job[%s]: %v", current.JenkinsInstanceName, current.JobName, err)
+			continue
+		}
+		base := lc.gitlabBases[gitlabInstanceName]
+
+		deployment := DeploymentLeadTime{
+			Provider:            ProviderJenkins,
+			JenkinsInstanceName: current.JenkinsInstanceName,
+			JobName:             current.JobName,
+			BuildNumber:         current.Number,
+			BuildURL:            current.URL,
+			GitlabInstanceName:  gitlabInstanceName,
+			ProjectPath:         projectPath,
+			PreviousSHA:         previous.Commit,
+			SHA:                 current.Commit,
+			BuiltAt:             builtAt,
+			DeployedAt:          builtAt.Add(time.Duration(current.Duration) * time.Millisecond),
+		}
+
+		// 重复部署同一 revision 时没有新的提交
+		if previous.Commit != current.Commit {
+			commits, err := lc.commits.compare(c, gitlabService, base, projectPath, previous.Commit, current.Commit)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, commit := range commits {
+				commitLeadTime, err := getCommitLeadTime(c, gitlabService, lc.commits, base, projectPath, commit, deployment.BuiltAt, deployment.DeployedAt)
+				if err != nil {
+					return nil, err
+				}
+				deployment.Commits = append(deployment.Commits, commitLeadTime)
+			}
+		}
+
+		var leadTimes []float64
+		for _, commit := range deployment.Commits {
+			leadTimes = append(leadTimes, commit.LeadTimeHours)
+			if commit.LeadTimeHours > deployment.MaxLeadTimeHours {
+				deployment.MaxLeadTimeHours = commit.LeadTimeHours
+			}
+		}
+		deployment.CommitCount = len(deployment.Commits)
+		deployment.LeadTimeHours = percentileStats(leadTimes)
+
+		deployments = append(deployments, deployment)
+	}
+
+	return deployments, nil
+}
+
+func getCommitLeadTime(c context.Context, gitlabService gitlab_api.GitlabAPIInterface, commits *commitCache, base *gitlab_api.GitlabBaseRequest, projectPath string, commit gitlab_api.GitlabCommit, builtAt, deployedAt time.Time) (CommitLeadTime, error) {
+
+	authoredAt := deployedAt
+	if commit.AuthoredDate != nil {
+		authoredAt = *commit.AuthoredDate
+	}
+
+	commitLeadTime := CommitLeadTime{
+		SHA:                  commit.ID,
+		Title:                commit.Title,
+		Author:               commit.AuthorName,
+		AuthoredAt:           authoredAt,
+		BuiltAt:              builtAt,
+		DeployedAt:           deployedAt,
+		BuiltToDeployedHours: hoursBetween(builtAt, deployedAt),
+		LeadTimeHours:        hoursBetween(authoredAt, deployedAt),
+	}
+
+	mergedAt, err := commits.mergedTime(c, gitlabService, base, projectPath, commit.ID)
+	if err != nil {
+		return commitLeadTime, err
+	}
+	commitLeadTime.MergedAt = mergedAt
+
+	if commitLeadTime.MergedAt != nil {
+		authoredToMerged := hoursBetween(authoredAt, *commitLeadTime.MergedAt)
+		mergedToBuilt := hoursBetween(*commitLeadTime.MergedAt, builtAt)
+		commitLeadTime.AuthoredToMergedHours = &authoredToMerged
+		commitLeadTime.MergedToBuiltHours = &mergedToBuilt
+	}
+
+	return commitLeadTime, nil
+}
+
+// 确定部署 job 对应的 GitLab 实例与项目：优先使用 leadTime.jobProjects 配置，否则按仓库地址的主机名匹配 GitLab 实例
+func resolveGitlabProject(lc *leadTimeContext, jobName string, remoteUrls []string) (string, string, error) {
+
+	if jobProject := lc.leadTimeConfig.ProjectOf(jobName); jobProject != nil {
+		if _, ok := lc.gitlabBases[jobProject.Gitlab]; !ok {
+			return "", "", errors.New("gitlab instance not configured: " + jobProject.Gitlab)
+		}
+		return jobProject.Gitlab, jobProject.Project, nil
+	}
+
+	for _, remote := range remoteUrls {
+		host, projectPath := parseGitRemote(remote)
+		if projectPath == "" {
+			continue
+		}
+		for gitlabInstanceName, base := range lc.gitlabBases {
+			gitlabURL, err := url.Parse(base.GitlabURL)
+			if err == nil && strings.EqualFold(gitlabURL.Hostname(), host) {
+				return gitlabInstanceName, projectPath, nil
+			}
+		}
+	}
+
+	return "", "", errors.New("no gitlab project matches remote urls: " + strings.Join(remoteUrls, ","))
+}
+
+// 解析 git 仓库地址，支持 https://host/group/app.git、ssh://git@host:port/group/app.git 与 git@host:group/app.git
+func parseGitRemote(remote string) (string, string) {
+	var host, projectPath string
+
+	if strings.Contains(remote, "://") {
+		remoteURL, err := url.Parse(remote)
+		if err != nil {
+			return "", ""
+		}
+		host, projectPath = remoteURL.Hostname(), remoteURL.Path
+	} else {
+		remote = remote[strings.Index(remote, "@")+1:]
+		parts := strings.SplitN(remote, ":", 2)
+		if len(parts) != 2 {
+			return "", ""
+		}
+		host, projectPath = parts[0], parts[1]
+	}
+
+	projectPath = strings.TrimSuffix(strings.Trim(projectPath, "/"), ".git")
+	return host, projectPath
+}
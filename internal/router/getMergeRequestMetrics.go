@@ -0,0 +1,167 @@
+﻿package router
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+	"github.com/zuoyangs/go-devops-observability/utils"
+)
+
+// 默认统计最近 4 周合并的 MR
+const defaultMergeRequestWeeks = 4
+
+// 单个已合并 MR 的评审数据，以及统计时所属的团队与周
+type mergeRequestCycleTime struct {
+	store.GitlabMergeRequestReview
+	Team string
+	Week string
+}
+
+type PercentileStats struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P75   float64 `json:"p75"`
+	P90   float64 `json:"p90"`
+}
+
+// 按周统计的 MR 评审效率指标，维度为项目或团队
+type MergeRequestCycleTimeStats struct {
+	Provider           string `json:"provider"`
+	GitlabInstanceName string `json:"gitlabInstanceName,omitempty"`
+	ProjectPath        string `json:"projectPath,omitempty"`
+	Team               string `json:"team,omitempty"`
+	Week               string `json:"week"`
+
+	MergedCount            int             `json:"mergedCount"`
+	TimeToFirstReviewHours PercentileStats `json:"timeToFirstReviewHours"`
+	TimeToApprovalHours    PercentileStats `json:"timeToApprovalHours"`
+	TimeToMergeHours       PercentileStats `json:"timeToMergeHours"`
+	LinesChanged           PercentileStats `json:"linesChanged"`
+	FilesChanged           PercentileStats `json:"filesChanged"`
+	ReviewRounds           PercentileStats `json:"reviewRounds"`
+
+	MergedWithoutApprovalCount int     `json:"mergedWithoutApprovalCount"`
+	MergedWithoutApprovalRate  float64 `json:"mergedWithoutApprovalRate"`
+}
+
+// 评审数据由 GitLab 后台采集计算，weeks 超过采集回溯的范围时只统计已采集到的 MR
+func getMergeRequestMetricsHandler(c *gin.Context) {
+
+	weeks := defaultMergeRequestWeeks
+	if value := c.Query("weeks"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid weeks"})
+			return
+		}
+		weeks = parsed
+	}
+
+	teams, err := config.GetTeams()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "团队配置格式错误"})
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -7*weeks)
+	var cycleTimes []mergeRequestCycleTime
+	for _, review := range dataStore.GitlabMergeRequestReviews() {
+		if review.MergedAt.Before(since) {
+			continue
+		}
+		year, week := review.MergedAt.Local().ISOWeek()
+		cycleTimes = append(cycleTimes, mergeRequestCycleTime{
+			GitlabMergeRequestReview: review,
+			Team:                     config.TeamOf(teams, review.ProjectPath, review.Author),
+			Week:                     fmt.Sprintf("%d-W%02d", year, week),
+		})
+	}
+
+	projectStats := aggregateMergeRequestCycleTimes(cycleTimes, func(item mergeRequestCycleTime) MergeRequestCycleTimeStats {
+		return MergeRequestCycleTimeStats{GitlabInstanceName: item.GitlabInstanceName, ProjectPath: item.ProjectPath, Week: item.Week}
+	})
+	teamStats := aggregateMergeRequestCycleTimes(cycleTimes, func(item mergeRequestCycleTime) MergeRequestCycleTimeStats {
+		return MergeRequestCycleTimeStats{Team: item.Team, Week: item.Week}
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"项目评审效率指标": projectStats,
+		"团队评审效率指标": teamStats,
+	})
+}
+
+func hoursBetween(from, to time.Time) float64 {
+	return utils.Round2(to.Sub(from).Hours())
+}
+
+func percentileStats(values []float64) PercentileStats {
+	return PercentileStats{
+		Count: len(values),
+		P50:   utils.Round2(utils.Percentile(values, 50)),
+		P75:   utils.Round2(utils.Percentile(values, 75)),
+		P90:   utils.Round2(utils.Percentile(values, 90)),
+	}
+}
+
+// 按 keyOf 返回的维度（项目+周 或 团队+周）聚合 MR 数据并计算分位数
+func aggregateMergeRequestCycleTimes(cycleTimes []mergeRequestCycleTime, keyOf func(mergeRequestCycleTime) MergeRequestCycleTimeStats) []MergeRequestCycleTimeStats {
+
+	type bucket struct {
+		stats                        MergeRequestCycleTimeStats
+		firstReview, approval, merge []float64
+		lines, files, rounds         []float64
+	}
+
+	buckets := make(map[string]*bucket)
+	var keys []string
+
+	for _, item := range cycleTimes {
+		stats := keyOf(item)
+		key := stats.GitlabInstanceName + "/" + stats.ProjectPath + "/" + stats.Team + "/" + stats.Week
+
+		b, ok := buckets[key]
+		if !ok {
+			stats.Provider = ProviderGitlab
+			b = &bucket{stats: stats}
+			buckets[key] = b
+			keys = append(keys, key)
+		}
+
+		b.stats.MergedCount++
+		if !item.Approved {
+			b.stats.MergedWithoutApprovalCount++
+		}
+		if item.TimeToFirstReview != nil {
+			b.firstReview = append(b.firstReview, *item.TimeToFirstReview)
+		}
+		if item.TimeToApproval != nil {
+			b.approval = append(b.approval, *item.TimeToApproval)
+		}
+		b.merge = append(b.merge, item.TimeToMerge)
+		b.lines = append(b.lines, float64(item.LinesChanged))
+		b.files = append(b.files, float64(item.FilesChanged))
+		b.rounds = append(b.rounds, float64(item.ReviewRounds))
+	}
+
+	sort.Strings(keys)
+	result := make([]MergeRequestCycleTimeStats, 0, len(keys))
+	for _, key := range keys {
+		b := buckets[key]
+		b.stats.TimeToFirstReviewHours = percentileStats(b.firstReview)
+		b.stats.TimeToApprovalHours = percentileStats(b.approval)
+		b.stats.TimeToMergeHours = percentileStats(b.merge)
+		b.stats.LinesChanged = percentileStats(b.lines)
+		b.stats.FilesChanged = percentileStats(b.files)
+		b.stats.ReviewRounds = percentileStats(b.rounds)
+		b.stats.MergedWithoutApprovalRate = percentage(b.stats.MergedWithoutApprovalCount, b.stats.MergedCount)
+		result = append(result, b.stats)
+	}
+
+	return result
+}
@@ -0,0 +1,84 @@
+﻿package router
+
+import (
+	"testing"
+
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+func TestAggregateMergeRequestCycleTimes(t *testing.T) {
+	hours := func(value float64) *float64 { return &value }
+	item := func(project, team, week string, firstReview *float64, merge float64, approved bool) mergeRequestCycleTime {
+		return mergeRequestCycleTime{
+			GitlabMergeRequestReview: store.GitlabMergeRequestReview{
+				GitlabInstanceName: "gitlab",
+				ProjectPath:        project,
+				TimeToFirstReview:  firstReview,
+				TimeToMerge:        merge,
+				LinesChanged:       10,
+				FilesChanged:       1,
+				ReviewRounds:       1,
+				Approved:           approved,
+			},
+			Team: team,
+			Week: week,
+		}
+	}
+	byProject := func(item mergeRequestCycleTime) MergeRequestCycleTimeStats {
+		return MergeRequestCycleTimeStats{GitlabInstanceName: item.GitlabInstanceName, ProjectPath: item.ProjectPath, Week: item.Week}
+	}
+	byTeam := func(item mergeRequestCycleTime) MergeRequestCycleTimeStats {
+		return MergeRequestCycleTimeStats{Team: item.Team, Week: item.Week}
+	}
+
+	cycleTimes := []mergeRequestCycleTime{
+		item("pay/api", "platform", "2024-W42", hours(1), 4, true),
+		item("pay/api", "platform", "2024-W42", hours(3), 8, false),
+		item("pay/api", "platform", "2024-W42", nil, 2, true),
+		item("pay/web", "platform", "2024-W42", hours(2), 6, true),
+		item("pay/api", "platform", "2024-W41", hours(5), 10, false),
+	}
+
+	tests := []struct {
+		name  string
+		keyOf func(mergeRequestCycleTime) MergeRequestCycleTimeStats
+		want  []MergeRequestCycleTimeStats
+	}{
+		{"by project", byProject, []MergeRequestCycleTimeStats{
+			{ProjectPath: "pay/api", Week: "2024-W41", MergedCount: 1, TimeToFirstReviewHours: PercentileStats{1, 5, 5, 5}, TimeToMergeHours: PercentileStats{1, 10, 10, 10}, MergedWithoutApprovalCount: 1, MergedWithoutApprovalRate: 100},
+			{ProjectPath: "pay/api", Week: "2024-W42", MergedCount: 3, TimeToFirstReviewHours: PercentileStats{2, 2, 2.5, 2.8}, TimeToMergeHours: PercentileStats{3, 4, 6, 7.2}, MergedWithoutApprovalCount: 1, MergedWithoutApprovalRate: 33.33},
+			{ProjectPath: "pay/web", Week: "2024-W42", MergedCount: 1, TimeToFirstReviewHours: PercentileStats{1, 2, 2, 2}, TimeToMergeHours: PercentileStats{1, 6, 6, 6}},
+		}},
+		{"by team", byTeam, []MergeRequestCycleTimeStats{
+			{Team: "platform", Week: "2024-W41", MergedCount: 1, TimeToFirstReviewHours: PercentileStats{1, 5, 5, 5}, TimeToMergeHours: PercentileStats{1, 10, 10, 10}, MergedWithoutApprovalCount: 1, MergedWithoutApprovalRate: 100},
+			{Team: "platform", Week: "2024-W42", MergedCount: 4, TimeToFirstReviewHours: PercentileStats{3, 2, 2.5, 2.8}, TimeToMergeHours: PercentileStats{4, 5, 6.5, 7.4}, MergedWithoutApprovalCount: 1, MergedWithoutApprovalRate: 25},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := aggregateMergeRequestCycleTimes(cycleTimes, tt.keyOf)
+			if len(stats) != len(tt.want) {
+				t.Fatalf("aggregateMergeRequestCycleTimes() = %+v", stats)
+			}
+			for i, want := range tt.want {
+				got := stats[i]
+				if got.Provider != ProviderGitlab || got.ProjectPath != want.ProjectPath || got.Team != want.Team || got.Week != want.Week || got.MergedCount != want.MergedCount {
+					t.Errorf("row %d = %+v, want %+v", i, got, want)
+				}
+				if got.TimeToFirstReviewHours != want.TimeToFirstReviewHours || got.TimeToMergeHours != want.TimeToMergeHours {
+					t.Errorf("row %d first review %+v merge %+v, want %+v %+v", i, got.TimeToFirstReviewHours, got.TimeToMergeHours, want.TimeToFirstReviewHours, want.TimeToMergeHours)
+				}
+				if got.MergedWithoutApprovalCount != want.MergedWithoutApprovalCount || got.MergedWithoutApprovalRate != want.MergedWithoutApprovalRate {
+					t.Errorf("row %d without approval %d %.2f, want %d %.2f", i, got.MergedWithoutApprovalCount, got.MergedWithoutApprovalRate, want.MergedWithoutApprovalCount, want.MergedWithoutApprovalRate)
+				}
+				if got.LinesChanged.Count != got.MergedCount || got.LinesChanged.P50 != 10 {
+					t.Errorf("row %d lines changed = %+v", i, got.LinesChanged)
+				}
+			}
+		})
+	}
+
+	if stats := aggregateMergeRequestCycleTimes(nil, byProject); stats == nil || len(stats) != 0 {
+		t.Errorf("aggregateMergeRequestCycleTimes(nil) = %#v, want empty", stats)
+	}
+}
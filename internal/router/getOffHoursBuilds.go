@@ -0,0 +1,163 @@
+﻿package router
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 默认列出最近 7 天非工作时间的构建
+const defaultOffHoursDays = 7
+
+type OffHoursBuild struct {
+	Provider            string `json:"provider"`
+	JenkinsInstanceName string `json:"jenkinsInstanceName"`
+	JobName             string `json:"jobName"`
+	Number              int    `json:"number"`
+	URL                 string `json:"url"`
+	Result              string `json:"result"`
+	Timestamp           int64  `json:"timestamp"`
+	// 按 workingHours.timezone 换算后的开始时间
+	LocalTime   string `json:"localTime"`
+	Weekday     string `json:"weekday"`
+	Reason      string `json:"reason"`
+	TriggerType string `json:"triggerType"`
+	TriggeredBy string `json:"triggeredBy"`
+	Team        string `json:"team"`
+}
+
+// 按触发用户汇总的非工作时间构建
+type OffHoursUserStats struct {
+	TriggeredBy  string `json:"triggeredBy"`
+	TotalCount   int    `json:"totalCount"`
+	FailureCount int    `json:"failureCount"`
+}
+
+// 在 workingHours 配置的工作时间之外开始的构建，最近的在前，并按触发用户汇总。
+// 定时触发的构建默认不计入，includeScheduled=true 时一并列出；
+// 可以通过 deployOnly=true、instance、team 筛选
+func getOffHoursBuildsHandler(c *gin.Context) {
+
+	days, err := positiveQuery(c, "days", defaultOffHoursDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	instance := c.Query("instance")
+	team := c.Query("team")
+	deployOnly := c.Query("deployOnly") == "true"
+	includeScheduled := c.Query("includeScheduled") == "true"
+
+	workingHours, err := config.GetWorkingHours()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "工作时间配置格式错误"})
+		return
+	}
+	leadTimeConfig, err := config.GetLeadTime()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "交付周期配置格式错误"})
+		return
+	}
+	teams, err := config.GetTeams()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "团队配置格式错误"})
+		return
+	}
+
+	sinceMillis := time.Now().AddDate(0, 0, -days).UnixMilli()
+	location := workingHours.Location()
+
+	builds := make([]OffHoursBuild, 0)
+	for _, build := range dataStore.JenkinsBuilds() {
+		if build.Timestamp < sinceMillis {
+			continue
+		}
+		if instance != "" && build.JenkinsInstanceName != instance {
+			continue
+		}
+		if deployOnly && !leadTimeConfig.IsDeployJob(build.JobName) {
+			continue
+		}
+		if !includeScheduled && build.TriggerType == jenkins_api.TriggerTimer {
+			continue
+		}
+		buildTeam := config.TeamOfJob(teams, build.JobName)
+		if team != "" && buildTeam != team {
+			continue
+		}
+
+		startedAt := time.UnixMilli(build.Timestamp)
+		reason := workingHours.OffHoursReason(startedAt)
+		if reason == "" {
+			continue
+		}
+
+		local := startedAt.In(location)
+		builds = append(builds, OffHoursBuild{
+			Provider:            ProviderJenkins,
+			JenkinsInstanceName: build.JenkinsInstanceName,
+			JobName:             build.JobName,
+			Number:              build.Number,
+			URL:                 build.URL,
+			Result:              offHoursResult(build),
+			Timestamp:           build.Timestamp,
+			LocalTime:           local.Format(time.RFC3339),
+			Weekday:             heatmapWeekdays[(int(local.Weekday())+6)%7],
+			Reason:              reason,
+			TriggerType:         valueOrUnknown(build.TriggerType),
+			TriggeredBy:         valueOrUnknown(build.TriggeredBy),
+			Team:                buildTeam,
+		})
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].Timestamp > builds[j].Timestamp
+	})
+
+	c.JSON(http.StatusOK, gin.H{"非工作时间构建": gin.H{
+		"timezone": location.String(),
+		"builds":   builds,
+		"users":    calculateOffHoursUsers(builds),
+	}})
+}
+
+// 仍在运行的构建没有结果
+func offHoursResult(build store.JenkinsBuild) string {
+	if build.Building {
+		return "BUILDING"
+	}
+	return build.Result
+}
+
+// 非工作时间构建最多的用户排在前面
+func calculateOffHoursUsers(builds []OffHoursBuild) []*OffHoursUserStats {
+
+	resultMap := make(map[string]*OffHoursUserStats)
+	for _, build := range builds {
+		stats, ok := resultMap[build.TriggeredBy]
+		if !ok {
+			stats = &OffHoursUserStats{TriggeredBy: build.TriggeredBy}
+			resultMap[build.TriggeredBy] = stats
+		}
+		stats.TotalCount++
+		if build.Result == "FAILURE" {
+			stats.FailureCount++
+		}
+	}
+
+	result := make([]*OffHoursUserStats, 0, len(resultMap))
+	for _, stats := range resultMap {
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalCount != result[j].TotalCount {
+			return result[i].TotalCount > result[j].TotalCount
+		}
+		return result[i].TriggeredBy < result[j].TriggeredBy
+	})
+	return result
+}
@@ -0,0 +1,230 @@
+﻿package router
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 默认统计最近 7 天结束的构建
+const defaultQueueDays = 7
+
+// 构建等待的节点标签为空时的展示名称
+const anyLabel = "任意节点"
+
+// 实例当前的构建队列
+type JenkinsQueueStats struct {
+	Provider            string         `json:"provider"`
+	JenkinsInstanceName string         `json:"jenkinsInstanceName"`
+	Length              int            `json:"length"`
+	StuckCount          int            `json:"stuckCount"`
+	BlockedCount        int            `json:"blockedCount"`
+	LongestWaitSeconds  float64        `json:"longestWaitSeconds"`
+	ByReason            map[string]int `json:"byReason"`
+	ByLabel             map[string]int `json:"byLabel"`
+	Items               []QueueItem    `json:"items"`
+}
+
+type QueueItem struct {
+	QueueID        int     `json:"queueId"`
+	JobName        string  `json:"jobName"`
+	Why            string  `json:"why"`
+	Reason         string  `json:"reason"`
+	Label          string  `json:"label"`
+	Blocked        bool    `json:"blocked"`
+	Stuck          bool    `json:"stuck"`
+	WaitingSeconds float64 `json:"waitingSeconds"`
+}
+
+// 已结束构建的排队耗时与执行耗时，按 job 或节点标签统计
+type QueueWaitStats struct {
+	Provider string `json:"provider"`
+	BuildDimensions
+	Label string `json:"label,omitempty"`
+
+	QueueSeconds     PercentileStats `json:"queueSeconds"`
+	ExecutionSeconds PercentileStats `json:"executionSeconds"`
+
+	// 构建数以及能得到排队耗时的构建数。QueueSampled 为 true 表示部分排队耗时来自轮询队列快照，
+	// 两次采集之间开始执行的短暂排队会被漏掉，排队耗时偏高；安装 Metrics 插件或开启 QUEUED 推送可以得到准确值
+	BuildCount      int  `json:"buildCount"`
+	QueueKnownCount int  `json:"queueKnownCount"`
+	QueueSampled    bool `json:"queueSampled"`
+}
+
+func getQueueMetricsHandler(c *gin.Context) {
+
+	dimensions, err := parseGroupBy(c, defaultGroupBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	days := defaultQueueDays
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid days"})
+			return
+		}
+		days = parsed
+	}
+
+	now := time.Now()
+	sinceMillis := now.AddDate(0, 0, -days).UnixMilli()
+
+	c.JSON(http.StatusOK, gin.H{"构建队列指标": gin.H{
+		"queues": calculateQueueStats(dataStore.JenkinsQueue(), now),
+		"jobs": calculateQueueWaitStats(dataStore.JenkinsBuilds(), sinceMillis, func(build store.JenkinsBuild) QueueWaitStats {
+			return QueueWaitStats{BuildDimensions: dimensions.dimensionsOf(build)}
+		}),
+		"labels": calculateQueueWaitStats(dataStore.JenkinsBuilds(), sinceMillis, func(build store.JenkinsBuild) QueueWaitStats {
+			label := build.Label
+			if label == "" {
+				label = anyLabel
+			}
+			return QueueWaitStats{BuildDimensions: BuildDimensions{JenkinsInstanceName: build.JenkinsInstanceName}, Label: label}
+		}),
+	}})
+}
+
+// 将 why 归类为有限的几种等待原因，便于统计
+func queueReason(why string) string {
+	switch {
+	case strings.Contains(why, "quiet period"):
+		return "静默期"
+	case strings.Contains(why, "already in progress"):
+		return "等待上一次构建结束"
+	case strings.Contains(why, "offline"):
+		return "节点离线"
+	case strings.Contains(why, "no nodes with the label"):
+		return "没有匹配标签的节点"
+	case strings.Contains(why, "available executor"):
+		return "等待空闲执行器"
+	case strings.Contains(why, "upstream") || strings.Contains(why, "downstream"):
+		return "等待上下游构建"
+	case why == "":
+		return "未知"
+	default:
+		return "其他"
+	}
+}
+
+func calculateQueueStats(items []store.JenkinsQueueItem, now time.Time) []*JenkinsQueueStats {
+
+	resultMap := make(map[string]*JenkinsQueueStats)
+	for _, item := range items {
+		stats, ok := resultMap[item.JenkinsInstanceName]
+		if !ok {
+			stats = &JenkinsQueueStats{
+				Provider:            ProviderJenkins,
+				JenkinsInstanceName: item.JenkinsInstanceName,
+				ByReason:            make(map[string]int),
+				ByLabel:             make(map[string]int),
+			}
+			resultMap[item.JenkinsInstanceName] = stats
+		}
+
+		label := item.Label
+		if label == "" {
+			label = anyLabel
+		}
+
+		var waitingSeconds float64
+		if item.InQueueSince != 0 {
+			waitingSeconds = float64(now.UnixMilli()-item.InQueueSince) / 1000
+		}
+
+		reason := queueReason(item.Why)
+		stats.Length++
+		stats.ByReason[reason]++
+		stats.ByLabel[label]++
+		if item.Stuck {
+			stats.StuckCount++
+		}
+		if item.Blocked {
+			stats.BlockedCount++
+		}
+		if waitingSeconds > stats.LongestWaitSeconds {
+			stats.LongestWaitSeconds = waitingSeconds
+		}
+		stats.Items = append(stats.Items, QueueItem{
+			QueueID:        item.QueueID,
+			JobName:        item.JobName,
+			Why:            item.Why,
+			Reason:         reason,
+			Label:          label,
+			Blocked:        item.Blocked,
+			Stuck:          item.Stuck,
+			WaitingSeconds: waitingSeconds,
+		})
+	}
+
+	var result []*JenkinsQueueStats
+	for _, stats := range resultMap {
+		// 等待最久的排在前面
+		sort.Slice(stats.Items, func(i, j int) bool {
+			return stats.Items[i].WaitingSeconds > stats.Items[j].WaitingSeconds
+		})
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].JenkinsInstanceName < result[j].JenkinsInstanceName
+	})
+	return result
+}
+
+// 按 keyOf 返回的维度（groupBy 指定的维度 或 实例+标签）聚合 since 之后结束的构建。
+// 无法得到排队耗时的构建只计入执行耗时
+func calculateQueueWaitStats(builds []store.JenkinsBuild, sinceMillis int64, keyOf func(store.JenkinsBuild) QueueWaitStats) []QueueWaitStats {
+
+	type bucket struct {
+		stats     QueueWaitStats
+		queue     []float64
+		execution []float64
+	}
+
+	buckets := make(map[QueueWaitStats]*bucket)
+	for _, build := range builds {
+		if build.Building || build.Timestamp < sinceMillis {
+			continue
+		}
+
+		key := keyOf(build)
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{stats: key}
+			b.stats.Provider = ProviderJenkins
+			buckets[key] = b
+		}
+
+		b.stats.BuildCount++
+		if build.QueueDuration > 0 {
+			b.queue = append(b.queue, float64(build.QueueDuration)/1000)
+			b.stats.QueueKnownCount++
+			if build.QueueSource == store.SourcePoll {
+				b.stats.QueueSampled = true
+			}
+		}
+		b.execution = append(b.execution, float64(build.Duration)/1000)
+	}
+
+	result := make([]QueueWaitStats, 0, len(buckets))
+	for _, b := range buckets {
+		b.stats.QueueSeconds = percentileStats(b.queue)
+		b.stats.ExecutionSeconds = percentileStats(b.execution)
+		result = append(result, b.stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].BuildDimensions != result[j].BuildDimensions {
+			return result[i].BuildDimensions.less(result[j].BuildDimensions)
+		}
+		return result[i].Label < result[j].Label
+	})
+	return result
+}
@@ -0,0 +1,181 @@
+﻿package router
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 当前处于失败状态（最近完成的构建为失败）的 job，用于每天的"修红灯"站会
+type RedJob struct {
+	Provider            string `json:"provider"`
+	JenkinsInstanceName string `json:"jenkinsInstanceName"`
+	JobName             string `json:"jobName"`
+	JobURL              string `json:"jobUrl"`
+	Team                string `json:"team"`
+
+	// 从最近一次完成的构建往前数连续失败的次数，中止的构建不打断也不计入
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// 连续失败中第一次失败构建的开始时间，以及至今的时长
+	RedSince   int64   `json:"redSince"`
+	RedSeconds float64 `json:"redSeconds"`
+
+	// 失败前最近一次成功的构建，保留的数据中没有成功构建时为空
+	LastGoodBuild *RedJobBuild `json:"lastGoodBuild"`
+	// 连续失败的构建，最近的在前
+	FailedBuilds []RedJobBuild `json:"failedBuilds"`
+	// 触发失败构建的用户，按触发次数从多到少排列
+	TriggeredBy []string `json:"triggeredBy"`
+}
+
+type RedJobBuild struct {
+	Number          int    `json:"number"`
+	URL             string `json:"url"`
+	Timestamp       int64  `json:"timestamp"`
+	Commit          string `json:"commit,omitempty"`
+	TriggerType     string `json:"triggerType,omitempty"`
+	TriggeredBy     string `json:"triggeredBy,omitempty"`
+	FailureCategory string `json:"failureCategory,omitempty"`
+}
+
+// 列出当前失败的 job，按失败持续时长从长到短排列。
+// 可以通过 team、instance 筛选，minFailures 指定至少连续失败的次数
+func getRedJobsHandler(c *gin.Context) {
+
+	minFailures, err := positiveQuery(c, "minFailures", 1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	teams, err := config.GetTeams()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "团队配置格式错误"})
+		return
+	}
+
+	team := c.Query("team")
+	instance := c.Query("instance")
+
+	var jobs []*RedJob
+	for _, job := range detectRedJobs(dataStore.JenkinsBuilds(), teams, time.Now()) {
+		if job.ConsecutiveFailures < minFailures {
+			continue
+		}
+		if team != "" && job.Team != team {
+			continue
+		}
+		if instance != "" && job.JenkinsInstanceName != instance {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"当前失败的job": jobs})
+}
+
+func detectRedJobs(builds []store.JenkinsBuild, teams map[string]config.Team, now time.Time) []*RedJob {
+
+	type jobKey struct {
+		instance, job string
+	}
+
+	// 每个 job 的构建按时间从新到旧排列
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].Timestamp > builds[j].Timestamp
+	})
+	history := make(map[jobKey][]store.JenkinsBuild)
+	var keys []jobKey
+	for _, build := range builds {
+		if build.Building {
+			continue
+		}
+		key := jobKey{build.JenkinsInstanceName, build.JobName}
+		if _, ok := history[key]; !ok {
+			keys = append(keys, key)
+		}
+		history[key] = append(history[key], build)
+	}
+
+	var result []*RedJob
+	for _, key := range keys {
+		// 从最近的构建往前数连续失败的构建，之后继续查找最近一次成功的构建
+		failed := store.FailureStreak(history[key])
+		if len(failed) == 0 {
+			continue
+		}
+
+		first := failed[len(failed)-1]
+		var lastGood *store.JenkinsBuild
+		for i := range history[key] {
+			build := &history[key][i]
+			if build.Timestamp < first.Timestamp && build.Result == "SUCCESS" {
+				lastGood = build
+				break
+			}
+		}
+		job := &RedJob{
+			Provider:            ProviderJenkins,
+			JenkinsInstanceName: key.instance,
+			JobName:             key.job,
+			JobURL:              failed[0].JobURL,
+			Team:                config.TeamOfJob(teams, key.job),
+			ConsecutiveFailures: len(failed),
+			RedSince:            first.Timestamp,
+			RedSeconds:          now.Sub(time.UnixMilli(first.Timestamp)).Seconds(),
+		}
+		if lastGood != nil {
+			build := redJobBuild(*lastGood)
+			job.LastGoodBuild = &build
+		}
+
+		triggers := make(map[string]int)
+		for _, build := range failed {
+			job.FailedBuilds = append(job.FailedBuilds, redJobBuild(build))
+			if build.TriggeredBy != "" {
+				triggers[build.TriggeredBy]++
+			}
+		}
+		job.TriggeredBy = make([]string, 0, len(triggers))
+		for user := range triggers {
+			job.TriggeredBy = append(job.TriggeredBy, user)
+		}
+		sort.Slice(job.TriggeredBy, func(i, j int) bool {
+			left, right := job.TriggeredBy[i], job.TriggeredBy[j]
+			if triggers[left] != triggers[right] {
+				return triggers[left] > triggers[right]
+			}
+			return left < right
+		})
+
+		result = append(result, job)
+	}
+
+	// 失败持续越久越靠前
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].RedSince != result[j].RedSince {
+			return result[i].RedSince < result[j].RedSince
+		}
+		if result[i].JenkinsInstanceName != result[j].JenkinsInstanceName {
+			return result[i].JenkinsInstanceName < result[j].JenkinsInstanceName
+		}
+		return result[i].JobName < result[j].JobName
+	})
+	return result
+}
+
+func redJobBuild(build store.JenkinsBuild) RedJobBuild {
+	return RedJobBuild{
+		Number:          build.Number,
+		URL:             build.URL,
+		Timestamp:       build.Timestamp,
+		Commit:          build.Commit,
+		TriggerType:     build.TriggerType,
+		TriggeredBy:     build.TriggeredBy,
+		FailureCategory: build.FailureCategory,
+	}
+}
@@ -0,0 +1,142 @@
+﻿package router
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+	"github.com/zuoyangs/go-devops-observability/utils"
+)
+
+// 运行中的构建，已运行时长与预计时长按请求时的时间计算
+type RunningBuild struct {
+	Provider            string `json:"provider"`
+	JenkinsInstanceName string `json:"jenkinsInstanceName"`
+	JobName             string `json:"jobName"`
+	Team                string `json:"team"`
+	Number              int    `json:"number"`
+	URL                 string `json:"url"`
+	Branch              string `json:"branch,omitempty"`
+	TriggeredBy         string `json:"triggeredBy,omitempty"`
+	BuiltOn             string `json:"builtOn,omitempty"`
+
+	StartedAt        int64   `json:"startedAt"`
+	ElapsedSeconds   float64 `json:"elapsedSeconds"`
+	EstimatedSeconds float64 `json:"estimatedSeconds"`
+	// 已运行时长占预计时长的百分比，没有预计时长时为 0
+	Progress float64 `json:"progress"`
+	// 取值见 config 中的 Running* 常量
+	Status string `json:"status"`
+	// 最近一次采集或推送更新构建的时间
+	LastSeenAt time.Time `json:"lastSeenAt"`
+}
+
+// RunningCount 不包含失联的构建
+type RunningBuildsSummary struct {
+	RunningCount int             `json:"runningCount"`
+	OverrunCount int             `json:"overrunCount"`
+	StuckCount   int             `json:"stuckCount"`
+	StaleCount   int             `json:"staleCount"`
+	Builds       []*RunningBuild `json:"builds"`
+}
+
+// 列出全部实例上运行中的构建，运行最久的在前。可以通过 status、instance、team 筛选，
+// 长时间没有更新的构建状态为 stale
+func getRunningBuildsHandler(c *gin.Context) {
+
+	status := c.Query("status")
+	switch status {
+	case "", config.RunningNormal, config.RunningOverrun, config.RunningStuck, config.RunningStale:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
+	}
+	instance := c.Query("instance")
+	team := c.Query("team")
+
+	running, err := config.GetRunningBuilds()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "运行中构建配置格式错误"})
+		return
+	}
+	teams, err := config.GetTeams()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "团队配置格式错误"})
+		return
+	}
+
+	summary := RunningBuildsSummary{Builds: make([]*RunningBuild, 0)}
+	for _, build := range runningBuilds(dataStore.JenkinsBuilds(), running, teams, time.Now()) {
+		if status != "" && build.Status != status {
+			continue
+		}
+		if instance != "" && build.JenkinsInstanceName != instance {
+			continue
+		}
+		if team != "" && build.Team != team {
+			continue
+		}
+
+		switch build.Status {
+		case config.RunningOverrun:
+			summary.OverrunCount++
+		case config.RunningStuck:
+			summary.StuckCount++
+		case config.RunningStale:
+			summary.StaleCount++
+		}
+		if build.Status != config.RunningStale {
+			summary.RunningCount++
+		}
+		summary.Builds = append(summary.Builds, build)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"运行中的构建": summary})
+}
+
+func runningBuilds(builds []store.JenkinsBuild, running *config.RunningBuilds, teams map[string]config.Team, now time.Time) []*RunningBuild {
+
+	var result []*RunningBuild
+	for _, build := range builds {
+		if !build.Building {
+			continue
+		}
+
+		elapsed := now.Sub(time.UnixMilli(build.Timestamp))
+		estimated := time.Duration(build.EstimatedDuration) * time.Millisecond
+		item := &RunningBuild{
+			Provider:            ProviderJenkins,
+			JenkinsInstanceName: build.JenkinsInstanceName,
+			JobName:             build.JobName,
+			Team:                config.TeamOfJob(teams, build.JobName),
+			Number:              build.Number,
+			URL:                 build.URL,
+			Branch:              build.Branch,
+			TriggeredBy:         build.TriggeredBy,
+			BuiltOn:             build.BuiltOn,
+			StartedAt:           build.Timestamp,
+			ElapsedSeconds:      utils.Round2(elapsed.Seconds()),
+			EstimatedSeconds:    utils.Round2(estimated.Seconds()),
+			Status:              running.StatusOf(elapsed, estimated),
+			LastSeenAt:          build.UpdatedAt,
+		}
+		if running.IsStale(build.UpdatedAt, now) {
+			item.Status = config.RunningStale
+		}
+		if estimated > 0 {
+			item.Progress = percentage(int(elapsed.Milliseconds()), int(estimated.Milliseconds()))
+		}
+		result = append(result, item)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].StartedAt != result[j].StartedAt {
+			return result[i].StartedAt < result[j].StartedAt
+		}
+		return result[i].URL < result[j].URL
+	})
+	return result
+}
@@ -0,0 +1,120 @@
+﻿package router
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 默认统计最近 30 天的流水线构建
+const defaultStageDays = 30
+
+// 流水线单个阶段的失败率与耗时，例如 "Deploy 阶段失败率 12%，Unit Test 阶段 p90 耗时 14 分钟"
+type PipelineStageStats struct {
+	Provider string `json:"provider"`
+	BuildDimensions
+	StageName string `json:"stageName"`
+
+	// 实际执行过的次数，不包含跳过的阶段
+	RunCount      int     `json:"runCount"`
+	FailureCount  int     `json:"failureCount"`
+	UnstableCount int     `json:"unstableCount"`
+	AbortedCount  int     `json:"abortedCount"`
+	SkippedCount  int     `json:"skippedCount"`
+	FailureRate   float64 `json:"failureRate"`
+
+	// 扣除等待人工输入（input）时间后的耗时
+	DurationMinutes PercentileStats `json:"durationMinutes"`
+}
+
+func getStageMetricsHandler(c *gin.Context) {
+
+	dimensions, err := parseGroupBy(c, defaultGroupBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	days := defaultStageDays
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid days"})
+			return
+		}
+		days = parsed
+	}
+
+	sinceMillis := time.Now().AddDate(0, 0, -days).UnixMilli()
+
+	c.JSON(http.StatusOK, gin.H{"流水线阶段指标": calculateStageStats(dataStore.JenkinsBuilds(), dimensions, sinceMillis)})
+}
+
+func calculateStageStats(builds []store.JenkinsBuild, dimensions groupBy, sinceMillis int64) []*PipelineStageStats {
+
+	type stageKey struct {
+		dimensions BuildDimensions
+		stage      string
+	}
+
+	resultMap := make(map[stageKey]*PipelineStageStats)
+	durations := make(map[stageKey][]float64)
+
+	for _, build := range builds {
+		if build.Building || build.Timestamp < sinceMillis {
+			continue
+		}
+
+		for _, stage := range build.Stages {
+			if stage.Status == jenkins_api.StageInProgress {
+				continue
+			}
+
+			key := stageKey{dimensions.dimensionsOf(build), stage.Name}
+			stats, ok := resultMap[key]
+			if !ok {
+				stats = &PipelineStageStats{
+					Provider:        ProviderJenkins,
+					BuildDimensions: key.dimensions,
+					StageName:       stage.Name,
+				}
+				resultMap[key] = stats
+			}
+
+			if stage.Status == jenkins_api.StageNotExecuted {
+				stats.SkippedCount++
+				continue
+			}
+
+			stats.RunCount++
+			switch stage.Status {
+			case jenkins_api.StageFailed:
+				stats.FailureCount++
+			case jenkins_api.StageUnstable:
+				stats.UnstableCount++
+			case jenkins_api.StageAborted:
+				stats.AbortedCount++
+			}
+			durations[key] = append(durations[key], float64(stage.Duration-stage.PauseDuration)/60000)
+		}
+	}
+
+	var result []*PipelineStageStats
+	for key, stats := range resultMap {
+		stats.FailureRate = percentage(stats.FailureCount, stats.RunCount)
+		stats.DurationMinutes = percentileStats(durations[key])
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].BuildDimensions != result[j].BuildDimensions {
+			return result[i].BuildDimensions.less(result[j].BuildDimensions)
+		}
+		return result[i].StageName < result[j].StageName
+	})
+	return result
+}
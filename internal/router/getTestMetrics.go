@@ -0,0 +1,324 @@
+﻿package router
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+	"github.com/zuoyangs/go-devops-observability/utils"
+)
+
+// 默认统计最近 14 天的构建；连续构建中通过与失败来回切换至少 3 次才认为是不稳定测试，
+// 一次回归加一次修复只会产生 2 次切换
+const (
+	defaultTestDays     = 14
+	defaultFlakyLimit   = 20
+	defaultFlakyMinFlip = 3
+)
+
+// 按 job 汇总的测试结果
+type JobTestStats struct {
+	Provider string `json:"provider"`
+	BuildDimensions
+	// 按 job 统计时 job 所属的团队，覆盖 BuildDimensions 中的同名字段
+	Team string `json:"team,omitempty"`
+
+	BuildCount      int             `json:"buildCount"`
+	FailedBuilds    int             `json:"failedBuilds"`
+	AvgTotal        float64         `json:"avgTotal"`
+	PassRate        float64         `json:"passRate"`
+	DurationSeconds PercentileStats `json:"durationSeconds"`
+
+	LatestBuild *JobTestBuild  `json:"latestBuild"`
+	Builds      []JobTestBuild `json:"builds"`
+}
+
+type JobTestBuild struct {
+	Number    int    `json:"number"`
+	Timestamp int64  `json:"timestamp"`
+	Commit    string `json:"commit,omitempty"`
+	store.JenkinsTestSummary
+}
+
+// 不稳定测试，History 按构建先后顺序用 P/F/S 表示通过、失败、跳过
+type FlakyTest struct {
+	JenkinsInstanceName string `json:"jenkinsInstanceName"`
+	JobName             string `json:"jobName"`
+	Team                string `json:"team"`
+	ClassName           string `json:"className"`
+	Name                string `json:"name"`
+
+	Runs            int     `json:"runs"`
+	Failures        int     `json:"failures"`
+	Flips           int     `json:"flips"`
+	FlipRate        float64 `json:"flipRate"`
+	SameCommitFlips int     `json:"sameCommitFlips"`
+	LastFailedBuild int     `json:"lastFailedBuild,omitempty"`
+	History         string  `json:"history"`
+}
+
+type FlakyTestGroup struct {
+	Provider            string       `json:"provider"`
+	JenkinsInstanceName string       `json:"jenkinsInstanceName,omitempty"`
+	JobName             string       `json:"jobName,omitempty"`
+	Team                string       `json:"team,omitempty"`
+	FlakyCount          int          `json:"flakyCount"`
+	Tests               []*FlakyTest `json:"tests"`
+}
+
+func getTestMetricsHandler(c *gin.Context) {
+
+	dimensions, err := parseGroupBy(c, defaultGroupBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	days, err := positiveQuery(c, "days", defaultTestDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	teams, err := config.GetTeams()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "团队配置格式错误"})
+		return
+	}
+
+	sinceMillis := time.Now().AddDate(0, 0, -days).UnixMilli()
+
+	c.JSON(http.StatusOK, gin.H{"测试结果指标": calculateJobTestStats(dataStore.JenkinsBuilds(), dimensions, teams, sinceMillis)})
+}
+
+func getFlakyTestMetricsHandler(c *gin.Context) {
+
+	days, err := positiveQuery(c, "days", defaultTestDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, err := positiveQuery(c, "limit", defaultFlakyLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	minFlips, err := positiveQuery(c, "minFlips", defaultFlakyMinFlip)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	teams, err := config.GetTeams()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "团队配置格式错误"})
+		return
+	}
+
+	sinceMillis := time.Now().AddDate(0, 0, -days).UnixMilli()
+	flakyTests := detectFlakyTests(dataStore.JenkinsTestCases(), teams, sinceMillis, minFlips)
+
+	byJob := groupFlakyTests(flakyTests, limit, func(test *FlakyTest) flakyGroupKey {
+		return flakyGroupKey{instance: test.JenkinsInstanceName, job: test.JobName}
+	})
+	byTeam := groupFlakyTests(flakyTests, limit, func(test *FlakyTest) flakyGroupKey {
+		return flakyGroupKey{team: test.Team}
+	})
+
+	c.JSON(http.StatusOK, gin.H{"不稳定测试": gin.H{
+		"jobs":  byJob,
+		"teams": byTeam,
+	}})
+}
+
+// 读取正整数类型的查询参数，未传时返回默认值
+func positiveQuery(c *gin.Context, name string, defaultValue int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return parsed, nil
+}
+
+func calculateJobTestStats(builds []store.JenkinsBuild, dimensions groupBy, teams map[string]config.Team, sinceMillis int64) []*JobTestStats {
+
+	type jobKey = BuildDimensions
+
+	resultMap := make(map[jobKey]*JobTestStats)
+	durations := make(map[jobKey][]float64)
+	passed := make(map[jobKey]int)
+	total := make(map[jobKey]int)
+
+	for _, build := range builds {
+		if build.Building || build.Tests == nil || build.Timestamp < sinceMillis {
+			continue
+		}
+
+		key := dimensions.dimensionsOf(build)
+		stats, ok := resultMap[key]
+		if !ok {
+			stats = &JobTestStats{
+				Provider:        ProviderJenkins,
+				BuildDimensions: key,
+			}
+			if key.Team != "" {
+				stats.Team = key.Team
+			} else if key.JobName != "" {
+				stats.Team = config.TeamOfJob(teams, key.JobName)
+			}
+			resultMap[key] = stats
+		}
+
+		stats.BuildCount++
+		if build.Tests.Failed > 0 {
+			stats.FailedBuilds++
+		}
+		passed[key] += build.Tests.Passed
+		total[key] += build.Tests.Passed + build.Tests.Failed
+		durations[key] = append(durations[key], build.Tests.Duration)
+		stats.AvgTotal += float64(build.Tests.Total)
+		stats.Builds = append(stats.Builds, JobTestBuild{
+			Number:             build.Number,
+			Timestamp:          build.Timestamp,
+			Commit:             build.Commit,
+			JenkinsTestSummary: *build.Tests,
+		})
+	}
+
+	var result []*JobTestStats
+	for key, stats := range resultMap {
+		stats.AvgTotal = utils.Round2(stats.AvgTotal / float64(stats.BuildCount))
+		stats.PassRate = percentage(passed[key], total[key])
+		stats.DurationSeconds = percentileStats(durations[key])
+		sort.Slice(stats.Builds, func(i, j int) bool {
+			return stats.Builds[i].Number < stats.Builds[j].Number
+		})
+		latest := stats.Builds[len(stats.Builds)-1]
+		stats.LatestBuild = &latest
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BuildDimensions.less(result[j].BuildDimensions)
+	})
+	return result
+}
+
+// 根据测试用例的执行历史识别不稳定测试：同一提交上既通过又失败，
+// 或者在连续构建中通过与失败来回切换至少 minFlips 次。跳过的结果不参与判断
+func detectFlakyTests(cases []store.JenkinsTestCase, teams map[string]config.Team, sinceMillis int64, minFlips int) []*FlakyTest {
+
+	var result []*FlakyTest
+	for _, testCase := range cases {
+		test := &FlakyTest{
+			JenkinsInstanceName: testCase.JenkinsInstanceName,
+			JobName:             testCase.JobName,
+			ClassName:           testCase.ClassName,
+			Name:                testCase.Name,
+		}
+
+		var history strings.Builder
+		previous := ""
+		commitStatuses := make(map[string]map[string]bool)
+		for _, run := range testCase.Results {
+			if run.Timestamp < sinceMillis {
+				continue
+			}
+			history.WriteString(run.Status[:1])
+			if run.Status == jenkins_api.TestSkipped {
+				continue
+			}
+
+			test.Runs++
+			if run.Status == jenkins_api.TestFailed {
+				test.Failures++
+				test.LastFailedBuild = run.Number
+			}
+			if previous != "" && previous != run.Status {
+				test.Flips++
+			}
+			previous = run.Status
+
+			if run.Commit != "" {
+				if commitStatuses[run.Commit] == nil {
+					commitStatuses[run.Commit] = make(map[string]bool)
+				}
+				commitStatuses[run.Commit][run.Status] = true
+			}
+		}
+
+		for _, statuses := range commitStatuses {
+			if statuses[jenkins_api.TestPassed] && statuses[jenkins_api.TestFailed] {
+				test.SameCommitFlips++
+			}
+		}
+
+		if test.SameCommitFlips == 0 && test.Flips < minFlips {
+			continue
+		}
+
+		if test.Runs > 1 {
+			test.FlipRate = percentage(test.Flips, test.Runs-1)
+		}
+		test.History = history.String()
+		test.Team = config.TeamOfJob(teams, testCase.JobName)
+		result = append(result, test)
+	}
+
+	// 切换越频繁越靠前
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].FlipRate != result[j].FlipRate {
+			return result[i].FlipRate > result[j].FlipRate
+		}
+		if result[i].SameCommitFlips != result[j].SameCommitFlips {
+			return result[i].SameCommitFlips > result[j].SameCommitFlips
+		}
+		return result[i].ClassName+"."+result[i].Name < result[j].ClassName+"."+result[j].Name
+	})
+	return result
+}
+
+type flakyGroupKey struct {
+	instance, job, team string
+}
+
+// 按 keyOf 返回的维度（实例+job 或 团队）分组，每组只保留最不稳定的 limit 个测试
+func groupFlakyTests(tests []*FlakyTest, limit int, keyOf func(*FlakyTest) flakyGroupKey) []*FlakyTestGroup {
+
+	groups := make(map[flakyGroupKey]*FlakyTestGroup)
+	var result []*FlakyTestGroup
+	for _, test := range tests {
+		key := keyOf(test)
+		group, ok := groups[key]
+		if !ok {
+			group = &FlakyTestGroup{
+				Provider:            ProviderJenkins,
+				JenkinsInstanceName: key.instance,
+				JobName:             key.job,
+				Team:                key.team,
+			}
+			groups[key] = group
+			result = append(result, group)
+		}
+
+		group.FlakyCount++
+		if len(group.Tests) < limit {
+			group.Tests = append(group.Tests, test)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].FlakyCount > result[j].FlakyCount
+	})
+	return result
+}
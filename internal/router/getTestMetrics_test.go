@@ -0,0 +1,85 @@
+﻿package router
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 按 statuses 中的字母（P/F/S）依次生成构建号与时间戳从 1 开始的测试结果，commits 按位置对应
+func testResults(statuses string, commits ...string) []store.JenkinsTestResult {
+	status := map[byte]string{'P': jenkins_api.TestPassed, 'F': jenkins_api.TestFailed, 'S': jenkins_api.TestSkipped}
+	results := make([]store.JenkinsTestResult, len(statuses))
+	for i := range statuses {
+		results[i] = store.JenkinsTestResult{Number: i + 1, Timestamp: int64(i + 1), Status: status[statuses[i]]}
+		if i < len(commits) {
+			results[i].Commit = commits[i]
+		}
+	}
+	return results
+}
+
+func TestDetectFlakyTests(t *testing.T) {
+	teams := map[string]config.Team{"pay": {Jobs: []string{"pay-*"}}}
+	testCase := func(name string, results []store.JenkinsTestResult) store.JenkinsTestCase {
+		return store.JenkinsTestCase{JenkinsInstanceName: "jenkins", JobName: "pay-api", ClassName: "pay.ApiTest", Name: name, Results: results}
+	}
+	flaky := func(name string, runs, failures, flips int, flipRate float64, sameCommitFlips, lastFailed int, history string) *FlakyTest {
+		return &FlakyTest{
+			JenkinsInstanceName: "jenkins", JobName: "pay-api", Team: "pay", ClassName: "pay.ApiTest", Name: name,
+			Runs: runs, Failures: failures, Flips: flips, FlipRate: flipRate, SameCommitFlips: sameCommitFlips, LastFailedBuild: lastFailed, History: history,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		results     []store.JenkinsTestResult
+		sinceMillis int64
+		minFlips    int
+		want        *FlakyTest
+	}{
+		{"stable", testResults("PPPP"), 0, 1, nil},
+		{"always failing", testResults("FFFF"), 0, 1, nil},
+		{"alternating", testResults("PFPF"), 0, 3, flaky("t", 4, 2, 3, 100, 0, 4, "PFPF")},
+		{"below min flips", testResults("PPFF"), 0, 2, nil},
+		{"partial flip rate", testResults("PPFFP"), 0, 2, flaky("t", 5, 2, 2, 50, 0, 4, "PPFFP")},
+		{"same commit flip below min flips", testResults("PF", "abc", "abc"), 0, 3, flaky("t", 2, 1, 1, 100, 1, 2, "PF")},
+		{"different commits below min flips", testResults("PF", "abc", "def"), 0, 3, nil},
+		{"skipped runs are ignored", testResults("PSFSP"), 0, 2, flaky("t", 3, 1, 2, 100, 0, 3, "PSFSP")},
+		{"runs before since are ignored", testResults("FPFPP"), 3, 2, nil},
+		{"window", testResults("PPFPF"), 3, 2, flaky("t", 3, 2, 2, 100, 0, 5, "FPF")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectFlakyTests([]store.JenkinsTestCase{testCase("t", tt.results)}, teams, tt.sinceMillis, tt.minFlips)
+			if tt.want == nil {
+				if len(got) != 0 {
+					t.Fatalf("detectFlakyTests() = %+v, want none", got[0])
+				}
+				return
+			}
+			if len(got) != 1 || !reflect.DeepEqual(got[0], tt.want) {
+				t.Fatalf("detectFlakyTests() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("order", func(t *testing.T) {
+		cases := []store.JenkinsTestCase{
+			testCase("b", testResults("PPFFP")),
+			testCase("c", testResults("PFPF")),
+			testCase("a", testResults("PPFFP", "", "x", "x")),
+			testCase("d", testResults("PFPF")),
+		}
+		var names []string
+		for _, test := range detectFlakyTests(cases, nil, 0, 2) {
+			names = append(names, test.Name)
+		}
+		if want := []string{"c", "d", "a", "b"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("order = %v, want %v", names, want)
+		}
+	})
+}
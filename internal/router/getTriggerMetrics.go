@@ -0,0 +1,128 @@
+﻿package router
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 默认统计最近 30 天的构建，失败发布最多的用户默认取前 10 名
+const (
+	defaultTriggerDays  = 30
+	defaultTriggerLimit = 10
+)
+
+// 按触发方式或触发用户统计的构建结果
+type TriggerStats struct {
+	Provider     string  `json:"provider"`
+	TriggerType  string  `json:"triggerType,omitempty"`
+	TriggeredBy  string  `json:"triggeredBy,omitempty"`
+	TotalCount   int     `json:"totalCount"`
+	SuccessCount int     `json:"successCount"`
+	FailureCount int     `json:"failureCount"`
+	FailureRate  float64 `json:"failureRate"`
+}
+
+// 构建触发方式分布，以及触发失败发布最多的用户。
+// deployOnly=true 时只统计 leadTime.deployJobs 配置的部署 job，用于区分手动热修复发布与自动发布
+func getTriggerMetricsHandler(c *gin.Context) {
+
+	days, err := positiveQuery(c, "days", defaultTriggerDays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, err := positiveQuery(c, "limit", defaultTriggerLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	leadTimeConfig, err := config.GetLeadTime()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "交付周期配置格式错误"})
+		return
+	}
+	deployOnly := c.Query("deployOnly") == "true"
+
+	sinceMillis := time.Now().AddDate(0, 0, -days).UnixMilli()
+
+	var builds []store.JenkinsBuild
+	for _, build := range dataStore.JenkinsBuilds() {
+		if build.Building || build.Timestamp < sinceMillis {
+			continue
+		}
+		if deployOnly && !leadTimeConfig.IsDeployJob(build.JobName) {
+			continue
+		}
+		builds = append(builds, build)
+	}
+
+	byType := calculateTriggerStats(builds, func(build store.JenkinsBuild) TriggerStats {
+		return TriggerStats{TriggerType: valueOrUnknown(build.TriggerType)}
+	})
+	sort.Slice(byType, func(i, j int) bool {
+		return byType[i].TotalCount > byType[j].TotalCount
+	})
+
+	// 只有能确定触发用户的构建才参与用户排名
+	var userBuilds []store.JenkinsBuild
+	for _, build := range builds {
+		if build.TriggeredBy != "" {
+			userBuilds = append(userBuilds, build)
+		}
+	}
+	byUser := calculateTriggerStats(userBuilds, func(build store.JenkinsBuild) TriggerStats {
+		return TriggerStats{TriggeredBy: build.TriggeredBy}
+	})
+	sort.Slice(byUser, func(i, j int) bool {
+		if byUser[i].FailureCount != byUser[j].FailureCount {
+			return byUser[i].FailureCount > byUser[j].FailureCount
+		}
+		return byUser[i].TriggeredBy < byUser[j].TriggeredBy
+	})
+	if len(byUser) > limit {
+		byUser = byUser[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{"构建触发方式指标": gin.H{
+		"triggerTypes":   byType,
+		"topFailedUsers": byUser,
+	}})
+}
+
+func calculateTriggerStats(builds []store.JenkinsBuild, keyOf func(store.JenkinsBuild) TriggerStats) []*TriggerStats {
+
+	resultMap := make(map[TriggerStats]*TriggerStats)
+	for _, build := range builds {
+		key := keyOf(build)
+		stats, ok := resultMap[key]
+		if !ok {
+			stats = &TriggerStats{
+				Provider:    ProviderJenkins,
+				TriggerType: key.TriggerType,
+				TriggeredBy: key.TriggeredBy,
+			}
+			resultMap[key] = stats
+		}
+
+		stats.TotalCount++
+		switch build.Result {
+		case "SUCCESS":
+			stats.SuccessCount++
+		case "FAILURE":
+			stats.FailureCount++
+		}
+	}
+
+	result := make([]*TriggerStats, 0, len(resultMap))
+	for _, stats := range resultMap {
+		stats.FailureRate = percentage(stats.FailureCount, stats.TotalCount)
+		result = append(result, stats)
+	}
+	return result
+}
@@ -0,0 +1,226 @@
+﻿package router
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 将事件写入数据模型，返回 false 表示事件比已有数据旧而被忽略
+type gitlabHookApplier func(s *store.Store) bool
+
+// 接收 GitLab 项目 webhook 与 system hook，路径中的 instance 对应 gitlab 配置下的实例名
+func gitlabWebhookHandler(c *gin.Context) {
+
+	gitlabInstanceName := strings.ToLower(c.Param("instance"))
+
+	gitlabConfigs, err := config.GetGitlabInstances()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "GitLab配置格式错误"})
+		return
+	}
+
+	gitlabConfig, ok := gitlabConfigs[gitlabInstanceName]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown gitlab instance"})
+		return
+	}
+
+	token := c.GetHeader(gitlab_api.HookTokenHeader)
+	if gitlabConfig.WebhookSecret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(gitlabConfig.WebhookSecret)) != 1 {
+		log.Warningf("GitLab实例[%s]的webhook校验失败, 来源: %s", gitlabInstanceName, c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var header gitlab_api.GitlabHookHeader
+	if err := json.Unmarshal(body, &header); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	apply, err := parseGitlabHook(gitlabInstanceName, header.Kind(), body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if apply == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	// GitLab 重发的事件使用相同的 UUID
+	if uuid := c.GetHeader(gitlab_api.HookEventUUIDHeader); uuid != "" && !dataStore.MarkEventSeen(uuid) {
+		c.JSON(http.StatusOK, gin.H{"status": "duplicate"})
+		return
+	}
+
+	if !apply(dataStore) {
+		c.JSON(http.StatusOK, gin.H{"status": "stale"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "accepted"})
+}
+
+// 解析事件并转换为数据模型，不关心的事件类型返回 nil
+func parseGitlabHook(gitlabInstanceName, kind string, body []byte) (gitlabHookApplier, error) {
+
+	switch kind {
+	case "push", "tag_push":
+		var hook gitlab_api.GitlabPushHook
+		if err := json.Unmarshal(body, &hook); err != nil {
+			return nil, err
+		}
+		push := store.GitlabPush{
+			GitlabInstanceName: gitlabInstanceName,
+			ProjectID:          hook.ProjectID,
+			ProjectPath:        hook.Project.PathWithNamespace,
+			Ref:                hook.Ref,
+			Before:             hook.Before,
+			After:              hook.After,
+			User:               hook.UserUsername,
+			CommitCount:        hook.TotalCommitsCount,
+			ReceivedAt:         time.Now(),
+		}
+		return func(s *store.Store) bool {
+			s.AddGitlabPush(push)
+			return true
+		}, nil
+
+	case "merge_request":
+		var hook gitlab_api.GitlabMergeRequestHook
+		if err := json.Unmarshal(body, &hook); err != nil {
+			return nil, err
+		}
+		attrs := hook.ObjectAttributes
+		mr := store.GitlabMergeRequest{
+			GitlabInstanceName: gitlabInstanceName,
+			ProjectID:          hook.Project.ID,
+			ProjectPath:        hook.Project.PathWithNamespace,
+			IID:                attrs.IID,
+			Title:              attrs.Title,
+			State:              attrs.State,
+			SourceBranch:       attrs.SourceBranch,
+			TargetBranch:       attrs.TargetBranch,
+			AuthorID:           attrs.AuthorID,
+			MergeCommitSHA:     attrs.MergeCommitSHA,
+			CreatedAt:          attrs.CreatedAt.Time,
+			UpdatedAt:          attrs.UpdatedAt.Time,
+		}
+		// 事件中没有 merged_at，merge 动作发生的时间即 updated_at
+		if attrs.Action == "merge" {
+			mr.MergedAt = attrs.UpdatedAt.Ptr()
+		}
+		return func(s *store.Store) bool {
+			return s.UpsertGitlabMergeRequest(mr)
+		}, nil
+
+	case "pipeline":
+		var hook gitlab_api.GitlabPipelineHook
+		if err := json.Unmarshal(body, &hook); err != nil {
+			return nil, err
+		}
+		attrs := hook.ObjectAttributes
+		pipeline := store.GitlabPipeline{
+			GitlabInstanceName: gitlabInstanceName,
+			ProjectID:          hook.Project.ID,
+			ProjectPath:        hook.Project.PathWithNamespace,
+			PipelineID:         attrs.ID,
+			Ref:                attrs.Ref,
+			SHA:                attrs.SHA,
+			Source:             attrs.Source,
+			Status:             attrs.Status,
+			CreatedAt:          attrs.CreatedAt.Time,
+			FinishedAt:         attrs.FinishedAt.Ptr(),
+			UpdatedAt:          attrs.CreatedAt.Time,
+		}
+		if attrs.Duration != nil {
+			pipeline.Duration = int(*attrs.Duration)
+		}
+		// 事件中没有 updated_at，用结束时间（未结束时用创建时间）表示状态发生的时间
+		if pipeline.FinishedAt != nil {
+			pipeline.UpdatedAt = *pipeline.FinishedAt
+		}
+		return func(s *store.Store) bool {
+			return s.UpsertGitlabPipeline(pipeline)
+		}, nil
+
+	case "build":
+		var hook gitlab_api.GitlabJobHook
+		if err := json.Unmarshal(body, &hook); err != nil {
+			return nil, err
+		}
+		job := store.GitlabJob{
+			GitlabInstanceName: gitlabInstanceName,
+			ProjectID:          hook.ProjectID,
+			ProjectPath:        hook.Project.PathWithNamespace,
+			JobID:              hook.BuildID,
+			PipelineID:         hook.PipelineID,
+			Name:               hook.BuildName,
+			Stage:              hook.BuildStage,
+			Status:             hook.BuildStatus,
+			Ref:                hook.Ref,
+			SHA:                hook.SHA,
+			AllowFailure:       hook.BuildAllowFailure,
+			FailureReason:      hook.BuildFailureReason,
+			CreatedAt:          hook.BuildCreatedAt.Time,
+			StartedAt:          hook.BuildStartedAt.Ptr(),
+			FinishedAt:         hook.BuildFinishedAt.Ptr(),
+			UpdatedAt:          hook.BuildCreatedAt.Time,
+		}
+		if hook.BuildDuration != nil {
+			job.Duration = *hook.BuildDuration
+		}
+		if hook.BuildQueuedDuration != nil {
+			job.QueuedDuration = *hook.BuildQueuedDuration
+		}
+		if job.FinishedAt != nil {
+			job.UpdatedAt = *job.FinishedAt
+		} else if job.StartedAt != nil {
+			job.UpdatedAt = *job.StartedAt
+		}
+		return func(s *store.Store) bool {
+			return s.UpsertGitlabJob(job)
+		}, nil
+
+	case "deployment":
+		var hook gitlab_api.GitlabDeploymentHook
+		if err := json.Unmarshal(body, &hook); err != nil {
+			return nil, err
+		}
+		deployment := store.GitlabDeployment{
+			GitlabInstanceName: gitlabInstanceName,
+			ProjectID:          hook.Project.ID,
+			ProjectPath:        hook.Project.PathWithNamespace,
+			DeploymentID:       hook.DeploymentID,
+			Environment:        hook.Environment,
+			Ref:                hook.Ref,
+			ShortSHA:           hook.ShortSHA,
+			Status:             hook.Status,
+			User:               hook.User.Username,
+			UpdatedAt:          hook.StatusChangedAt.Time,
+		}
+		return func(s *store.Store) bool {
+			return s.UpsertGitlabDeployment(deployment)
+		}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,139 @@
+﻿package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/gitlab_api"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 使用只保存在内存中的数据模型注册全部路由，并在测试结束后重置配置
+func newTestRouter(t *testing.T) (*gin.Engine, *store.Store) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	s := store.New("", 0)
+	engine := gin.New()
+	SetupAPIRouters(engine, s, nil)
+	return engine, s
+}
+
+// 发送 webhook 请求，返回状态码与响应中的 status
+func postHook(t *testing.T, engine *gin.Engine, path, body string, headers map[string]string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+
+	var response struct {
+		Status string `json:"status"`
+	}
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder.Code, response.Status
+}
+
+const gitlabPushHook = `{"object_kind": "push", "ref": "refs/heads/main", "before": "a", "after": "b", "project_id": 1, "project": {"id": 1, "path_with_namespace": "pay/api"}}`
+
+func gitlabPipelineHook(status, finishedAt string) string {
+	return `{"object_kind": "pipeline", "project": {"id": 1, "path_with_namespace": "pay/api"}, "object_attributes": {"id": 7, "ref": "main", "sha": "abc", "source": "push", "status": "` + status + `", "created_at": "2024-10-14 09:00:00 UTC", "finished_at": ` + finishedAt + `}}`
+}
+
+func TestGitlabWebhookToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		instance string
+		token    string
+		want     int
+	}{
+		{"valid token", "internal", "s3cret", http.StatusOK},
+		{"instance name is case insensitive", "Internal", "s3cret", http.StatusOK},
+		{"wrong token", "internal", "guess", http.StatusUnauthorized},
+		{"missing token", "internal", "", http.StatusUnauthorized},
+		{"instance without secret", "open", "", http.StatusUnauthorized},
+		{"unknown instance", "unknown", "s3cret", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, s := newTestRouter(t)
+			viper.Set(config.GitlabConfigKey, map[string]interface{}{
+				"internal": map[string]interface{}{"gitlabURL": "https://gitlab.example.com", "token": "t", "webhookSecret": "s3cret"},
+				"open":     map[string]interface{}{"gitlabURL": "https://gitlab.com", "token": "t"},
+			})
+
+			headers := map[string]string{}
+			if tt.token != "" {
+				headers[gitlab_api.HookTokenHeader] = tt.token
+			}
+			code, _ := postHook(t, engine, "/webhooks/gitlab/"+tt.instance, gitlabPushHook, headers)
+			if code != tt.want {
+				t.Errorf("status = %d, want %d", code, tt.want)
+			}
+			if pushes := s.GitlabPushes(); (code == http.StatusOK) != (len(pushes) == 1) {
+				t.Errorf("stored pushes = %+v", pushes)
+			}
+		})
+	}
+}
+
+func TestGitlabWebhookEvents(t *testing.T) {
+	engine, s := newTestRouter(t)
+	viper.Set(config.GitlabConfigKey, map[string]interface{}{
+		"internal": map[string]interface{}{"gitlabURL": "https://gitlab.example.com", "token": "t", "webhookSecret": "s3cret"},
+	})
+	post := func(body, uuid string) string {
+		headers := map[string]string{gitlab_api.HookTokenHeader: "s3cret"}
+		if uuid != "" {
+			headers[gitlab_api.HookEventUUIDHeader] = uuid
+		}
+		code, status := postHook(t, engine, "/webhooks/gitlab/internal", body, headers)
+		if code != http.StatusOK {
+			t.Fatalf("status code = %d", code)
+		}
+		return status
+	}
+
+	// 重发的事件带有相同的 UUID，只处理一次
+	if status := post(gitlabPushHook, "uuid-1"); status != "accepted" {
+		t.Errorf("first delivery: %s", status)
+	}
+	if status := post(gitlabPushHook, "uuid-1"); status != "duplicate" {
+		t.Errorf("redelivery: %s", status)
+	}
+	if status := post(gitlabPushHook, "uuid-2"); status != "accepted" {
+		t.Errorf("new event: %s", status)
+	}
+	if pushes := s.GitlabPushes(); len(pushes) != 2 {
+		t.Errorf("expected 2 pushes, got %+v", pushes)
+	}
+
+	// 乱序到达的旧状态不覆盖已有的最终状态
+	if status := post(gitlabPipelineHook("success", `"2024-10-14 09:10:00 UTC"`), "uuid-3"); status != "accepted" {
+		t.Errorf("finished pipeline: %s", status)
+	}
+	if status := post(gitlabPipelineHook("running", `null`), "uuid-4"); status != "stale" {
+		t.Errorf("late running event: %s", status)
+	}
+	if status := post(gitlabPipelineHook("failed", `"2024-10-14 09:20:00 UTC"`), "uuid-5"); status != "accepted" {
+		t.Errorf("retried pipeline: %s", status)
+	}
+	pipelines := s.GitlabPipelines()
+	if len(pipelines) != 1 || pipelines[0].Status != "failed" || pipelines[0].ProjectPath != "pay/api" {
+		t.Errorf("unexpected pipelines: %+v", pipelines)
+	}
+
+	if status := post(`{"object_kind": "wiki_page"}`, "uuid-6"); status != "ignored" {
+		t.Errorf("unsupported event: %s", status)
+	}
+}
@@ -0,0 +1,180 @@
+﻿package router
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 推送方式的校验信息：Notification 插件无法自定义请求头，可以在 URL 中携带 token；
+// 通用 webhook 可以使用 X-Jenkins-Token 请求头，或者用 X-Jenkins-Signature 携带请求体的 HMAC-SHA256 签名
+const (
+	jenkinsTokenHeader     = "X-Jenkins-Token"
+	jenkinsSignatureHeader = "X-Jenkins-Signature"
+)
+
+// 接收 Jenkins Notification 插件或通用构建后 webhook 推送的构建事件，路径中的 instance 对应 Jenkins 实例名
+func jenkinsWebhookHandler(c *gin.Context) {
+
+	jenkinsInstanceName := strings.ToLower(c.Param("instance"))
+	if config.IsReservedKey(jenkinsInstanceName) || !viper.IsSet(jenkinsInstanceName) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown jenkins instance"})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret := viper.GetString(jenkinsInstanceName + ".webhookSecret")
+	if !verifyJenkinsWebhook(c, secret, body) {
+		log.Warningf("Jenkins实例[%s]的推送校验失败, 来源: %s", jenkinsInstanceName, c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token or signature"})
+		return
+	}
+
+	var notification jenkins_api.JenkinsNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 排队事件只记录入队时间，用于计算构建的排队耗时
+	if strings.ToUpper(notification.Build.Phase) == jenkins_api.PhaseQueued {
+		if notification.Build.QueueID == 0 {
+			c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+			return
+		}
+		dataStore.UpsertJenkinsQueueItem(queueItemFromNotification(jenkinsInstanceName, notification))
+		c.JSON(http.StatusOK, gin.H{"status": "accepted"})
+		return
+	}
+
+	build, err := buildFromNotification(jenkinsInstanceName, notification)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if build == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	if !dataStore.UpsertJenkinsBuild(*build) {
+		c.JSON(http.StatusOK, gin.H{"status": "stale"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "accepted"})
+}
+
+// 未配置 webhookSecret 的实例拒绝所有推送
+func verifyJenkinsWebhook(c *gin.Context, secret string, body []byte) bool {
+	if secret == "" {
+		return false
+	}
+
+	if signature := c.GetHeader(jenkinsSignatureHeader); signature != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		expected := hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(strings.TrimPrefix(signature, "sha256=")), []byte(expected))
+	}
+
+	token := c.GetHeader(jenkinsTokenHeader)
+	if token == "" {
+		token = c.Query("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+func queueItemFromNotification(jenkinsInstanceName string, notification jenkins_api.JenkinsNotification) store.JenkinsQueueItem {
+	jobName := jenkins_api.JobNameFromURL(notification.URL)
+	if jobName == "" {
+		jobName = notification.Name
+	}
+
+	now := time.Now()
+	inQueueSince := notification.Build.Timestamp
+	if inQueueSince == 0 {
+		inQueueSince = now.UnixMilli()
+	}
+
+	return store.JenkinsQueueItem{
+		JenkinsInstanceName: jenkinsInstanceName,
+		QueueID:             notification.Build.QueueID,
+		JobName:             jobName,
+		InQueueSince:        inQueueSince,
+		InQueue:             true,
+		Source:              store.SourcePush,
+		LastSeenAt:          now,
+	}
+}
+
+// 将推送事件转换为数据模型，不关心的事件返回 nil
+func buildFromNotification(jenkinsInstanceName string, notification jenkins_api.JenkinsNotification) (*store.JenkinsBuild, error) {
+
+	jobName := jenkins_api.JobNameFromURL(notification.URL)
+	if jobName == "" {
+		jobName = notification.Name
+	}
+	if jobName == "" || notification.Build.Number == 0 {
+		return nil, fmt.Errorf("missing job name or build number")
+	}
+
+	build := &store.JenkinsBuild{
+		JenkinsInstanceName: jenkinsInstanceName,
+		JobName:             jobName,
+		Number:              notification.Build.Number,
+		URL:                 notification.Build.FullURL,
+		QueueID:             notification.Build.QueueID,
+		Timestamp:           notification.Build.Timestamp,
+		Duration:            notification.Build.Duration,
+		SCMURL:              notification.Build.SCM.URL,
+		Branch:              jenkins_api.NormalizeBranch(notification.Build.SCM.Branch),
+		Commit:              notification.Build.SCM.Commit,
+		Source:              store.SourcePush,
+		UpdatedAt:           time.Now(),
+	}
+
+	suffix := strconv.Itoa(build.Number) + "/"
+	if strings.HasSuffix(build.URL, suffix) {
+		build.JobURL = strings.TrimSuffix(build.URL, suffix)
+	}
+
+	if len(notification.Build.Parameters) > 0 {
+		build.Parameters = make(map[string]string, len(notification.Build.Parameters))
+		for name, value := range notification.Build.Parameters {
+			build.Parameters[name] = fmt.Sprint(value)
+		}
+	}
+
+	switch strings.ToUpper(notification.Build.Phase) {
+	case jenkins_api.PhaseStarted:
+		build.Building = true
+	case jenkins_api.PhaseCompleted, jenkins_api.PhaseFinalized:
+		build.Result = notification.Build.Status
+	default:
+		return nil, nil
+	}
+
+	return build, nil
+}
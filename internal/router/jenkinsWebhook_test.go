@@ -0,0 +1,65 @@
+﻿package router
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const jenkinsCompletedHook = `{"name": "api", "url": "job/api/", "build": {"full_url": "http://jenkins/job/api/1/", "number": 1, "timestamp": 1728896400000, "phase": "COMPLETED", "status": "SUCCESS"}}`
+
+func jenkinsSignature(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestJenkinsWebhookVerification(t *testing.T) {
+	tests := []struct {
+		name     string
+		instance string
+		query    string
+		headers  map[string]string
+		want     int
+	}{
+		{"hmac signature", "ci", "", map[string]string{jenkinsSignatureHeader: jenkinsSignature("s3cret", jenkinsCompletedHook)}, http.StatusOK},
+		{"hmac signature with prefix", "ci", "", map[string]string{jenkinsSignatureHeader: "sha256=" + jenkinsSignature("s3cret", jenkinsCompletedHook)}, http.StatusOK},
+		{"token header", "ci", "", map[string]string{jenkinsTokenHeader: "s3cret"}, http.StatusOK},
+		{"query token", "ci", "?token=s3cret", nil, http.StatusOK},
+		{"wrong signature", "ci", "", map[string]string{jenkinsSignatureHeader: jenkinsSignature("guess", jenkinsCompletedHook)}, http.StatusUnauthorized},
+		{"signature over another body", "ci", "", map[string]string{jenkinsSignatureHeader: jenkinsSignature("s3cret", "{}")}, http.StatusUnauthorized},
+		// 携带签名时只校验签名，正确的 token 不能绕过错误的签名
+		{"wrong signature with valid token", "ci", "?token=s3cret", map[string]string{jenkinsSignatureHeader: "deadbeef", jenkinsTokenHeader: "s3cret"}, http.StatusUnauthorized},
+		{"wrong token", "ci", "", map[string]string{jenkinsTokenHeader: "guess"}, http.StatusUnauthorized},
+		{"no credentials", "ci", "", nil, http.StatusUnauthorized},
+		{"empty secret", "empty", "?token=", map[string]string{jenkinsSignatureHeader: jenkinsSignature("", jenkinsCompletedHook)}, http.StatusUnauthorized},
+		{"unset secret", "unset", "", map[string]string{jenkinsTokenHeader: ""}, http.StatusUnauthorized},
+		{"unknown instance", "unknown", "?token=s3cret", nil, http.StatusNotFound},
+		{"reserved config key", "gitlab", "?token=s3cret", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, s := newTestRouter(t)
+			viper.Set("ci", map[string]interface{}{"jenkinsurl": "http://jenkins", "webhookSecret": "s3cret"})
+			viper.Set("empty", map[string]interface{}{"jenkinsurl": "http://jenkins", "webhookSecret": ""})
+			viper.Set("unset", map[string]interface{}{"jenkinsurl": "http://jenkins"})
+			viper.Set("gitlab", map[string]interface{}{})
+
+			code, status := postHook(t, engine, "/webhooks/jenkins/"+tt.instance+tt.query, jenkinsCompletedHook, tt.headers)
+			if code != tt.want {
+				t.Fatalf("status = %d, want %d", code, tt.want)
+			}
+			builds := s.JenkinsBuilds()
+			if code == http.StatusOK && (status != "accepted" || len(builds) != 1 || builds[0].Result != "SUCCESS") {
+				t.Errorf("status %q, stored builds %+v", status, builds)
+			}
+			if code != http.StatusOK && len(builds) != 0 {
+				t.Errorf("rejected push stored builds %+v", builds)
+			}
+		})
+	}
+}
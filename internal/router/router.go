@@ -1,18 +1,12 @@
 ﻿package router
 
 import (
-	"context"
-	"math"
 	"net/http"
-	"sync"
-	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
-	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api"
-	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/impl"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
 )
 
 type ResponseData struct {
@@ -31,8 +25,15 @@ type ResponseData struct {
 }
 
 type JenkinsJobStatsExtended struct {
-	JenkinsInstanceName string `json:"jenkinsInstanceName"`
-	JobName             string `json:"jobName"`
+	Provider            string              `json:"provider"`
+	JenkinsInstanceName string              `json:"jenkinsInstanceName"`
+	JobName             string              `json:"jobName"`
+	TriggerType         string              `json:"triggerType,omitempty"`
+	TriggeredBy         string              `json:"triggeredBy,omitempty"`
+	Branch              string              `json:"branch,omitempty"`
+	BranchClass         string              `json:"branchClass,omitempty"`
+	Team                string              `json:"team,omitempty"`
+	Parameters          dimensionParameters `json:"parameters,omitempty"`
 
 	// 当天的统计信息
 	TodaySuccessCount int     `json:"todaySuccessCount"`
@@ -49,207 +50,120 @@ type JenkinsJobStatsExtended struct {
 	CurrentMonthFailureRate  float64 `json:"currentMonthFailureRate"`
 }
 
-func SetupAPIRouters(r *gin.Engine) {
+// 平台数据模型，由 SetupAPIRouters 注入
+var dataStore *store.Store
+
+// 实时查询 Jenkins、GitLab 的接口使用的 http client，为空时使用默认 client
+var httpClient *http.Client
+
+func SetupAPIRouters(r *gin.Engine, s *store.Store, client *http.Client) {
+	dataStore = s
+	httpClient = client
+
 	r.GET("/metrics", getMetricsHandler)
+	r.GET("/metrics/gitlab", getGitlabMetricsHandler)
+	r.GET("/metrics/gitlab/merge-requests", getMergeRequestMetricsHandler)
+	r.GET("/metrics/lead-time", getLeadTimeMetricsHandler)
+	r.GET("/metrics/queue", getQueueMetricsHandler)
+	r.GET("/metrics/agents", getAgentMetricsHandler)
+	r.GET("/metrics/stages", getStageMetricsHandler)
+	r.GET("/metrics/tests", getTestMetricsHandler)
+	r.GET("/metrics/tests/flaky", getFlakyTestMetricsHandler)
+	r.GET("/metrics/triggers", getTriggerMetricsHandler)
+	r.GET("/metrics/branches", getBranchMetricsHandler)
+	r.GET("/metrics/failures", getFailureMetricsHandler)
+	r.GET("/metrics/red", getRedJobsHandler)
+	r.GET("/metrics/running", getRunningBuildsHandler)
+	r.GET("/metrics/anomalies", getAnomaliesHandler)
+	r.GET("/metrics/heatmap", getHeatmapHandler)
+	r.GET("/metrics/off-hours", getOffHoursBuildsHandler)
+
+	r.GET("/api/v1/instances", getInstancesHandler)
+	r.GET("/api/v1/alerts", getAlertsHandler)
+	r.GET("/api/v1/silences", getSilencesHandler)
+	r.POST("/api/v1/silences", requireSilenceToken, createSilenceHandler)
+	r.DELETE("/api/v1/silences/:id", requireSilenceToken, expireSilenceHandler)
+
+	r.POST("/webhooks/gitlab/:instance", gitlabWebhookHandler)
+	r.POST("/webhooks/jenkins/:instance", jenkinsWebhookHandler)
 }
 
 func getMetricsHandler(c *gin.Context) {
 
-	jenkinsConfigs := viper.AllSettings()
-	buildsHistoryMap := make(map[string][]jenkins_api.JenkinsBuildsHistoryMap)
-	var responseData []interface{}
-	jenkinsService := &impl.JenkinsServiceImpl{}
-
-	log.Println("正在从'etc/config.yaml'配置文件中获取Jenkins实例的用户名、密码以及访问地址...")
+	dimensions, err := parseGroupBy(c, defaultGroupBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	for jenkinsInstanceName, jenkinsConfig := range jenkinsConfigs {
+	millis, todayMillis, firstDayOfMonthMillis := statsWindow()
 
-		//读取 Jenkins 配置信息
-		jobs_config, err := GetJenkinsConfig(jenkinsConfig)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Jenkins配置格式错误"})
-			return
-		}
+	resultMap := make(map[BuildDimensions]*JenkinsJobStatsExtended)
 
-		log.Printf("正在从Jenkins实例[%s]中获取Jobs信息...", jenkinsInstanceName)
-		// 调用 GetAllJobs 方法获取 Jenkins 中的 jobs 信息
-		jobs, err := jenkinsService.GetAllJobs(context.Background(), jobs_config)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	// 构建数据由后台采集与 Notification 插件推送写入，这里只做统计
+	for _, item := range dataStore.JenkinsBuilds() {
 
-		// 检查Jobs列表是否为空或其第一个元素的JobsURL属性是否为空字符串
-		if jobs.Jobs == nil || jobs.Jobs[0].JobsURL == "" {
-			log.Warningf("对于%s实例, job.Jobs为nil或jobs.Jobs的第一个元素的JobsURL为空字符串", jenkinsInstanceName)
+		// 构建中的任务没有结果，不参与统计
+		if item.Building {
 			continue
 		}
 
-		var wg sync.WaitGroup
-		resultCh := make(chan gin.H, len(jobs.Jobs))
-
-		//填充 jobsMap 逻辑
-		for _, job := range jobs.Jobs {
-			wg.Add(1)
-
-			// 启动一个goroutine来处理每个job
-			go func(job jenkins_api.JenkinsJobs) {
-				defer wg.Done()
-
-				jobURL := job.JobsURL
-				builds_config := &jenkins_api.JenkinsBuildsRequest{
-					JobURL:   jobURL,
-					Username: jobs_config.JenkinsBaseRequest.Username,
-					Password: jobs_config.JenkinsBaseRequest.Password,
-				}
-				buildsHistory, err := jenkinsService.GetBuildsHistory(context.Background(), builds_config)
-				if err != nil {
-					resultCh <- gin.H{"error": err.Error()}
-					return
-				}
-
-				var buildData []JenkinsBuildsResponse
-				for _, build := range buildsHistory.Builds {
-					data := getBuildInfo(c, jenkinsInstanceName, job.Name, job.JobsURL, job, build, buildsHistoryMap, jobs_config.JenkinsBaseRequest.Username, jobs_config.JenkinsBaseRequest.Password)
-					buildData = append(buildData, data)
-				}
-				//log.Printf("goroutine: jenkins实例: %v,  builds: %v", jenkinsInstanceName, buildData)
-
-				// 将结果发送到channel
-				resultCh <- gin.H{"builds": buildData}
-			}(job)
+		inToday := item.Timestamp >= todayMillis && item.Timestamp <= millis
+		inMonth := item.Timestamp >= firstDayOfMonthMillis && item.Timestamp <= millis
+		if !inToday && !inMonth {
+			continue
 		}
 
-		wg.Wait()
-
-		close(resultCh)
-
-		// 从channel中读取结果并处理它们
-		for result := range resultCh {
-			if errStr, ok := result["error"]; ok {
-				// 处理错误
-				c.JSON(http.StatusInternalServerError, gin.H{"error": errStr})
-				return
-			} else if errNull, ok := result["null"]; ok {
-				// 处理错误
-				c.JSON(http.StatusInternalServerError, gin.H{"error": errNull})
-				continue
-			} else {
-				// 处理结果
-				builds := result["builds"].([]JenkinsBuildsResponse)
-				responseData = append(responseData, builds)
+		key := dimensions.dimensionsOf(item)
+		stats, ok := resultMap[key]
+		if !ok {
+			stats = &JenkinsJobStatsExtended{
+				Provider:            ProviderJenkins,
+				JenkinsInstanceName: key.JenkinsInstanceName,
+				JobName:             key.JobName,
+				TriggerType:         key.TriggerType,
+				TriggeredBy:         key.TriggeredBy,
+				Branch:              key.Branch,
+				BranchClass:         key.BranchClass,
+				Team:                key.Team,
+				Parameters:          key.Parameters,
 			}
+			resultMap[key] = stats
 		}
-	}
-
-	now := time.Now()
-	millis := now.UnixNano() / int64(time.Millisecond)
-
-	todayStart := time.Now().Truncate(24 * time.Hour)
-	todayMillis := todayStart.UnixNano() / int64(time.Millisecond)
-
-	// 计算当前月份的第一天
-	firstDayOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	firstDayOfMonthMillis := firstDayOfMonth.UnixNano() / int64(time.Millisecond)
-
-	resultMap := make(map[string]map[string]*JenkinsJobStatsExtended)
-
-	// 遍历构建数据并计算成功和失败次数
-	for _, buildItem := range responseData {
-
-		if buildItem == nil {
-			log.Printf("构建项 (buildItem) 为空, 可能尚未发布过任何内容。")
-			continue
-		}
-
-		// 遍历每个构建
-		if build, ok := buildItem.([]JenkinsBuildsResponse); ok {
-			for _, items := range build {
-				for _, item := range items.Jobs {
 
-					//计算当日的发布次数
-					if item.Timestamp >= todayMillis && item.Timestamp <= millis {
-						if _, ok := resultMap[item.JenkinsInstanceName]; !ok {
-							resultMap[item.JenkinsInstanceName] = make(map[string]*JenkinsJobStatsExtended)
-						}
-						if _, ok := resultMap[item.JenkinsInstanceName][item.JobName]; !ok {
-							resultMap[item.JenkinsInstanceName][item.JobName] = &JenkinsJobStatsExtended{
-								JenkinsInstanceName: item.JenkinsInstanceName,
-								JobName:             item.JobName,
-								TodaySuccessCount:   0,
-								TodayFailureCount:   0,
-								TodaySuccessRate:    0.0,
-								TodayFailureRate:    0.0,
-								TodayTotalCount:     0,
-							}
-						}
-						//更新成功或失败次数
-						switch item.Result {
-						case "SUCCESS":
-							resultMap[item.JenkinsInstanceName][item.JobName].TodaySuccessCount++
-						case "FAILURE":
-							resultMap[item.JenkinsInstanceName][item.JobName].TodayFailureCount++
-						default:
-							// 处理未知Result，例如记录日志或增加错误计数
-							log.Printf("Unknown result for job %s in instance %s: %s", item.JobName, item.JenkinsInstanceName, item.Result)
-						}
-						resultMap[item.JenkinsInstanceName][item.JobName].TodayTotalCount = resultMap[item.JenkinsInstanceName][item.JobName].TodaySuccessCount + resultMap[item.JenkinsInstanceName][item.JobName].TodayFailureCount
-
-						if resultMap[item.JenkinsInstanceName][item.JobName].TodayTotalCount > 0 {
-							resultMap[item.JenkinsInstanceName][item.JobName].TodaySuccessRate = math.Round(float64(resultMap[item.JenkinsInstanceName][item.JobName].TodaySuccessCount)/float64(resultMap[item.JenkinsInstanceName][item.JobName].TodayTotalCount)*100.0*1e2) / 1e2
-							resultMap[item.JenkinsInstanceName][item.JobName].TodayFailureRate = math.Round(float64(resultMap[item.JenkinsInstanceName][item.JobName].TodayFailureCount)/float64(resultMap[item.JenkinsInstanceName][item.JobName].TodayTotalCount)*100.0*1e2) / 1e2
-						}
-					}
-
-					//计算当前月的发布次数
-					if item.Timestamp >= firstDayOfMonthMillis && item.Timestamp <= millis {
-						if _, ok := resultMap[item.JenkinsInstanceName]; !ok {
-							resultMap[item.JenkinsInstanceName] = make(map[string]*JenkinsJobStatsExtended)
-						}
-						if _, ok := resultMap[item.JenkinsInstanceName][item.JobName]; !ok {
-							resultMap[item.JenkinsInstanceName][item.JobName] = &JenkinsJobStatsExtended{
-								JenkinsInstanceName:      item.JenkinsInstanceName,
-								JobName:                  item.JobName,
-								CurrentMonthSuccessCount: 0,
-								CurrentMonthFailureCount: 0,
-								CurrentMonthTotalCount:   0,
-								CurrentMonthSuccessRate:  0.0,
-								CurrentMonthFailureRate:  0.0,
-							}
-						}
-						//更新成功或失败次数
-						switch item.Result {
-						case "SUCCESS":
-							resultMap[item.JenkinsInstanceName][item.JobName].CurrentMonthSuccessCount++
-						case "FAILURE":
-							resultMap[item.JenkinsInstanceName][item.JobName].CurrentMonthFailureCount++
-						default:
-							// 处理未知Result，例如记录日志或增加错误计数
-							log.Printf("Unknown result for job %s in instance %s: %s", item.JobName, item.JenkinsInstanceName, item.Result)
-						}
-						resultMap[item.JenkinsInstanceName][item.JobName].CurrentMonthTotalCount = resultMap[item.JenkinsInstanceName][item.JobName].CurrentMonthSuccessCount + resultMap[item.JenkinsInstanceName][item.JobName].CurrentMonthFailureCount
-
-						if resultMap[item.JenkinsInstanceName][item.JobName].CurrentMonthTotalCount > 0 {
-							resultMap[item.JenkinsInstanceName][item.JobName].CurrentMonthSuccessRate = math.Round(float64(resultMap[item.JenkinsInstanceName][item.JobName].CurrentMonthSuccessCount)/float64(resultMap[item.JenkinsInstanceName][item.JobName].CurrentMonthTotalCount)*100.0*1e2) / 1e2
-							resultMap[item.JenkinsInstanceName][item.JobName].CurrentMonthFailureRate = math.Round(float64(resultMap[item.JenkinsInstanceName][item.JobName].CurrentMonthFailureCount)/float64(resultMap[item.JenkinsInstanceName][item.JobName].CurrentMonthTotalCount)*100.0*1e2) / 1e2
-						}
-					}
-				}
+		//更新成功或失败次数
+		switch item.Result {
+		case "SUCCESS":
+			if inToday {
+				stats.TodaySuccessCount++
+			}
+			if inMonth {
+				stats.CurrentMonthSuccessCount++
+			}
+		case "FAILURE":
+			if inToday {
+				stats.TodayFailureCount++
 			}
-		} else {
-			// 处理buildItem不是JenkinsBuildsResponse的情况
-			log.Printf("处理buildItem不是JenkinsBuildsResponse的情况: %v", buildItem)
+			if inMonth {
+				stats.CurrentMonthFailureCount++
+			}
+		default:
+			// 处理未知Result，例如记录日志或增加错误计数
+			log.Printf("Unknown result for job %s in instance %s: %s", item.JobName, item.JenkinsInstanceName, item.Result)
 		}
 	}
 
-	//遍历 stats，将 jobName 下的层级append到切片字符串中
 	var stats []*JenkinsJobStatsExtended
-	for _, jobName := range resultMap {
-		log.Printf("jobName: %v\n", jobName)
-		for _, job := range jobName {
-			log.Printf("job: %v\n", job)
-			stats = append(stats, job)
-		}
+	for _, job := range resultMap {
+		job.TodayTotalCount = job.TodaySuccessCount + job.TodayFailureCount
+		job.TodaySuccessRate = percentage(job.TodaySuccessCount, job.TodayTotalCount)
+		job.TodayFailureRate = percentage(job.TodayFailureCount, job.TodayTotalCount)
+
+		job.CurrentMonthTotalCount = job.CurrentMonthSuccessCount + job.CurrentMonthFailureCount
+		job.CurrentMonthSuccessRate = percentage(job.CurrentMonthSuccessCount, job.CurrentMonthTotalCount)
+		job.CurrentMonthFailureRate = percentage(job.CurrentMonthFailureCount, job.CurrentMonthTotalCount)
+
+		stats = append(stats, job)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"持续集成发布稳定性指标": stats})
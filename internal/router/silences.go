@@ -0,0 +1,154 @@
+﻿package router
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
+)
+
+// 创建静默的请求，EndsAt 与 Duration 二选一，StartsAt 为空时立即生效
+type SilenceRequest struct {
+	Matchers  []store.Matcher `json:"matchers"`
+	StartsAt  *time.Time      `json:"startsAt"`
+	EndsAt    *time.Time      `json:"endsAt"`
+	Duration  string          `json:"duration"`
+	CreatedBy string          `json:"createdBy"`
+	Comment   string          `json:"comment"`
+}
+
+// 带状态的静默
+type SilenceStatus struct {
+	store.Silence
+	State string `json:"state"`
+}
+
+// 列出静默，可以通过 state 查询参数筛选，如 state=active
+func getSilencesHandler(c *gin.Context) {
+
+	state := c.Query("state")
+	switch state {
+	case "", store.SilencePending, store.SilenceActive, store.SilenceExpired:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid state"})
+		return
+	}
+
+	now := time.Now()
+	silences := make([]SilenceStatus, 0)
+	for _, silence := range dataStore.Silences() {
+		status := SilenceStatus{Silence: silence, State: silence.State(now)}
+		if state != "" && status.State != state {
+			continue
+		}
+		silences = append(silences, status)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"静默": silences})
+}
+
+// 创建、结束静默需要携带 silences.token，未配置 token 时拒绝所有修改
+func requireSilenceToken(c *gin.Context) {
+
+	silencesConfig, err := config.GetSilences()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "静默配置格式错误"})
+		return
+	}
+
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if silencesConfig.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(silencesConfig.Token)) != 1 {
+		log.Warningf("静默接口鉴权失败, 来源: %s", c.ClientIP())
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+}
+
+func createSilenceHandler(c *gin.Context) {
+
+	var req SilenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	silence, err := req.silence(now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	silence, err = dataStore.AddSilence(silence)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"静默": SilenceStatus{Silence: silence, State: silence.State(now)}})
+}
+
+// 提前结束静默
+func expireSilenceHandler(c *gin.Context) {
+
+	now := time.Now()
+	silence, ok := dataStore.ExpireSilence(c.Param("id"), now)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown silence"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"静默": SilenceStatus{Silence: silence, State: silence.State(now)}})
+}
+
+func (r *SilenceRequest) silence(now time.Time) (store.Silence, error) {
+	if len(r.Matchers) == 0 {
+		return store.Silence{}, errors.New("at least one matcher is required")
+	}
+	for i := range r.Matchers {
+		if err := r.Matchers[i].Compile(); err != nil {
+			return store.Silence{}, err
+		}
+	}
+	if r.CreatedBy == "" || r.Comment == "" {
+		return store.Silence{}, errors.New("createdBy and comment are required")
+	}
+
+	startsAt := now
+	if r.StartsAt != nil && r.StartsAt.After(now) {
+		startsAt = *r.StartsAt
+	}
+
+	var endsAt time.Time
+	switch {
+	case r.EndsAt != nil && r.Duration != "":
+		return store.Silence{}, errors.New("endsAt and duration are mutually exclusive")
+	case r.EndsAt != nil:
+		endsAt = *r.EndsAt
+	case r.Duration != "":
+		duration, err := time.ParseDuration(r.Duration)
+		if err != nil || duration <= 0 {
+			return store.Silence{}, errors.New("invalid duration")
+		}
+		endsAt = startsAt.Add(duration)
+	default:
+		return store.Silence{}, errors.New("endsAt or duration is required")
+	}
+	if !endsAt.After(startsAt) {
+		return store.Silence{}, errors.New("endsAt must be after startsAt")
+	}
+
+	return store.Silence{
+		Matchers:  r.Matchers,
+		StartsAt:  startsAt,
+		EndsAt:    endsAt,
+		CreatedBy: r.CreatedBy,
+		Comment:   r.Comment,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
@@ -0,0 +1,35 @@
+﻿package router
+
+import (
+	"math"
+	"time"
+)
+
+// 统计指标的数据来源
+const (
+	ProviderJenkins = "jenkins"
+	ProviderGitlab  = "gitlab"
+)
+
+// 返回当前时间、当天起始时间以及当月第一天的毫秒时间戳，Jenkins 与 GitLab 指标共用同一套统计窗口
+func statsWindow() (int64, int64, int64) {
+	now := time.Now()
+	millis := now.UnixNano() / int64(time.Millisecond)
+
+	todayStart := time.Now().Truncate(24 * time.Hour)
+	todayMillis := todayStart.UnixNano() / int64(time.Millisecond)
+
+	// 计算当前月份的第一天
+	firstDayOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	firstDayOfMonthMillis := firstDayOfMonth.UnixNano() / int64(time.Millisecond)
+
+	return millis, todayMillis, firstDayOfMonthMillis
+}
+
+// 计算百分比，保留两位小数
+func percentage(count, total int) float64 {
+	if total == 0 {
+		return 0.0
+	}
+	return math.Round(float64(count)/float64(total)*100.0*1e2) / 1e2
+}
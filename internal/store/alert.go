@@ -0,0 +1,62 @@
+﻿package store
+
+import (
+	"sort"
+	"time"
+)
+
+// 告警状态
+const (
+	AlertPending  = "pending"
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// 一条告警，同一规则的不同分组（Labels 不同）是不同的告警
+type Alert struct {
+	Fingerprint string            `json:"fingerprint"`
+	Rule        string            `json:"rule"`
+	Severity    string            `json:"severity"`
+	State       string            `json:"state"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// 最近一次评估得到的值，如失败率、连续失败次数、距离上次成功的小时数
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+
+	// 条件开始满足的时间
+	ActiveAt    time.Time  `json:"activeAt"`
+	FiredAt     *time.Time `json:"firedAt,omitempty"`
+	ResolvedAt  *time.Time `json:"resolvedAt,omitempty"`
+	EvaluatedAt time.Time  `json:"evaluatedAt"`
+}
+
+func (s *Store) Alerts() []Alert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Alert, 0, len(s.alerts))
+	for _, alert := range s.alerts {
+		result = append(result, *alert)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Rule != result[j].Rule {
+			return result[i].Rule < result[j].Rule
+		}
+		return result[i].Fingerprint < result[j].Fingerprint
+	})
+	return result
+}
+
+// 写入一轮评估后的全部告警
+func (s *Store) ReplaceAlerts(alerts []Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.alerts = make(map[string]*Alert, len(alerts))
+	for i := range alerts {
+		alert := alerts[i]
+		s.alerts[alert.Fingerprint] = &alert
+	}
+	s.dirty = true
+}
@@ -0,0 +1,299 @@
+﻿package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// push 事件最多保留的条数
+const maxGitlabPushes = 10000
+
+type GitlabPipeline struct {
+	GitlabInstanceName string     `json:"gitlabInstanceName"`
+	ProjectID          int        `json:"projectId"`
+	ProjectPath        string     `json:"projectPath"`
+	PipelineID         int        `json:"pipelineId"`
+	Ref                string     `json:"ref"`
+	SHA                string     `json:"sha"`
+	Source             string     `json:"source"`
+	Status             string     `json:"status"`
+	Duration           int        `json:"duration"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	FinishedAt         *time.Time `json:"finishedAt"`
+	// 当前状态发生的时间，用于处理乱序到达的事件
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (p *GitlabPipeline) key() string {
+	return fmt.Sprintf("%s/%d/%d", p.GitlabInstanceName, p.ProjectID, p.PipelineID)
+}
+
+type GitlabMergeRequest struct {
+	GitlabInstanceName string     `json:"gitlabInstanceName"`
+	ProjectID          int        `json:"projectId"`
+	ProjectPath        string     `json:"projectPath"`
+	IID                int        `json:"iid"`
+	Title              string     `json:"title"`
+	State              string     `json:"state"`
+	SourceBranch       string     `json:"sourceBranch"`
+	TargetBranch       string     `json:"targetBranch"`
+	AuthorID           int        `json:"authorId"`
+	MergeCommitSHA     string     `json:"mergeCommitSha"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+	MergedAt           *time.Time `json:"mergedAt"`
+}
+
+func (m *GitlabMergeRequest) key() string {
+	return fmt.Sprintf("%s/%d/%d", m.GitlabInstanceName, m.ProjectID, m.IID)
+}
+
+// 已合并 MR 的评审数据，由后台采集根据 MR 的评论与 diff 计算，时间单位均为小时
+type GitlabMergeRequestReview struct {
+	GitlabInstanceName string    `json:"gitlabInstanceName"`
+	ProjectID          int       `json:"projectId"`
+	ProjectPath        string    `json:"projectPath"`
+	IID                int       `json:"iid"`
+	Author             string    `json:"author"`
+	MergedAt           time.Time `json:"mergedAt"`
+
+	TimeToFirstReview *float64 `json:"timeToFirstReview"`
+	TimeToApproval    *float64 `json:"timeToApproval"`
+	TimeToMerge       float64  `json:"timeToMerge"`
+	LinesChanged      int      `json:"linesChanged"`
+	FilesChanged      int      `json:"filesChanged"`
+	ReviewRounds      int      `json:"reviewRounds"`
+	Approved          bool     `json:"approved"`
+}
+
+func (r *GitlabMergeRequestReview) key() string {
+	return mergeRequestReviewKey(r.GitlabInstanceName, r.ProjectID, r.IID)
+}
+
+func mergeRequestReviewKey(gitlabInstanceName string, projectID, iid int) string {
+	return fmt.Sprintf("%s/%d/%d", gitlabInstanceName, projectID, iid)
+}
+
+type GitlabJob struct {
+	GitlabInstanceName string     `json:"gitlabInstanceName"`
+	ProjectID          int        `json:"projectId"`
+	ProjectPath        string     `json:"projectPath"`
+	JobID              int        `json:"jobId"`
+	PipelineID         int        `json:"pipelineId"`
+	Name               string     `json:"name"`
+	Stage              string     `json:"stage"`
+	Status             string     `json:"status"`
+	Ref                string     `json:"ref"`
+	SHA                string     `json:"sha"`
+	AllowFailure       bool       `json:"allowFailure"`
+	FailureReason      string     `json:"failureReason"`
+	Duration           float64    `json:"duration"`
+	QueuedDuration     float64    `json:"queuedDuration"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	StartedAt          *time.Time `json:"startedAt"`
+	FinishedAt         *time.Time `json:"finishedAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+}
+
+func (j *GitlabJob) key() string {
+	return fmt.Sprintf("%s/%d/%d", j.GitlabInstanceName, j.ProjectID, j.JobID)
+}
+
+type GitlabDeployment struct {
+	GitlabInstanceName string `json:"gitlabInstanceName"`
+	ProjectID          int    `json:"projectId"`
+	ProjectPath        string `json:"projectPath"`
+	DeploymentID       int    `json:"deploymentId"`
+	Environment        string `json:"environment"`
+	Ref                string `json:"ref"`
+	// deployment 事件只带短 SHA，与流水线、构建的完整 SHA 关联时需要按前缀匹配
+	ShortSHA  string    `json:"shortSha"`
+	Status    string    `json:"status"`
+	User      string    `json:"user"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (d *GitlabDeployment) key() string {
+	return fmt.Sprintf("%s/%d/%d", d.GitlabInstanceName, d.ProjectID, d.DeploymentID)
+}
+
+type GitlabPush struct {
+	GitlabInstanceName string    `json:"gitlabInstanceName"`
+	ProjectID          int       `json:"projectId"`
+	ProjectPath        string    `json:"projectPath"`
+	Ref                string    `json:"ref"`
+	Before             string    `json:"before"`
+	After              string    `json:"after"`
+	User               string    `json:"user"`
+	CommitCount        int       `json:"commitCount"`
+	ReceivedAt         time.Time `json:"receivedAt"`
+}
+
+// 流水线、job、部署的状态先后顺序，终态排在最后
+func statusRank(status string) int {
+	switch status {
+	case "created", "waiting_for_resource", "preparing", "pending", "scheduled", "manual", "blocked":
+		return 1
+	case "running":
+		return 2
+	case "success", "failed", "canceled", "skipped", "canceling":
+		return 3
+	default:
+		return 0
+	}
+}
+
+// 写入流水线，返回 false 表示数据比已有数据旧而被忽略
+func (s *Store) UpsertGitlabPipeline(pipeline GitlabPipeline) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := pipeline.key()
+	if existing, ok := s.gitlabPipelines[key]; ok {
+		if !isNewer(existing.UpdatedAt, statusRank(existing.Status), pipeline.UpdatedAt, statusRank(pipeline.Status)) {
+			return false
+		}
+	}
+	s.gitlabPipelines[key] = &pipeline
+	s.dirty = true
+	return true
+}
+
+func (s *Store) UpsertGitlabMergeRequest(mr GitlabMergeRequest) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := mr.key()
+	if existing, ok := s.gitlabMergeRequests[key]; ok {
+		if !isNewer(existing.UpdatedAt, 0, mr.UpdatedAt, 0) {
+			return false
+		}
+		// 合并时间只在 merge 事件中出现，后续事件不应将其清空
+		if mr.MergedAt == nil {
+			mr.MergedAt = existing.MergedAt
+		}
+	}
+	s.gitlabMergeRequests[key] = &mr
+	s.dirty = true
+	return true
+}
+
+// 已合并 MR 的评审数据不再变化，写入后采集不再重复计算
+func (s *Store) AddGitlabMergeRequestReview(review GitlabMergeRequestReview) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gitlabMergeRequestReviews[review.key()] = &review
+	s.dirty = true
+}
+
+func (s *Store) HasGitlabMergeRequestReview(gitlabInstanceName string, projectID, iid int) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.gitlabMergeRequestReviews[mergeRequestReviewKey(gitlabInstanceName, projectID, iid)]
+	return ok
+}
+
+func (s *Store) UpsertGitlabJob(job GitlabJob) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := job.key()
+	if existing, ok := s.gitlabJobs[key]; ok {
+		if !isNewer(existing.UpdatedAt, statusRank(existing.Status), job.UpdatedAt, statusRank(job.Status)) {
+			return false
+		}
+	}
+	s.gitlabJobs[key] = &job
+	s.dirty = true
+	return true
+}
+
+func (s *Store) UpsertGitlabDeployment(deployment GitlabDeployment) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := deployment.key()
+	if existing, ok := s.gitlabDeployments[key]; ok {
+		if !isNewer(existing.UpdatedAt, statusRank(existing.Status), deployment.UpdatedAt, statusRank(deployment.Status)) {
+			return false
+		}
+	}
+	s.gitlabDeployments[key] = &deployment
+	s.dirty = true
+	return true
+}
+
+func (s *Store) AddGitlabPush(push GitlabPush) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gitlabPushes = append(s.gitlabPushes, push)
+	if len(s.gitlabPushes) > maxGitlabPushes {
+		s.gitlabPushes = s.gitlabPushes[len(s.gitlabPushes)-maxGitlabPushes:]
+	}
+	s.dirty = true
+}
+
+func (s *Store) GitlabPipelines() []GitlabPipeline {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pipelines := make([]GitlabPipeline, 0, len(s.gitlabPipelines))
+	for _, pipeline := range s.gitlabPipelines {
+		pipelines = append(pipelines, *pipeline)
+	}
+	return pipelines
+}
+
+func (s *Store) GitlabMergeRequests() []GitlabMergeRequest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	mergeRequests := make([]GitlabMergeRequest, 0, len(s.gitlabMergeRequests))
+	for _, mr := range s.gitlabMergeRequests {
+		mergeRequests = append(mergeRequests, *mr)
+	}
+	return mergeRequests
+}
+
+func (s *Store) GitlabMergeRequestReviews() []GitlabMergeRequestReview {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reviews := make([]GitlabMergeRequestReview, 0, len(s.gitlabMergeRequestReviews))
+	for _, review := range s.gitlabMergeRequestReviews {
+		reviews = append(reviews, *review)
+	}
+	return reviews
+}
+
+func (s *Store) GitlabJobs() []GitlabJob {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]GitlabJob, 0, len(s.gitlabJobs))
+	for _, job := range s.gitlabJobs {
+		jobs = append(jobs, *job)
+	}
+	return jobs
+}
+
+func (s *Store) GitlabDeployments() []GitlabDeployment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deployments := make([]GitlabDeployment, 0, len(s.gitlabDeployments))
+	for _, deployment := range s.gitlabDeployments {
+		deployments = append(deployments, *deployment)
+	}
+	return deployments
+}
+
+func (s *Store) GitlabPushes() []GitlabPush {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]GitlabPush(nil), s.gitlabPushes...)
+}
@@ -0,0 +1,240 @@
+﻿package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 构建数据来源
+const (
+	SourcePoll = "poll"
+	SourcePush = "push"
+)
+
+// 排队耗时来自 Metrics 插件。推送的 QUEUED 事件同样能得到准确的入队时间，
+// 轮询只能看到采集时刻仍在队列中的构建，排队耗时是采样得到的
+const QueueSourceMetrics = "metrics"
+
+type JenkinsBuild struct {
+	JenkinsInstanceName string `json:"jenkinsInstanceName"`
+	// 包含文件夹路径的完整 job 名称，如 folder/job
+	JobName           string `json:"jobName"`
+	JobURL            string `json:"jobUrl"`
+	Number            int    `json:"number"`
+	URL               string `json:"url"`
+	DisplayName       string `json:"displayName"`
+	Building          bool   `json:"building"`
+	Result            string `json:"result"`
+	Timestamp         int64  `json:"timestamp"`
+	Duration          int    `json:"duration"`
+	EstimatedDuration int    `json:"estimatedDuration"`
+	QueueID           int    `json:"queueId"`
+	// 在队列中等待的时长，单位为毫秒，与 Duration 表示的执行时长分开统计
+	QueueDuration int `json:"queueDuration"`
+	// 排队耗时的来源，取值为 QueueSourceMetrics、SourcePush 或 SourcePoll
+	QueueSource string `json:"queueSource,omitempty"`
+	BuiltOn     string `json:"builtOn,omitempty"`
+	// 构建等待的节点标签
+	Label string `json:"label,omitempty"`
+
+	SCMURL     string            `json:"scmUrl,omitempty"`
+	Branch     string            `json:"branch,omitempty"`
+	Commit     string            `json:"commit,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// 触发方式（取值见 jenkins_api 中的 Trigger* 常量）、触发用户以及上游构建
+	TriggerType   string `json:"triggerType,omitempty"`
+	TriggeredBy   string `json:"triggeredBy,omitempty"`
+	UpstreamJob   string `json:"upstreamJob,omitempty"`
+	UpstreamBuild int    `json:"upstreamBuild,omitempty"`
+
+	// 流水线构建的各阶段，只有 Pipeline 类型的 job 才有
+	Stages []JenkinsStage `json:"stages,omitempty"`
+	// 根据控制台日志得到的失败原因类别，以及匹配到的日志行
+	FailureCategory string `json:"failureCategory,omitempty"`
+	FailureLine     string `json:"failureLine,omitempty"`
+	// JUnit 测试结果汇总，没有测试报告的构建为空
+	Tests *JenkinsTestSummary `json:"tests,omitempty"`
+
+	Source string `json:"source"`
+	// 是否已通过 API 获取过构建详情，推送的数据不包含完整的构建详情
+	Detailed bool `json:"detailed"`
+	// 构建结束后未能获取完整详情的次数，超过上限后不再重试
+	DetailAttempts int       `json:"detailAttempts,omitempty"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+type JenkinsStage struct {
+	Name string `json:"name"`
+	// 取值见 jenkins_api 中的 Stage* 常量
+	Status        string `json:"status"`
+	StartTime     int64  `json:"startTime"`
+	Duration      int    `json:"duration"`
+	PauseDuration int    `json:"pauseDuration"`
+}
+
+// 从最近一次构建往前数连续失败（FAILURE）的构建，按时间从新到旧返回。
+// 中止的构建不打断也不计入，其他结果（包括 UNSTABLE）结束连续失败，构建中的构建不参与计算
+func FailureStreak(builds []JenkinsBuild) []JenkinsBuild {
+	sorted := append([]JenkinsBuild(nil), builds...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp > sorted[j].Timestamp
+	})
+
+	var streak []JenkinsBuild
+	for _, build := range sorted {
+		if build.Building || build.Result == "ABORTED" {
+			continue
+		}
+		if build.Result != "FAILURE" {
+			break
+		}
+		streak = append(streak, build)
+	}
+	return streak
+}
+
+func (b *JenkinsBuild) key() string {
+	return fmt.Sprintf("%s/%s/%d", b.JenkinsInstanceName, b.JobName, b.Number)
+}
+
+// 构建中的状态排在结束状态之前
+func (b *JenkinsBuild) rank() int {
+	if b.Building || b.Result == "" {
+		return 1
+	}
+	return 2
+}
+
+// 写入构建。推送与轮询的数据字段不完全相同，新数据中缺失的字段保留已有的值；
+// 已结束的构建不会被迟到的构建中事件覆盖
+func (s *Store) UpsertJenkinsBuild(build JenkinsBuild) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := build.key()
+	if existing, ok := s.jenkinsBuilds[key]; ok {
+		if build.rank() < existing.rank() {
+			return false
+		}
+		mergeJenkinsBuild(&build, existing)
+	}
+	s.fillJenkinsQueueInfo(&build)
+	s.jenkinsBuilds[key] = &build
+	s.dirty = true
+	return true
+}
+
+func mergeJenkinsBuild(build, existing *JenkinsBuild) {
+	if build.JobURL == "" {
+		build.JobURL = existing.JobURL
+	}
+	if build.URL == "" {
+		build.URL = existing.URL
+	}
+	if build.DisplayName == "" {
+		build.DisplayName = existing.DisplayName
+	}
+	if build.Timestamp == 0 {
+		build.Timestamp = existing.Timestamp
+	}
+	if build.Duration == 0 {
+		build.Duration = existing.Duration
+	}
+	if build.EstimatedDuration == 0 {
+		build.EstimatedDuration = existing.EstimatedDuration
+	}
+	if build.QueueID == 0 {
+		build.QueueID = existing.QueueID
+	}
+	if build.QueueDuration == 0 {
+		build.QueueDuration = existing.QueueDuration
+		build.QueueSource = existing.QueueSource
+	}
+	if build.BuiltOn == "" {
+		build.BuiltOn = existing.BuiltOn
+	}
+	if build.Label == "" {
+		build.Label = existing.Label
+	}
+	if build.SCMURL == "" {
+		build.SCMURL = existing.SCMURL
+	}
+	if build.Branch == "" {
+		build.Branch = existing.Branch
+	}
+	if build.Commit == "" {
+		build.Commit = existing.Commit
+	}
+	if len(build.Parameters) == 0 {
+		build.Parameters = existing.Parameters
+	}
+	if build.TriggerType == "" {
+		build.TriggerType = existing.TriggerType
+		build.TriggeredBy = existing.TriggeredBy
+		build.UpstreamJob = existing.UpstreamJob
+		build.UpstreamBuild = existing.UpstreamBuild
+	}
+	if len(build.Stages) == 0 && existing.rank() == build.rank() {
+		build.Stages = existing.Stages
+	}
+	if build.FailureCategory == "" && existing.rank() == build.rank() {
+		build.FailureCategory = existing.FailureCategory
+		build.FailureLine = existing.FailureLine
+	}
+	if build.Tests == nil && existing.rank() == build.rank() {
+		build.Tests = existing.Tests
+	}
+	if build.DetailAttempts == 0 && existing.rank() == build.rank() {
+		build.DetailAttempts = existing.DetailAttempts
+	}
+	// 构建中获取的详情在构建结束后需要重新获取
+	build.Detailed = build.Detailed || (existing.Detailed && existing.rank() == build.rank())
+}
+
+// 构建是否已结束且已获取过详情，这样的构建轮询时无需再次获取
+func (s *Store) IsJenkinsBuildCollected(jenkinsInstanceName, jobName string, number int) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	build, ok := s.jenkinsBuilds[(&JenkinsBuild{JenkinsInstanceName: jenkinsInstanceName, JobName: jobName, Number: number}).key()]
+	return ok && build.rank() == 2 && build.Detailed
+}
+
+// 构建结束后未能获取完整详情的次数，数据模型中没有该构建时返回 0
+func (s *Store) JenkinsBuildDetailAttempts(jenkinsInstanceName, jobName string, number int) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	build, ok := s.jenkinsBuilds[(&JenkinsBuild{JenkinsInstanceName: jenkinsInstanceName, JobName: jobName, Number: number}).key()]
+	if !ok || build.rank() != 2 {
+		return 0
+	}
+	return build.DetailAttempts
+}
+
+// job 中仍在运行的构建
+func (s *Store) RunningJenkinsBuilds(jenkinsInstanceName, jobName string) []JenkinsBuild {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var builds []JenkinsBuild
+	for _, build := range s.jenkinsBuilds {
+		if build.Building && build.JenkinsInstanceName == jenkinsInstanceName && build.JobName == jobName {
+			builds = append(builds, *build)
+		}
+	}
+	return builds
+}
+
+func (s *Store) JenkinsBuilds() []JenkinsBuild {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	builds := make([]JenkinsBuild, 0, len(s.jenkinsBuilds))
+	for _, build := range s.jenkinsBuilds {
+		builds = append(builds, *build)
+	}
+	return builds
+}
@@ -0,0 +1,113 @@
+﻿package store
+
+import (
+	"sort"
+	"time"
+)
+
+// Jenkins 实例的健康状态与最近一次采集情况，由健康探测与后台采集分别写入
+type JenkinsInstanceStatus struct {
+	JenkinsInstanceName string `json:"jenkinsInstanceName"`
+	JenkinsURL          string `json:"jenkinsURL"`
+
+	// 最近一次健康探测的结果
+	Reachable   bool       `json:"reachable"`
+	AuthValid   bool       `json:"authValid"`
+	Version     string     `json:"version,omitempty"`
+	ProbeError  string     `json:"probeError,omitempty"`
+	LastProbeAt *time.Time `json:"lastProbeAt,omitempty"`
+
+	// 最近一次采集的结果，JobCount 为包含文件夹内 job 的总数
+	JobCount                   int        `json:"jobCount"`
+	LastCollectionAt           *time.Time `json:"lastCollectionAt,omitempty"`
+	LastSuccessfulCollectionAt *time.Time `json:"lastSuccessfulCollectionAt,omitempty"`
+	LastCollectionSeconds      float64    `json:"lastCollectionSeconds"`
+	LastCollectionError        string     `json:"lastCollectionError,omitempty"`
+	// 最近一次采集中失败的 job 数、连续采集失败次数与累计采集失败次数
+	FailedJobCount        int `json:"failedJobCount"`
+	ConsecutiveErrorCount int `json:"consecutiveErrorCount"`
+	TotalCollectionErrors int `json:"totalCollectionErrors"`
+}
+
+// 一次健康探测的结果
+type JenkinsProbeResult struct {
+	JenkinsURL string
+	Reachable  bool
+	AuthValid  bool
+	Version    string
+	Error      string
+	ProbedAt   time.Time
+}
+
+// 一次采集的结果，Error 不为空表示整个实例采集失败
+type JenkinsCollectionResult struct {
+	StartedAt      time.Time
+	Duration       time.Duration
+	JobCount       int
+	FailedJobCount int
+	Error          string
+}
+
+// 调用方需持有写锁
+func (s *Store) jenkinsInstance(jenkinsInstanceName string) *JenkinsInstanceStatus {
+	status, ok := s.jenkinsInstances[jenkinsInstanceName]
+	if !ok {
+		status = &JenkinsInstanceStatus{JenkinsInstanceName: jenkinsInstanceName}
+		s.jenkinsInstances[jenkinsInstanceName] = status
+	}
+	return status
+}
+
+func (s *Store) RecordJenkinsProbe(jenkinsInstanceName string, result JenkinsProbeResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.jenkinsInstance(jenkinsInstanceName)
+	status.JenkinsURL = result.JenkinsURL
+	status.Reachable = result.Reachable
+	status.AuthValid = result.AuthValid
+	status.ProbeError = result.Error
+	// 探测失败时保留上一次获取到的版本
+	if result.Version != "" {
+		status.Version = result.Version
+	}
+	probedAt := result.ProbedAt
+	status.LastProbeAt = &probedAt
+	s.dirty = true
+}
+
+func (s *Store) RecordJenkinsCollection(jenkinsInstanceName string, result JenkinsCollectionResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.jenkinsInstance(jenkinsInstanceName)
+	startedAt := result.StartedAt
+	status.LastCollectionAt = &startedAt
+	status.LastCollectionSeconds = result.Duration.Seconds()
+	status.LastCollectionError = result.Error
+
+	if result.Error != "" {
+		status.ConsecutiveErrorCount++
+		status.TotalCollectionErrors++
+	} else {
+		status.JobCount = result.JobCount
+		status.FailedJobCount = result.FailedJobCount
+		status.ConsecutiveErrorCount = 0
+		status.LastSuccessfulCollectionAt = &startedAt
+	}
+	s.dirty = true
+}
+
+func (s *Store) JenkinsInstances() []JenkinsInstanceStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]JenkinsInstanceStatus, 0, len(s.jenkinsInstances))
+	for _, status := range s.jenkinsInstances {
+		result = append(result, *status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].JenkinsInstanceName < result[j].JenkinsInstanceName
+	})
+	return result
+}
@@ -0,0 +1,168 @@
+﻿package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Jenkins 节点（agent）的当前状态
+type JenkinsNode struct {
+	JenkinsInstanceName string   `json:"jenkinsInstanceName"`
+	Name                string   `json:"name"`
+	Labels              []string `json:"labels"`
+	Offline             bool     `json:"offline"`
+	TemporarilyOffline  bool     `json:"temporarilyOffline"`
+	OfflineReason       string   `json:"offlineReason,omitempty"`
+	NumExecutors        int      `json:"numExecutors"`
+	BusyExecutors       int      `json:"busyExecutors"`
+	// 剩余磁盘空间（字节）与平均响应时间（毫秒），没有监控数据时为 -1
+	DiskSpace    int64      `json:"diskSpace"`
+	ResponseTime int64      `json:"responseTime"`
+	OfflineSince *time.Time `json:"offlineSince,omitempty"`
+	LastSeenAt   time.Time  `json:"lastSeenAt"`
+}
+
+func (n *JenkinsNode) key() string {
+	return fmt.Sprintf("%s/%s", n.JenkinsInstanceName, n.Name)
+}
+
+// 节点的一次离线记录，EndedAt 为空表示仍处于离线状态
+type JenkinsNodeOutage struct {
+	JenkinsInstanceName string     `json:"jenkinsInstanceName"`
+	Name                string     `json:"name"`
+	Reason              string     `json:"reason,omitempty"`
+	StartedAt           time.Time  `json:"startedAt"`
+	EndedAt             *time.Time `json:"endedAt,omitempty"`
+}
+
+// 某一时刻单个标签下的执行器使用情况，节点有多个标签时分别计入每个标签；
+// Label 为空表示没有标签的节点
+type JenkinsUtilizationSample struct {
+	JenkinsInstanceName string    `json:"jenkinsInstanceName"`
+	Label               string    `json:"label"`
+	SampledAt           time.Time `json:"sampledAt"`
+	OnlineNodes         int       `json:"onlineNodes"`
+	OfflineNodes        int       `json:"offlineNodes"`
+	// 只统计在线节点的执行器
+	TotalExecutors int `json:"totalExecutors"`
+	BusyExecutors  int `json:"busyExecutors"`
+}
+
+// 写入一次完整的节点快照：更新节点状态、记录离线区间的开始与结束，并按标签记录执行器使用情况。
+// 快照中不存在的节点视为已被删除
+func (s *Store) ReplaceJenkinsNodes(jenkinsInstanceName string, nodes []JenkinsNode, sampledAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := make(map[string]*JenkinsUtilizationSample)
+	current := make(map[string]bool, len(nodes))
+
+	for i := range nodes {
+		node := nodes[i]
+		node.JenkinsInstanceName = jenkinsInstanceName
+		node.LastSeenAt = sampledAt
+		key := node.key()
+		current[key] = true
+
+		existing, ok := s.jenkinsNodes[key]
+		wasOffline := ok && existing.Offline
+		switch {
+		case node.Offline && !wasOffline:
+			node.OfflineSince = &sampledAt
+			s.jenkinsNodeOutages = append(s.jenkinsNodeOutages, JenkinsNodeOutage{
+				JenkinsInstanceName: jenkinsInstanceName,
+				Name:                node.Name,
+				Reason:              node.OfflineReason,
+				StartedAt:           sampledAt,
+			})
+		case node.Offline && wasOffline:
+			node.OfflineSince = existing.OfflineSince
+		case !node.Offline && wasOffline:
+			s.endJenkinsNodeOutage(jenkinsInstanceName, node.Name, sampledAt)
+		}
+		s.jenkinsNodes[key] = &node
+
+		labels := node.Labels
+		if len(labels) == 0 {
+			labels = []string{""}
+		}
+		for _, label := range labels {
+			sample, ok := samples[label]
+			if !ok {
+				sample = &JenkinsUtilizationSample{
+					JenkinsInstanceName: jenkinsInstanceName,
+					Label:               label,
+					SampledAt:           sampledAt,
+				}
+				samples[label] = sample
+			}
+			if node.Offline {
+				sample.OfflineNodes++
+				continue
+			}
+			sample.OnlineNodes++
+			sample.TotalExecutors += node.NumExecutors
+			sample.BusyExecutors += node.BusyExecutors
+		}
+	}
+
+	for key, node := range s.jenkinsNodes {
+		if node.JenkinsInstanceName == jenkinsInstanceName && !current[key] {
+			s.endJenkinsNodeOutage(jenkinsInstanceName, node.Name, sampledAt)
+			delete(s.jenkinsNodes, key)
+		}
+	}
+
+	for _, sample := range samples {
+		s.jenkinsUtilization = append(s.jenkinsUtilization, *sample)
+	}
+	s.dirty = true
+}
+
+// 调用方需持有写锁
+func (s *Store) endJenkinsNodeOutage(jenkinsInstanceName, name string, endedAt time.Time) {
+	for i := range s.jenkinsNodeOutages {
+		outage := &s.jenkinsNodeOutages[i]
+		if outage.JenkinsInstanceName == jenkinsInstanceName && outage.Name == name && outage.EndedAt == nil {
+			outage.EndedAt = &endedAt
+		}
+	}
+}
+
+func (s *Store) JenkinsNodes() []JenkinsNode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nodes := make([]JenkinsNode, 0, len(s.jenkinsNodes))
+	for _, node := range s.jenkinsNodes {
+		nodes = append(nodes, *node)
+	}
+	return nodes
+}
+
+// since 之后仍在持续或已结束的离线记录
+func (s *Store) JenkinsNodeOutages(since time.Time) []JenkinsNodeOutage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var outages []JenkinsNodeOutage
+	for _, outage := range s.jenkinsNodeOutages {
+		if outage.EndedAt == nil || outage.EndedAt.After(since) {
+			outages = append(outages, outage)
+		}
+	}
+	return outages
+}
+
+func (s *Store) JenkinsUtilization(since time.Time) []JenkinsUtilizationSample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var samples []JenkinsUtilizationSample
+	for _, sample := range s.jenkinsUtilization {
+		if !sample.SampledAt.Before(since) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
@@ -0,0 +1,124 @@
+﻿package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// 已离开队列的队列项保留时长，只用于与构建关联计算排队耗时
+const queueItemRetention = 24 * time.Hour
+
+// Jenkins 构建队列中的一项，构建开始执行后离开队列，QueueID 与构建的 queueId 对应
+type JenkinsQueueItem struct {
+	JenkinsInstanceName string `json:"jenkinsInstanceName"`
+	QueueID             int    `json:"queueId"`
+	JobName             string `json:"jobName"`
+	JobURL              string `json:"jobUrl"`
+	Why                 string `json:"why"`
+	// 等待的节点标签，从 why 中解析，没有指定标签时为空
+	Label        string `json:"label"`
+	Blocked      bool   `json:"blocked"`
+	Buildable    bool   `json:"buildable"`
+	Stuck        bool   `json:"stuck"`
+	InQueueSince int64  `json:"inQueueSince"`
+	InQueue      bool   `json:"inQueue"`
+	// 入队时间的来源，推送的 QUEUED 事件是准确的，轮询时入队时间以第一次看到的快照为准
+	Source     string    `json:"source"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+}
+
+func (q *JenkinsQueueItem) key() string {
+	return fmt.Sprintf("%s/%d", q.JenkinsInstanceName, q.QueueID)
+}
+
+// 写入一次完整的队列快照，快照中不存在的队列项视为已离开队列
+func (s *Store) ReplaceJenkinsQueue(jenkinsInstanceName string, items []JenkinsQueueItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := make(map[string]bool, len(items))
+	for i := range items {
+		item := items[i]
+		item.JenkinsInstanceName = jenkinsInstanceName
+		item.InQueue = true
+		item.Source = SourcePoll
+		s.upsertJenkinsQueueItem(item)
+		current[item.key()] = true
+	}
+
+	for key, item := range s.jenkinsQueueItems {
+		if item.JenkinsInstanceName == jenkinsInstanceName && item.InQueue && !current[key] {
+			item.InQueue = false
+		}
+	}
+	s.dirty = true
+}
+
+// 写入单个队列项，例如 Notification 插件推送的 QUEUED 事件
+func (s *Store) UpsertJenkinsQueueItem(item JenkinsQueueItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.upsertJenkinsQueueItem(item)
+	s.dirty = true
+}
+
+// 调用方需持有写锁。入队时间以最早看到的为准，缺失的字段保留已有的值
+func (s *Store) upsertJenkinsQueueItem(item JenkinsQueueItem) {
+	if existing, ok := s.jenkinsQueueItems[item.key()]; ok {
+		if item.InQueueSince == 0 || (existing.InQueueSince != 0 && existing.InQueueSince < item.InQueueSince) {
+			item.InQueueSince = existing.InQueueSince
+		}
+		if item.JobName == "" {
+			item.JobName = existing.JobName
+		}
+		if item.JobURL == "" {
+			item.JobURL = existing.JobURL
+		}
+		if item.Why == "" {
+			item.Why = existing.Why
+		}
+		if item.Label == "" {
+			item.Label = existing.Label
+		}
+		if existing.Source == SourcePush {
+			item.Source = SourcePush
+		}
+	}
+	s.jenkinsQueueItems[item.key()] = &item
+}
+
+// 当前仍在队列中的队列项
+func (s *Store) JenkinsQueue() []JenkinsQueueItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var items []JenkinsQueueItem
+	for _, item := range s.jenkinsQueueItems {
+		if item.InQueue {
+			items = append(items, *item)
+		}
+	}
+	return items
+}
+
+// 调用方需持有写锁。根据队列项补齐构建的排队耗时与节点标签，
+// 构建的 timestamp 即离开队列开始执行的时间
+func (s *Store) fillJenkinsQueueInfo(build *JenkinsBuild) {
+	if build.QueueID == 0 {
+		return
+	}
+	item, ok := s.jenkinsQueueItems[(&JenkinsQueueItem{JenkinsInstanceName: build.JenkinsInstanceName, QueueID: build.QueueID}).key()]
+	if !ok {
+		return
+	}
+	item.InQueue = false
+
+	if build.Label == "" {
+		build.Label = item.Label
+	}
+	if build.QueueDuration == 0 && item.InQueueSince != 0 && build.Timestamp > item.InQueueSince {
+		build.QueueDuration = int(build.Timestamp - item.InQueueSince)
+		build.QueueSource = item.Source
+	}
+}
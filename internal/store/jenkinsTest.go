@@ -0,0 +1,113 @@
+﻿package store
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 每个测试用例保留的最近执行记录数
+const testCaseHistoryLimit = 50
+
+// 构建的测试结果汇总，Duration 单位为秒
+type JenkinsTestSummary struct {
+	Total    int     `json:"total"`
+	Passed   int     `json:"passed"`
+	Failed   int     `json:"failed"`
+	Skipped  int     `json:"skipped"`
+	Duration float64 `json:"duration"`
+}
+
+// 单个测试用例在某个 job 下的执行历史，按构建号升序排列
+type JenkinsTestCase struct {
+	JenkinsInstanceName string              `json:"jenkinsInstanceName"`
+	JobName             string              `json:"jobName"`
+	ClassName           string              `json:"className"`
+	Name                string              `json:"name"`
+	Results             []JenkinsTestResult `json:"results"`
+}
+
+type JenkinsTestResult struct {
+	Number    int    `json:"number"`
+	Commit    string `json:"commit,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+	// 取值见 jenkins_api 中的 Test* 常量
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration"`
+}
+
+func (t *JenkinsTestCase) key() string {
+	return fmt.Sprintf("%s/%s/%s.%s", t.JenkinsInstanceName, t.JobName, t.ClassName, t.Name)
+}
+
+// 构建中单个测试用例的执行结果
+type JenkinsTestCaseRun struct {
+	ClassName string
+	Name      string
+	Status    string
+	Duration  float64
+}
+
+// 记录一次构建中全部测试用例的结果，同一构建重复写入时覆盖之前的结果
+func (s *Store) AddJenkinsTestResults(build JenkinsBuild, runs []JenkinsTestCaseRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	touched := make(map[string]*JenkinsTestCase)
+	for _, run := range runs {
+		testCase := &JenkinsTestCase{
+			JenkinsInstanceName: build.JenkinsInstanceName,
+			JobName:             build.JobName,
+			ClassName:           run.ClassName,
+			Name:                run.Name,
+		}
+		key := testCase.key()
+
+		existing, ok := touched[key]
+		if !ok {
+			if existing, ok = s.jenkinsTestCases[key]; !ok {
+				existing = testCase
+				s.jenkinsTestCases[key] = existing
+			}
+			// 同一构建第一次写入该用例时先清除旧的结果
+			results := existing.Results[:0]
+			for _, result := range existing.Results {
+				if result.Number != build.Number {
+					results = append(results, result)
+				}
+			}
+			existing.Results = results
+			touched[key] = existing
+		}
+
+		existing.Results = append(existing.Results, JenkinsTestResult{
+			Number:    build.Number,
+			Commit:    build.Commit,
+			Timestamp: build.Timestamp,
+			Status:    run.Status,
+			Duration:  run.Duration,
+		})
+	}
+
+	for _, testCase := range touched {
+		sort.SliceStable(testCase.Results, func(i, j int) bool {
+			return testCase.Results[i].Number < testCase.Results[j].Number
+		})
+		if len(testCase.Results) > testCaseHistoryLimit {
+			testCase.Results = testCase.Results[len(testCase.Results)-testCaseHistoryLimit:]
+		}
+	}
+	s.dirty = true
+}
+
+func (s *Store) JenkinsTestCases() []JenkinsTestCase {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cases := make([]JenkinsTestCase, 0, len(s.jenkinsTestCases))
+	for _, testCase := range s.jenkinsTestCases {
+		testCase := *testCase
+		testCase.Results = append([]JenkinsTestResult(nil), testCase.Results...)
+		cases = append(cases, testCase)
+	}
+	return cases
+}
@@ -0,0 +1,180 @@
+﻿package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// 静默的状态
+const (
+	SilencePending = "pending"
+	SilenceActive  = "active"
+	SilenceExpired = "expired"
+)
+
+// 标签匹配方式，未指定时为精确匹配
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+// 已过期的静默保留一段时间，便于查看最近的静默记录
+const silenceRetention = 7 * 24 * time.Hour
+
+// 告警标签的匹配条件，正则表达式需要完整匹配标签值
+type Matcher struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Operator string `json:"operator,omitempty"`
+
+	// 正则匹配的表达式，创建静默或加载快照时由 Compile 编译
+	re *regexp.Regexp
+}
+
+// 静默期间匹配全部 Matchers 的告警不发送通知
+type Silence struct {
+	ID        string    `json:"id"`
+	Matchers  []Matcher `json:"matchers"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	CreatedBy string    `json:"createdBy"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// 校验匹配条件并编译正则表达式，避免每次匹配告警时重复编译
+func (m *Matcher) Compile() error {
+	if m.Name == "" {
+		return errors.New("matcher name is required")
+	}
+	switch m.Operator {
+	case "", MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + m.Value + ")$")
+		if err != nil {
+			return fmt.Errorf("matcher %s: %w", m.Name, err)
+		}
+		m.re = re
+	default:
+		return fmt.Errorf("matcher %s: invalid operator %q", m.Name, m.Operator)
+	}
+	return nil
+}
+
+func (m Matcher) Matches(labels map[string]string) bool {
+	value := labels[m.Name]
+	switch m.Operator {
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp, MatchNotRegexp:
+		// 没有编译过的正则匹配条件不匹配任何告警
+		if m.re == nil {
+			return false
+		}
+		return m.re.MatchString(value) == (m.Operator == MatchRegexp)
+	default:
+		return value == m.Value
+	}
+}
+
+func (s *Silence) compile() error {
+	for i := range s.Matchers {
+		if err := s.Matchers[i].Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Silence) State(now time.Time) string {
+	switch {
+	case !now.Before(s.EndsAt):
+		return SilenceExpired
+	case now.Before(s.StartsAt):
+		return SilencePending
+	default:
+		return SilenceActive
+	}
+}
+
+// 静默生效中且全部条件匹配
+func (s *Silence) Mutes(labels map[string]string, now time.Time) bool {
+	if s.State(now) != SilenceActive || len(s.Matchers) == 0 {
+		return false
+	}
+	for _, matcher := range s.Matchers {
+		if !matcher.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// 按开始时间倒序返回全部静默
+func (s *Store) Silences() []Silence {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Silence, 0, len(s.silences))
+	for _, silence := range s.silences {
+		result = append(result, *silence)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].StartsAt.Equal(result[j].StartsAt) {
+			return result[i].StartsAt.After(result[j].StartsAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// 新建静默并分配 ID，匹配条件无效时返回错误
+func (s *Store) AddSilence(silence Silence) (Silence, error) {
+	silence.Matchers = append([]Matcher(nil), silence.Matchers...)
+	if err := silence.compile(); err != nil {
+		return Silence{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	silence.ID = newSilenceID()
+	s.silences[silence.ID] = &silence
+	s.dirty = true
+	return silence, nil
+}
+
+// 提前结束静默，已过期的静默保持不变
+func (s *Store) ExpireSilence(id string, now time.Time) (Silence, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	silence, ok := s.silences[id]
+	if !ok {
+		return Silence{}, false
+	}
+	if silence.State(now) != SilenceExpired {
+		// 尚未开始的静默直接结束于开始时间
+		if now.Before(silence.StartsAt) {
+			silence.StartsAt = now
+		}
+		silence.EndsAt = now
+		silence.UpdatedAt = now
+		s.dirty = true
+	}
+	return *silence, true
+}
+
+func newSilenceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
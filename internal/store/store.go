@@ -0,0 +1,364 @@
+﻿package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 事件 UUID 的去重窗口，GitLab 重发 webhook 一般发生在几分钟到几小时内
+const eventDedupWindow = 24 * time.Hour
+
+// 平台的数据模型：webhook 推送与定时采集写入的数据统一存放在这里，各类指标都从这里计算。
+// 数据保存在内存中，并定期以 JSON 快照的形式持久化到磁盘
+type Store struct {
+	mu sync.RWMutex
+
+	path      string
+	retention time.Duration
+	dirty     bool
+
+	seenEvents map[string]time.Time
+
+	gitlabPipelines           map[string]*GitlabPipeline
+	gitlabMergeRequests       map[string]*GitlabMergeRequest
+	gitlabMergeRequestReviews map[string]*GitlabMergeRequestReview
+	gitlabJobs                map[string]*GitlabJob
+	gitlabDeployments         map[string]*GitlabDeployment
+	gitlabPushes              []GitlabPush
+
+	jenkinsBuilds      map[string]*JenkinsBuild
+	jenkinsQueueItems  map[string]*JenkinsQueueItem
+	jenkinsNodes       map[string]*JenkinsNode
+	jenkinsNodeOutages []JenkinsNodeOutage
+	jenkinsUtilization []JenkinsUtilizationSample
+	jenkinsTestCases   map[string]*JenkinsTestCase
+	jenkinsInstances   map[string]*JenkinsInstanceStatus
+
+	alerts   map[string]*Alert
+	silences map[string]*Silence
+}
+
+// 磁盘快照格式
+type snapshot struct {
+	SeenEvents                map[string]time.Time        `json:"seenEvents"`
+	GitlabPipelines           []*GitlabPipeline           `json:"gitlabPipelines"`
+	GitlabMergeRequests       []*GitlabMergeRequest       `json:"gitlabMergeRequests"`
+	GitlabMergeRequestReviews []*GitlabMergeRequestReview `json:"gitlabMergeRequestReviews"`
+	GitlabJobs                []*GitlabJob                `json:"gitlabJobs"`
+	GitlabDeployments         []*GitlabDeployment         `json:"gitlabDeployments"`
+	GitlabPushes              []GitlabPush                `json:"gitlabPushes"`
+	JenkinsBuilds             []*JenkinsBuild             `json:"jenkinsBuilds"`
+	JenkinsQueueItems         []*JenkinsQueueItem         `json:"jenkinsQueueItems"`
+	JenkinsNodes              []*JenkinsNode              `json:"jenkinsNodes"`
+	JenkinsNodeOutages        []JenkinsNodeOutage         `json:"jenkinsNodeOutages"`
+	JenkinsUtilization        []JenkinsUtilizationSample  `json:"jenkinsUtilization"`
+	JenkinsTestCases          []*JenkinsTestCase          `json:"jenkinsTestCases"`
+	JenkinsInstances          []*JenkinsInstanceStatus    `json:"jenkinsInstances"`
+	Alerts                    []*Alert                    `json:"alerts"`
+	Silences                  []*Silence                  `json:"silences"`
+}
+
+// path 为空时只保存在内存中；retentionDays 之前的数据由 RunSaver 定期清理
+func New(path string, retentionDays int) *Store {
+	return &Store{
+		path:                      path,
+		retention:                 time.Duration(retentionDays) * 24 * time.Hour,
+		seenEvents:                make(map[string]time.Time),
+		gitlabPipelines:           make(map[string]*GitlabPipeline),
+		gitlabMergeRequests:       make(map[string]*GitlabMergeRequest),
+		gitlabMergeRequestReviews: make(map[string]*GitlabMergeRequestReview),
+		gitlabJobs:                make(map[string]*GitlabJob),
+		gitlabDeployments:         make(map[string]*GitlabDeployment),
+		jenkinsBuilds:             make(map[string]*JenkinsBuild),
+		jenkinsQueueItems:         make(map[string]*JenkinsQueueItem),
+		jenkinsNodes:              make(map[string]*JenkinsNode),
+		jenkinsTestCases:          make(map[string]*JenkinsTestCase),
+		jenkinsInstances:          make(map[string]*JenkinsInstanceStatus),
+		alerts:                    make(map[string]*Alert),
+		silences:                  make(map[string]*Silence),
+	}
+}
+
+// 从磁盘快照恢复数据，快照不存在时忽略
+func (s *Store) Load() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if snap.SeenEvents != nil {
+		s.seenEvents = snap.SeenEvents
+	}
+	for _, pipeline := range snap.GitlabPipelines {
+		s.gitlabPipelines[pipeline.key()] = pipeline
+	}
+	for _, mr := range snap.GitlabMergeRequests {
+		s.gitlabMergeRequests[mr.key()] = mr
+	}
+	for _, review := range snap.GitlabMergeRequestReviews {
+		s.gitlabMergeRequestReviews[review.key()] = review
+	}
+	for _, job := range snap.GitlabJobs {
+		s.gitlabJobs[job.key()] = job
+	}
+	for _, deployment := range snap.GitlabDeployments {
+		s.gitlabDeployments[deployment.key()] = deployment
+	}
+	s.gitlabPushes = snap.GitlabPushes
+	for _, build := range snap.JenkinsBuilds {
+		s.jenkinsBuilds[build.key()] = build
+	}
+	for _, item := range snap.JenkinsQueueItems {
+		s.jenkinsQueueItems[item.key()] = item
+	}
+	for _, node := range snap.JenkinsNodes {
+		s.jenkinsNodes[node.key()] = node
+	}
+	s.jenkinsNodeOutages = snap.JenkinsNodeOutages
+	s.jenkinsUtilization = snap.JenkinsUtilization
+	for _, testCase := range snap.JenkinsTestCases {
+		s.jenkinsTestCases[testCase.key()] = testCase
+	}
+	for _, status := range snap.JenkinsInstances {
+		s.jenkinsInstances[status.JenkinsInstanceName] = status
+	}
+	for _, alert := range snap.Alerts {
+		s.alerts[alert.Fingerprint] = alert
+	}
+	for _, silence := range snap.Silences {
+		if err := silence.compile(); err != nil {
+			log.Errorf("静默[%s]的匹配条件无效，已忽略: %v", silence.ID, err)
+			continue
+		}
+		s.silences[silence.ID] = silence
+	}
+
+	return nil
+}
+
+// 清理过期数据后将快照写入磁盘，先写临时文件再重命名，避免写入中断导致快照损坏
+func (s *Store) Save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.prune(time.Now())
+	snap := snapshot{
+		SeenEvents:         s.seenEvents,
+		GitlabPushes:       s.gitlabPushes,
+		JenkinsNodeOutages: s.jenkinsNodeOutages,
+		JenkinsUtilization: s.jenkinsUtilization,
+	}
+	for _, pipeline := range s.gitlabPipelines {
+		snap.GitlabPipelines = append(snap.GitlabPipelines, pipeline)
+	}
+	for _, mr := range s.gitlabMergeRequests {
+		snap.GitlabMergeRequests = append(snap.GitlabMergeRequests, mr)
+	}
+	for _, review := range s.gitlabMergeRequestReviews {
+		snap.GitlabMergeRequestReviews = append(snap.GitlabMergeRequestReviews, review)
+	}
+	for _, job := range s.gitlabJobs {
+		snap.GitlabJobs = append(snap.GitlabJobs, job)
+	}
+	for _, deployment := range s.gitlabDeployments {
+		snap.GitlabDeployments = append(snap.GitlabDeployments, deployment)
+	}
+	for _, build := range s.jenkinsBuilds {
+		snap.JenkinsBuilds = append(snap.JenkinsBuilds, build)
+	}
+	for _, item := range s.jenkinsQueueItems {
+		snap.JenkinsQueueItems = append(snap.JenkinsQueueItems, item)
+	}
+	for _, node := range s.jenkinsNodes {
+		snap.JenkinsNodes = append(snap.JenkinsNodes, node)
+	}
+	for _, testCase := range s.jenkinsTestCases {
+		snap.JenkinsTestCases = append(snap.JenkinsTestCases, testCase)
+	}
+	for _, status := range s.jenkinsInstances {
+		snap.JenkinsInstances = append(snap.JenkinsInstances, status)
+	}
+	for _, alert := range s.alerts {
+		snap.Alerts = append(snap.Alerts, alert)
+	}
+	for _, silence := range s.silences {
+		snap.Silences = append(snap.Silences, silence)
+	}
+	data, err := json.Marshal(snap)
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// 定期清理过期数据并将有变更的数据持久化，ctx 结束时再保存一次。只保存在内存中时也需要定期清理
+func (s *Store) RunSaver(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Save(); err != nil {
+				log.Errorf("保存数据快照失败: %v", err)
+			}
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			s.prune(now)
+			dirty := s.dirty
+			s.mu.Unlock()
+			if !dirty || s.path == "" {
+				continue
+			}
+			if err := s.Save(); err != nil {
+				log.Errorf("保存数据快照失败: %v", err)
+			}
+		}
+	}
+}
+
+// 记录事件 UUID，返回 false 表示该事件已经处理过
+func (s *Store) MarkEventSeen(uuid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.seenEvents[uuid]; ok {
+		return false
+	}
+	s.seenEvents[uuid] = time.Now()
+	s.dirty = true
+	return true
+}
+
+// 调用方需持有写锁
+func (s *Store) prune(now time.Time) {
+	for uuid, seenAt := range s.seenEvents {
+		if now.Sub(seenAt) > eventDedupWindow {
+			delete(s.seenEvents, uuid)
+		}
+	}
+	for key, item := range s.jenkinsQueueItems {
+		if !item.InQueue && now.Sub(item.LastSeenAt) > queueItemRetention {
+			delete(s.jenkinsQueueItems, key)
+		}
+	}
+	for id, silence := range s.silences {
+		if now.Sub(silence.EndsAt) > silenceRetention {
+			delete(s.silences, id)
+		}
+	}
+
+	if s.retention <= 0 {
+		return
+	}
+	deadline := now.Add(-s.retention)
+
+	for key, pipeline := range s.gitlabPipelines {
+		if pipeline.UpdatedAt.Before(deadline) {
+			delete(s.gitlabPipelines, key)
+		}
+	}
+	for key, mr := range s.gitlabMergeRequests {
+		if mr.UpdatedAt.Before(deadline) {
+			delete(s.gitlabMergeRequests, key)
+		}
+	}
+	for key, review := range s.gitlabMergeRequestReviews {
+		if review.MergedAt.Before(deadline) {
+			delete(s.gitlabMergeRequestReviews, key)
+		}
+	}
+	for key, job := range s.gitlabJobs {
+		if job.UpdatedAt.Before(deadline) {
+			delete(s.gitlabJobs, key)
+		}
+	}
+	for key, deployment := range s.gitlabDeployments {
+		if deployment.UpdatedAt.Before(deadline) {
+			delete(s.gitlabDeployments, key)
+		}
+	}
+	pushes := s.gitlabPushes[:0]
+	for _, push := range s.gitlabPushes {
+		if !push.ReceivedAt.Before(deadline) {
+			pushes = append(pushes, push)
+		}
+	}
+	s.gitlabPushes = pushes
+
+	for key, build := range s.jenkinsBuilds {
+		if build.Timestamp != 0 && time.UnixMilli(build.Timestamp).Before(deadline) {
+			delete(s.jenkinsBuilds, key)
+		}
+	}
+
+	outages := s.jenkinsNodeOutages[:0]
+	for _, outage := range s.jenkinsNodeOutages {
+		if outage.EndedAt == nil || !outage.EndedAt.Before(deadline) {
+			outages = append(outages, outage)
+		}
+	}
+	s.jenkinsNodeOutages = outages
+
+	samples := s.jenkinsUtilization[:0]
+	for _, sample := range s.jenkinsUtilization {
+		if !sample.SampledAt.Before(deadline) {
+			samples = append(samples, sample)
+		}
+	}
+	s.jenkinsUtilization = samples
+
+	for key, testCase := range s.jenkinsTestCases {
+		if len(testCase.Results) == 0 || time.UnixMilli(testCase.Results[len(testCase.Results)-1].Timestamp).Before(deadline) {
+			delete(s.jenkinsTestCases, key)
+		}
+	}
+}
+
+// 判断新到达的状态是否比已保存的状态更新。先比较状态发生的时间，时间相同时按状态先后顺序比较，
+// 这样迟到的旧事件（例如 success 之后才到达的 running）不会覆盖已有的最终状态
+func isNewer(existingAt time.Time, existingRank int, incomingAt time.Time, incomingRank int) bool {
+	if incomingAt.After(existingAt) {
+		return true
+	}
+	if incomingAt.Before(existingAt) {
+		return false
+	}
+	return incomingRank >= existingRank
+}
@@ -0,0 +1,41 @@
+﻿package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunSaverPrunesInMemory(t *testing.T) {
+	s := New("", 1)
+	now := time.Now()
+	s.UpsertGitlabPipeline(GitlabPipeline{GitlabInstanceName: "gitlab", ProjectID: 1, PipelineID: 1, Status: "success", UpdatedAt: now.Add(-48 * time.Hour)})
+	s.UpsertGitlabPipeline(GitlabPipeline{GitlabInstanceName: "gitlab", ProjectID: 1, PipelineID: 2, Status: "success", UpdatedAt: now})
+	s.AddGitlabPush(GitlabPush{GitlabInstanceName: "gitlab", ReceivedAt: now.Add(-48 * time.Hour)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.RunSaver(ctx, time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		pipelines := s.GitlabPipelines()
+		if len(pipelines) == 1 && len(s.GitlabPushes()) == 0 {
+			if pipelines[0].PipelineID != 2 {
+				t.Fatalf("pruned the wrong pipeline: %+v", pipelines)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expired data not pruned: %d pipelines, %d pushes", len(pipelines), len(s.GitlabPushes()))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
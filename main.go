@@ -1,15 +1,37 @@
 ﻿package main
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	// 内嵌时区数据，运行镜像中没有 tzdata 时也能加载 workingHours.timezone
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
+	"github.com/zuoyangs/go-devops-observability/config"
+	"github.com/zuoyangs/go-devops-observability/internal/alert"
+	"github.com/zuoyangs/go-devops-observability/internal/collector"
+	"github.com/zuoyangs/go-devops-observability/internal/demo"
+	gitlab_impl "github.com/zuoyangs/go-devops-observability/internal/gitlab_api/impl"
+	"github.com/zuoyangs/go-devops-observability/internal/jenkins_api/impl"
+	"github.com/zuoyangs/go-devops-observability/internal/notify"
+	"github.com/zuoyangs/go-devops-observability/internal/replay"
+	"github.com/zuoyangs/go-devops-observability/internal/report"
 	"github.com/zuoyangs/go-devops-observability/internal/router"
+	"github.com/zuoyangs/go-devops-observability/internal/store"
 )
 
 func main() {
 
+	// 收到 SIGINT/SIGTERM 时停止后台任务，等待 HTTP 请求处理完并保存数据快照后退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// 设置配置文件路径
 	viper.SetConfigFile("./etc/config.yaml")
 
@@ -18,9 +40,118 @@ func main() {
 		log.Fatalf("Error reading config file, %s", err)
 	}
 
+	storageConfig, err := config.GetStorage()
+	if err != nil {
+		log.Fatalf("Error reading storage config, %s", err)
+	}
+
+	collectorConfig, err := config.GetCollector()
+	if err != nil {
+		log.Fatalf("Error reading collector config, %s", err)
+	}
+
+	demoConfig, err := config.GetDemo()
+	if err != nil {
+		log.Fatalf("Error reading demo config, %s", err)
+	}
+	// go run . demo 与配置 demo.enabled 效果相同
+	if len(os.Args) > 1 && os.Args[1] == "demo" {
+		demoConfig.Enabled = true
+	}
+
+	// 演示模式下使用模拟 Jenkins 代替配置中的实例，数据只保存在内存中，不访问真实网络
+	if demoConfig.Enabled {
+		generator, err := demo.Start(demoConfig)
+		if err != nil {
+			log.Fatalf("Error generating demo data, %s", err)
+		}
+		defer generator.Close()
+		go generator.Run(ctx, time.Minute)
+
+		storageConfig.Path = ""
+		collectorConfig.HTTPMode = replay.ModeLive
+	}
+
+	// 演示模式下已从配置中去掉通知渠道与路由，因此在启动演示之后读取
+	notifyConfig, err := config.GetNotify()
+	if err != nil {
+		log.Fatalf("Error reading notify config, %s", err)
+	}
+
+	// 加载数据快照
+	dataStore := store.New(storageConfig.Path, storageConfig.RetentionDays)
+	if err := dataStore.Load(); err != nil {
+		log.Fatalf("Error loading data snapshot, %s", err)
+	}
+
+	// 录制或回放模式下，所有访问 Jenkins、GitLab 的请求都经过同一个 transport
+	transport, err := replay.NewTransport(collectorConfig.HTTPMode, collectorConfig.FixtureDir, nil)
+	if err != nil {
+		log.Fatalf("Error creating http transport, %s", err)
+	}
+	httpClient := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+	}
+
+	// 数据快照在 HTTP 服务关闭后再做最后一次保存，避免丢失关闭过程中收到的推送
+	saverCtx, stopSaver := context.WithCancel(context.Background())
+	saverDone := make(chan struct{})
+	go func() {
+		defer close(saverDone)
+		dataStore.RunSaver(saverCtx, time.Minute)
+	}()
+
+	// 后台定时对账 GitLab 流水线
+	gitlabCollector := collector.NewGitlabCollector(dataStore, gitlab_impl.NewGitlabServiceImpl(httpClient))
+	go gitlabCollector.Run(ctx, collectorConfig.GitlabInterval)
+
+	// 后台定时轮询 Jenkins 构建，补齐推送漏发的事件
+	jenkinsCollector := collector.NewJenkinsCollector(dataStore, impl.NewJenkinsServiceImpl(httpClient))
+	// 每轮采集结束后评估告警规则
+	alertEngine := alert.NewEngine(dataStore)
+	jenkinsCollector.OnCollected(alertEngine.Evaluate)
+
+	// 告警按标签分组、去掉静默与被抑制的告警后按路由发送通知，通知渠道不经过录制回放的 transport
+	notifier, err := notify.New(notifyConfig, &http.Client{Timeout: 10 * time.Second})
+	if err != nil {
+		log.Fatalf("Error creating notifier, %s", err)
+	}
+	alertDispatcher := alert.NewDispatcher(dataStore, notifier, notifyConfig)
+	go alertDispatcher.Run(ctx, 10*time.Second)
+	// 每天定时发送前一天的构建日报
+	dailyReport := report.NewDaily(dataStore, notifier, notifyConfig)
+	go dailyReport.Run(ctx, time.Minute)
+	go jenkinsCollector.Run(ctx, collectorConfig.JenkinsInterval)
+
+	// 后台定时探测 Jenkins 实例健康状态
+	jenkinsProber := collector.NewJenkinsProber(dataStore, impl.NewJenkinsServiceImpl(httpClient))
+	go jenkinsProber.Run(ctx, collectorConfig.ProbeInterval)
+
 	r := gin.Default()
 
-	router.SetupAPIRouters(r) // 设置路由
+	router.SetupAPIRouters(r, dataStore, httpClient) // 设置路由
+
+	server := &http.Server{Addr: ":8080", Handler: r}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Printf("HTTP server stopped, %s", err)
+		stop()
+	case <-ctx.Done():
+		log.Printf("Shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down HTTP server, %s", err)
+	}
 
-	r.Run(":8080")
+	stopSaver()
+	<-saverDone
 }
@@ -1,2 +1,37 @@
 ﻿package utils
 
+import (
+	"math"
+	"sort"
+)
+
+// 计算 p 分位数（p 取值 0-100），采用线性插值，values 无需预先排序
+func Percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 100 {
+		return sorted[len(sorted)-1]
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// 保留两位小数
+func Round2(value float64) float64 {
+	return math.Round(value*1e2) / 1e2
+}